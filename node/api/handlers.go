@@ -10,7 +10,9 @@ import (
 	"path/filepath"
 	"node/internal/config"
 	"node/internal/game"
+	"node/internal/tickets"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,11 +23,17 @@ type Handler struct {
 	manager *game.Manager
 	config  *config.Config
 	logger  *slog.Logger
+	tickets *tickets.Verifier
+
+	onPlayerEvent func(instanceID string, playerID int64, event, reason string)
+
+	gameConnsMu sync.Mutex
+	gameConns   map[*gameConn]string // Connected game servers -> instance ID
 }
 
 // NewHandler creates a new API handler.
 func NewHandler(m *game.Manager, c *config.Config, l *slog.Logger) *Handler {
-	return &Handler{manager: m, config: c, logger: l}
+	return &Handler{manager: m, config: c, logger: l, gameConns: make(map[*gameConn]string)}
 }
 
 // RegisterRoutes sets up the API endpoints
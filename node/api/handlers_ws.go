@@ -2,6 +2,9 @@ package api
 
 import (
 	"net/http"
+	"node/internal/tickets"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -13,6 +16,102 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// gameConn serializes writes to a game server socket: replies come from the read loop,
+// revocation notices from the master connection.
+type gameConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+
+	players map[int64]bool // Joined players, guarded by Handler.gameConnsMu
+}
+
+func (g *gameConn) writeJSON(v interface{}) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.conn.WriteJSON(v)
+}
+
+// SetTicketVerifier enables "verify_ticket" on the instance WebSocket and tells
+// connected game servers when a player's tickets are revoked.
+func (h *Handler) SetTicketVerifier(v *tickets.Verifier) {
+	h.tickets = v
+	v.OnRevoke(h.broadcastRevoked)
+}
+
+func (h *Handler) broadcastRevoked(playerID int64) {
+	h.gameConnsMu.Lock()
+	conns := make([]*gameConn, 0, len(h.gameConns))
+	for gc := range h.gameConns {
+		conns = append(conns, gc)
+	}
+	h.gameConnsMu.Unlock()
+
+	for _, gc := range conns {
+		if err := gc.writeJSON(gin.H{"type": "player_revoked", "player_id": playerID}); err != nil {
+			h.logger.Warn("Failed to notify game server of revocation", "error", err)
+		}
+	}
+}
+
+// OnPlayerEvent registers the callback for player joins and leaves reported by game
+// servers. Players still joined when a game server disconnects leave with reason
+// "server_disconnected".
+func (h *Handler) OnPlayerEvent(fn func(instanceID string, playerID int64, event, reason string)) {
+	h.onPlayerEvent = fn
+}
+
+// Roster returns the players joined on each connected instance.
+func (h *Handler) Roster() map[string][]int64 {
+	h.gameConnsMu.Lock()
+	defer h.gameConnsMu.Unlock()
+
+	roster := make(map[string][]int64)
+	for gc, id := range h.gameConns {
+		for playerID := range gc.players {
+			roster[id] = append(roster[id], playerID)
+		}
+	}
+	return roster
+}
+
+func (h *Handler) playerEvent(gc *gameConn, instanceID string, playerID int64, event, reason string) {
+	if playerID <= 0 {
+		h.logger.Warn("Ignoring player event without player_id", "id", instanceID, "event", event)
+		return
+	}
+
+	h.gameConnsMu.Lock()
+	if event == "join" {
+		gc.players[playerID] = true
+	} else {
+		if !gc.players[playerID] {
+			h.logger.Debug("Leave for player that never joined", "id", instanceID, "player_id", playerID)
+		}
+		delete(gc.players, playerID)
+	}
+	h.gameConnsMu.Unlock()
+
+	if h.onPlayerEvent != nil {
+		h.onPlayerEvent(instanceID, playerID, event, reason)
+	}
+}
+
+func (h *Handler) verifyTicket(gc *gameConn, instanceID, requestID, token string) {
+	resp := gin.H{"type": "ticket_result", "request_id": requestID, "valid": false}
+	if h.tickets == nil {
+		resp["error"] = "ticket verification not available"
+	} else if claims, err := h.tickets.Verify(token, instanceID, time.Now()); err != nil {
+		resp["error"] = err.Error()
+	} else {
+		resp["valid"] = true
+		resp["player_id"] = claims.PlayerID
+		resp["expires_at"] = claims.ExpiresAt
+	}
+	if err := gc.writeJSON(resp); err != nil {
+		h.logger.Warn("Failed to send ticket result", "id", instanceID, "error", err)
+	}
+}
+
 // HandleInstanceWebSocket handles the WebSocket connection for a specific instance.
 func (h *Handler) HandleInstanceWebSocket(c *gin.Context) {
 	id := c.Param("id")
@@ -28,6 +127,26 @@ func (h *Handler) HandleInstanceWebSocket(c *gin.Context) {
 	}
 	defer func() { _ = conn.Close() }()
 
+	gc := &gameConn{conn: conn, players: make(map[int64]bool)}
+	h.gameConnsMu.Lock()
+	h.gameConns[gc] = id
+	h.gameConnsMu.Unlock()
+	defer func() {
+		h.gameConnsMu.Lock()
+		delete(h.gameConns, gc)
+		remaining := make([]int64, 0, len(gc.players))
+		for playerID := range gc.players {
+			remaining = append(remaining, playerID)
+		}
+		h.gameConnsMu.Unlock()
+
+		if h.onPlayerEvent != nil {
+			for _, playerID := range remaining {
+				h.onPlayerEvent(id, playerID, "leave", "server_disconnected")
+			}
+		}
+	}()
+
 	h.logger.Info("Game server connected via WebSocket", "id", id)
 
 	for {
@@ -35,6 +154,12 @@ func (h *Handler) HandleInstanceWebSocket(c *gin.Context) {
 			Type        string `json:"type"`
 			PlayerCount int    `json:"player_count"`
 			MaxPlayers  int    `json:"max_players"`
+			Map         string `json:"map"`
+			GameMode    string `json:"game_mode"`
+			RequestID   string `json:"request_id"`
+			Ticket      string `json:"ticket"`
+			PlayerID    int64  `json:"player_id"`
+			Reason      string `json:"reason"`
 		}
 
 		if err := conn.ReadJSON(&msg); err != nil {
@@ -44,10 +169,22 @@ func (h *Handler) HandleInstanceWebSocket(c *gin.Context) {
 			break
 		}
 
-		if msg.Type == "stats" {
+		switch msg.Type {
+		case "stats":
 			if err := h.manager.UpdatePlayerStats(id, msg.PlayerCount, msg.MaxPlayers); err != nil {
 				h.logger.Warn("Failed to update player stats", "id", id, "error", err)
 			}
+			if msg.Map != "" || msg.GameMode != "" {
+				if err := h.manager.UpdateMatchInfo(id, msg.Map, msg.GameMode); err != nil {
+					h.logger.Warn("Failed to update match info", "id", id, "error", err)
+				}
+			}
+		case "verify_ticket":
+			h.verifyTicket(gc, id, msg.RequestID, msg.Ticket)
+		case "player_join":
+			h.playerEvent(gc, id, msg.PlayerID, "join", "")
+		case "player_leave":
+			h.playerEvent(gc, id, msg.PlayerID, "leave", msg.Reason)
 		}
 	}
 
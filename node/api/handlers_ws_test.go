@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type playerEvent struct {
+	instanceID string
+	playerID   int64
+	event      string
+	reason     string
+}
+
+func TestInstanceWebSocketPlayerEvents(t *testing.T) {
+	router, handler := setupHandler()
+	events := make(chan playerEvent, 8)
+	handler.OnPlayerEvent(func(instanceID string, playerID int64, event, reason string) {
+		events <- playerEvent{instanceID, playerID, event, reason}
+	})
+
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/instance/eu-7777/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := func() playerEvent {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for player event")
+			return playerEvent{}
+		}
+	}
+
+	_ = conn.WriteJSON(map[string]interface{}{"type": "player_join", "player_id": 1})
+	_ = conn.WriteJSON(map[string]interface{}{"type": "player_join", "player_id": 2})
+	_ = conn.WriteJSON(map[string]interface{}{"type": "player_leave", "player_id": 1, "reason": "kicked"})
+
+	if ev := next(); ev != (playerEvent{"eu-7777", 1, "join", ""}) {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	next()
+	if ev := next(); ev != (playerEvent{"eu-7777", 1, "leave", "kicked"}) {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+
+	roster := handler.Roster()
+	if len(roster["eu-7777"]) != 1 || roster["eu-7777"][0] != 2 {
+		t.Fatalf("expected only player 2 on the roster, got %v", roster)
+	}
+
+	// Players still joined leave when the game server goes away
+	_ = conn.Close()
+	if ev := next(); ev != (playerEvent{"eu-7777", 2, "leave", "server_disconnected"}) {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if len(handler.Roster()) != 0 {
+		t.Fatalf("expected empty roster after disconnect, got %v", handler.Roster())
+	}
+}
@@ -63,6 +63,11 @@ type Instance struct {
 	PlayerCount int `json:"player_count"`
 	MaxPlayers  int `json:"max_players"`
 
+	// Reported by the game server over the instance WebSocket
+	Map          string    `json:"map,omitempty"`
+	GameMode     string    `json:"game_mode,omitempty"`
+	LastReportAt time.Time `json:"last_report_at"`
+
 	History []HistoryPoint `json:"-"` // Stored in memory, not serialized in basic list
 
 	cmd *exec.Cmd // Private: command handle for process management
@@ -761,16 +766,19 @@ func (m *Manager) Shutdown() {
 
 func (inst *Instance) clone() *Instance {
 	return &Instance{
-		ID:          inst.ID,
-		Port:        inst.Port,
-		ProcessID:   inst.ProcessID,
-		Status:      inst.Status,
-		Region:      inst.Region,
-		Version:     inst.Version,
-		StartTime:   inst.StartTime,
-		Path:        inst.Path,
-		PlayerCount: inst.PlayerCount,
-		MaxPlayers:  inst.MaxPlayers,
+		ID:           inst.ID,
+		Port:         inst.Port,
+		ProcessID:    inst.ProcessID,
+		Status:       inst.Status,
+		Region:       inst.Region,
+		Version:      inst.Version,
+		StartTime:    inst.StartTime,
+		Path:         inst.Path,
+		PlayerCount:  inst.PlayerCount,
+		MaxPlayers:   inst.MaxPlayers,
+		Map:          inst.Map,
+		GameMode:     inst.GameMode,
+		LastReportAt: inst.LastReportAt,
 		// History and cmd/proc are intentionally not cloned for public view
 	}
 }
@@ -903,6 +911,22 @@ func (m *Manager) UpdatePlayerStats(id string, current, maxPlayers int) error {
 
 	inst.PlayerCount = current
 	inst.MaxPlayers = maxPlayers
+	inst.LastReportAt = time.Now()
+	return nil
+}
+
+// UpdateMatchInfo records the map and game mode a game server reports, for the server browser.
+func (m *Manager) UpdateMatchInfo(id, mapName, gameMode string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	inst, exists := m.instances[id]
+	if !exists {
+		return fmt.Errorf("instance not found")
+	}
+
+	inst.Map = mapName
+	inst.GameMode = gameMode
 	return nil
 }
 
@@ -0,0 +1,129 @@
+// Package tickets verifies the join tickets the master server issues to players.
+package tickets
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verification errors reported back to game servers.
+var (
+	ErrNoKey         = errors.New("no verification key from master yet")
+	ErrMalformed     = errors.New("malformed ticket")
+	ErrSignature     = errors.New("invalid ticket signature")
+	ErrExpired       = errors.New("ticket expired")
+	ErrRevoked       = errors.New("ticket revoked")
+	ErrWrongInstance = errors.New("ticket was issued for another instance")
+)
+
+// Claims is the signed content of a ticket.
+type Claims struct {
+	PlayerID   int64  `json:"pid"`
+	NodeID     int    `json:"nid"`
+	InstanceID string `json:"iid"`
+	IssuedAt   int64  `json:"iat"`
+	ExpiresAt  int64  `json:"exp"`
+	ID         string `json:"jti"`
+	KeyID      string `json:"kid"`
+}
+
+// Verifier checks tickets against the master's public key and revocations.
+type Verifier struct {
+	mu       sync.RWMutex
+	key      ed25519.PublicKey
+	keyID    string
+	nodeID   int
+	revoked  map[int64]int64 // PlayerID -> tickets issued at or before this unix time are invalid
+	onRevoke []func(playerID int64)
+}
+
+// NewVerifier creates a verifier with no key; every ticket fails until SetKey is called.
+func NewVerifier() *Verifier {
+	return &Verifier{revoked: make(map[int64]int64)}
+}
+
+// SetKey installs the master's public key (standard base64) and this node's ID.
+func (v *Verifier) SetKey(publicKey, keyID string, nodeID int) error {
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid ed25519 public key")
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.key = ed25519.PublicKey(raw)
+	v.keyID = keyID
+	v.nodeID = nodeID
+	return nil
+}
+
+// OnRevoke registers a callback run when a player's tickets are revoked.
+func (v *Verifier) OnRevoke(fn func(playerID int64)) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.onRevoke = append(v.onRevoke, fn)
+}
+
+// Revoke rejects tickets issued to playerID at or before issuedBefore (unix seconds).
+func (v *Verifier) Revoke(playerID, issuedBefore int64) {
+	v.mu.Lock()
+	if issuedBefore > v.revoked[playerID] {
+		v.revoked[playerID] = issuedBefore
+	}
+	hooks := v.onRevoke
+	v.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(playerID)
+	}
+}
+
+// Verify checks a ticket presented to instanceID.
+func (v *Verifier) Verify(token, instanceID string, now time.Time) (*Claims, error) {
+	v.mu.RLock()
+	key, nodeID := v.key, v.nodeID
+	v.mu.RUnlock()
+	if key == nil {
+		return nil, ErrNoKey
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrMalformed
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !ed25519.Verify(key, body, sig) {
+		return nil, ErrSignature
+	}
+
+	var c Claims
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, ErrMalformed
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return nil, ErrExpired
+	}
+	if c.InstanceID != instanceID || (nodeID != 0 && c.NodeID != nodeID) {
+		return nil, ErrWrongInstance
+	}
+
+	v.mu.RLock()
+	before, revoked := v.revoked[c.PlayerID]
+	v.mu.RUnlock()
+	if revoked && c.IssuedAt <= before {
+		return nil, ErrRevoked
+	}
+	return &c, nil
+}
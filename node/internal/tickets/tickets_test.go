@@ -0,0 +1,88 @@
+package tickets
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func issue(t *testing.T, priv ed25519.PrivateKey, c Claims) string {
+	t.Helper()
+	body, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(body) + "." + enc.EncodeToString(ed25519.Sign(priv, body))
+}
+
+func TestVerify(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	pub := base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey))
+	now := time.Now()
+	valid := Claims{PlayerID: 42, NodeID: 3, InstanceID: "eu-7777", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Minute).Unix()}
+
+	v := NewVerifier()
+	if _, err := v.Verify(issue(t, priv, valid), "eu-7777", now); err != ErrNoKey {
+		t.Fatalf("expected ErrNoKey before the master sent a key, got %v", err)
+	}
+	if err := v.SetKey(pub, "k1", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := v.Verify(issue(t, priv, valid), "eu-7777", now)
+	if err != nil || c.PlayerID != 42 {
+		t.Fatalf("expected valid ticket, got %+v %v", c, err)
+	}
+
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Second).Unix()
+	wrongNode := valid
+	wrongNode.NodeID = 4
+	otherKey := ed25519.NewKeyFromSeed([]byte("0123456789abcdef0123456789abcdef"))
+
+	cases := []struct {
+		name     string
+		token    string
+		instance string
+		want     error
+	}{
+		{"expired", issue(t, priv, expired), "eu-7777", ErrExpired},
+		{"other instance", issue(t, priv, valid), "eu-7778", ErrWrongInstance},
+		{"other node", issue(t, priv, wrongNode), "eu-7777", ErrWrongInstance},
+		{"foreign key", issue(t, otherKey, valid), "eu-7777", ErrSignature},
+		{"garbage", "abc", "eu-7777", ErrMalformed},
+	}
+	for _, tc := range cases {
+		if _, err := v.Verify(tc.token, tc.instance, now); err != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	v := NewVerifier()
+	_ = v.SetKey(base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)), "k1", 0)
+
+	var kicked []int64
+	v.OnRevoke(func(id int64) { kicked = append(kicked, id) })
+
+	now := time.Now()
+	old := Claims{PlayerID: 42, InstanceID: "a", IssuedAt: now.Unix() - 10, ExpiresAt: now.Unix() + 60}
+	v.Revoke(42, now.Unix()-5)
+
+	if _, err := v.Verify(issue(t, priv, old), "a", now); err != ErrRevoked {
+		t.Fatalf("expected revoked, got %v", err)
+	}
+	fresh := old
+	fresh.IssuedAt = now.Unix()
+	if _, err := v.Verify(issue(t, priv, fresh), "a", now); err != nil {
+		t.Fatalf("tickets issued after the revocation stay valid, got %v", err)
+	}
+	if len(kicked) != 1 || kicked[0] != 42 {
+		t.Fatalf("expected revoke callback for 42, got %v", kicked)
+	}
+}
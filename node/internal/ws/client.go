@@ -16,6 +16,7 @@ import (
 
 	"node/internal/config"
 	"node/internal/game"
+	"node/internal/tickets"
 
 	"github.com/gorilla/websocket"
 	"github.com/shirou/gopsutil/v3/cpu"
@@ -33,6 +34,8 @@ type Client struct {
 	id        int
 	metrics   cachedMetrics
 	metricsMu sync.RWMutex
+	tickets   *tickets.Verifier
+	roster    func() map[string][]int64
 }
 
 type cachedMetrics struct {
@@ -61,6 +64,47 @@ func NewClient(cfg *config.Config, m *game.Manager, l *slog.Logger) *Client {
 	}
 }
 
+// SetTicketVerifier receives the join ticket key and revocations pushed by the master.
+func (c *Client) SetTicketVerifier(v *tickets.Verifier) {
+	c.tickets = v
+}
+
+// SetRosterSource provides the players connected to each instance. The roster is sent
+// after every registration so the master can repair sessions missed while disconnected.
+func (c *Client) SetRosterSource(fn func() map[string][]int64) {
+	c.roster = fn
+}
+
+// SendPlayerEvent forwards a game server's player join or leave to the master.
+// Events are queued while disconnected and delivered after reconnecting.
+func (c *Client) SendPlayerEvent(instanceID string, playerID int64, event, reason string) {
+	c.sendMessage("PLAYER_EVENT", map[string]interface{}{
+		"instance_id": instanceID,
+		"player_id":   playerID,
+		"event":       event,
+		"reason":      reason,
+		"at":          time.Now().Unix(),
+	})
+}
+
+func (c *Client) sendRoster() {
+	if c.roster == nil {
+		return
+	}
+	c.sendMessage("PLAYER_ROSTER", map[string]interface{}{"instances": c.roster()})
+}
+
+func (c *Client) sendMessage(msgType string, payload interface{}) {
+	data, _ := json.Marshal(payload)
+	bytes, _ := json.Marshal(Message{Type: msgType, Payload: data})
+
+	select {
+	case c.send <- bytes:
+	default:
+		c.logger.Warn("Send buffer full, dropping message", "type", msgType)
+	}
+}
+
 // Start initiates the WebSocket client loop.
 func (c *Client) Start() {
 	for {
@@ -333,6 +377,7 @@ func (c *Client) handleMessage(msg Message) {
 			if resp.Status == "success" {
 				c.id = resp.ID
 				c.logger.Info("✅ Successfully registered with Master Server via WebSocket", "node_id", c.id)
+				c.sendRoster()
 			} else {
 				c.logger.Error("❌ Registration failed", "error", resp.Error)
 				if strings.Contains(resp.Error, "not enrolled") {
@@ -679,6 +724,37 @@ func (c *Client) handleMessage(msg Message) {
 			}
 		}
 
+	case "set_join_ticket_key":
+		var req struct {
+			KeyID       string           `json:"key_id"`
+			PublicKey   string           `json:"public_key"`
+			NodeID      int              `json:"node_id"`
+			Revocations map[string]int64 `json:"revocations"`
+		}
+		if err := json.Unmarshal(msg.Payload, &req); err != nil || c.tickets == nil {
+			return
+		}
+		if err := c.tickets.SetKey(req.PublicKey, req.KeyID, req.NodeID); err != nil {
+			c.logger.Error("Failed to install join ticket key", "error", err)
+			return
+		}
+		for id, before := range req.Revocations {
+			if playerID, err := strconv.ParseInt(id, 10, 64); err == nil {
+				c.tickets.Revoke(playerID, before)
+			}
+		}
+		c.logger.Info("Join ticket key installed", "key_id", req.KeyID)
+
+	case "revoke_join_tickets":
+		var req struct {
+			PlayerID     int64 `json:"player_id"`
+			IssuedBefore int64 `json:"issued_before"`
+		}
+		if err := json.Unmarshal(msg.Payload, &req); err != nil || c.tickets == nil {
+			return
+		}
+		c.tickets.Revoke(req.PlayerID, req.IssuedBefore)
+
 	case "clear_instance_logs":
 		var req struct {
 			InstanceID string `json:"instance_id"`
@@ -15,6 +15,7 @@ import (
 	"node/internal/config"
 	"node/internal/enrollment"
 	"node/internal/game"
+	"node/internal/tickets"
 	"node/internal/updater"
 	"node/internal/ws"
 	"syscall"
@@ -114,8 +115,10 @@ func main() {
 	}
 
 	// 4. Start WebSocket Client (Handles registration and heartbeat)
+	// The master pushes the join ticket key over it; game servers verify through the API handler.
+	ticketVerifier := tickets.NewVerifier()
 	wsClient := ws.NewClient(cfg, manager, logger)
-	go wsClient.Start()
+	wsClient.SetTicketVerifier(ticketVerifier)
 
 	// 6. Initialize Router
 	gin.SetMode(gin.ReleaseMode)
@@ -141,8 +144,14 @@ func main() {
 	})
 
 	handler := api.NewHandler(manager, cfg, logger)
+	handler.SetTicketVerifier(ticketVerifier)
+	handler.OnPlayerEvent(wsClient.SendPlayerEvent)
+	wsClient.SetRosterSource(handler.Roster)
 	handler.RegisterRoutes(router)
 
+	// The client reports player sessions through the handler, so start it once both are wired
+	go wsClient.Start()
+
 	// 7. Run Server with Graceful Shutdown
 	srv := &http.Server{
 		Addr:              ":" + cfg.Port,
@@ -0,0 +1,231 @@
+package appeals
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"exile/server/bans"
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/redeye"
+	"exile/server/ws_player"
+)
+
+// =================================================================================
+// APPEALS: players contest their bans, moderators approve or deny them
+// =================================================================================
+
+const (
+	// Player WS message sent when an appeal is decided and the player is online
+	MsgAppealDecided = "APPEAL_DECIDED"
+
+	maxMessageLen = 2000
+	maxNoteLen    = 2000
+)
+
+var (
+	ErrBanNotFound     = errors.New("ban not found")
+	ErrBanNotActive    = errors.New("ban is no longer active")
+	ErrAlreadyAppealed = errors.New("this ban has already been appealed")
+)
+
+// BanStatus is one of a player's bans as the player sees it, with its appeal if any.
+type BanStatus struct {
+	Ban    bans.Notice    `json:"ban"`
+	Appeal *models.Appeal `json:"appeal,omitempty"`
+}
+
+// playerActor names a player in the audit trail.
+func playerActor(playerID int64) string {
+	return fmt.Sprintf("player:%d", playerID)
+}
+
+// cleanText trims s and checks it is present and at most max bytes long.
+func cleanText(field, s string, max int) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("%s is required", field)
+	}
+	if len(s) > max {
+		return "", fmt.Errorf("%s is too long", field)
+	}
+	return s, nil
+}
+
+// Submit files a player's appeal against one of their active bans. Each ban can be
+// appealed once.
+func Submit(playerID, banID int64, message string) (*models.Appeal, error) {
+	if database.DBConn == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	message, err := cleanText("message", message, maxMessageLen)
+	if err != nil {
+		return nil, err
+	}
+	ban, err := database.GetBan(database.DBConn, banID)
+	if err != nil {
+		return nil, err
+	}
+	// Someone else's ban is reported as missing so ban IDs cannot be probed
+	if ban == nil || ban.PlayerID != playerID {
+		return nil, ErrBanNotFound
+	}
+	if !ban.IsActive(time.Now()) {
+		return nil, ErrBanNotActive
+	}
+	existing, err := database.GetAppealByBan(database.DBConn, banID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAlreadyAppealed
+	}
+
+	a := &models.Appeal{BanID: banID, PlayerID: playerID, Message: message}
+	id, err := database.CreateAppeal(database.DBConn, a, playerActor(playerID))
+	if err != nil {
+		// Two submissions racing past the check above; the unique ban_id rejects one
+		if again, _ := database.GetAppealByBan(database.DBConn, banID); again != nil {
+			return nil, ErrAlreadyAppealed
+		}
+		return nil, err
+	}
+	log.Printf("Appeals: player %d appealed ban %d (appeal %d)", playerID, banID, id)
+	return database.GetAppeal(database.DBConn, id)
+}
+
+// PlayerStatus returns a player's active bans and appealed bans with their appeals, newest first.
+func PlayerStatus(playerID int64) ([]BanStatus, error) {
+	history, err := database.ListPlayerBans(database.DBConn, playerID)
+	if err != nil {
+		return nil, err
+	}
+	appeals, err := database.ListPlayerAppeals(database.DBConn, playerID)
+	if err != nil {
+		return nil, err
+	}
+	byBan := make(map[int64]*models.Appeal, len(appeals))
+	for i := range appeals {
+		appeals[i].DecidedBy = "" // Moderators stay anonymous to the player
+		byBan[appeals[i].BanID] = &appeals[i]
+	}
+
+	now := time.Now()
+	status := []BanStatus{}
+	for i := range history {
+		b := &history[i]
+		a := byBan[b.ID]
+		if !b.IsActive(now) && a == nil {
+			continue
+		}
+		status = append(status, BanStatus{Ban: bans.NoticeFor(b), Appeal: a})
+	}
+	return status, nil
+}
+
+// Get returns an appeal with its ban and audit trail, or nil.
+func Get(id int64) (*models.Appeal, error) {
+	a, err := database.GetAppeal(database.DBConn, id)
+	if err != nil || a == nil {
+		return nil, err
+	}
+	if a.Ban, err = database.GetBan(database.DBConn, a.BanID); err != nil {
+		return nil, err
+	}
+	if a.Audit, err = database.ListAppealAudit(database.DBConn, id); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Decide approves or denies a pending appeal. Approving lifts the ban; if the ban came
+// from an anticheat policy still waiting for review, that review is reverted too. The
+// decision is recorded first so concurrent decisions cannot both apply, and an approval
+// whose ban cannot be lifted goes back to pending.
+func Decide(id int64, approve bool, by, note string) (*models.Appeal, error) {
+	note, err := cleanText("note", note, maxNoteLen)
+	if err != nil {
+		return nil, err
+	}
+	a, err := database.GetAppeal(database.DBConn, id)
+	if err != nil || a == nil {
+		return nil, err
+	}
+	if a.Status != models.AppealPending {
+		return nil, fmt.Errorf("appeal is already %s", a.Status)
+	}
+
+	status := models.AppealDenied
+	if approve {
+		status = models.AppealApproved
+	}
+	if err := database.DecideAppeal(database.DBConn, id, status, by, note); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("appeal was decided concurrently")
+		}
+		return nil, err
+	}
+
+	if approve {
+		if err := liftBan(a, by); err != nil {
+			if reopenErr := database.ReopenAppeal(database.DBConn, id, by, "ban could not be lifted: "+err.Error()); reopenErr != nil {
+				log.Printf("Appeals: failed to reopen appeal %d after a failed ban lift: %v", id, reopenErr)
+			}
+			return nil, fmt.Errorf("lift ban: %w", err)
+		}
+	}
+	log.Printf("Appeals: appeal %d of player %d %s by %s", id, a.PlayerID, status, by)
+
+	decided, err := Get(id)
+	if err != nil || decided == nil {
+		return nil, fmt.Errorf("appeal decided but could not be reloaded")
+	}
+	notify(decided)
+	return decided, nil
+}
+
+// liftBan lifts the appealed ban and records it in the audit trail.
+func liftBan(a *models.Appeal, by string) error {
+	reason := fmt.Sprintf("Appeal %d approved", a.ID)
+
+	review, err := database.GetPendingCheatReviewByBan(database.DBConn, a.BanID)
+	if err != nil {
+		return err
+	}
+	if review != nil {
+		if _, err := redeye.ReviewAction(review.ID, false, by, reason); err != nil {
+			return err
+		}
+		_ = database.AddAppealAudit(database.DBConn, a.ID, by, "review_reverted", fmt.Sprintf("anticheat review %d", review.ID))
+	} else if _, err := bans.Lift(a.BanID, by, reason); err != nil {
+		// The ban may have expired or been lifted while the appeal was pending
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		return database.AddAppealAudit(database.DBConn, a.ID, by, "ban_already_ended", "")
+	}
+	return database.AddAppealAudit(database.DBConn, a.ID, by, "ban_lifted", reason)
+}
+
+// notify tells an online player their appeal was decided. Offline players see it
+// the next time they check their appeals.
+func notify(a *models.Appeal) {
+	if ws_player.GlobalPlayerWS == nil || !ws_player.GlobalPlayerWS.IsPlayerOnline(a.PlayerID) {
+		return
+	}
+	ws_player.GlobalPlayerWS.SendMessage(a.PlayerID, ws_player.NewMessage(MsgAppealDecided, decisionPayload(a)))
+}
+
+// decisionPayload is what the player is told about a decided appeal.
+func decisionPayload(a *models.Appeal) map[string]interface{} {
+	return map[string]interface{}{
+		"appeal_id": a.ID,
+		"ban_id":    a.BanID,
+		"status":    a.Status,
+		"note":      a.DecisionNote,
+	}
+}
@@ -0,0 +1,138 @@
+package appeals
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"exile/server/bans"
+	"exile/server/database"
+	"exile/server/models"
+)
+
+func TestCleanText(t *testing.T) {
+	if got, err := cleanText("message", "  I was lagging  ", 20); err != nil || got != "I was lagging" {
+		t.Errorf("expected trimmed message, got %q (%v)", got, err)
+	}
+	if _, err := cleanText("message", "   ", 20); err == nil || err.Error() != "message is required" {
+		t.Errorf("blank message should be rejected, got %v", err)
+	}
+	if _, err := cleanText("note", strings.Repeat("x", 21), 20); err == nil || err.Error() != "note is too long" {
+		t.Errorf("long note should be rejected, got %v", err)
+	}
+}
+
+func TestDecisionPayloadHidesModerator(t *testing.T) {
+	a := &models.Appeal{ID: 3, BanID: 9, Status: models.AppealDenied, DecidedBy: "alice", DecisionNote: "Replay confirms it"}
+	payload := decisionPayload(a)
+	if _, ok := payload["decided_by"]; ok {
+		t.Error("payload should not name the moderator")
+	}
+	if payload["status"] != models.AppealDenied || payload["note"] != "Replay confirms it" {
+		t.Errorf("unexpected payload %v", payload)
+	}
+}
+
+// appealsDB connects to the test database and creates the player system tables.
+func appealsDB(t *testing.T) {
+	t.Helper()
+	dsn := os.Getenv("DB_DSN")
+	if dsn == "" {
+		t.Skip("DB_DSN not set, skipping DB test")
+	}
+	if err := database.InitDB(dsn); err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	if err := database.InitPlayerSystem(database.DBConn); err != nil {
+		t.Fatalf("init player system: %v", err)
+	}
+}
+
+// bannedPlayer creates a player with an active ban; both are removed when the test ends.
+func bannedPlayer(t *testing.T, name string) (int64, *models.Ban) {
+	t.Helper()
+	suffix := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	id, err := database.CreatePlayer(database.DBConn, &models.Player{UID: "uid-" + suffix, Name: name, DeviceID: "dev-" + suffix})
+	if err != nil {
+		t.Fatalf("create player: %v", err)
+	}
+	t.Cleanup(func() { _ = database.DeletePlayer(database.DBConn, id) })
+
+	ban, err := bans.Issue(bans.Request{PlayerID: id, Reason: "Speed hacking", IssuedBy: "test"})
+	if err != nil {
+		t.Fatalf("issue ban: %v", err)
+	}
+	return id, ban
+}
+
+func TestSubmit(t *testing.T) {
+	appealsDB(t)
+	player, ban := bannedPlayer(t, "appellant")
+	other, _ := bannedPlayer(t, "other")
+
+	if _, err := Submit(other, ban.ID, "Not me"); !errors.Is(err, ErrBanNotFound) {
+		t.Errorf("expected someone else's ban to be reported missing, got %v", err)
+	}
+
+	a, err := Submit(player, ban.ID, "  I was lagging  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Status != models.AppealPending || a.Message != "I was lagging" {
+		t.Errorf("unexpected appeal %+v", a)
+	}
+	if _, err := Submit(player, ban.ID, "Please"); !errors.Is(err, ErrAlreadyAppealed) {
+		t.Errorf("expected a second appeal of the ban to be refused, got %v", err)
+	}
+
+	// A ban that was lifted cannot be appealed
+	_, lifted := bannedPlayer(t, "lifted")
+	if _, err := bans.Lift(lifted.ID, "test", "mistake"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Submit(lifted.PlayerID, lifted.ID, "Unban me"); !errors.Is(err, ErrBanNotActive) {
+		t.Errorf("expected an inactive ban to be refused, got %v", err)
+	}
+}
+
+func TestDecide(t *testing.T) {
+	appealsDB(t)
+
+	approvedPlayer, approvedBan := bannedPlayer(t, "approved")
+	a, err := Submit(approvedPlayer, approvedBan.ID, "It was a false positive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decided, err := Decide(a.ID, true, "mod", "Replay shows no cheating")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decided.Status != models.AppealApproved || decided.DecidedBy != "mod" {
+		t.Errorf("expected an approved appeal, got %+v", decided)
+	}
+	if decided.Ban == nil || decided.Ban.IsActive(time.Now()) {
+		t.Errorf("expected approval to lift the ban, got %+v", decided.Ban)
+	}
+	if _, err := Decide(a.ID, false, "mod", "Changed my mind"); err == nil {
+		t.Error("expected a decided appeal to stay decided")
+	}
+
+	deniedPlayer, deniedBan := bannedPlayer(t, "denied")
+	a, err = Submit(deniedPlayer, deniedBan.ID, "I did nothing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decided, err = Decide(a.ID, false, "mod", "Replay confirms it")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decided.Status != models.AppealDenied {
+		t.Errorf("expected a denied appeal, got %+v", decided)
+	}
+	if decided.Ban == nil || !decided.Ban.IsActive(time.Now()) {
+		t.Errorf("expected denial to keep the ban, got %+v", decided.Ban)
+	}
+}
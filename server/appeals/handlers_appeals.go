@@ -0,0 +1,224 @@
+package appeals
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"exile/server/auth"
+	"exile/server/database"
+	"exile/server/evidence"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Player Appeal Handlers --
+
+// authenticate verifies a Firebase ID token and returns the player, writing the error
+// response if that fails. Banned players cannot get a session, so appeal requests carry
+// the token themselves.
+func authenticate(w http.ResponseWriter, r *http.Request, idToken string) *models.Player {
+	if auth.FirebaseMgr == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "firebase not initialized")
+		return nil
+	}
+	if idToken == "" {
+		utils.WriteError(w, r, http.StatusBadRequest, "id_token is required")
+		return nil
+	}
+	uid, err := auth.FirebaseMgr.VerifyIDToken(idToken)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusUnauthorized, "invalid token: "+err.Error())
+		return nil
+	}
+	p, err := database.GetPlayerByUID(database.DBConn, uid)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return nil
+	}
+	if p == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "player not found")
+		return nil
+	}
+	return p
+}
+
+// SubmitAppealHandler files an appeal against one of the player's active bans.
+//
+// Request (JSON):
+//   - id_token (required): Firebase ID token of the banned player
+//   - ban_id (required): The ban being appealed
+//   - message (required): The player's case
+func SubmitAppealHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	var req struct {
+		IDToken string `json:"id_token"`
+		BanID   int64  `json:"ban_id"`
+		Message string `json:"message"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	p := authenticate(w, r, req.IDToken)
+	if p == nil {
+		return
+	}
+
+	appeal, err := Submit(p.ID, req.BanID, req.Message)
+	switch {
+	case errors.Is(err, ErrBanNotFound):
+		utils.WriteError(w, r, http.StatusNotFound, err.Error())
+		return
+	case errors.Is(err, ErrAlreadyAppealed):
+		utils.WriteError(w, r, http.StatusConflict, err.Error())
+		return
+	case err != nil:
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	appeal.DecidedBy = ""
+	utils.WriteJSON(w, http.StatusCreated, appeal)
+}
+
+// GetAppealStatusHandler returns the player's active bans and appealed bans with the
+// state of their appeals.
+//
+// Request (JSON):
+//   - id_token (required): Firebase ID token of the player
+func GetAppealStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	var req struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	p := authenticate(w, r, req.IDToken)
+	if p == nil {
+		return
+	}
+
+	status, err := PlayerStatus(p.ID)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to retrieve appeals")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, status)
+}
+
+// -- Appeal Review Handlers --
+
+// ListAppealsHandler returns the appeal queue, oldest first. Query "status" defaults to
+// pending; "all" lists every appeal.
+func ListAppealsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.AppealPending
+	case "all":
+		status = ""
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	list, err := database.ListAppeals(database.DBConn, status, limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to retrieve appeals")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// GetAppealHandler returns an appeal with its ban and audit trail, and the player's case:
+// bans, reports, warnings, anticheat history and evidence.
+func GetAppealHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	appeal, err := Get(int64(id))
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if appeal == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "appeal not found")
+		return
+	}
+	manifest, err := evidence.BuildManifest(appeal.PlayerID)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"appeal": appeal,
+		"case":   manifest,
+	})
+}
+
+// DecideAppealHandler approves or denies a pending appeal. Approving lifts the ban.
+//
+// Request (JSON):
+//   - decision (required): approve or deny
+//   - note (required): Reason for the decision, shown to the player
+//   - decided_by: Who decided (defaults to "dashboard")
+func DecideAppealHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req struct {
+		Decision  string `json:"decision"`
+		Note      string `json:"note"`
+		DecidedBy string `json:"decided_by"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Decision != "approve" && req.Decision != "deny" {
+		utils.WriteError(w, r, http.StatusBadRequest, "decision must be approve or deny")
+		return
+	}
+	if req.DecidedBy == "" {
+		req.DecidedBy = "dashboard"
+	}
+
+	appeal, err := Decide(int64(id), req.Decision == "approve", req.DecidedBy, req.Note)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if appeal == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "appeal not found")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, appeal)
+}
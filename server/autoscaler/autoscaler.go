@@ -0,0 +1,436 @@
+package autoscaler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/placement"
+	"exile/server/registry"
+	"exile/server/utils"
+	"exile/server/ws"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// =================================================================================
+// AUTOSCALER: keeps a warm buffer of idle, ready servers per region/tag group
+// =================================================================================
+
+const (
+	ActionScaleUp = "scale_up"
+	ActionDrain   = "drain"
+	ActionRemove  = "remove"
+	ActionHold    = "hold"
+
+	defaultInterval = 30 * time.Second
+	commandTimeout  = 10 * time.Second
+)
+
+var (
+	// Serializes evaluations (background loop vs. manual trigger)
+	evalMu sync.Mutex
+
+	drainsMu sync.RWMutex
+	drains   = map[string]Drain{}
+
+	// Last hold reason per policy, so a policy waiting out a cooldown doesn't flood the history
+	lastHold = map[int]string{}
+
+	globalDryRun bool
+
+	done    chan struct{}
+	wg      sync.WaitGroup
+	running bool
+)
+
+// Drain marks an instance the autoscaler is retiring: it gets no new players and is
+// removed once empty.
+type Drain struct {
+	NodeID     int       `json:"node_id"`
+	InstanceID string    `json:"instance_id"`
+	PolicyID   int       `json:"policy_id"`
+	Since      time.Time `json:"since"`
+}
+
+func drainKey(nodeID int, instanceID string) string {
+	return fmt.Sprintf("%d/%s", nodeID, instanceID)
+}
+
+// IsInstanceDraining reports whether the autoscaler is retiring an instance.
+func IsInstanceDraining(nodeID int, instanceID string) bool {
+	drainsMu.RLock()
+	defer drainsMu.RUnlock()
+	_, ok := drains[drainKey(nodeID, instanceID)]
+	return ok
+}
+
+// ListDrains returns the instances currently being drained.
+func ListDrains() []Drain {
+	drainsMu.RLock()
+	defer drainsMu.RUnlock()
+	out := make([]Drain, 0, len(drains))
+	for _, d := range drains {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Since.Before(out[j].Since) })
+	return out
+}
+
+func setDraining(d Drain) {
+	drainsMu.Lock()
+	defer drainsMu.Unlock()
+	drains[drainKey(d.NodeID, d.InstanceID)] = d
+}
+
+func clearDraining(nodeID int, instanceID string) {
+	drainsMu.Lock()
+	defer drainsMu.Unlock()
+	delete(drains, drainKey(nodeID, instanceID))
+}
+
+// scopedInstance is an instance on a node matched by a policy.
+type scopedInstance struct {
+	NodeID   int
+	Instance models.GameInstance
+	Draining bool
+}
+
+func isLive(inst models.GameInstance) bool {
+	return inst.Status == "Running" || inst.Status == "Provisioning"
+}
+
+// Plan is what a policy wants done right now, before cooldowns are applied.
+type Plan struct {
+	Total   int              // Live instances not being drained
+	Idle    int              // Of those, running with no players or still provisioning
+	ScaleUp int              // Instances to spawn
+	Drain   []scopedInstance // Instances to retire, emptiest first
+	Remove  []scopedInstance // Drained instances that are now empty
+	Reason  string
+}
+
+// computePlan applies min/max/target-idle to the instances in a policy's scope.
+func computePlan(p *models.AutoscalePolicy, instances []scopedInstance) Plan {
+	var plan Plan
+	var idleRunning []scopedInstance
+	var running []scopedInstance
+	for _, si := range instances {
+		if si.Draining {
+			if si.Instance.PlayerCount == 0 || !isLive(si.Instance) {
+				plan.Remove = append(plan.Remove, si)
+			}
+			continue
+		}
+		if !isLive(si.Instance) {
+			continue
+		}
+		plan.Total++
+		switch {
+		case si.Instance.Status == "Provisioning":
+			plan.Idle++
+		case si.Instance.PlayerCount == 0:
+			plan.Idle++
+			idleRunning = append(idleRunning, si)
+			running = append(running, si)
+		default:
+			running = append(running, si)
+		}
+	}
+
+	up := p.MinInstances - plan.Total
+	reason := fmt.Sprintf("below minimum of %d instances", p.MinInstances)
+	if need := p.TargetIdle - plan.Idle; need > up {
+		up = need
+		reason = fmt.Sprintf("%d idle, target %d", plan.Idle, p.TargetIdle)
+	}
+	if p.MaxInstances > 0 && plan.Total+up > p.MaxInstances {
+		up = p.MaxInstances - plan.Total
+		reason += fmt.Sprintf(" (capped at maximum %d)", p.MaxInstances)
+	}
+	if up > 0 {
+		plan.ScaleUp = up
+		plan.Reason = reason
+		return plan
+	}
+
+	// Surplus: idle beyond the target, never going below the minimum
+	down := len(idleRunning) - p.TargetIdle
+	if room := plan.Total - p.MinInstances; down > room {
+		down = room
+	}
+	reason = fmt.Sprintf("%d idle, target %d", plan.Idle, p.TargetIdle)
+	if p.MaxInstances > 0 && plan.Total-p.MaxInstances > down {
+		down = plan.Total - p.MaxInstances
+		reason = fmt.Sprintf("%d instances above maximum %d", plan.Total-p.MaxInstances, p.MaxInstances)
+	}
+	if down > len(running) {
+		down = len(running)
+	}
+	if down > 0 {
+		sort.SliceStable(running, func(i, j int) bool {
+			a, b := running[i].Instance, running[j].Instance
+			if a.PlayerCount != b.PlayerCount {
+				return a.PlayerCount < b.PlayerCount
+			}
+			return a.StartTime.After(b.StartTime) // Of equally empty instances, retire the newest
+		})
+		plan.Drain = running[:down]
+		plan.Reason = reason
+	}
+	return plan
+}
+
+func matchesNode(p *models.AutoscalePolicy, n *models.Node) bool {
+	if p.Region != "" && !strings.EqualFold(p.Region, n.Region) {
+		return false
+	}
+	have := map[string]bool{}
+	for _, t := range utils.ParseTags(n.Tags) {
+		have[t] = true
+	}
+	for _, t := range utils.ParseTags(p.Tags) {
+		if !have[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// collect lists instances on the online, non-draining nodes a policy covers.
+func collect(p *models.AutoscalePolicy) ([]scopedInstance, error) {
+	var out []scopedInstance
+	for _, n := range registry.GlobalRegistry.List() {
+		if n.Status != "Online" || n.IsDraining || !matchesNode(p, &n) {
+			continue
+		}
+		instances, err := ws.GlobalWSManager.ListInstances(n.ID, commandTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("node %d: %w", n.ID, err)
+		}
+		for _, inst := range instances {
+			out = append(out, scopedInstance{NodeID: n.ID, Instance: inst, Draining: IsInstanceDraining(n.ID, inst.ID)})
+		}
+	}
+	return out, nil
+}
+
+// Evaluate runs one autoscaling pass for a policy and returns the decisions it made.
+func Evaluate(db *sqlx.DB, p *models.AutoscalePolicy, forceDryRun bool) []models.AutoscaleDecision {
+	evalMu.Lock()
+	defer evalMu.Unlock()
+
+	dryRun := forceDryRun || globalDryRun || p.DryRun
+	now := time.Now().UTC()
+	var decisions []models.AutoscaleDecision
+	record := func(d models.AutoscaleDecision) {
+		d.PolicyID = p.ID
+		d.DryRun = dryRun
+		d.CreatedAt = now
+		if err := database.SaveAutoscaleDecision(db, &d); err != nil {
+			log.Printf("Autoscaler: failed to record decision for policy %d: %v", p.ID, err)
+		}
+		decisions = append(decisions, d)
+	}
+	hold := func(reason string, total, idle int, errMsg string) {
+		if lastHold[p.ID] == reason+errMsg {
+			return
+		}
+		lastHold[p.ID] = reason + errMsg
+		record(models.AutoscaleDecision{Action: ActionHold, Reason: reason, Total: total, Idle: idle, Error: errMsg})
+	}
+
+	instances, err := collect(p)
+	if err != nil {
+		hold("instance listing incomplete, skipping evaluation", 0, 0, err.Error())
+		return decisions
+	}
+	forgetVanishedDrains(p.ID, instances)
+	plan := computePlan(p, instances)
+
+	// Retired instances that emptied out are removed regardless of cooldowns
+	for _, si := range plan.Remove {
+		d := models.AutoscaleDecision{Action: ActionRemove, NodeID: si.NodeID, InstanceID: si.Instance.ID,
+			Reason: "drained instance is empty", Total: plan.Total, Idle: plan.Idle}
+		if !dryRun {
+			if err := removeInstance(si.NodeID, si.Instance.ID); err != nil {
+				d.Error = err.Error()
+			} else {
+				clearDraining(si.NodeID, si.Instance.ID)
+			}
+		}
+		record(d)
+	}
+
+	changed := false
+	switch {
+	case plan.ScaleUp > 0:
+		cooldown := time.Duration(p.ScaleUpCooldownSeconds) * time.Second
+		if wait := cooldown - now.Sub(p.LastScaleUpAt); !p.LastScaleUpAt.IsZero() && wait > 0 {
+			hold(fmt.Sprintf("scale-up of %d wanted (%s) but cooling down", plan.ScaleUp, plan.Reason), plan.Total, plan.Idle, "")
+			break
+		}
+		req := placement.Request{Region: p.Region, Tags: utils.ParseTags(p.Tags), Strategy: p.Strategy}
+		for i := 0; i < plan.ScaleUp; i++ {
+			d := models.AutoscaleDecision{Action: ActionScaleUp, Reason: plan.Reason, Total: plan.Total, Idle: plan.Idle}
+			if !dryRun {
+				res, err := placement.Spawn(req)
+				if err != nil {
+					d.Error = err.Error()
+					record(d)
+					break // Placement will fail the same way for the rest
+				}
+				d.NodeID = res.NodeID
+				d.InstanceID = res.Instance.ID
+			}
+			record(d)
+		}
+		p.LastScaleUpAt = now
+		changed = true
+
+	case len(plan.Drain) > 0:
+		cooldown := time.Duration(p.ScaleDownCooldownSeconds) * time.Second
+		if wait := cooldown - now.Sub(p.LastScaleDownAt); !p.LastScaleDownAt.IsZero() && wait > 0 {
+			hold(fmt.Sprintf("scale-down of %d wanted (%s) but cooling down", len(plan.Drain), plan.Reason), plan.Total, plan.Idle, "")
+			break
+		}
+		for _, si := range plan.Drain {
+			d := models.AutoscaleDecision{Action: ActionDrain, NodeID: si.NodeID, InstanceID: si.Instance.ID,
+				Reason: fmt.Sprintf("%s; %d players", plan.Reason, si.Instance.PlayerCount), Total: plan.Total, Idle: plan.Idle}
+			if !dryRun {
+				setDraining(Drain{NodeID: si.NodeID, InstanceID: si.Instance.ID, PolicyID: p.ID, Since: now})
+				// Empty instances don't need to wait for the next pass
+				if si.Instance.PlayerCount == 0 {
+					if err := removeInstance(si.NodeID, si.Instance.ID); err != nil {
+						d.Error = err.Error()
+					} else {
+						clearDraining(si.NodeID, si.Instance.ID)
+						d.Action = ActionRemove
+					}
+				}
+			}
+			record(d)
+		}
+		p.LastScaleDownAt = now
+		changed = true
+
+	default:
+		delete(lastHold, p.ID)
+	}
+
+	// A forced preview must not push back the real cooldowns
+	if changed && !forceDryRun {
+		delete(lastHold, p.ID)
+		if err := database.SaveAutoscalePolicy(db, p); err != nil {
+			log.Printf("Autoscaler: failed to update policy %d: %v", p.ID, err)
+		}
+	}
+	return decisions
+}
+
+// forgetVanishedDrains drops drain marks for instances that no longer exist (removed by hand, node gone).
+func forgetVanishedDrains(policyID int, instances []scopedInstance) {
+	seen := map[string]bool{}
+	for _, si := range instances {
+		seen[drainKey(si.NodeID, si.Instance.ID)] = true
+	}
+	drainsMu.Lock()
+	defer drainsMu.Unlock()
+	for key, d := range drains {
+		if d.PolicyID == policyID && !seen[key] {
+			delete(drains, key)
+		}
+	}
+}
+
+// removeInstance stops an instance and deletes it from its node, freeing the slot.
+func removeInstance(nodeID int, instanceID string) error {
+	payload := map[string]string{"instance_id": instanceID}
+	for _, cmd := range []string{"stop_instance", "remove_instance"} {
+		resp, err := ws.GlobalWSManager.SendCommandSync(nodeID, cmd, payload, commandTimeout)
+		if err != nil {
+			return fmt.Errorf("%s: %w", cmd, err)
+		}
+		if resp.Status != "success" {
+			if resp.Error == "" {
+				resp.Error = "no response from node"
+			}
+			// Already stopped is fine; anything else aborts the removal
+			if cmd == "stop_instance" && strings.Contains(strings.ToLower(resp.Error), "not running") {
+				continue
+			}
+			return fmt.Errorf("%s: %s", cmd, resp.Error)
+		}
+	}
+	if database.DBConn != nil {
+		database.SaveInstanceAction(database.DBConn, &models.InstanceAction{
+			NodeID:     nodeID,
+			InstanceID: instanceID,
+			Action:     "remove",
+			Timestamp:  time.Now().UTC(),
+			Status:     "success",
+			Details:    "autoscaler scale-down",
+		})
+	}
+	return nil
+}
+
+// StartAutoscaler launches the evaluation loop. AUTOSCALER_DRY_RUN=true records
+// decisions for every policy without acting on them.
+func StartAutoscaler(db *sqlx.DB) {
+	if db == nil || running {
+		return
+	}
+	globalDryRun = strings.EqualFold(utils.GetEnv("AUTOSCALER_DRY_RUN", "false"), "true")
+	interval := utils.GetEnvDuration("AUTOSCALER_INTERVAL", defaultInterval)
+
+	done = make(chan struct{})
+	running = true
+	wg.Add(1)
+	go loop(db, interval)
+}
+
+// StopAutoscaler stops the evaluation loop.
+func StopAutoscaler() {
+	if !running {
+		return
+	}
+	close(done)
+	wg.Wait()
+	running = false
+}
+
+// GlobalDryRun reports whether the autoscaler was started in dry-run mode.
+func GlobalDryRun() bool {
+	return globalDryRun
+}
+
+func loop(db *sqlx.DB, interval time.Duration) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			policies, err := database.ListAutoscalePolicies(db)
+			if err != nil {
+				log.Printf("Autoscaler: failed to load policies: %v", err)
+				continue
+			}
+			for i := range policies {
+				if policies[i].Enabled {
+					Evaluate(db, &policies[i], false)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package autoscaler
+
+import (
+	"testing"
+
+	"exile/server/models"
+)
+
+func inst(id, status string, players int) scopedInstance {
+	return scopedInstance{NodeID: 1, Instance: models.GameInstance{ID: id, Status: status, PlayerCount: players}}
+}
+
+func TestComputePlan(t *testing.T) {
+	policy := &models.AutoscalePolicy{MinInstances: 2, MaxInstances: 6, TargetIdle: 2}
+
+	cases := []struct {
+		name      string
+		instances []scopedInstance
+		up        int
+		drain     []string
+		remove    []string
+	}{
+		{"empty fleet fills minimum and buffer", nil, 2, nil, nil},
+		{"busy fleet grows idle buffer", []scopedInstance{inst("a", "Running", 8), inst("b", "Running", 3), inst("c", "Running", 0)}, 1, nil, nil},
+		{"provisioning counts as idle", []scopedInstance{inst("a", "Running", 8), inst("b", "Provisioning", 0), inst("c", "Running", 0)}, 0, nil, nil},
+		{"growth capped at max", []scopedInstance{inst("a", "Running", 1), inst("b", "Running", 1), inst("c", "Running", 1), inst("d", "Running", 1), inst("e", "Running", 1)}, 1, nil, nil},
+		{"surplus idle drained", []scopedInstance{inst("a", "Running", 4), inst("b", "Running", 0), inst("c", "Running", 0), inst("d", "Running", 0), inst("e", "Running", 0)}, 0, []string{"b", "c"}, nil},
+		{"never below minimum", []scopedInstance{inst("a", "Running", 0), inst("b", "Running", 0), inst("c", "Running", 0)}, 0, []string{"a"}, nil},
+		{"stopped instances ignored", []scopedInstance{inst("a", "Stopped", 0), inst("b", "Running", 0), inst("c", "Running", 0)}, 0, nil, nil},
+		{"empty drained instance removed", []scopedInstance{
+			{NodeID: 1, Instance: models.GameInstance{ID: "x", Status: "Running"}, Draining: true},
+			{NodeID: 1, Instance: models.GameInstance{ID: "y", Status: "Running", PlayerCount: 2}, Draining: true},
+			inst("b", "Running", 0), inst("c", "Running", 0),
+		}, 0, nil, []string{"x"}},
+	}
+
+	ids := func(sis []scopedInstance) []string {
+		var out []string
+		for _, si := range sis {
+			out = append(out, si.Instance.ID)
+		}
+		return out
+	}
+	equal := func(a, b []string) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, tc := range cases {
+		plan := computePlan(policy, tc.instances)
+		if plan.ScaleUp != tc.up {
+			t.Errorf("%s: scale up %d, want %d (%s)", tc.name, plan.ScaleUp, tc.up, plan.Reason)
+		}
+		if got := ids(plan.Drain); !equal(got, tc.drain) {
+			t.Errorf("%s: drain %v, want %v", tc.name, got, tc.drain)
+		}
+		if got := ids(plan.Remove); !equal(got, tc.remove) {
+			t.Errorf("%s: remove %v, want %v", tc.name, got, tc.remove)
+		}
+	}
+}
+
+func TestMatchesNode(t *testing.T) {
+	n := &models.Node{Region: "EU", Tags: "ranked,eu-west"}
+	if !matchesNode(&models.AutoscalePolicy{Region: "eu", Tags: "ranked"}, n) {
+		t.Error("expected region/tag match")
+	}
+	if matchesNode(&models.AutoscalePolicy{Tags: "casual"}, n) {
+		t.Error("expected tag mismatch")
+	}
+}
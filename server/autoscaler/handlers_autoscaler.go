@@ -0,0 +1,212 @@
+package autoscaler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/placement"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Autoscaler Handlers --
+
+func ListPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	policies, err := database.ListAutoscalePolicies(database.DBConn)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"global_dry_run": GlobalDryRun(),
+		"policies":       policies,
+		"drains":         ListDrains(),
+	})
+}
+
+func CreatePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	p := models.AutoscalePolicy{Enabled: true, ScaleUpCooldownSeconds: 60, ScaleDownCooldownSeconds: 300}
+	if err := utils.DecodeJSON(r, &p); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := validatePolicy(&p); msg != "" {
+		utils.WriteError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+	p.ID = 0
+
+	if err := database.SaveAutoscalePolicy(database.DBConn, &p); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, p)
+}
+
+func UpdatePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req models.AutoscalePolicy
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := validatePolicy(&req); msg != "" {
+		utils.WriteError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	evalMu.Lock()
+	defer evalMu.Unlock()
+
+	p, err := database.GetAutoscalePolicy(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if p == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "policy not found")
+		return
+	}
+
+	// Cooldown timestamps are owned by the autoscaler
+	req.ID = p.ID
+	req.LastScaleUpAt = p.LastScaleUpAt
+	req.LastScaleDownAt = p.LastScaleDownAt
+	if err := database.SaveAutoscalePolicy(database.DBConn, &req); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	delete(lastHold, id)
+
+	utils.WriteJSON(w, http.StatusOK, req)
+}
+
+func DeletePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	evalMu.Lock()
+	defer evalMu.Unlock()
+	if err := database.DeleteAutoscalePolicy(database.DBConn, id); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	delete(lastHold, id)
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// EvaluatePolicyHandler runs a policy now. ?dry_run=true shows the decisions without acting.
+func EvaluatePolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p, err := database.GetAutoscalePolicy(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if p == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "policy not found")
+		return
+	}
+
+	dryRun := strings.EqualFold(r.URL.Query().Get("dry_run"), "true")
+	decisions := Evaluate(database.DBConn, p, dryRun)
+	if decisions == nil {
+		decisions = []models.AutoscaleDecision{}
+	}
+
+	utils.WriteJSON(w, http.StatusOK, decisions)
+}
+
+func ListDecisionsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	policyID, _ := strconv.Atoi(r.URL.Query().Get("policy_id"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	decisions, err := database.ListAutoscaleDecisions(database.DBConn, policyID, limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, decisions)
+}
+
+func validatePolicy(p *models.AutoscalePolicy) string {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return "name is required"
+	}
+	if p.MinInstances < 0 || p.MaxInstances < 0 || p.TargetIdle < 0 {
+		return "min_instances, max_instances and target_idle must not be negative"
+	}
+	if p.MaxInstances > 0 && p.MinInstances > p.MaxInstances {
+		return "min_instances cannot exceed max_instances"
+	}
+	if p.ScaleUpCooldownSeconds < 0 || p.ScaleDownCooldownSeconds < 0 {
+		return "cooldowns must not be negative"
+	}
+	if p.Strategy != "" {
+		known := false
+		for _, s := range placement.Strategies() {
+			if strings.EqualFold(s, p.Strategy) {
+				known = true
+			}
+		}
+		if !known {
+			return "unknown placement strategy: " + p.Strategy
+		}
+	}
+	return ""
+}
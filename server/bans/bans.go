@@ -0,0 +1,272 @@
+package bans
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/jointicket"
+	"exile/server/matchmaking"
+	"exile/server/models"
+	"exile/server/utils"
+	"exile/server/ws_player"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// =================================================================================
+// BANS: timed, scoped player bans and their enforcement on live sessions
+// =================================================================================
+
+const (
+	// Player WS message sent when a ban takes effect; for account-wide bans the
+	// connection is closed right after.
+	MsgBanned = "BANNED"
+
+	maxReasonLen   = 500
+	maxEvidence    = 20
+	kickGrace      = time.Second // Lets the BANNED message reach the client before the socket closes
+	activeBanLimit = 500
+)
+
+var (
+	// ExpiryInterval is how often expired bans are swept (BAN_EXPIRY_INTERVAL).
+	ExpiryInterval = time.Minute
+
+	running bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+)
+
+// Request describes a new ban.
+type Request struct {
+	PlayerID      int64
+	Scope         string
+	Reason        string
+	EvidenceLinks []string
+	Duration      time.Duration // 0 = permanent
+	IssuedBy      string
+}
+
+// Notice is what a banned player is told about their ban.
+type Notice struct {
+	BanID     int64      `json:"ban_id"`
+	Scope     string     `json:"scope"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// NoticeFor strips a ban down to what the player may see.
+func NoticeFor(b *models.Ban) Notice {
+	return Notice{BanID: b.ID, Scope: b.Scope, Reason: b.Reason, ExpiresAt: b.ExpiresAt}
+}
+
+// ValidScope reports whether scope is a known ban scope.
+func ValidScope(scope string) bool {
+	switch scope {
+	case models.BanScopeAll, models.BanScopeGame, models.BanScopeChat:
+		return true
+	}
+	return false
+}
+
+// ScopesBlocking returns the ban scopes that keep a player from an action scope, e.g.
+// both account-wide and game bans keep a player off servers.
+func ScopesBlocking(scope string) []string {
+	if scope == models.BanScopeAll {
+		return []string{models.BanScopeAll}
+	}
+	return []string{models.BanScopeAll, scope}
+}
+
+// Active returns the ban keeping a player from an action scope, or nil.
+func Active(playerID int64, scope string) (*models.Ban, error) {
+	if database.DBConn == nil {
+		return nil, nil
+	}
+	return database.GetActiveBan(database.DBConn, playerID, ScopesBlocking(scope)...)
+}
+
+// Issue records a ban and enforces it on the player's live sessions.
+func Issue(req Request) (*models.Ban, error) {
+	if database.DBConn == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	if req.Scope == "" {
+		req.Scope = models.BanScopeAll
+	}
+	if !ValidScope(req.Scope) {
+		return nil, fmt.Errorf("scope must be all, game or chat")
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	if len(req.Reason) > maxReasonLen {
+		return nil, fmt.Errorf("reason is too long")
+	}
+	if req.Duration < 0 {
+		return nil, fmt.Errorf("duration must not be negative")
+	}
+	links, err := cleanEvidence(req.EvidenceLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := database.GetPlayerByID(database.DBConn, req.PlayerID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("player not found")
+	}
+
+	b := &models.Ban{
+		PlayerID:      req.PlayerID,
+		Scope:         req.Scope,
+		Reason:        req.Reason,
+		EvidenceLinks: links,
+		IssuedBy:      req.IssuedBy,
+	}
+	if req.Duration > 0 {
+		expires := time.Now().Add(req.Duration).UTC()
+		b.ExpiresAt = &expires
+	}
+	if _, err := database.CreateBan(database.DBConn, b); err != nil {
+		return nil, err
+	}
+	log.Printf("Bans: player %d banned (%s, %s) by %s: %s", b.PlayerID, b.Scope, describeExpiry(b), b.IssuedBy, b.Reason)
+
+	syncFlags()
+	enforce(b)
+	return b, nil
+}
+
+// Lift ends a ban early.
+func Lift(banID int64, by, reason string) (*models.Ban, error) {
+	if database.DBConn == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	if err := database.LiftBan(database.DBConn, banID, by, strings.TrimSpace(reason)); err != nil {
+		return nil, err
+	}
+	syncFlags()
+	b, err := database.GetBan(database.DBConn, banID)
+	if err != nil || b == nil {
+		return nil, fmt.Errorf("ban lifted but could not be reloaded")
+	}
+	log.Printf("Bans: ban %d of player %d lifted by %s", b.ID, b.PlayerID, by)
+	return b, nil
+}
+
+// LiftAll lifts every active ban of a player.
+func LiftAll(playerID int64, by, reason string) (int64, error) {
+	if database.DBConn == nil {
+		return 0, fmt.Errorf("database not connected")
+	}
+	n, err := database.LiftPlayerBans(database.DBConn, playerID, "", by, strings.TrimSpace(reason))
+	if err != nil {
+		return 0, err
+	}
+	syncFlags()
+	if n > 0 {
+		log.Printf("Bans: %d bans of player %d lifted by %s", n, playerID, by)
+	}
+	return n, nil
+}
+
+// enforce applies a new ban to whatever the player is doing right now.
+func enforce(b *models.Ban) {
+	switch b.Scope {
+	case models.BanScopeAll, models.BanScopeGame:
+		// Outstanding join tickets must not get the player into a server, and the nodes
+		// drop the player from the game servers they are on
+		jointicket.Revoke(b.PlayerID)
+		if t, err := matchmaking.Cancel(b.PlayerID); err == nil {
+			log.Printf("Bans: cancelled matchmaking ticket %s of player %d", t.ID, b.PlayerID)
+		}
+	}
+
+	if ws_player.GlobalPlayerWS == nil {
+		return
+	}
+	msg := ws_player.NewMessage(MsgBanned, NoticeFor(b))
+	if b.Scope == models.BanScopeAll {
+		ws_player.GlobalPlayerWS.Kick(b.PlayerID, msg, kickGrace)
+		return
+	}
+	ws_player.GlobalPlayerWS.SendMessage(b.PlayerID, msg)
+}
+
+func cleanEvidence(links []string) ([]string, error) {
+	out := make([]string, 0, len(links))
+	for _, l := range links {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	if len(out) > maxEvidence {
+		return nil, fmt.Errorf("at most %d evidence links", maxEvidence)
+	}
+	return out, nil
+}
+
+func describeExpiry(b *models.Ban) string {
+	if b.ExpiresAt == nil {
+		return "permanent"
+	}
+	return "until " + b.ExpiresAt.Format(time.RFC3339)
+}
+
+// syncFlags keeps Player.Banned in line with active account-wide bans.
+func syncFlags() []int64 {
+	ids, err := database.SyncBannedFlags(database.DBConn)
+	if err != nil {
+		log.Printf("Bans: failed to sync banned flags: %v", err)
+	}
+	return ids
+}
+
+// StartBans starts the loop that expires bans.
+func StartBans(db *sqlx.DB) {
+	if db == nil || running {
+		return
+	}
+	ExpiryInterval = utils.GetEnvDuration("BAN_EXPIRY_INTERVAL", ExpiryInterval)
+	done = make(chan struct{})
+	running = true
+	wg.Add(1)
+	go expiryLoop()
+}
+
+// StopBans stops the expiry loop.
+func StopBans() {
+	if !running {
+		return
+	}
+	close(done)
+	wg.Wait()
+	running = false
+}
+
+func expiryLoop() {
+	defer wg.Done()
+
+	syncFlags() // Catch up on bans that expired while the server was down
+
+	ticker := time.NewTicker(ExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, id := range syncFlags() {
+				log.Printf("Bans: account ban status of player %d changed", id)
+			}
+		}
+	}
+}
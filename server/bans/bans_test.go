@@ -0,0 +1,74 @@
+package bans
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"exile/server/models"
+)
+
+func TestScopesBlocking(t *testing.T) {
+	cases := map[string][]string{
+		models.BanScopeAll:  {models.BanScopeAll},
+		models.BanScopeGame: {models.BanScopeAll, models.BanScopeGame},
+		models.BanScopeChat: {models.BanScopeAll, models.BanScopeChat},
+	}
+	for scope, want := range cases {
+		if got := ScopesBlocking(scope); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", scope, got, want)
+		}
+		if !ValidScope(scope) {
+			t.Errorf("%s should be a valid scope", scope)
+		}
+	}
+	if ValidScope("voice") {
+		t.Error("unknown scopes should be rejected")
+	}
+}
+
+func TestBanIsActive(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	cases := []struct {
+		name string
+		ban  models.Ban
+		want bool
+	}{
+		{"permanent", models.Ban{}, true},
+		{"running", models.Ban{ExpiresAt: &future}, true},
+		{"expired", models.Ban{ExpiresAt: &past}, false},
+		{"lifted", models.Ban{ExpiresAt: &future, LiftedAt: &past}, false},
+		{"lifted permanent", models.Ban{LiftedAt: &past}, false},
+	}
+	for _, c := range cases {
+		if got := c.ban.IsActive(now); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCleanEvidence(t *testing.T) {
+	got, err := cleanEvidence([]string{" https://clips.example/1 ", "", "  "})
+	if err != nil || !reflect.DeepEqual(got, []string{"https://clips.example/1"}) {
+		t.Fatalf("unexpected %v %v", got, err)
+	}
+
+	many := make([]string, maxEvidence+1)
+	for i := range many {
+		many[i] = "https://clips.example/x"
+	}
+	if _, err := cleanEvidence(many); err == nil {
+		t.Fatal("expected an error for too many links")
+	}
+}
+
+func TestNoticeHidesInternals(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	b := &models.Ban{ID: 7, Scope: models.BanScopeChat, Reason: "spam", IssuedBy: "mod-anna", EvidenceLinks: []string{"https://internal/1"}, ExpiresAt: &expires}
+	n := NoticeFor(b)
+	if n.BanID != 7 || n.Scope != models.BanScopeChat || n.Reason != "spam" || n.ExpiresAt != &expires {
+		t.Fatalf("unexpected notice %+v", n)
+	}
+}
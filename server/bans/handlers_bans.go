@@ -0,0 +1,97 @@
+package bans
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"exile/server/database"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Ban Handlers --
+
+// ListActiveBansHandler returns the bans currently in force, newest first.
+func ListActiveBansHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > activeBanLimit {
+		limit = activeBanLimit
+	}
+
+	list, err := database.ListActiveBans(database.DBConn, limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to retrieve bans")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// GetPlayerBansHandler returns a player's ban history, newest first.
+func GetPlayerBansHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	list, err := database.ListPlayerBans(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to retrieve bans")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// LiftBanHandler ends one ban early.
+//
+// Request (JSON, optional):
+//   - lifted_by: Who lifted the ban (defaults to "dashboard")
+//   - reason: Why it was lifted
+func LiftBanHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req struct {
+		LiftedBy string `json:"lifted_by"`
+		Reason   string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, "invalid request")
+			return
+		}
+	}
+	if req.LiftedBy == "" {
+		req.LiftedBy = "dashboard"
+	}
+
+	b, err := Lift(id, req.LiftedBy, req.Reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteError(w, r, http.StatusNotFound, "no active ban with this id")
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, b)
+}
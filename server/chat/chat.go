@@ -0,0 +1,709 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"exile/server/bans"
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+	"exile/server/ws_player"
+)
+
+// =================================================================================
+// CHAT: direct messages between friends and group/party channels over the player WS
+// =================================================================================
+
+const (
+	// Client messages
+	MsgSend          = "CHAT_SEND"
+	MsgRead          = "CHAT_READ"
+	MsgHistory       = "CHAT_HISTORY"
+	MsgChannels      = "CHAT_CHANNELS"
+	MsgChannelCreate = "CHAT_CHANNEL_CREATE"
+	MsgChannelInvite = "CHAT_CHANNEL_INVITE"
+	MsgChannelLeave  = "CHAT_CHANNEL_LEAVE"
+
+	// Server messages (MsgHistory and MsgChannels are also used for the replies)
+	MsgMessage     = "CHAT_MESSAGE"
+	MsgSent        = "CHAT_SENT"
+	MsgReadReceipt = "CHAT_READ_RECEIPT"
+	MsgInbox       = "CHAT_INBOX"
+	MsgChannel     = "CHAT_CHANNEL"
+	MsgChannelLeft = "CHAT_CHANNEL_LEFT"
+
+	KindGroup = "group"
+	KindParty = "party"
+
+	inboxLimit        = 200
+	historyLimit      = 50
+	maxHistoryLimit   = 100
+	maxChannelNameLen = 32
+)
+
+// Config holds the chat limits.
+type Config struct {
+	MaxLength         int // Max runes per message
+	RateLimit         int // Messages allowed per RateWindow (also the burst)
+	RateWindow        time.Duration
+	MaxChannelMembers int // Group channels only; parties enforce their own size
+}
+
+var (
+	config  Config
+	limiter *rateLimiter
+
+	// Channel members are cached so channel messages do not query the member list each time
+	membersMu sync.Mutex
+	members   = map[int64][]int64{}
+
+	// Sender names are cached per online player for the CHAT_MESSAGE payload
+	namesMu sync.Mutex
+	names   = map[int64]string{}
+
+	// Party ID -> chat channel of the party
+	partyMu       sync.Mutex
+	partyChannels = map[string]int64{}
+)
+
+// InitChat loads limits and the word filter from the environment and registers the
+// chat messages on the player WebSocket.
+func InitChat() error {
+	config = Config{
+		MaxLength:         utils.GetEnvInt("CHAT_MAX_LENGTH", 500),
+		RateLimit:         utils.GetEnvInt("CHAT_RATE_LIMIT", 5),
+		RateWindow:        utils.GetEnvDuration("CHAT_RATE_WINDOW", 5*time.Second),
+		MaxChannelMembers: utils.GetEnvInt("CHAT_MAX_CHANNEL_MEMBERS", 20),
+	}
+	limiter = newRateLimiter(config.RateLimit, config.RateWindow)
+
+	f, err := LoadWordFilter(utils.GetEnv("CHAT_BANNED_WORDS", ""), utils.GetEnv("CHAT_BANNED_WORDS_FILE", ""))
+	if err != nil {
+		return fmt.Errorf("load chat word filter: %w", err)
+	}
+	if f.Len() > 0 {
+		SetFilter(f)
+	}
+
+	if database.DBConn != nil {
+		if n, err := database.CloseChatChannelsByKind(database.DBConn, KindParty); err != nil {
+			log.Printf("Chat: failed to close stale party channels: %v", err)
+		} else if n > 0 {
+			log.Printf("Chat: closed %d party channels from before the restart", n)
+		}
+	}
+
+	if ws_player.GlobalPlayerWS != nil {
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgSend, handleSend)
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgRead, handleRead)
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgHistory, handleHistory)
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgChannels, handleChannels)
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgChannelCreate, handleChannelCreate)
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgChannelInvite, handleChannelInvite)
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgChannelLeave, handleChannelLeave)
+		ws_player.GlobalPlayerWS.OnConnect(deliverInbox)
+		ws_player.GlobalPlayerWS.OnDisconnect(forget)
+		ws_player.GlobalPlayerWS.OnPartyChange(handlePartyChange)
+	}
+	return nil
+}
+
+// -- Rate Limiting --
+
+// rateLimiter is a token bucket per player: limit tokens, refilled over window.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   float64
+	perSec  float64
+	buckets map[int64]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if window <= 0 {
+		window = time.Second
+	}
+	return &rateLimiter{limit: float64(limit), perSec: float64(limit) / window.Seconds(), buckets: make(map[int64]*bucket)}
+}
+
+func (rl *rateLimiter) allow(playerID int64, now time.Time) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b, ok := rl.buckets[playerID]
+	if !ok {
+		b = &bucket{tokens: rl.limit, last: now}
+		rl.buckets[playerID] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rl.perSec
+	if b.tokens > rl.limit {
+		b.tokens = rl.limit
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (rl *rateLimiter) forget(playerID int64) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	delete(rl.buckets, playerID)
+}
+
+func forget(playerID int64) {
+	if limiter != nil {
+		limiter.forget(playerID)
+	}
+	namesMu.Lock()
+	delete(names, playerID)
+	namesMu.Unlock()
+}
+
+// -- Messages --
+
+// prepareBody validates and filters a message body.
+func prepareBody(body string) (string, bool, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", false, fmt.Errorf("message is empty")
+	}
+	if config.MaxLength > 0 && utf8.RuneCountInString(body) > config.MaxLength {
+		return "", false, fmt.Errorf("message is longer than %d characters", config.MaxLength)
+	}
+	cleaned, filtered := clean(body)
+	return cleaned, filtered, nil
+}
+
+// checkMuted returns an error if the sender has a chat (or account-wide) ban.
+func checkMuted(senderID int64) error {
+	ban, err := bans.Active(senderID, models.BanScopeChat)
+	if err != nil {
+		return fmt.Errorf("failed to check bans")
+	}
+	if ban != nil {
+		if ban.ExpiresAt != nil {
+			return fmt.Errorf("you are muted until %s", ban.ExpiresAt.Format(time.RFC3339))
+		}
+		return fmt.Errorf("you are muted")
+	}
+	return nil
+}
+
+func senderName(playerID int64) string {
+	namesMu.Lock()
+	name, ok := names[playerID]
+	namesMu.Unlock()
+	if ok {
+		return name
+	}
+	if p, err := database.GetPlayerByID(database.DBConn, playerID); err == nil && p != nil {
+		name = p.Name
+		namesMu.Lock()
+		names[playerID] = name
+		namesMu.Unlock()
+	}
+	return name
+}
+
+// SendDirect stores and delivers a direct message. Who may message whom follows the
+// recipient's privacy settings and both players' block lists.
+func SendDirect(senderID, recipientID int64, body string) (*models.ChatMessage, error) {
+	if senderID == recipientID {
+		return nil, fmt.Errorf("cannot message yourself")
+	}
+	if err := checkMuted(senderID); err != nil {
+		return nil, err
+	}
+	if err := database.CheckDirectMessage(database.DBConn, senderID, recipientID); err != nil {
+		return nil, err
+	}
+	body, filtered, err := prepareBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &models.ChatMessage{SenderID: senderID, RecipientID: &recipientID, Body: body, Filtered: filtered, SenderName: senderName(senderID)}
+	online := ws_player.GlobalPlayerWS.IsPlayerOnline(recipientID)
+	if online {
+		now := time.Now().UTC()
+		m.DeliveredAt = &now
+	}
+	id, err := database.CreateChatMessage(database.DBConn, m)
+	if err != nil {
+		log.Printf("Chat: failed to store message from %d: %v", senderID, err)
+		return nil, fmt.Errorf("failed to send message")
+	}
+	m.ID = id
+
+	if online {
+		ws_player.GlobalPlayerWS.SendMessage(recipientID, ws_player.NewMessage(MsgMessage, m))
+	}
+	return m, nil
+}
+
+// SendToChannel stores a message and delivers it to the channel's online members.
+func SendToChannel(senderID, channelID int64, body string) (*models.ChatMessage, error) {
+	ids, err := channelMembers(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load channel")
+	}
+	if !contains(ids, senderID) {
+		return nil, fmt.Errorf("not a member of this channel")
+	}
+	if err := checkMuted(senderID); err != nil {
+		return nil, err
+	}
+	body, filtered, err := prepareBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &models.ChatMessage{SenderID: senderID, ChannelID: &channelID, Body: body, Filtered: filtered, SenderName: senderName(senderID)}
+	id, err := database.CreateChatMessage(database.DBConn, m)
+	if err != nil {
+		log.Printf("Chat: failed to store channel message from %d: %v", senderID, err)
+		return nil, fmt.Errorf("failed to send message")
+	}
+	m.ID = id
+
+	// Members who blocked the sender stay in the channel but do not see their messages
+	blockers, err := database.GetBlockersOf(database.DBConn, senderID)
+	if err != nil {
+		log.Printf("Chat: failed to load blockers of %d: %v", senderID, err)
+	}
+	msg := ws_player.NewMessage(MsgMessage, m)
+	for _, id := range ids {
+		if id != senderID && !contains(blockers, id) {
+			ws_player.GlobalPlayerWS.SendMessage(id, msg)
+		}
+	}
+	return m, nil
+}
+
+func handleSend(c *ws_player.PlayerConnection, raw json.RawMessage) {
+	var req struct {
+		To        int64  `json:"to"`
+		ChannelID int64  `json:"channel_id"`
+		Body      string `json:"body"`
+		Ref       string `json:"ref"` // Echoed in CHAT_SENT so the client can match its pending message
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || (req.To == 0) == (req.ChannelID == 0) {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "chat message needs either to or channel_id")
+		return
+	}
+	if database.DBConn == nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "chat is unavailable")
+		return
+	}
+	if !limiter.allow(c.PlayerID, time.Now()) {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "You are sending messages too fast")
+		return
+	}
+
+	var m *models.ChatMessage
+	var err error
+	if req.To != 0 {
+		m, err = SendDirect(c.PlayerID, req.To, req.Body)
+	} else {
+		m, err = SendToChannel(c.PlayerID, req.ChannelID, req.Body)
+	}
+	if err != nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "Message not sent: "+err.Error())
+		return
+	}
+	ws_player.GlobalPlayerWS.SendMessage(c.PlayerID, ws_player.NewMessage(MsgSent, map[string]interface{}{
+		"ref":     req.Ref,
+		"message": m,
+	}))
+}
+
+// handleRead records a read receipt: for direct messages every message from "from" up to
+// message_id, for channels the member's read marker.
+func handleRead(c *ws_player.PlayerConnection, raw json.RawMessage) {
+	var req struct {
+		From      int64 `json:"from"`
+		ChannelID int64 `json:"channel_id"`
+		MessageID int64 `json:"message_id"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || req.MessageID <= 0 || database.DBConn == nil {
+		return
+	}
+
+	receipt := ws_player.NewMessage(MsgReadReceipt, map[string]interface{}{
+		"reader_id":  c.PlayerID,
+		"from":       req.From,
+		"channel_id": req.ChannelID,
+		"message_id": req.MessageID,
+	})
+	if req.ChannelID != 0 {
+		advanced, err := database.SetChatChannelRead(database.DBConn, req.ChannelID, c.PlayerID, req.MessageID)
+		if err != nil || !advanced {
+			return
+		}
+		ids, _ := channelMembers(req.ChannelID)
+		for _, id := range ids {
+			if id != c.PlayerID {
+				ws_player.GlobalPlayerWS.SendMessage(id, receipt)
+			}
+		}
+		return
+	}
+	if req.From != 0 {
+		n, err := database.MarkDirectMessagesRead(database.DBConn, c.PlayerID, req.From, req.MessageID)
+		if err == nil && n > 0 {
+			ws_player.GlobalPlayerWS.SendMessage(req.From, receipt)
+		}
+	}
+}
+
+func handleHistory(c *ws_player.PlayerConnection, raw json.RawMessage) {
+	var req struct {
+		With      int64 `json:"with"`
+		ChannelID int64 `json:"channel_id"`
+		BeforeID  int64 `json:"before_id"`
+		Limit     int   `json:"limit"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || (req.With == 0) == (req.ChannelID == 0) {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "chat history needs either with or channel_id")
+		return
+	}
+	if database.DBConn == nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "chat is unavailable")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = historyLimit
+	} else if req.Limit > maxHistoryLimit {
+		req.Limit = maxHistoryLimit
+	}
+
+	var msgs []models.ChatMessage
+	var err error
+	if req.With != 0 {
+		msgs, err = database.ListDirectMessages(database.DBConn, c.PlayerID, req.With, req.BeforeID, req.Limit)
+	} else {
+		ids, lerr := channelMembers(req.ChannelID)
+		if lerr != nil || !contains(ids, c.PlayerID) {
+			ws_player.GlobalPlayerWS.SendError(c.PlayerID, "not a member of this channel")
+			return
+		}
+		msgs, err = database.ListChannelMessages(database.DBConn, req.ChannelID, c.PlayerID, req.BeforeID, req.Limit)
+	}
+	if err != nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "failed to load chat history")
+		return
+	}
+	ws_player.GlobalPlayerWS.SendMessage(c.PlayerID, ws_player.NewMessage(MsgHistory, map[string]interface{}{
+		"with":       req.With,
+		"channel_id": req.ChannelID,
+		"messages":   msgs,
+	}))
+}
+
+// deliverInbox sends the direct messages received while offline and the unread counts
+// of the player's channels.
+func deliverInbox(playerID int64) {
+	if database.DBConn == nil {
+		return
+	}
+	msgs, err := database.GetUndeliveredDirectMessages(database.DBConn, playerID, inboxLimit)
+	if err != nil {
+		log.Printf("Chat: failed to load inbox of %d: %v", playerID, err)
+		return
+	}
+	channels, err := database.ListPlayerChatChannels(database.DBConn, playerID)
+	if err != nil {
+		log.Printf("Chat: failed to load channels of %d: %v", playerID, err)
+		channels = []models.ChatChannel{}
+	}
+	ws_player.GlobalPlayerWS.SendMessage(playerID, ws_player.NewMessage(MsgInbox, map[string]interface{}{
+		"messages": msgs,
+		"channels": channels,
+	}))
+	if len(msgs) > 0 {
+		if err := database.MarkDirectMessagesDelivered(database.DBConn, playerID, msgs[len(msgs)-1].ID); err != nil {
+			log.Printf("Chat: failed to mark inbox of %d delivered: %v", playerID, err)
+		}
+	}
+}
+
+// -- Channels --
+
+func channelMembers(channelID int64) ([]int64, error) {
+	membersMu.Lock()
+	ids, ok := members[channelID]
+	membersMu.Unlock()
+	if ok {
+		return ids, nil
+	}
+	ids, err := database.GetChatChannelMembers(database.DBConn, channelID)
+	if err != nil {
+		return nil, err
+	}
+	membersMu.Lock()
+	members[channelID] = ids
+	membersMu.Unlock()
+	return ids, nil
+}
+
+func invalidateMembers(channelID int64) {
+	membersMu.Lock()
+	delete(members, channelID)
+	membersMu.Unlock()
+}
+
+func contains(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func notifyChannel(channelID int64) {
+	ch, err := database.GetChatChannel(database.DBConn, channelID)
+	if err != nil || ch == nil {
+		return
+	}
+	msg := ws_player.NewMessage(MsgChannel, ch)
+	for _, id := range ch.Members {
+		ws_player.GlobalPlayerWS.SendMessage(id, msg)
+	}
+}
+
+// CreateChannel creates a channel and tells its members about it. Used by the WS handler
+// for group chats and by parties.
+func CreateChannel(kind, name string, ownerID int64, memberIDs []int64) (*models.ChatChannel, error) {
+	ch, err := database.CreateChatChannel(database.DBConn, kind, name, ownerID, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	msg := ws_player.NewMessage(MsgChannel, ch)
+	for _, id := range ch.Members {
+		ws_player.GlobalPlayerWS.SendMessage(id, msg)
+	}
+	return ch, nil
+}
+
+// AddMember adds a player to a channel and sends every member the updated channel.
+func AddMember(channelID, playerID int64) error {
+	if err := database.AddChatChannelMember(database.DBConn, channelID, playerID); err != nil {
+		return err
+	}
+	invalidateMembers(channelID)
+	notifyChannel(channelID)
+	return nil
+}
+
+// RemoveMember removes a player from a channel. If the owner leaves, the longest-standing
+// member takes over; an empty channel is deleted.
+func RemoveMember(channelID, playerID int64) error {
+	ch, err := database.GetChatChannel(database.DBConn, channelID)
+	if err != nil {
+		return err
+	}
+	if ch == nil {
+		return nil
+	}
+	if err := database.RemoveChatChannelMember(database.DBConn, channelID, playerID); err != nil {
+		return err
+	}
+	invalidateMembers(channelID)
+
+	remaining := make([]int64, 0, len(ch.Members))
+	for _, id := range ch.Members {
+		if id != playerID {
+			remaining = append(remaining, id)
+		}
+	}
+	if len(remaining) == 0 {
+		return CloseChannel(channelID)
+	}
+	if ch.OwnerID == playerID {
+		if err := database.SetChatChannelOwner(database.DBConn, channelID, remaining[0]); err != nil {
+			return err
+		}
+	}
+
+	left := ws_player.NewMessage(MsgChannelLeft, map[string]int64{"channel_id": channelID, "player_id": playerID})
+	ws_player.GlobalPlayerWS.SendMessage(playerID, left)
+	for _, id := range remaining {
+		ws_player.GlobalPlayerWS.SendMessage(id, left)
+	}
+	return nil
+}
+
+// CloseChannel closes a channel for its members; its messages are kept for moderation.
+func CloseChannel(channelID int64) error {
+	ids, _ := channelMembers(channelID)
+	if err := database.CloseChatChannel(database.DBConn, channelID); err != nil {
+		return err
+	}
+	invalidateMembers(channelID)
+	for _, id := range ids {
+		ws_player.GlobalPlayerWS.SendMessage(id, ws_player.NewMessage(MsgChannelLeft, map[string]int64{"channel_id": channelID, "player_id": id}))
+	}
+	return nil
+}
+
+func handleChannels(c *ws_player.PlayerConnection, _ json.RawMessage) {
+	if database.DBConn == nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "chat is unavailable")
+		return
+	}
+	channels, err := database.ListPlayerChatChannels(database.DBConn, c.PlayerID)
+	if err != nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "failed to load channels")
+		return
+	}
+	ws_player.GlobalPlayerWS.SendMessage(c.PlayerID, ws_player.NewMessage(MsgChannels, map[string]interface{}{"channels": channels}))
+}
+
+func handleChannelCreate(c *ws_player.PlayerConnection, raw json.RawMessage) {
+	var req struct {
+		Name    string  `json:"name"`
+		Members []int64 `json:"members"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "invalid channel request")
+		return
+	}
+	if database.DBConn == nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "chat is unavailable")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if utf8.RuneCountInString(req.Name) > maxChannelNameLen {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, fmt.Sprintf("channel name is longer than %d characters", maxChannelNameLen))
+		return
+	}
+	if config.MaxChannelMembers > 0 && len(req.Members)+1 > config.MaxChannelMembers {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, fmt.Sprintf("channels are limited to %d members", config.MaxChannelMembers))
+		return
+	}
+	for _, id := range req.Members {
+		if ok, err := database.AreFriends(database.DBConn, c.PlayerID, id); err != nil || !ok {
+			ws_player.GlobalPlayerWS.SendError(c.PlayerID, fmt.Sprintf("player %d is not your friend", id))
+			return
+		}
+	}
+	req.Name, _ = clean(req.Name)
+
+	if _, err := CreateChannel(KindGroup, req.Name, c.PlayerID, req.Members); err != nil {
+		log.Printf("Chat: failed to create channel for %d: %v", c.PlayerID, err)
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "failed to create channel")
+	}
+}
+
+func handleChannelInvite(c *ws_player.PlayerConnection, raw json.RawMessage) {
+	var req struct {
+		ChannelID int64 `json:"channel_id"`
+		PlayerID  int64 `json:"player_id"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || database.DBConn == nil {
+		return
+	}
+	ch, err := database.GetChatChannel(database.DBConn, req.ChannelID)
+	if err != nil || ch == nil || !contains(ch.Members, c.PlayerID) {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "not a member of this channel")
+		return
+	}
+	if ch.Kind != KindGroup {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "members of this channel are managed by its "+ch.Kind)
+		return
+	}
+	if ch.OwnerID != c.PlayerID {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "only the channel owner can invite")
+		return
+	}
+	if contains(ch.Members, req.PlayerID) {
+		return
+	}
+	if config.MaxChannelMembers > 0 && len(ch.Members) >= config.MaxChannelMembers {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "channel is full")
+		return
+	}
+	if ok, err := database.AreFriends(database.DBConn, c.PlayerID, req.PlayerID); err != nil || !ok {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "you can only invite friends")
+		return
+	}
+	if err := AddMember(req.ChannelID, req.PlayerID); err != nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "failed to add member")
+	}
+}
+
+func handleChannelLeave(c *ws_player.PlayerConnection, raw json.RawMessage) {
+	var req struct {
+		ChannelID int64 `json:"channel_id"`
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || database.DBConn == nil {
+		return
+	}
+	ch, err := database.GetChatChannel(database.DBConn, req.ChannelID)
+	if err != nil || ch == nil || !contains(ch.Members, c.PlayerID) {
+		return
+	}
+	if ch.Kind != KindGroup {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "leave the "+ch.Kind+" to leave its chat")
+		return
+	}
+	if err := RemoveMember(req.ChannelID, c.PlayerID); err != nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "failed to leave channel")
+	}
+}
+
+// handlePartyChange keeps each party's chat channel in step with its members. The
+// channel is closed with the party; its messages are kept for moderation.
+func handlePartyChange(ev ws_player.PartyEvent) {
+	if database.DBConn == nil {
+		return
+	}
+	partyMu.Lock()
+	defer partyMu.Unlock()
+
+	channelID, ok := partyChannels[ev.Party.ID]
+	var err error
+	switch ev.Type {
+	case ws_player.PartyCreated:
+		var ch *models.ChatChannel
+		if ch, err = CreateChannel(KindParty, "Party", ev.Party.LeaderID, nil); err == nil {
+			partyChannels[ev.Party.ID] = ch.ID
+		}
+	case ws_player.PartyJoined:
+		if ok {
+			err = AddMember(channelID, ev.PlayerID)
+		}
+	case ws_player.PartyLeft:
+		if ok {
+			err = RemoveMember(channelID, ev.PlayerID)
+		}
+	case ws_player.PartyPromoted:
+		if ok {
+			err = database.SetChatChannelOwner(database.DBConn, channelID, ev.PlayerID)
+		}
+	case ws_player.PartyDisbanded:
+		if ok {
+			delete(partyChannels, ev.Party.ID)
+			err = CloseChannel(channelID)
+		}
+	}
+	if err != nil {
+		log.Printf("Chat: failed to update chat of party %s (%s): %v", ev.Party.ID, ev.Type, err)
+	}
+}
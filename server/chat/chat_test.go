@@ -0,0 +1,87 @@
+package chat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWordFilter(t *testing.T) {
+	f := NewWordFilter([]string{"Darn", " heck "})
+	cases := []struct {
+		in, want string
+		changed  bool
+	}{
+		{"well darn it", "well **** it", true},
+		{"HECK, DARN!", "****, ****!", true},
+		{"darning the socks", "darning the socks", false}, // Whole words only
+		{"héllo darn", "héllo ****", true},
+		{"", "", false},
+	}
+	for _, tc := range cases {
+		got, changed := f.Clean(tc.in)
+		if got != tc.want || changed != tc.changed {
+			t.Errorf("Clean(%q) = %q, %v; want %q, %v", tc.in, got, changed, tc.want, tc.changed)
+		}
+	}
+}
+
+func TestLoadWordFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("# comment\nfoo\n\nbar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadWordFilter("baz, ", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Len() != 3 {
+		t.Fatalf("expected 3 words, got %d", f.Len())
+	}
+	if _, err := LoadWordFilter("", filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+}
+
+func TestPrepareBodyUsesFilter(t *testing.T) {
+	config = Config{MaxLength: 10}
+	SetFilter(NewWordFilter([]string{"bad"}))
+	defer SetFilter(nil)
+
+	if body, filtered, err := prepareBody("  bad day "); err != nil || body != "*** day" || !filtered {
+		t.Fatalf("got %q %v %v", body, filtered, err)
+	}
+	if _, _, err := prepareBody("   "); err == nil {
+		t.Fatal("expected error for empty message")
+	}
+	if _, _, err := prepareBody("this is too long"); err == nil {
+		t.Fatal("expected error for long message")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	rl := newRateLimiter(3, 3*time.Second)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !rl.allow(1, now) {
+			t.Fatalf("message %d should be allowed by the burst", i+1)
+		}
+	}
+	if rl.allow(1, now) {
+		t.Fatal("fourth message in the same instant should be limited")
+	}
+	if !rl.allow(2, now) {
+		t.Fatal("limits are per player")
+	}
+	if !rl.allow(1, now.Add(time.Second)) {
+		t.Fatal("one token should refill after a second")
+	}
+	if rl.allow(1, now.Add(time.Second)) {
+		t.Fatal("only one token refilled")
+	}
+	rl.forget(1)
+	if !rl.allow(1, now.Add(time.Second)) {
+		t.Fatal("forgotten players start with a full bucket")
+	}
+}
@@ -0,0 +1,119 @@
+package chat
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Filter cleans message bodies before they are stored and delivered. Implementations
+// must be safe for concurrent use.
+type Filter interface {
+	// Clean returns the text to deliver and whether it differs from the input.
+	Clean(text string) (string, bool)
+}
+
+var (
+	filterMu     sync.RWMutex
+	activeFilter Filter
+)
+
+// SetFilter replaces the message filter; nil disables filtering.
+func SetFilter(f Filter) {
+	filterMu.Lock()
+	defer filterMu.Unlock()
+	activeFilter = f
+}
+
+func clean(text string) (string, bool) {
+	filterMu.RLock()
+	f := activeFilter
+	filterMu.RUnlock()
+	if f == nil {
+		return text, false
+	}
+	return f.Clean(text)
+}
+
+// WordFilter masks listed words with asterisks. Matching is case-insensitive and on
+// whole words, so "class" is not hit by "ass".
+type WordFilter struct {
+	words map[string]bool
+}
+
+// NewWordFilter creates a filter for the given words.
+func NewWordFilter(words []string) *WordFilter {
+	f := &WordFilter{words: make(map[string]bool)}
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			f.words[w] = true
+		}
+	}
+	return f
+}
+
+// Len returns the number of words in the filter.
+func (f *WordFilter) Len() int {
+	return len(f.words)
+}
+
+// Clean implements Filter.
+func (f *WordFilter) Clean(text string) (string, bool) {
+	if len(f.words) == 0 {
+		return text, false
+	}
+	runes := []rune(text)
+	changed := false
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		if f.words[strings.ToLower(string(runes[start:end]))] {
+			for i := start; i < end; i++ {
+				runes[i] = '*'
+			}
+			changed = true
+		}
+		start = end
+	}
+	if !changed {
+		return text, false
+	}
+	return string(runes), true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// LoadWordFilter builds a WordFilter from a comma separated list and/or a file with one
+// word per line ('#' starts a comment).
+func LoadWordFilter(list, path string) (*WordFilter, error) {
+	words := strings.Split(list, ",")
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = file.Close() }()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			words = append(words, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return NewWordFilter(words), nil
+}
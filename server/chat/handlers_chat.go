@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Chat Moderation Handlers --
+
+// SearchMessagesHandler searches chat messages. Query: player_id (sender, or one side
+// with other_id), other_id, q (text), from/to (RFC 3339), limit.
+func SearchMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	q := r.URL.Query()
+	s := models.ChatSearch{Query: q.Get("q")}
+	s.PlayerID, _ = strconv.ParseInt(q.Get("player_id"), 10, 64)
+	s.OtherID, _ = strconv.ParseInt(q.Get("other_id"), 10, 64)
+	s.Limit, _ = strconv.Atoi(q.Get("limit"))
+	for key, dst := range map[string]*time.Time{"from": &s.From, "to": &s.To} {
+		if v := q.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				utils.WriteError(w, r, http.StatusBadRequest, "invalid "+key+" (expected RFC 3339)")
+				return
+			}
+			*dst = t
+		}
+	}
+	if s.PlayerID == 0 && s.Query == "" {
+		utils.WriteError(w, r, http.StatusBadRequest, "player_id or q is required")
+		return
+	}
+
+	msgs, err := database.SearchChatMessages(database.DBConn, s)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to search messages")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, msgs)
+}
+
+// ReportMessagesHandler returns the chat context of a report: the conversation between
+// reporter and reported player, and everything the reported player sent, within a
+// window (default 24h, query "window") around the report. "q" narrows both by text.
+func ReportMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	window := 24 * time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		if window, err = time.ParseDuration(v); err != nil || window <= 0 {
+			utils.WriteError(w, r, http.StatusBadRequest, "invalid window")
+			return
+		}
+	}
+
+	report, err := database.GetReportByID(database.DBConn, int64(id))
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if report == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "report not found")
+		return
+	}
+
+	base := models.ChatSearch{
+		PlayerID: report.ReportedUserID,
+		Query:    r.URL.Query().Get("q"),
+		From:     report.Timestamp.Add(-window),
+		To:       report.Timestamp.Add(window),
+		Limit:    500,
+	}
+	conversation := base
+	conversation.OtherID = report.ReporterID
+
+	between, err := database.SearchChatMessages(database.DBConn, conversation)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to search messages")
+		return
+	}
+	sent, err := database.SearchChatMessages(database.DBConn, base)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to search messages")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"report":            report,
+		"conversation":      between,
+		"reported_messages": sent,
+	})
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// initAnticheatTables creates the per-player cheat score, policy and review tables in
+// the 'player_system' schema. The events themselves stay in redeye_anticheat_events.
+func initAnticheatTables(db *sqlx.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS player_system.cheat_scores (
+			player_id BIGINT PRIMARY KEY REFERENCES player_system.players(id) ON DELETE CASCADE,
+			score DOUBLE PRECISION NOT NULL DEFAULT 0,
+			events INTEGER NOT NULL DEFAULT 0,
+			shadow_queued BOOLEAN NOT NULL DEFAULT FALSE,
+			last_event_at TIMESTAMP WITH TIME ZONE,
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS player_system.cheat_policies (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			event_type TEXT NOT NULL DEFAULT '',
+			min_score DOUBLE PRECISION NOT NULL,
+			action TEXT NOT NULL,
+			ban_duration BIGINT NOT NULL DEFAULT 0,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS player_system.cheat_reviews (
+			id BIGSERIAL PRIMARY KEY,
+			player_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			policy_id BIGINT REFERENCES player_system.cheat_policies(id) ON DELETE SET NULL,
+			event_id BIGINT,
+			action TEXT NOT NULL,
+			score DOUBLE PRECISION NOT NULL DEFAULT 0,
+			ban_id BIGINT REFERENCES player_system.bans(id) ON DELETE SET NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			reviewed_by TEXT NOT NULL DEFAULT '',
+			review_note TEXT NOT NULL DEFAULT '',
+			reviewed_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_cheat_reviews_status ON player_system.cheat_reviews(status, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_cheat_reviews_player ON player_system.cheat_reviews(player_id, created_at DESC)`,
+		// Starting policies; only added while no policy exists so deleting them sticks
+		`INSERT INTO player_system.cheat_policies (name, min_score, action, ban_duration)
+			SELECT v.name, v.min_score, v.action, v.ban_duration FROM (VALUES
+				('Flag for review', 50::DOUBLE PRECISION, 'flag', 0::BIGINT),
+				('Shadow queue', 150, 'shadow_queue', 0),
+				('Week ban', 300, 'ban', 604800)
+			) AS v(name, min_score, action, ban_duration)
+			WHERE NOT EXISTS (SELECT 1 FROM player_system.cheat_policies)`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("create anticheat tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// -- Cheat Scores --
+
+// AddCheatScore decays a player's score to now and adds delta to it. halfLife is in seconds.
+func AddCheatScore(db *sqlx.DB, playerID int64, delta, halfLife float64) (*models.CheatScore, error) {
+	var s models.CheatScore
+	query := `INSERT INTO player_system.cheat_scores AS c (player_id, score, events, last_event_at, updated_at)
+		VALUES ($1, $2, 1, NOW(), NOW())
+		ON CONFLICT (player_id) DO UPDATE SET
+			score = c.score * POWER(0.5, EXTRACT(EPOCH FROM (NOW() - c.updated_at)) / $3) + $2,
+			events = c.events + 1,
+			last_event_at = NOW(),
+			updated_at = NOW()
+		RETURNING c.*`
+	if err := db.Get(&s, query, playerID, delta, halfLife); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetCheatScore returns a player's stored score, or nil if they never had an event.
+func GetCheatScore(db *sqlx.DB, playerID int64) (*models.CheatScore, error) {
+	var s models.CheatScore
+	query := `SELECT c.*, p.name FROM player_system.cheat_scores c
+		JOIN player_system.players p ON p.id = c.player_id WHERE c.player_id = $1`
+	if err := db.Get(&s, query, playerID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListCheatScores returns the highest scores decayed to now, plus every shadow-queued
+// player. halfLife is in seconds.
+func ListCheatScores(db *sqlx.DB, halfLife float64, limit int) ([]models.CheatScore, error) {
+	scores := []models.CheatScore{}
+	query := `SELECT c.player_id, c.events, c.shadow_queued, c.last_event_at, NOW() as updated_at, p.name,
+			c.score * POWER(0.5, EXTRACT(EPOCH FROM (NOW() - c.updated_at)) / $1) as score
+		FROM player_system.cheat_scores c
+		JOIN player_system.players p ON p.id = c.player_id
+		ORDER BY c.shadow_queued DESC, score DESC LIMIT $2`
+	err := db.Select(&scores, query, halfLife, limit)
+	return scores, err
+}
+
+func SetShadowQueued(db *sqlx.DB, playerID int64, shadow bool) error {
+	_, err := db.Exec(`INSERT INTO player_system.cheat_scores (player_id, shadow_queued) VALUES ($1, $2)
+		ON CONFLICT (player_id) DO UPDATE SET shadow_queued = EXCLUDED.shadow_queued`, playerID, shadow)
+	return err
+}
+
+func IsShadowQueued(db *sqlx.DB, playerID int64) (bool, error) {
+	var shadow bool
+	err := db.Get(&shadow, `SELECT shadow_queued FROM player_system.cheat_scores WHERE player_id = $1`, playerID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return shadow, err
+}
+
+// -- Cheat Policies --
+
+func ListCheatPolicies(db *sqlx.DB) ([]models.CheatPolicy, error) {
+	policies := []models.CheatPolicy{}
+	err := db.Select(&policies, `SELECT * FROM player_system.cheat_policies ORDER BY min_score, id`)
+	return policies, err
+}
+
+func GetCheatPolicy(db *sqlx.DB, id int64) (*models.CheatPolicy, error) {
+	var p models.CheatPolicy
+	if err := db.Get(&p, `SELECT * FROM player_system.cheat_policies WHERE id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func CreateCheatPolicy(db *sqlx.DB, p *models.CheatPolicy) (int64, error) {
+	p.CreatedAt = time.Now().UTC()
+	var id int64
+	query := `INSERT INTO player_system.cheat_policies (name, event_type, min_score, action, ban_duration, enabled, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err := db.QueryRow(query, p.Name, p.EventType, p.MinScore, p.Action, p.BanDuration, p.Enabled, p.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func UpdateCheatPolicy(db *sqlx.DB, p *models.CheatPolicy) error {
+	res, err := db.Exec(`UPDATE player_system.cheat_policies
+		SET name = $2, event_type = $3, min_score = $4, action = $5, ban_duration = $6, enabled = $7
+		WHERE id = $1`, p.ID, p.Name, p.EventType, p.MinScore, p.Action, p.BanDuration, p.Enabled)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func DeleteCheatPolicy(db *sqlx.DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM player_system.cheat_policies WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// -- Cheat Reviews --
+
+const cheatReviewSelect = `SELECT r.*, p.name as player_name, COALESCE(cp.name, '') as policy_name
+	FROM player_system.cheat_reviews r
+	JOIN player_system.players p ON p.id = r.player_id
+	LEFT JOIN player_system.cheat_policies cp ON cp.id = r.policy_id`
+
+func CreateCheatReview(db *sqlx.DB, r *models.CheatReview) (int64, error) {
+	r.CreatedAt = time.Now().UTC()
+	if r.Status == "" {
+		r.Status = models.CheatReviewPending
+	}
+	var id int64
+	query := `INSERT INTO player_system.cheat_reviews (player_id, policy_id, event_id, action, score, ban_id, status, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+	err := db.QueryRow(query, r.PlayerID, r.PolicyID, r.EventID, r.Action, r.Score, r.BanID, r.Status, r.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func GetCheatReview(db *sqlx.DB, id int64) (*models.CheatReview, error) {
+	var r models.CheatReview
+	if err := db.Get(&r, cheatReviewSelect+` WHERE r.id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListCheatReviews returns the review queue, newest first. An empty status lists all.
+func ListCheatReviews(db *sqlx.DB, status string, limit int) ([]models.CheatReview, error) {
+	reviews := []models.CheatReview{}
+	query := cheatReviewSelect + ` WHERE ($1 = '' OR r.status = $1) ORDER BY r.created_at DESC LIMIT $2`
+	err := db.Select(&reviews, query, status, limit)
+	return reviews, err
+}
+
+// ListPlayerCheatReviews returns a player's reviews, newest first.
+func ListPlayerCheatReviews(db *sqlx.DB, playerID int64) ([]models.CheatReview, error) {
+	reviews := []models.CheatReview{}
+	err := db.Select(&reviews, cheatReviewSelect+` WHERE r.player_id = $1 ORDER BY r.created_at DESC`, playerID)
+	return reviews, err
+}
+
+// HasPendingCheatReview reports whether a policy already fired for a player and is
+// waiting for review.
+func HasPendingCheatReview(db *sqlx.DB, playerID, policyID int64) (bool, error) {
+	var exists bool
+	err := db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM player_system.cheat_reviews
+		WHERE player_id = $1 AND policy_id = $2 AND status = 'pending')`, playerID, policyID)
+	return exists, err
+}
+
+// ResolveCheatReview closes a pending review. Closing one that is not pending is an error.
+func ResolveCheatReview(db *sqlx.DB, id int64, status, by, note string) error {
+	res, err := db.Exec(`UPDATE player_system.cheat_reviews
+		SET status = $2, reviewed_by = $3, review_note = $4, reviewed_at = NOW()
+		WHERE id = $1 AND status = 'pending'`, id, status, by, note)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetPendingCheatReviewByBan returns the pending review of the anticheat action that
+// issued a ban, or nil.
+func GetPendingCheatReviewByBan(db *sqlx.DB, banID int64) (*models.CheatReview, error) {
+	var reviews []models.CheatReview
+	if err := db.Select(&reviews, cheatReviewSelect+` WHERE r.ban_id = $1 AND r.status = 'pending' LIMIT 1`, banID); err != nil {
+		return nil, err
+	}
+	if len(reviews) == 0 {
+		return nil, nil
+	}
+	return &reviews[0], nil
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// initAppealTables creates the ban appeal and appeal audit tables in the 'player_system' schema.
+func initAppealTables(db *sqlx.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS player_system.appeals (
+			id BIGSERIAL PRIMARY KEY,
+			ban_id BIGINT NOT NULL UNIQUE REFERENCES player_system.bans(id) ON DELETE CASCADE,
+			player_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			message TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			decided_by TEXT NOT NULL DEFAULT '',
+			decision_note TEXT NOT NULL DEFAULT '',
+			decided_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_appeals_status ON player_system.appeals(status, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_appeals_player ON player_system.appeals(player_id)`,
+		`CREATE TABLE IF NOT EXISTS player_system.appeal_audit (
+			id BIGSERIAL PRIMARY KEY,
+			appeal_id BIGINT NOT NULL REFERENCES player_system.appeals(id) ON DELETE CASCADE,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_appeal_audit_appeal ON player_system.appeal_audit(appeal_id, id)`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("create appeal tables: %w", err)
+		}
+	}
+	return nil
+}
+
+const appealSelect = `SELECT a.*, p.name as player_name FROM player_system.appeals a
+	JOIN player_system.players p ON p.id = a.player_id`
+
+// CreateAppeal stores an appeal and its "submitted" audit entry. A second appeal for
+// the same ban fails on the unique ban_id.
+func CreateAppeal(db *sqlx.DB, a *models.Appeal, actor string) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	a.CreatedAt = time.Now().UTC()
+	a.Status = models.AppealPending
+	var id int64
+	err = tx.QueryRow(`INSERT INTO player_system.appeals (ban_id, player_id, message, status, created_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`, a.BanID, a.PlayerID, a.Message, a.Status, a.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`INSERT INTO player_system.appeal_audit (appeal_id, actor, action, created_at)
+		VALUES ($1, $2, 'submitted', $3)`, id, actor, a.CreatedAt); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func GetAppeal(db *sqlx.DB, id int64) (*models.Appeal, error) {
+	var a models.Appeal
+	if err := db.Get(&a, appealSelect+` WHERE a.id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+func GetAppealByBan(db *sqlx.DB, banID int64) (*models.Appeal, error) {
+	var a models.Appeal
+	if err := db.Get(&a, appealSelect+` WHERE a.ban_id = $1`, banID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAppeals returns appeals, oldest first so the queue is worked in order. An empty
+// status lists all.
+func ListAppeals(db *sqlx.DB, status string, limit int) ([]models.Appeal, error) {
+	appeals := []models.Appeal{}
+	err := db.Select(&appeals, appealSelect+` WHERE ($1 = '' OR a.status = $1) ORDER BY a.created_at LIMIT $2`, status, limit)
+	return appeals, err
+}
+
+// ListPlayerAppeals returns a player's appeals, newest first.
+func ListPlayerAppeals(db *sqlx.DB, playerID int64) ([]models.Appeal, error) {
+	appeals := []models.Appeal{}
+	err := db.Select(&appeals, appealSelect+` WHERE a.player_id = $1 ORDER BY a.created_at DESC`, playerID)
+	return appeals, err
+}
+
+// DecideAppeal records the decision on a pending appeal and its audit entry. Deciding an
+// appeal that is not pending is an error.
+func DecideAppeal(db *sqlx.DB, id int64, status, by, note string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE player_system.appeals SET status = $2, decided_by = $3, decision_note = $4, decided_at = NOW()
+		WHERE id = $1 AND status = 'pending'`, id, status, by, note)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec(`INSERT INTO player_system.appeal_audit (appeal_id, actor, action, note)
+		VALUES ($1, $2, $3, $4)`, id, by, status, note); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReopenAppeal puts a decided appeal back to pending when its decision could not be
+// carried out, noting why in the audit trail.
+func ReopenAppeal(db *sqlx.DB, id int64, by, note string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE player_system.appeals SET status = 'pending', decided_by = '', decision_note = '', decided_at = NULL
+		WHERE id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO player_system.appeal_audit (appeal_id, actor, action, note)
+		VALUES ($1, $2, 'reopened', $3)`, id, by, note); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func AddAppealAudit(db *sqlx.DB, appealID int64, actor, action, note string) error {
+	_, err := db.Exec(`INSERT INTO player_system.appeal_audit (appeal_id, actor, action, note)
+		VALUES ($1, $2, $3, $4)`, appealID, actor, action, note)
+	return err
+}
+
+func ListAppealAudit(db *sqlx.DB, appealID int64) ([]models.AppealAuditEntry, error) {
+	entries := []models.AppealAuditEntry{}
+	err := db.Select(&entries, `SELECT * FROM player_system.appeal_audit WHERE appeal_id = $1 ORDER BY id`, appealID)
+	return entries, err
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// -- Autoscale Policies --
+
+const autoscalePolicyColumns = `id, name, region, tags, min_instances, max_instances, target_idle, strategy,
+        scale_up_cooldown_seconds, scale_down_cooldown_seconds, enabled, dry_run, last_scale_up_at, last_scale_down_at, updated_at`
+
+func scanAutoscalePolicy(row rowScanner) (*models.AutoscalePolicy, error) {
+	var p models.AutoscalePolicy
+	var region, tags, strategy sql.NullString
+	var lastUp, lastDown, updated int64
+	if err := row.Scan(&p.ID, &p.Name, &region, &tags, &p.MinInstances, &p.MaxInstances, &p.TargetIdle, &strategy,
+		&p.ScaleUpCooldownSeconds, &p.ScaleDownCooldownSeconds, &p.Enabled, &p.DryRun, &lastUp, &lastDown, &updated); err != nil {
+		return nil, err
+	}
+	p.Region = region.String
+	p.Tags = tags.String
+	p.Strategy = strategy.String
+	if lastUp > 0 {
+		p.LastScaleUpAt = time.Unix(lastUp, 0).UTC()
+	}
+	if lastDown > 0 {
+		p.LastScaleDownAt = time.Unix(lastDown, 0).UTC()
+	}
+	p.UpdatedAt = time.Unix(updated, 0).UTC()
+	return &p, nil
+}
+
+// ListAutoscalePolicies returns all policies ordered by ID.
+func ListAutoscalePolicies(db *sqlx.DB) ([]models.AutoscalePolicy, error) {
+	rows, err := db.Query(`SELECT ` + autoscalePolicyColumns + ` FROM autoscale_policies ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("query autoscale policies: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]models.AutoscalePolicy, 0)
+	for rows.Next() {
+		p, err := scanAutoscalePolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan autoscale policy: %w", err)
+		}
+		out = append(out, *p)
+	}
+	return out, rows.Err()
+}
+
+// GetAutoscalePolicy returns a policy by ID, or nil if it does not exist.
+func GetAutoscalePolicy(db *sqlx.DB, id int) (*models.AutoscalePolicy, error) {
+	p, err := scanAutoscalePolicy(db.QueryRow(`SELECT `+autoscalePolicyColumns+` FROM autoscale_policies WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// SaveAutoscalePolicy inserts a new policy (ID == 0) or updates an existing one.
+func SaveAutoscalePolicy(db *sqlx.DB, p *models.AutoscalePolicy) error {
+	if p == nil {
+		return fmt.Errorf("nil autoscale policy")
+	}
+	p.UpdatedAt = time.Now().UTC()
+	var lastUp, lastDown int64
+	if !p.LastScaleUpAt.IsZero() {
+		lastUp = p.LastScaleUpAt.Unix()
+	}
+	if !p.LastScaleDownAt.IsZero() {
+		lastDown = p.LastScaleDownAt.Unix()
+	}
+
+	do := func() error {
+		if p.ID == 0 {
+			var id int
+			err := db.QueryRow(`INSERT INTO autoscale_policies (name, region, tags, min_instances, max_instances, target_idle, strategy,
+                                scale_up_cooldown_seconds, scale_down_cooldown_seconds, enabled, dry_run, last_scale_up_at, last_scale_down_at, updated_at)
+                                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`,
+				p.Name, p.Region, p.Tags, p.MinInstances, p.MaxInstances, p.TargetIdle, p.Strategy,
+				p.ScaleUpCooldownSeconds, p.ScaleDownCooldownSeconds, boolToInt(p.Enabled), boolToInt(p.DryRun), lastUp, lastDown, p.UpdatedAt.Unix()).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("insert autoscale policy: %w", err)
+			}
+			p.ID = id
+			return nil
+		}
+		_, err := db.Exec(`UPDATE autoscale_policies SET name=$1, region=$2, tags=$3, min_instances=$4, max_instances=$5, target_idle=$6,
+                        strategy=$7, scale_up_cooldown_seconds=$8, scale_down_cooldown_seconds=$9, enabled=$10, dry_run=$11,
+                        last_scale_up_at=$12, last_scale_down_at=$13, updated_at=$14 WHERE id=$15`,
+			p.Name, p.Region, p.Tags, p.MinInstances, p.MaxInstances, p.TargetIdle, p.Strategy,
+			p.ScaleUpCooldownSeconds, p.ScaleDownCooldownSeconds, boolToInt(p.Enabled), boolToInt(p.DryRun), lastUp, lastDown, p.UpdatedAt.Unix(), p.ID)
+		if err != nil {
+			return fmt.Errorf("update autoscale policy: %w", err)
+		}
+		return nil
+	}
+	return execWithRetry(do)
+}
+
+// DeleteAutoscalePolicy removes a policy and its decision history.
+func DeleteAutoscalePolicy(db *sqlx.DB, id int) error {
+	do := func() error {
+		if _, err := db.Exec(`DELETE FROM autoscale_decisions WHERE policy_id = $1`, id); err != nil {
+			return err
+		}
+		_, err := db.Exec(`DELETE FROM autoscale_policies WHERE id = $1`, id)
+		return err
+	}
+	return execWithRetry(do)
+}
+
+// -- Autoscale Decisions --
+
+// SaveAutoscaleDecision records an autoscaler decision.
+func SaveAutoscaleDecision(db *sqlx.DB, d *models.AutoscaleDecision) error {
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now().UTC()
+	}
+	do := func() error {
+		return db.QueryRow(`INSERT INTO autoscale_decisions (policy_id, action, node_id, instance_id, reason, total, idle, dry_run, error, created_at)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+			d.PolicyID, d.Action, d.NodeID, d.InstanceID, d.Reason, d.Total, d.Idle, boolToInt(d.DryRun), d.Error, d.CreatedAt.Unix()).Scan(&d.ID)
+	}
+	return execWithRetry(do)
+}
+
+// ListAutoscaleDecisions returns the newest decisions, optionally for a single policy (policyID > 0).
+func ListAutoscaleDecisions(db *sqlx.DB, policyID, limit int) ([]models.AutoscaleDecision, error) {
+	query := `SELECT id, policy_id, action, node_id, instance_id, reason, total, idle, dry_run, error, created_at FROM autoscale_decisions`
+	args := []interface{}{}
+	if policyID > 0 {
+		query += ` WHERE policy_id = $1`
+		args = append(args, policyID)
+	}
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT %d`, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query autoscale decisions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]models.AutoscaleDecision, 0)
+	for rows.Next() {
+		var d models.AutoscaleDecision
+		var instanceID, reason, errMsg sql.NullString
+		var created int64
+		if err := rows.Scan(&d.ID, &d.PolicyID, &d.Action, &d.NodeID, &instanceID, &reason, &d.Total, &d.Idle, &d.DryRun, &errMsg, &created); err != nil {
+			return nil, fmt.Errorf("scan autoscale decision: %w", err)
+		}
+		d.InstanceID = instanceID.String
+		d.Reason = reason.String
+		d.Error = errMsg.String
+		d.CreatedAt = time.Unix(created, 0).UTC()
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// initBanTables creates the ban records table in the 'player_system' schema.
+func initBanTables(db *sqlx.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS player_system.bans (
+			id BIGSERIAL PRIMARY KEY,
+			player_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			scope TEXT NOT NULL DEFAULT 'all',
+			reason TEXT NOT NULL,
+			evidence TEXT NOT NULL DEFAULT '[]',
+			issued_by TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			expires_at TIMESTAMP WITH TIME ZONE,
+			lifted_at TIMESTAMP WITH TIME ZONE,
+			lifted_by TEXT NOT NULL DEFAULT '',
+			lift_reason TEXT NOT NULL DEFAULT ''
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_bans_player ON player_system.bans(player_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_bans_active ON player_system.bans(expires_at) WHERE lifted_at IS NULL`,
+		// Players banned with the old on/off flag get a permanent record so the flag stays derived from bans
+		`INSERT INTO player_system.bans (player_id, scope, reason, issued_by)
+			SELECT p.id, 'all', 'Banned before ban records were kept', 'system' FROM player_system.players p
+			WHERE p.banned AND NOT EXISTS (SELECT 1 FROM player_system.bans b WHERE b.player_id = p.id)`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("create ban tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// activeBanCondition matches bans in force; table alias b.
+const activeBanCondition = `b.lifted_at IS NULL AND (b.expires_at IS NULL OR b.expires_at > NOW())`
+
+// fillBans decodes evidence links and computes Active.
+func fillBans(bans []models.Ban) {
+	now := time.Now()
+	for i := range bans {
+		bans[i].EvidenceLinks = []string{}
+		if bans[i].Evidence != "" {
+			_ = json.Unmarshal([]byte(bans[i].Evidence), &bans[i].EvidenceLinks)
+		}
+		bans[i].Active = bans[i].IsActive(now)
+	}
+}
+
+func CreateBan(db *sqlx.DB, b *models.Ban) (int64, error) {
+	if b.EvidenceLinks == nil {
+		b.EvidenceLinks = []string{}
+	}
+	evidence, err := json.Marshal(b.EvidenceLinks)
+	if err != nil {
+		return 0, err
+	}
+	b.Evidence = string(evidence)
+	b.CreatedAt = time.Now().UTC()
+
+	var id int64
+	query := `INSERT INTO player_system.bans (player_id, scope, reason, evidence, issued_by, created_at, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err = db.QueryRow(query, b.PlayerID, b.Scope, b.Reason, b.Evidence, b.IssuedBy, b.CreatedAt, b.ExpiresAt).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	b.ID = id
+	b.Active = b.IsActive(time.Now())
+	return id, nil
+}
+
+func GetBan(db *sqlx.DB, id int64) (*models.Ban, error) {
+	var bans []models.Ban
+	if err := db.Select(&bans, `SELECT * FROM player_system.bans WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	if len(bans) == 0 {
+		return nil, nil
+	}
+	fillBans(bans)
+	return &bans[0], nil
+}
+
+// ListPlayerBans returns a player's ban history, newest first.
+func ListPlayerBans(db *sqlx.DB, playerID int64) ([]models.Ban, error) {
+	bans := []models.Ban{}
+	if err := db.Select(&bans, `SELECT * FROM player_system.bans WHERE player_id = $1 ORDER BY created_at DESC`, playerID); err != nil {
+		return nil, err
+	}
+	fillBans(bans)
+	return bans, nil
+}
+
+// ListActiveBans returns the bans currently in force, newest first.
+func ListActiveBans(db *sqlx.DB, limit int) ([]models.Ban, error) {
+	bans := []models.Ban{}
+	query := `SELECT b.* FROM player_system.bans b WHERE ` + activeBanCondition + ` ORDER BY b.created_at DESC LIMIT $1`
+	if err := db.Select(&bans, query, limit); err != nil {
+		return nil, err
+	}
+	fillBans(bans)
+	return bans, nil
+}
+
+// GetActiveBan returns the ban in force for a player in any of the given scopes, or nil.
+// When several apply, the one lasting longest (permanent first) is returned.
+func GetActiveBan(db *sqlx.DB, playerID int64, scopes ...string) (*models.Ban, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("no ban scope given")
+	}
+	args := []interface{}{playerID}
+	placeholders := make([]string, len(scopes))
+	for i, s := range scopes {
+		args = append(args, s)
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+	}
+	query := `SELECT b.* FROM player_system.bans b
+		WHERE b.player_id = $1 AND b.scope IN (` + strings.Join(placeholders, ", ") + `) AND ` + activeBanCondition + `
+		ORDER BY b.expires_at DESC NULLS FIRST LIMIT 1`
+	var bans []models.Ban
+	if err := db.Select(&bans, query, args...); err != nil {
+		return nil, err
+	}
+	if len(bans) == 0 {
+		return nil, nil
+	}
+	fillBans(bans)
+	return &bans[0], nil
+}
+
+// LiftBan ends a ban early. Lifting an expired or already lifted ban is an error.
+func LiftBan(db *sqlx.DB, id int64, by, reason string) error {
+	res, err := db.Exec(`UPDATE player_system.bans b SET lifted_at = NOW(), lifted_by = $2, lift_reason = $3
+		WHERE b.id = $1 AND `+activeBanCondition, id, by, reason)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// LiftPlayerBans lifts every active ban of a player, or only those of one scope.
+func LiftPlayerBans(db *sqlx.DB, playerID int64, scope, by, reason string) (int64, error) {
+	query := `UPDATE player_system.bans b SET lifted_at = NOW(), lifted_by = $2, lift_reason = $3
+		WHERE b.player_id = $1 AND ($4 = '' OR b.scope = $4) AND ` + activeBanCondition
+	res, err := db.Exec(query, playerID, by, reason, scope)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SyncBannedFlags makes Player.Banned match whether the player has an active
+// account-wide ban, and returns the players whose flag changed. Only flagged players and
+// players with active bans are looked at.
+func SyncBannedFlags(db *sqlx.DB) ([]int64, error) {
+	ids := []int64{}
+	active := `SELECT b.player_id FROM player_system.bans b WHERE b.scope = 'all' AND ` + activeBanCondition
+	query := `UPDATE player_system.players p SET banned = (p.id IN (` + active + `)), updated_at = NOW()
+		WHERE (p.banned IS NOT FALSE OR p.id IN (` + active + `))
+		AND p.banned IS DISTINCT FROM (p.id IN (` + active + `))
+		RETURNING p.id`
+	err := db.Select(&ids, query)
+	return ids, err
+}
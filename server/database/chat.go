@@ -0,0 +1,318 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// initChatTables creates the chat tables in the 'player_system' schema.
+func initChatTables(db *sqlx.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS player_system.chat_channels (
+			id BIGSERIAL PRIMARY KEY,
+			kind TEXT NOT NULL DEFAULT 'group',
+			name TEXT NOT NULL DEFAULT '',
+			owner_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			deleted_at TIMESTAMP WITH TIME ZONE
+		);`,
+		`ALTER TABLE player_system.chat_channels ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE`,
+		`CREATE TABLE IF NOT EXISTS player_system.chat_channel_members (
+			channel_id BIGINT NOT NULL REFERENCES player_system.chat_channels(id) ON DELETE CASCADE,
+			player_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			last_read_id BIGINT NOT NULL DEFAULT 0,
+			joined_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (channel_id, player_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS player_system.chat_messages (
+			id BIGSERIAL PRIMARY KEY,
+			sender_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			recipient_id BIGINT REFERENCES player_system.players(id) ON DELETE CASCADE,
+			channel_id BIGINT REFERENCES player_system.chat_channels(id) ON DELETE CASCADE,
+			body TEXT NOT NULL,
+			filtered BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			delivered_at TIMESTAMP WITH TIME ZONE,
+			read_at TIMESTAMP WITH TIME ZONE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_messages_recipient ON player_system.chat_messages(recipient_id, delivered_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_messages_dm ON player_system.chat_messages(sender_id, recipient_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_messages_channel ON player_system.chat_messages(channel_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_chat_members_player ON player_system.chat_channel_members(player_id)`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("create chat tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// -- Chat Messages --
+
+const chatMessageColumns = `m.id, m.sender_id, m.recipient_id, m.channel_id, m.body, m.filtered, m.created_at, m.delivered_at, m.read_at, p.name as sender_name`
+
+func CreateChatMessage(db *sqlx.DB, m *models.ChatMessage) (int64, error) {
+	m.CreatedAt = time.Now().UTC()
+	var id int64
+	query := `INSERT INTO player_system.chat_messages (sender_id, recipient_id, channel_id, body, filtered, created_at, delivered_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err := db.QueryRow(query, m.SenderID, m.RecipientID, m.ChannelID, m.Body, m.Filtered, m.CreatedAt, m.DeliveredAt).Scan(&id)
+	return id, err
+}
+
+// notBlockedBy excludes messages from players the viewer (the given placeholder) blocked.
+func notBlockedBy(viewer string) string {
+	return `m.sender_id NOT IN (SELECT blocked_id FROM player_system.player_blocks WHERE blocker_id = ` + viewer + `)`
+}
+
+// GetUndeliveredDirectMessages returns direct messages sent to a player while they were
+// offline, oldest first. Messages from players they have since blocked are left out.
+func GetUndeliveredDirectMessages(db *sqlx.DB, recipientID int64, limit int) ([]models.ChatMessage, error) {
+	msgs := []models.ChatMessage{}
+	query := `SELECT ` + chatMessageColumns + ` FROM player_system.chat_messages m
+		JOIN player_system.players p ON p.id = m.sender_id
+		WHERE m.recipient_id = $1 AND m.delivered_at IS NULL AND ` + notBlockedBy("$1") + ` ORDER BY m.id ASC LIMIT $2`
+	err := db.Select(&msgs, query, recipientID, limit)
+	return msgs, err
+}
+
+func MarkDirectMessagesDelivered(db *sqlx.DB, recipientID, upToID int64) error {
+	_, err := db.Exec(`UPDATE player_system.chat_messages SET delivered_at = NOW()
+		WHERE recipient_id = $1 AND id <= $2 AND delivered_at IS NULL`, recipientID, upToID)
+	return err
+}
+
+// MarkDirectMessagesRead marks the messages from senderID to readerID up to upToID as read
+// and returns how many were unread.
+func MarkDirectMessagesRead(db *sqlx.DB, readerID, senderID, upToID int64) (int64, error) {
+	res, err := db.Exec(`UPDATE player_system.chat_messages SET read_at = NOW(), delivered_at = COALESCE(delivered_at, NOW())
+		WHERE recipient_id = $1 AND sender_id = $2 AND id <= $3 AND read_at IS NULL`, readerID, senderID, upToID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListDirectMessages returns the conversation between two players, newest first.
+func ListDirectMessages(db *sqlx.DB, a, b, beforeID int64, limit int) ([]models.ChatMessage, error) {
+	if beforeID <= 0 {
+		beforeID = 1<<63 - 1
+	}
+	msgs := []models.ChatMessage{}
+	query := `SELECT ` + chatMessageColumns + ` FROM player_system.chat_messages m
+		JOIN player_system.players p ON p.id = m.sender_id
+		WHERE ((m.sender_id = $1 AND m.recipient_id = $2) OR (m.sender_id = $2 AND m.recipient_id = $1)) AND m.id < $3
+		ORDER BY m.id DESC LIMIT $4`
+	err := db.Select(&msgs, query, a, b, beforeID, limit)
+	return msgs, err
+}
+
+// ListChannelMessages returns a channel's messages as seen by viewerID (without messages
+// from players they blocked), newest first.
+func ListChannelMessages(db *sqlx.DB, channelID, viewerID, beforeID int64, limit int) ([]models.ChatMessage, error) {
+	if beforeID <= 0 {
+		beforeID = 1<<63 - 1
+	}
+	msgs := []models.ChatMessage{}
+	query := `SELECT ` + chatMessageColumns + ` FROM player_system.chat_messages m
+		JOIN player_system.players p ON p.id = m.sender_id
+		WHERE m.channel_id = $1 AND m.id < $3 AND ` + notBlockedBy("$2") + ` ORDER BY m.id DESC LIMIT $4`
+	err := db.Select(&msgs, query, channelID, viewerID, beforeID, limit)
+	return msgs, err
+}
+
+// SearchChatMessages finds messages for moderation, newest first.
+func SearchChatMessages(db *sqlx.DB, s models.ChatSearch) ([]models.ChatMessage, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch {
+	case s.PlayerID != 0 && s.OtherID != 0:
+		a, b := arg(s.PlayerID), arg(s.OtherID)
+		where = append(where, fmt.Sprintf("((m.sender_id = %s AND m.recipient_id = %s) OR (m.sender_id = %s AND m.recipient_id = %s))", a, b, b, a))
+	case s.PlayerID != 0:
+		where = append(where, "m.sender_id = "+arg(s.PlayerID))
+	}
+	if s.Query != "" {
+		where = append(where, "m.body ILIKE "+arg("%"+escapeLike(s.Query)+"%"))
+	}
+	if !s.From.IsZero() {
+		where = append(where, "m.created_at >= "+arg(s.From))
+	}
+	if !s.To.IsZero() {
+		where = append(where, "m.created_at <= "+arg(s.To))
+	}
+	if s.Limit <= 0 || s.Limit > 500 {
+		s.Limit = 100
+	}
+
+	query := `SELECT ` + chatMessageColumns + ` FROM player_system.chat_messages m
+		JOIN player_system.players p ON p.id = m.sender_id`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY m.id DESC LIMIT " + arg(s.Limit)
+
+	msgs := []models.ChatMessage{}
+	err := db.Select(&msgs, query, args...)
+	return msgs, err
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// -- Chat Channels --
+
+// CreateChatChannel creates a channel with the owner and the given members.
+func CreateChatChannel(db *sqlx.DB, kind, name string, ownerID int64, members []int64) (*models.ChatChannel, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	c := &models.ChatChannel{Kind: kind, Name: name, OwnerID: ownerID, CreatedAt: time.Now().UTC()}
+	if err := tx.QueryRow(`INSERT INTO player_system.chat_channels (kind, name, owner_id, created_at) VALUES ($1, $2, $3, $4) RETURNING id`,
+		kind, name, ownerID, c.CreatedAt).Scan(&c.ID); err != nil {
+		return nil, fmt.Errorf("insert chat channel: %w", err)
+	}
+
+	seen := map[int64]bool{}
+	for _, id := range append([]int64{ownerID}, members...) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := tx.Exec(`INSERT INTO player_system.chat_channel_members (channel_id, player_id) VALUES ($1, $2)`, c.ID, id); err != nil {
+			return nil, fmt.Errorf("add chat channel member: %w", err)
+		}
+		c.Members = append(c.Members, id)
+	}
+	return c, tx.Commit()
+}
+
+func GetChatChannel(db *sqlx.DB, id int64) (*models.ChatChannel, error) {
+	var c models.ChatChannel
+	if err := db.Get(&c, `SELECT id, kind, name, owner_id, created_at, 0 as unread FROM player_system.chat_channels WHERE id = $1 AND deleted_at IS NULL`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	members, err := GetChatChannelMembers(db, id)
+	if err != nil {
+		return nil, err
+	}
+	c.Members = members
+	return &c, nil
+}
+
+// CloseChatChannel soft-deletes a channel and drops its members. The channel row and its
+// messages are kept so moderators can still search and review them.
+func CloseChatChannel(db *sqlx.DB, id int64) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`UPDATE player_system.chat_channels SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM player_system.chat_channel_members WHERE channel_id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CloseChatChannelsByKind closes every open channel of a kind, e.g. party chats left over
+// from before a restart (parties are not persisted). Their messages are kept.
+func CloseChatChannelsByKind(db *sqlx.DB, kind string) (int64, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM player_system.chat_channel_members WHERE channel_id IN
+		(SELECT id FROM player_system.chat_channels WHERE kind = $1 AND deleted_at IS NULL)`, kind); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`UPDATE player_system.chat_channels SET deleted_at = NOW() WHERE kind = $1 AND deleted_at IS NULL`, kind)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
+func SetChatChannelOwner(db *sqlx.DB, channelID, ownerID int64) error {
+	_, err := db.Exec(`UPDATE player_system.chat_channels SET owner_id = $1 WHERE id = $2`, ownerID, channelID)
+	return err
+}
+
+func AddChatChannelMember(db *sqlx.DB, channelID, playerID int64) error {
+	_, err := db.Exec(`INSERT INTO player_system.chat_channel_members (channel_id, player_id, last_read_id)
+		VALUES ($1, $2, COALESCE((SELECT MAX(id) FROM player_system.chat_messages WHERE channel_id = $1), 0))
+		ON CONFLICT DO NOTHING`, channelID, playerID)
+	return err
+}
+
+func RemoveChatChannelMember(db *sqlx.DB, channelID, playerID int64) error {
+	_, err := db.Exec(`DELETE FROM player_system.chat_channel_members WHERE channel_id = $1 AND player_id = $2`, channelID, playerID)
+	return err
+}
+
+func GetChatChannelMembers(db *sqlx.DB, channelID int64) ([]int64, error) {
+	ids := []int64{}
+	err := db.Select(&ids, `SELECT player_id FROM player_system.chat_channel_members WHERE channel_id = $1 ORDER BY joined_at ASC`, channelID)
+	return ids, err
+}
+
+// ListPlayerChatChannels returns the channels a player is in, with their unread message counts.
+func ListPlayerChatChannels(db *sqlx.DB, playerID int64) ([]models.ChatChannel, error) {
+	channels := []models.ChatChannel{}
+	query := `
+		SELECT c.id, c.kind, c.name, c.owner_id, c.created_at,
+			(SELECT COUNT(*) FROM player_system.chat_messages m
+			 WHERE m.channel_id = c.id AND m.id > mem.last_read_id AND m.sender_id != $1) as unread
+		FROM player_system.chat_channels c
+		JOIN player_system.chat_channel_members mem ON mem.channel_id = c.id AND mem.player_id = $1
+		WHERE c.deleted_at IS NULL
+		ORDER BY c.id ASC
+	`
+	if err := db.Select(&channels, query, playerID); err != nil {
+		return nil, err
+	}
+	for i := range channels {
+		members, err := GetChatChannelMembers(db, channels[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		channels[i].Members = members
+	}
+	return channels, nil
+}
+
+// SetChatChannelRead moves a member's read marker forward.
+func SetChatChannelRead(db *sqlx.DB, channelID, playerID, messageID int64) (bool, error) {
+	res, err := db.Exec(`UPDATE player_system.chat_channel_members SET last_read_id = $1
+		WHERE channel_id = $2 AND player_id = $3 AND last_read_id < $1`, messageID, channelID, playerID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
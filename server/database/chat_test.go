@@ -0,0 +1,74 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"exile/server/models"
+)
+
+func TestClosedChatChannelKeepsMessages(t *testing.T) {
+	db := playerSystemDB(t)
+	owner := testPlayer(t, db, "chat-owner")
+	member := testPlayer(t, db, "chat-member")
+
+	ch, err := CreateChatChannel(db, "group", "test", owner, []int64{member})
+	if err != nil {
+		t.Fatalf("create channel: %v", err)
+	}
+	body := fmt.Sprintf("report me %d", time.Now().UnixNano())
+	msgID, err := CreateChatMessage(db, &models.ChatMessage{SenderID: member, ChannelID: &ch.ID, Body: body})
+	if err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+
+	if err := CloseChatChannel(db, ch.ID); err != nil {
+		t.Fatalf("close channel: %v", err)
+	}
+	if got, err := GetChatChannel(db, ch.ID); err != nil || got != nil {
+		t.Fatalf("closed channel should not be found, got %+v %v", got, err)
+	}
+	if channels, err := ListPlayerChatChannels(db, owner); err != nil || len(channels) != 0 {
+		t.Fatalf("closed channel should not be listed, got %+v %v", channels, err)
+	}
+
+	msgs, err := SearchChatMessages(db, models.ChatSearch{PlayerID: member, Query: body})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].ID != msgID || msgs[0].ChannelID == nil || *msgs[0].ChannelID != ch.ID {
+		t.Fatalf("expected the closed channel's message to be found, got %+v", msgs)
+	}
+}
+
+func TestCloseChatChannelsByKind(t *testing.T) {
+	db := playerSystemDB(t)
+	leader := testPlayer(t, db, "party-leader")
+
+	party, err := CreateChatChannel(db, "party", "Party", leader, nil)
+	if err != nil {
+		t.Fatalf("create party channel: %v", err)
+	}
+	group, err := CreateChatChannel(db, "group", "test", leader, nil)
+	if err != nil {
+		t.Fatalf("create group channel: %v", err)
+	}
+	body := fmt.Sprintf("party message %d", time.Now().UnixNano())
+	if _, err := CreateChatMessage(db, &models.ChatMessage{SenderID: leader, ChannelID: &party.ID, Body: body}); err != nil {
+		t.Fatalf("create message: %v", err)
+	}
+
+	if n, err := CloseChatChannelsByKind(db, "party"); err != nil || n < 1 {
+		t.Fatalf("expected the party channel to be closed, got %d %v", n, err)
+	}
+	if got, _ := GetChatChannel(db, party.ID); got != nil {
+		t.Fatalf("party channel should be closed, got %+v", got)
+	}
+	if got, _ := GetChatChannel(db, group.ID); got == nil {
+		t.Fatal("group channel should stay open")
+	}
+	if msgs, err := SearchChatMessages(db, models.ChatSearch{PlayerID: leader, Query: body}); err != nil || len(msgs) != 1 {
+		t.Fatalf("expected the party message to be kept, got %+v %v", msgs, err)
+	}
+}
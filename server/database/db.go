@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -184,7 +185,81 @@ func MigrateDB(db *sqlx.DB) error {
                 version TEXT,
                 comment TEXT,
                 uploaded_at INTEGER NOT NULL,
-                is_active INTEGER DEFAULT 0
+                is_active INTEGER DEFAULT 0,
+                sha256 TEXT,
+                size_bytes INTEGER DEFAULT 0,
+                validation_status TEXT,
+                validation_errors TEXT,
+                build_metadata TEXT,
+                validated_at INTEGER DEFAULT 0,
+                pinned INTEGER DEFAULT 0
+        )`, pkType),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS release_channels (
+                id %s,
+                name TEXT UNIQUE NOT NULL,
+                priority INTEGER DEFAULT 0,
+                regions TEXT,
+                tags TEXT,
+                version_id INTEGER DEFAULT 0,
+                previous_version_id INTEGER DEFAULT 0,
+                rollout_percent INTEGER DEFAULT 0,
+                wave_step INTEGER DEFAULT 0,
+                wave_interval_seconds INTEGER DEFAULT 0,
+                auto_rollback INTEGER DEFAULT 1,
+                max_failure_rate REAL DEFAULT 0.2,
+                status TEXT DEFAULT 'idle',
+                status_reason TEXT,
+                last_wave_at INTEGER DEFAULT 0,
+                updated_at INTEGER NOT NULL
+        )`, pkType),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS fleet_update_jobs (
+                id %s,
+                status TEXT NOT NULL,
+                error TEXT,
+                spec TEXT NOT NULL,
+                targets TEXT NOT NULL,
+                created_by TEXT,
+                created_at INTEGER NOT NULL,
+                updated_at INTEGER NOT NULL
+        )`, pkType),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS autoscale_policies (
+                id %s,
+                name TEXT NOT NULL,
+                region TEXT,
+                tags TEXT,
+                min_instances INTEGER DEFAULT 0,
+                max_instances INTEGER DEFAULT 0,
+                target_idle INTEGER DEFAULT 0,
+                strategy TEXT,
+                scale_up_cooldown_seconds INTEGER DEFAULT 60,
+                scale_down_cooldown_seconds INTEGER DEFAULT 300,
+                enabled INTEGER DEFAULT 1,
+                dry_run INTEGER DEFAULT 0,
+                last_scale_up_at INTEGER DEFAULT 0,
+                last_scale_down_at INTEGER DEFAULT 0,
+                updated_at INTEGER NOT NULL
+        )`, pkType),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS autoscale_decisions (
+                id %s,
+                policy_id INTEGER NOT NULL,
+                action TEXT NOT NULL,
+                node_id INTEGER DEFAULT 0,
+                instance_id TEXT,
+                reason TEXT,
+                total INTEGER DEFAULT 0,
+                idle INTEGER DEFAULT 0,
+                dry_run INTEGER DEFAULT 0,
+                error TEXT,
+                created_at INTEGER NOT NULL
+        )`, pkType),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS player_sessions (
+                id %s,
+                player_id BIGINT NOT NULL,
+                node_id INTEGER NOT NULL,
+                instance_id TEXT NOT NULL,
+                started_at INTEGER NOT NULL,
+                ended_at INTEGER DEFAULT 0,
+                end_reason TEXT
         )`, pkType),
 		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS server_config (
                 id %s,
@@ -254,7 +329,9 @@ func MigrateDB(db *sqlx.DB) error {
                 port TEXT NOT NULL,
                 path_pattern TEXT DEFAULT '',
                 protocol TEXT NOT NULL,
+                method TEXT DEFAULT '',
                 action TEXT NOT NULL,
+                priority INTEGER DEFAULT 0,
                 rate_limit INTEGER DEFAULT 0,
                 burst INTEGER DEFAULT 0,
                 enabled INTEGER DEFAULT 1,
@@ -281,6 +358,25 @@ func MigrateDB(db *sqlx.DB) error {
                 severity INTEGER DEFAULT 0,
                 timestamp INTEGER NOT NULL
         )`, pkType),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS redeye_feeds (
+                id %s,
+                name TEXT NOT NULL,
+                source TEXT NOT NULL,
+                format TEXT NOT NULL DEFAULT 'plain',
+                refresh_minutes INTEGER DEFAULT 60,
+                enabled INTEGER DEFAULT 1,
+                entry_count INTEGER DEFAULT 0,
+                hits INTEGER DEFAULT 0,
+                last_refreshed INTEGER,
+                last_error TEXT DEFAULT '',
+                created_at INTEGER NOT NULL
+        )`, pkType),
+		`CREATE TABLE IF NOT EXISTS redeye_feed_entries (
+                feed_id INTEGER NOT NULL,
+                cidr TEXT NOT NULL,
+                added_at INTEGER NOT NULL,
+                PRIMARY KEY (feed_id, cidr)
+        )`,
 		`CREATE TABLE IF NOT EXISTS redeye_ip_reputation (
                 ip TEXT PRIMARY KEY,
                 reputation_score INTEGER DEFAULT 0,
@@ -288,7 +384,8 @@ func MigrateDB(db *sqlx.DB) error {
                 last_seen INTEGER NOT NULL,
                 is_banned INTEGER DEFAULT 0,
                 ban_reason TEXT,
-                ban_expires_at INTEGER
+                ban_expires_at INTEGER,
+                ban_count INTEGER DEFAULT 0
         )`,
 	}
 
@@ -310,6 +407,9 @@ func MigrateDB(db *sqlx.DB) error {
 		"CREATE INDEX IF NOT EXISTS idx_redeye_rules_active ON redeye_rules(enabled, action)",
 		"CREATE INDEX IF NOT EXISTS idx_todos_parent ON todos(parent_id)",
 		"CREATE INDEX IF NOT EXISTS idx_todo_comments_todo ON todo_comments(todo_id)",
+		"CREATE INDEX IF NOT EXISTS idx_autoscale_decisions_policy ON autoscale_decisions(policy_id, created_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_player_sessions_player ON player_sessions(player_id, started_at DESC)",
+		"CREATE INDEX IF NOT EXISTS idx_player_sessions_instance ON player_sessions(node_id, instance_id, ended_at)",
 	}
 
 	for _, q := range indexQueries {
@@ -338,8 +438,26 @@ func MigrateDB(db *sqlx.DB) error {
 	_, _ = db.Exec("ALTER TABLE nodes ADD COLUMN resource_limits TEXT")
 	_, _ = db.Exec("ALTER TABLE nodes ADD COLUMN public_ip TEXT")
 
+	// Add integrity columns to server_versions if missing
+	_, _ = db.Exec("ALTER TABLE server_versions ADD COLUMN sha256 TEXT")
+	_, _ = db.Exec("ALTER TABLE server_versions ADD COLUMN size_bytes INTEGER DEFAULT 0")
+	_, _ = db.Exec("ALTER TABLE server_versions ADD COLUMN validation_status TEXT")
+	_, _ = db.Exec("ALTER TABLE server_versions ADD COLUMN validation_errors TEXT")
+	_, _ = db.Exec("ALTER TABLE server_versions ADD COLUMN build_metadata TEXT")
+	_, _ = db.Exec("ALTER TABLE server_versions ADD COLUMN validated_at INTEGER DEFAULT 0")
+	_, _ = db.Exec("ALTER TABLE server_versions ADD COLUMN pinned INTEGER DEFAULT 0")
+
 	// Add details to redeye_logs if missing
 	_, _ = db.Exec("ALTER TABLE redeye_logs ADD COLUMN details TEXT")
+	// Add method matching to redeye_rules if missing
+	_, _ = db.Exec("ALTER TABLE redeye_rules ADD COLUMN method TEXT DEFAULT ''")
+	// Add rule priority to redeye_rules if missing
+	_, _ = db.Exec("ALTER TABLE redeye_rules ADD COLUMN priority INTEGER DEFAULT 0")
+	// Count bans per IP so repeat offenders get longer ones
+	_, _ = db.Exec("ALTER TABLE redeye_ip_reputation ADD COLUMN ban_count INTEGER DEFAULT 0")
+	// Link anticheat events to player accounts
+	_, _ = db.Exec("ALTER TABLE redeye_anticheat_events ADD COLUMN account_id INTEGER")
+	_, _ = db.Exec("CREATE INDEX IF NOT EXISTS idx_redeye_anticheat_account ON redeye_anticheat_events(account_id, timestamp DESC)")
     
 	return nil
 }
@@ -802,6 +920,11 @@ func execWithRetry(fn func() error) error {
 	return lastErr
 }
 
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
 func boolToInt(b bool) int {
 	if b {
 		return 1
@@ -1102,9 +1225,13 @@ func GetNodeByHostPort(db *sqlx.DB, host string, port int) (*models.Node, error)
 // -- Server Versions --
 
 func SaveServerVersion(db *sqlx.DB, v *models.GameServerVersion) error {
+	errs, meta, validatedAt := encodeVersionValidation(v)
 	do := func() error {
-		_, err := db.Exec(`INSERT INTO server_versions (filename, version, comment, uploaded_at, is_active) VALUES ($1, $2, $3, $4, $5)`,
-			v.Filename, v.Version, v.Comment, v.UploadedAt.Unix(), boolToInt(v.IsActive))
+		err := db.QueryRow(`INSERT INTO server_versions (filename, version, comment, uploaded_at, is_active, sha256, size_bytes,
+                        validation_status, validation_errors, build_metadata, validated_at)
+                        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`,
+			v.Filename, v.Version, v.Comment, v.UploadedAt.Unix(), boolToInt(v.IsActive), v.SHA256, v.SizeBytes,
+			v.ValidationStatus, errs, meta, validatedAt).Scan(&v.ID)
 		if err != nil {
 			return fmt.Errorf("insert version: %w", err)
 		}
@@ -1113,35 +1240,82 @@ func SaveServerVersion(db *sqlx.DB, v *models.GameServerVersion) error {
 	return execWithRetry(do)
 }
 
-// ListServerVersions returns all uploaded server binary versions.
-func ListServerVersions(db *sqlx.DB) ([]models.GameServerVersion, error) {
-
-	rows, err := db.Queryx(`SELECT id, filename, version, comment, uploaded_at, is_active FROM server_versions ORDER BY uploaded_at DESC`)
+// UpdateServerVersionValidation stores the result of (re)inspecting a version's package.
+func UpdateServerVersionValidation(db *sqlx.DB, v *models.GameServerVersion) error {
+	errs, meta, validatedAt := encodeVersionValidation(v)
+	do := func() error {
+		_, err := db.Exec(`UPDATE server_versions SET validation_status = $1, validation_errors = $2, build_metadata = $3, validated_at = $4 WHERE id = $5`,
+			v.ValidationStatus, errs, meta, validatedAt, v.ID)
+		return err
+	}
+	return execWithRetry(do)
+}
 
-	if err != nil {
+func encodeVersionValidation(v *models.GameServerVersion) (string, string, int64) {
+	var errs, meta string
+	if len(v.ValidationErrors) > 0 {
+		b, _ := json.Marshal(v.ValidationErrors)
+		errs = string(b)
+	}
+	if len(v.BuildMetadata) > 0 {
+		b, _ := json.Marshal(v.BuildMetadata)
+		meta = string(b)
+	}
+	var validatedAt int64
+	if !v.ValidatedAt.IsZero() {
+		validatedAt = v.ValidatedAt.Unix()
+	}
+	return errs, meta, validatedAt
+}
 
-		return nil, fmt.Errorf("query versions: %w", err)
+const serverVersionColumns = `id, filename, version, comment, uploaded_at, is_active, sha256, size_bytes,
+        validation_status, validation_errors, build_metadata, validated_at, pinned`
 
+func scanServerVersion(row rowScanner) (*models.GameServerVersion, error) {
+	var v models.GameServerVersion
+	var uploadedAtUnix int64
+	// Handle NULL columns if migration hasn't happened or older records
+	var version, comment, sha, status, errs, meta sql.NullString
+	var size, validatedAt sql.NullInt64
+	var pinned sql.NullBool
+	if err := row.Scan(&v.ID, &v.Filename, &version, &comment, &uploadedAtUnix, &v.IsActive, &sha, &size,
+		&status, &errs, &meta, &validatedAt, &pinned); err != nil {
+		return nil, err
+	}
+	v.Version = version.String
+	v.Comment = comment.String
+	v.SHA256 = sha.String
+	v.SizeBytes = size.Int64
+	v.UploadedAt = time.Unix(uploadedAtUnix, 0).UTC()
+	v.ValidationStatus = status.String
+	v.Pinned = pinned.Bool
+	if errs.String != "" {
+		_ = json.Unmarshal([]byte(errs.String), &v.ValidationErrors)
+	}
+	if meta.String != "" {
+		_ = json.Unmarshal([]byte(meta.String), &v.BuildMetadata)
 	}
+	if validatedAt.Int64 > 0 {
+		v.ValidatedAt = time.Unix(validatedAt.Int64, 0).UTC()
+	}
+	return &v, nil
+}
 
+// ListServerVersions returns all uploaded server binary versions.
+func ListServerVersions(db *sqlx.DB) ([]models.GameServerVersion, error) {
+	rows, err := db.Query(`SELECT ` + serverVersionColumns + ` FROM server_versions ORDER BY uploaded_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("query versions: %w", err)
+	}
 	defer func() { _ = rows.Close() }()
 
 	out := make([]models.GameServerVersion, 0)
 	for rows.Next() {
-
-		var v models.GameServerVersion
-
-		var uploadedAtUnix int64
-		// Handle NULL version if migration hasn't happened or older records
-		var version sql.NullString
-		if err := rows.Scan(&v.ID, &v.Filename, &version, &v.Comment, &uploadedAtUnix, &v.IsActive); err != nil {
+		v, err := scanServerVersion(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan version: %w", err)
 		}
-		if version.Valid {
-			v.Version = version.String
-		}
-		v.UploadedAt = time.Unix(uploadedAtUnix, 0).UTC()
-		out = append(out, v)
+		out = append(out, *v)
 	}
 	return out, nil
 }
@@ -1168,6 +1342,15 @@ func SetActiveVersion(db *sqlx.DB, id int) error {
 	return execWithRetry(do)
 }
 
+// SetServerVersionPinned protects (or releases) a version from retention cleanup.
+func SetServerVersionPinned(db *sqlx.DB, id int, pinned bool) error {
+	do := func() error {
+		_, err := db.Exec(`UPDATE server_versions SET pinned = $1 WHERE id = $2`, boolToInt(pinned), id)
+		return err
+	}
+	return execWithRetry(do)
+}
+
 func DeleteServerVersion(db *sqlx.DB, id int) (string, error) {
 	var filename string
 	do := func() error {
@@ -1187,22 +1370,26 @@ func DeleteServerVersion(db *sqlx.DB, id int) (string, error) {
 }
 
 func GetActiveServerVersion(db *sqlx.DB) (*models.GameServerVersion, error) {
-	var v models.GameServerVersion
-	var uploadedAtUnix int64
-	var version sql.NullString
-	err := db.QueryRow(`SELECT id, filename, version, comment, uploaded_at, is_active FROM server_versions WHERE is_active = 1 LIMIT 1`).
-		Scan(&v.ID, &v.Filename, &version, &v.Comment, &uploadedAtUnix, &v.IsActive)
+	v, err := scanServerVersion(db.QueryRow(`SELECT ` + serverVersionColumns + ` FROM server_versions WHERE is_active = 1 LIMIT 1`))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
-	if version.Valid {
-		v.Version = version.String
+	return v, nil
+}
+
+// GetServerVersionByID returns a single version, or nil if it does not exist.
+func GetServerVersionByID(db *sqlx.DB, id int) (*models.GameServerVersion, error) {
+	v, err := scanServerVersion(db.QueryRow(`SELECT `+serverVersionColumns+` FROM server_versions WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
 	}
-	v.UploadedAt = time.Unix(uploadedAtUnix, 0).UTC()
-	return &v, nil
+	return v, nil
 }
 
 // -- Server Configuration --
@@ -1319,6 +1506,26 @@ func SeedDefaultConfig(db *sqlx.DB) error {
 			RequiresRestart: false,
 			UpdatedBy:       "system",
 		},
+		{
+			Key:             "redeye.ban_durations",
+			Value:           "1h,24h,168h,permanent",
+			Type:            "string",
+			Category:        "redeye",
+			Description:     "Auto-ban durations for the first, second, ... ban of an IP; the last one repeats",
+			IsReadOnly:      false,
+			RequiresRestart: false,
+			UpdatedBy:       "system",
+		},
+		{
+			Key:             "redeye.score_half_life",
+			Value:           "10m",
+			Type:            "duration",
+			Category:        "redeye",
+			Description:     "Time for an IP's reputation score to decay by half",
+			IsReadOnly:      false,
+			RequiresRestart: false,
+			UpdatedBy:       "system",
+		},
 		{
 			Key:             "redeye.alert_enabled",
 			Value:           "true",
@@ -1971,8 +2178,8 @@ func GetRedEyeRuleByID(db *sqlx.DB, id int) (*models.RedEyeRule, error) {
 	var r models.RedEyeRule
 	var tsUnix int64
 	var enabledInt int
-	query := `SELECT id, name, cidr, port, COALESCE(path_pattern, '') as path_pattern, protocol, action, rate_limit, burst, enabled, created_at FROM redeye_rules WHERE id = $1`
-	err := db.QueryRowx(query, id).Scan(&r.ID, &r.Name, &r.CIDR, &r.Port, &r.PathPattern, &r.Protocol, &r.Action, &r.RateLimit, &r.Burst, &enabledInt, &tsUnix)
+	query := `SELECT id, name, cidr, port, COALESCE(path_pattern, '') as path_pattern, protocol, COALESCE(method, '') as method, action, COALESCE(priority, 0) as priority, rate_limit, burst, enabled, created_at FROM redeye_rules WHERE id = $1`
+	err := db.QueryRowx(query, id).Scan(&r.ID, &r.Name, &r.CIDR, &r.Port, &r.PathPattern, &r.Protocol, &r.Method, &r.Action, &r.Priority, &r.RateLimit, &r.Burst, &enabledInt, &tsUnix)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Rule not found
@@ -1987,17 +2194,18 @@ func GetRedEyeRuleByID(db *sqlx.DB, id int) (*models.RedEyeRule, error) {
 func CreateRedEyeRule(db *sqlx.DB, r *models.RedEyeRule) (int, error) {
 	var id int
 	do := func() error {
-		query := `INSERT INTO redeye_rules (name, cidr, port, path_pattern, protocol, action, rate_limit, burst, enabled, created_at) 
-                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
-		err := db.QueryRow(query, r.Name, r.CIDR, r.Port, r.PathPattern, r.Protocol, r.Action, r.RateLimit, r.Burst, boolToInt(r.Enabled), time.Now().Unix()).Scan(&id)
+		query := `INSERT INTO redeye_rules (name, cidr, port, path_pattern, protocol, method, action, priority, rate_limit, burst, enabled, created_at) 
+                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+		err := db.QueryRow(query, r.Name, r.CIDR, r.Port, r.PathPattern, r.Protocol, r.Method, r.Action, r.Priority, r.RateLimit, r.Burst, boolToInt(r.Enabled), time.Now().Unix()).Scan(&id)
 		return err
 	}
 	if err := execWithRetry(do); err != nil {
 		return 0, err
 	}
 
-	// Apply OS-level block if it's a DENY rule
-	if r.Action == "DENY" {
+	// Apply OS-level block if it's a DENY rule for the whole host; narrower rules are
+	// only enforced by the RedEye middleware
+	if r.Action == "DENY" && r.IsHostWide() {
 		if err := utils.BlockIPSystem(r.CIDR); err != nil {
 			log.Printf("RedEye: Failed to apply OS block for new rule (CIDR: %s): %v", r.CIDR, err)
 		}
@@ -2006,7 +2214,7 @@ func CreateRedEyeRule(db *sqlx.DB, r *models.RedEyeRule) (int, error) {
 }
 
 func GetRedEyeRules(db *sqlx.DB) ([]models.RedEyeRule, error) {
-	rows, err := db.Queryx(`SELECT id, name, cidr, port, COALESCE(path_pattern, '') as path_pattern, protocol, action, rate_limit, burst, enabled, created_at FROM redeye_rules ORDER BY created_at DESC`)
+	rows, err := db.Queryx(`SELECT id, name, cidr, port, COALESCE(path_pattern, '') as path_pattern, protocol, COALESCE(method, '') as method, action, COALESCE(priority, 0) as priority, rate_limit, burst, enabled, created_at FROM redeye_rules ORDER BY priority, id`)
 	if err != nil {
 		return nil, fmt.Errorf("query redeye rules: %w", err)
 	}
@@ -2017,7 +2225,7 @@ func GetRedEyeRules(db *sqlx.DB) ([]models.RedEyeRule, error) {
 		var r models.RedEyeRule
 		var tsUnix int64
 		var enabledInt int
-		if err := rows.Scan(&r.ID, &r.Name, &r.CIDR, &r.Port, &r.PathPattern, &r.Protocol, &r.Action, &r.RateLimit, &r.Burst, &enabledInt, &tsUnix); err != nil {
+		if err := rows.Scan(&r.ID, &r.Name, &r.CIDR, &r.Port, &r.PathPattern, &r.Protocol, &r.Method, &r.Action, &r.Priority, &r.RateLimit, &r.Burst, &enabledInt, &tsUnix); err != nil {
 			return nil, err
 		}
 		r.Enabled = enabledInt == 1
@@ -2041,8 +2249,8 @@ func UpdateRedEyeRule(db *sqlx.DB, r *models.RedEyeRule) error {
 	}
 
 	do := func() error {
-		query := `UPDATE redeye_rules SET name=$1, cidr=$2, port=$3, path_pattern=$4, protocol=$5, action=$6, rate_limit=$7, burst=$8, enabled=$9 WHERE id=$10`
-		_, err := db.Exec(query, r.Name, r.CIDR, r.Port, r.PathPattern, r.Protocol, r.Action, r.RateLimit, r.Burst, boolToInt(r.Enabled), r.ID)
+		query := `UPDATE redeye_rules SET name=$1, cidr=$2, port=$3, path_pattern=$4, protocol=$5, method=$6, action=$7, priority=$8, rate_limit=$9, burst=$10, enabled=$11 WHERE id=$12`
+		_, err := db.Exec(query, r.Name, r.CIDR, r.Port, r.PathPattern, r.Protocol, r.Method, r.Action, r.Priority, r.RateLimit, r.Burst, boolToInt(r.Enabled), r.ID)
 		return err
 	}
 
@@ -2051,8 +2259,8 @@ func UpdateRedEyeRule(db *sqlx.DB, r *models.RedEyeRule) error {
 	}
 
 	// Logic for UFW updates based on rule changes
-	oldWasDeny := oldRule.Action == "DENY" && oldRule.Enabled
-	newIsDeny := r.Action == "DENY" && r.Enabled
+	oldWasDeny := oldRule.Action == "DENY" && oldRule.Enabled && oldRule.IsHostWide()
+	newIsDeny := r.Action == "DENY" && r.Enabled && r.IsHostWide()
 
 	// Case 1: Rule changed from DENY to non-DENY, or CIDR changed
 	if oldWasDeny && (!newIsDeny || oldRule.CIDR != r.CIDR) {
@@ -2093,7 +2301,7 @@ func DeleteRedEyeRule(db *sqlx.DB, id int) error {
 	}
 
 	// If the deleted rule was a DENY rule, unblock the IP at the OS level
-	if ruleToDelete != nil && ruleToDelete.Action == "DENY" {
+	if ruleToDelete != nil && ruleToDelete.Action == "DENY" && ruleToDelete.IsHostWide() {
 		if err := utils.UnblockIPSystem(ruleToDelete.CIDR); err != nil {
 			log.Printf("RedEye: Failed to remove OS block for deleted rule (CIDR: %s): %v", ruleToDelete.CIDR, err)
 		}
@@ -2157,44 +2365,69 @@ func ClearRedEyeLogs(db *sqlx.DB) error {
 
 func SaveAnticheatEvent(db *sqlx.DB, e *models.RedEyeAnticheatEvent) error {
 	do := func() error {
-		query := `INSERT INTO redeye_anticheat_events (player_id, game_server_id, event_type, details, client_ip, severity, timestamp)
-                  VALUES ($1, $2, $3, $4, $5, $6, $7)`
-		_, err := db.Exec(query, e.PlayerID, e.GameServerID, e.EventType, e.Details, e.ClientIP, e.Severity, e.Timestamp.Unix())
-		return err
+		query := `INSERT INTO redeye_anticheat_events (player_id, account_id, game_server_id, event_type, details, client_ip, severity, timestamp)
+                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+		return db.QueryRow(query, e.PlayerID, e.AccountID, e.GameServerID, e.EventType, e.Details, e.ClientIP, e.Severity, e.Timestamp.Unix()).Scan(&e.ID)
 	}
 	return execWithRetry(do)
 }
 
 func GetAnticheatEvents(db *sqlx.DB, limit, offset int) ([]models.RedEyeAnticheatEvent, int64, error) {
-	var events []models.RedEyeAnticheatEvent
 	var total int64
 
 	if err := db.Get(&total, "SELECT COUNT(*) FROM redeye_anticheat_events"); err != nil {
 		return nil, 0, err
 	}
 
-	query := fmt.Sprintf(`SELECT id, player_id, game_server_id, event_type, details, client_ip, severity, timestamp
+	query := fmt.Sprintf(`SELECT id, player_id, account_id, game_server_id, event_type, details, client_ip, severity, timestamp
                           FROM redeye_anticheat_events ORDER BY timestamp DESC LIMIT $%d OFFSET $%d`, 1, 2)
-	rows, err := db.Queryx(query, limit, offset)
+	events, err := queryAnticheatEvents(db, query, limit, offset)
 	if err != nil {
 		return nil, 0, err
 	}
+	return events, total, nil
+}
+
+// GetAnticheatEvent returns one event, or nil.
+func GetAnticheatEvent(db *sqlx.DB, id int64) (*models.RedEyeAnticheatEvent, error) {
+	query := `SELECT id, player_id, account_id, game_server_id, event_type, details, client_ip, severity, timestamp
+              FROM redeye_anticheat_events WHERE id = $1`
+	events, err := queryAnticheatEvents(db, query, id)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// GetPlayerAnticheatEvents returns the events linked to a player account, newest first.
+func GetPlayerAnticheatEvents(db *sqlx.DB, accountID int64, limit int) ([]models.RedEyeAnticheatEvent, error) {
+	query := `SELECT id, player_id, account_id, game_server_id, event_type, details, client_ip, severity, timestamp
+              FROM redeye_anticheat_events WHERE account_id = $1 ORDER BY timestamp DESC LIMIT $2`
+	return queryAnticheatEvents(db, query, accountID, limit)
+}
+
+func queryAnticheatEvents(db *sqlx.DB, query string, args ...interface{}) ([]models.RedEyeAnticheatEvent, error) {
+	rows, err := db.Queryx(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	events := []models.RedEyeAnticheatEvent{}
 	for rows.Next() {
 		var e models.RedEyeAnticheatEvent
 		var tsUnix int64
-		if err := rows.Scan(&e.ID, &e.PlayerID, &e.GameServerID, &e.EventType, &e.Details, &e.ClientIP, &e.Severity, &tsUnix); err != nil {
-			return nil, 0, err
+		var accountID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.PlayerID, &accountID, &e.GameServerID, &e.EventType, &e.Details, &e.ClientIP, &e.Severity, &tsUnix); err != nil {
+			return nil, err
+		}
+		if accountID.Valid {
+			e.AccountID = &accountID.Int64
 		}
 		e.Timestamp = time.Unix(tsUnix, 0).UTC()
 		events = append(events, e)
 	}
-
-	if events == nil {
-		events = []models.RedEyeAnticheatEvent{}
-	}
-	return events, total, nil
+	return events, rows.Err()
 }
 
 func GetIPReputation(db *sqlx.DB, ip string) (*models.RedEyeIPReputation, error) {
@@ -2204,8 +2437,8 @@ func GetIPReputation(db *sqlx.DB, ip string) (*models.RedEyeIPReputation, error)
 	var isBannedInt int
 	var banReason sql.NullString
 
-	query := `SELECT ip, reputation_score, total_events, last_seen, is_banned, ban_reason, ban_expires_at FROM redeye_ip_reputation WHERE ip = $1`
-	err := db.QueryRowx(query, ip).Scan(&r.IP, &r.ReputationScore, &r.TotalEvents, &tsUnix, &isBannedInt, &banReason, &banExpiresUnix)
+	query := `SELECT ip, reputation_score, total_events, last_seen, is_banned, ban_reason, ban_expires_at, COALESCE(ban_count, 0) FROM redeye_ip_reputation WHERE ip = $1`
+	err := db.QueryRowx(query, ip).Scan(&r.IP, &r.ReputationScore, &r.TotalEvents, &tsUnix, &isBannedInt, &banReason, &banExpiresUnix, &r.BanCount)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Return default reputation for new IP
@@ -2236,17 +2469,18 @@ func UpdateIPReputation(db *sqlx.DB, r *models.RedEyeIPReputation) error {
 			banExpiresUnix = &t
 		}
 
-		query := `INSERT INTO redeye_ip_reputation (ip, reputation_score, total_events, last_seen, is_banned, ban_reason, ban_expires_at)
-                  VALUES ($1, $2, $3, $4, $5, $6, $7)
+		query := `INSERT INTO redeye_ip_reputation (ip, reputation_score, total_events, last_seen, is_banned, ban_reason, ban_expires_at, ban_count)
+                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
                   ON CONFLICT(ip) DO UPDATE SET
                   reputation_score=excluded.reputation_score,
                   total_events=excluded.total_events,
                   last_seen=excluded.last_seen,
                   is_banned=excluded.is_banned,
                   ban_reason=excluded.ban_reason,
-                  ban_expires_at=excluded.ban_expires_at`
+                  ban_expires_at=excluded.ban_expires_at,
+                  ban_count=excluded.ban_count`
 
-		_, err := db.Exec(query, r.IP, r.ReputationScore, r.TotalEvents, r.LastSeen.Unix(), boolToInt(r.IsBanned), r.BanReason, banExpiresUnix)
+		_, err := db.Exec(query, r.IP, r.ReputationScore, r.TotalEvents, r.LastSeen.Unix(), boolToInt(r.IsBanned), r.BanReason, banExpiresUnix, r.BanCount)
 		return err
 	}
 	return execWithRetry(do)
@@ -2260,8 +2494,17 @@ func GetBannedIPList(db *sqlx.DB) ([]string, error) {
 	return ips, err
 }
 
+// GetExpiredBanList returns the IPs whose timed ban has run out but which are still
+// flagged as banned.
+func GetExpiredBanList(db *sqlx.DB, now time.Time) ([]string, error) {
+	var ips []string
+	query := `SELECT ip FROM redeye_ip_reputation WHERE is_banned = 1 AND ban_expires_at IS NOT NULL AND ban_expires_at <= $1`
+	err := db.Select(&ips, query, now.Unix())
+	return ips, err
+}
+
 func GetBannedIPsFull(db *sqlx.DB) ([]models.RedEyeIPReputation, error) {
-	query := `SELECT ip, reputation_score, total_events, last_seen, is_banned, ban_reason, ban_expires_at 
+	query := `SELECT ip, reputation_score, total_events, last_seen, is_banned, ban_reason, ban_expires_at, COALESCE(ban_count, 0)
               FROM redeye_ip_reputation 
               WHERE is_banned = 1 AND (ban_expires_at IS NULL OR ban_expires_at > $1)
               ORDER BY last_seen DESC`
@@ -2279,7 +2522,7 @@ func GetBannedIPsFull(db *sqlx.DB) ([]models.RedEyeIPReputation, error) {
 		var banExpiresUnix sql.NullInt64
 		var isBannedInt int
 		var banReason sql.NullString
-		if err := rows.Scan(&r.IP, &r.ReputationScore, &r.TotalEvents, &tsUnix, &isBannedInt, &banReason, &banExpiresUnix); err != nil {
+		if err := rows.Scan(&r.IP, &r.ReputationScore, &r.TotalEvents, &tsUnix, &isBannedInt, &banReason, &banExpiresUnix, &r.BanCount); err != nil {
 			return nil, err
 		}
 		r.LastSeen = time.Unix(tsUnix, 0).UTC()
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// evidenceLockKey serializes appends to the evidence chain across connections.
+const evidenceLockKey = 0x45564944 // "EVID"
+
+// initEvidenceTables creates the append-only evidence log in the 'player_system' schema.
+// Rows are never updated or deleted; a trigger rejects attempts to.
+func initEvidenceTables(db *sqlx.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS player_system.evidence (
+			id BIGSERIAL PRIMARY KEY,
+			event_id BIGINT NOT NULL,
+			player_id BIGINT,
+			kind TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			content_type TEXT NOT NULL DEFAULT '',
+			sha256 TEXT NOT NULL,
+			size BIGINT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			prev_hash TEXT NOT NULL,
+			entry_hash TEXT NOT NULL UNIQUE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_evidence_event ON player_system.evidence(event_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_evidence_player ON player_system.evidence(player_id)`,
+		`CREATE OR REPLACE FUNCTION player_system.evidence_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION 'evidence log is append-only';
+		END;
+		$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS evidence_append_only ON player_system.evidence`,
+		`CREATE TRIGGER evidence_append_only BEFORE UPDATE OR DELETE ON player_system.evidence
+			FOR EACH ROW EXECUTE PROCEDURE player_system.evidence_append_only()`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("create evidence tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// AppendEvidence adds an item to the end of the evidence chain. hash computes the
+// item's EntryHash from the previous entry's hash (genesis for the first entry).
+func AppendEvidence(db *sqlx.DB, e *models.Evidence, genesis string, hash func(prev string, e *models.Evidence) string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, evidenceLockKey); err != nil {
+		return err
+	}
+	prev := genesis
+	err = tx.Get(&prev, `SELECT entry_hash FROM player_system.evidence ORDER BY id DESC LIMIT 1`)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	e.PrevHash = prev
+	e.EntryHash = hash(prev, e)
+	query := `INSERT INTO player_system.evidence (event_id, player_id, kind, name, content_type, sha256, size, created_at, prev_hash, entry_hash)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+	if err := tx.QueryRow(query, e.EventID, e.PlayerID, e.Kind, e.Name, e.ContentType, e.SHA256, e.Size, e.CreatedAt, e.PrevHash, e.EntryHash).Scan(&e.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func GetEvidence(db *sqlx.DB, id int64) (*models.Evidence, error) {
+	var e models.Evidence
+	if err := db.Get(&e, `SELECT * FROM player_system.evidence WHERE id = $1`, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ListEvidenceChain returns up to limit entries after afterID, in chain order.
+func ListEvidenceChain(db *sqlx.DB, afterID int64, limit int) ([]models.Evidence, error) {
+	items := []models.Evidence{}
+	err := db.Select(&items, `SELECT * FROM player_system.evidence WHERE id > $1 ORDER BY id LIMIT $2`, afterID, limit)
+	return items, err
+}
+
+func ListEventEvidence(db *sqlx.DB, eventID int64) ([]models.Evidence, error) {
+	items := []models.Evidence{}
+	err := db.Select(&items, `SELECT * FROM player_system.evidence WHERE event_id = $1 ORDER BY id`, eventID)
+	return items, err
+}
+
+func ListPlayerEvidence(db *sqlx.DB, playerID int64) ([]models.Evidence, error) {
+	items := []models.Evidence{}
+	err := db.Select(&items, `SELECT * FROM player_system.evidence WHERE player_id = $1 ORDER BY id`, playerID)
+	return items, err
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// -- Fleet Update Jobs --
+
+// fleetJobSpec is the immutable part of a job, stored as a JSON blob.
+type fleetJobSpec struct {
+	Filter               models.FleetUpdateFilter `json:"filter"`
+	BatchSize            int                      `json:"batch_size"`
+	MaxUnavailable       int                      `json:"max_unavailable"`
+	HealthTimeoutSeconds int                      `json:"health_timeout_seconds"`
+}
+
+// SaveFleetUpdateJob inserts a new job (ID == 0) or updates its status and targets.
+func SaveFleetUpdateJob(db *sqlx.DB, j *models.FleetUpdateJob) error {
+	if j == nil {
+		return fmt.Errorf("nil fleet job")
+	}
+	spec, err := json.Marshal(fleetJobSpec{j.Filter, j.BatchSize, j.MaxUnavailable, j.HealthTimeoutSeconds})
+	if err != nil {
+		return err
+	}
+	targets, err := json.Marshal(j.Targets)
+	if err != nil {
+		return err
+	}
+	j.UpdatedAt = time.Now().UTC()
+
+	do := func() error {
+		if j.ID == 0 {
+			if j.CreatedAt.IsZero() {
+				j.CreatedAt = j.UpdatedAt
+			}
+			var id int
+			err := db.QueryRow(`INSERT INTO fleet_update_jobs (status, error, spec, targets, created_by, created_at, updated_at)
+                                VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+				j.Status, j.Error, string(spec), string(targets), j.CreatedBy, j.CreatedAt.Unix(), j.UpdatedAt.Unix()).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("insert fleet job: %w", err)
+			}
+			j.ID = id
+			return nil
+		}
+		_, err := db.Exec(`UPDATE fleet_update_jobs SET status=$1, error=$2, targets=$3, updated_at=$4 WHERE id=$5`,
+			j.Status, j.Error, string(targets), j.UpdatedAt.Unix(), j.ID)
+		if err != nil {
+			return fmt.Errorf("update fleet job: %w", err)
+		}
+		return nil
+	}
+	return execWithRetry(do)
+}
+
+func scanFleetUpdateJob(row rowScanner) (*models.FleetUpdateJob, error) {
+	var j models.FleetUpdateJob
+	var errStr, createdBy sql.NullString
+	var spec, targets string
+	var createdAt, updatedAt int64
+	if err := row.Scan(&j.ID, &j.Status, &errStr, &spec, &targets, &createdBy, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	var s fleetJobSpec
+	if err := json.Unmarshal([]byte(spec), &s); err != nil {
+		return nil, fmt.Errorf("decode fleet job spec: %w", err)
+	}
+	if err := json.Unmarshal([]byte(targets), &j.Targets); err != nil {
+		return nil, fmt.Errorf("decode fleet job targets: %w", err)
+	}
+	j.Filter = s.Filter
+	j.BatchSize = s.BatchSize
+	j.MaxUnavailable = s.MaxUnavailable
+	j.HealthTimeoutSeconds = s.HealthTimeoutSeconds
+	j.Error = errStr.String
+	j.CreatedBy = createdBy.String
+	j.CreatedAt = time.Unix(createdAt, 0).UTC()
+	j.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+	return &j, nil
+}
+
+// GetFleetUpdateJob returns a job by ID, or nil if it does not exist.
+func GetFleetUpdateJob(db *sqlx.DB, id int) (*models.FleetUpdateJob, error) {
+	j, err := scanFleetUpdateJob(db.QueryRow(`SELECT id, status, error, spec, targets, created_by, created_at, updated_at FROM fleet_update_jobs WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return j, nil
+}
+
+// ListFleetUpdateJobs returns the most recent jobs first.
+func ListFleetUpdateJobs(db *sqlx.DB, limit int) ([]models.FleetUpdateJob, error) {
+	rows, err := db.Query(`SELECT id, status, error, spec, targets, created_by, created_at, updated_at FROM fleet_update_jobs ORDER BY id DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query fleet jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]models.FleetUpdateJob, 0)
+	for rows.Next() {
+		j, err := scanFleetUpdateJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *j)
+	}
+	return out, rows.Err()
+}
+
+// ListFleetUpdateJobsByStatus returns all jobs currently in the given status.
+func ListFleetUpdateJobsByStatus(db *sqlx.DB, status string) ([]models.FleetUpdateJob, error) {
+	rows, err := db.Query(`SELECT id, status, error, spec, targets, created_by, created_at, updated_at FROM fleet_update_jobs WHERE status = $1`, status)
+	if err != nil {
+		return nil, fmt.Errorf("query fleet jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]models.FleetUpdateJob, 0)
+	for rows.Next() {
+		j, err := scanFleetUpdateJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *j)
+	}
+	return out, rows.Err()
+}
@@ -92,34 +92,36 @@ func InitPlayerSystem(db *sqlx.DB) error {
 		return fmt.Errorf("create reports table: %w", err)
 	}
 
-	return nil
-}
-// -- Reports CRUD --
+	if err := initReportTables(db); err != nil {
+		return err
+	}
 
-func CreateReport(db *sqlx.DB, r *models.Report) (int64, error) {
-	var id int64
-	query := `INSERT INTO player_system.reports (reporter_id, reported_user_id, reason, game_server_instance_id, timestamp)
-			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	if err := initChatTables(db); err != nil {
+		return err
+	}
 
-	r.Timestamp = time.Now().UTC()
+	if err := initPrivacyTables(db); err != nil {
+		return err
+	}
 
-	err := db.QueryRow(query, r.ReporterID, r.ReportedUserID, r.Reason, r.GameServerInstanceID, r.Timestamp).Scan(&id)
-	return id, err
-}
+	if err := initBanTables(db); err != nil {
+		return err
+	}
 
-func GetAllReports(db *sqlx.DB) ([]models.Report, error) {
-	var reports []models.Report
-	query := `
-		SELECT r.*, p1.name as reporter_name, p2.name as reported_user_name
-		FROM player_system.reports r
-		JOIN player_system.players p1 ON r.reporter_id = p1.id
-		JOIN player_system.players p2 ON r.reported_user_id = p2.id
-		ORDER BY r.timestamp DESC
-	`
-	err := db.Select(&reports, query)
-	return reports, err
-}
+	if err := initAnticheatTables(db); err != nil {
+		return err
+	}
+
+	if err := initEvidenceTables(db); err != nil {
+		return err
+	}
 
+	if err := initAppealTables(db); err != nil {
+		return err
+	}
+
+	return nil
+}
 // -- Player CRUD --
 
 func CreatePlayer(db *sqlx.DB, p *models.Player) (int64, error) {
@@ -206,6 +208,9 @@ func SendFriendRequest(db *sqlx.DB, senderID, receiverID int64) error {
 	if count > 0 {
 		return fmt.Errorf("already friends")
 	}
+	if err := CheckFriendRequest(db, senderID, receiverID); err != nil {
+		return err
+	}
 
 	query := `INSERT INTO player_system.friend_requests (sender_id, receiver_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
 	_, err = db.Exec(query, senderID, receiverID)
@@ -274,6 +279,13 @@ func RemoveFriendship(db *sqlx.DB, p1, p2 int64) error {
 	return err
 }
 
+func AreFriends(db *sqlx.DB, a, b int64) (bool, error) {
+	p1, p2 := sortIDs(a, b)
+	var count int
+	err := db.Get(&count, `SELECT COUNT(*) FROM player_system.friendships WHERE player1_id=$1 AND player2_id=$2`, p1, p2)
+	return count > 0, err
+}
+
 func sortIDs(a, b int64) (int64, int64) {
 	if a < b {
 		return a, b
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// initPrivacyTables creates the block list and privacy settings tables in the 'player_system' schema.
+func initPrivacyTables(db *sqlx.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS player_system.player_blocks (
+			blocker_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			blocked_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			PRIMARY KEY (blocker_id, blocked_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_player_blocks_blocked ON player_system.player_blocks(blocked_id)`,
+		`CREATE TABLE IF NOT EXISTS player_system.privacy_settings (
+			player_id BIGINT PRIMARY KEY REFERENCES player_system.players(id) ON DELETE CASCADE,
+			friend_requests TEXT NOT NULL DEFAULT 'everyone',
+			presence TEXT NOT NULL DEFAULT 'friends',
+			messages TEXT NOT NULL DEFAULT 'friends',
+			updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("create privacy tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// -- Block List --
+
+// BlockPlayer adds blocked to blocker's block list. Any friendship and pending friend
+// requests between the two are removed.
+func BlockPlayer(db *sqlx.DB, blockerID, blockedID int64) error {
+	if blockerID == blockedID {
+		return fmt.Errorf("cannot block yourself")
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`INSERT INTO player_system.player_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, blockerID, blockedID); err != nil {
+		return err
+	}
+	p1, p2 := sortIDs(blockerID, blockedID)
+	if _, err := tx.Exec(`DELETE FROM player_system.friendships WHERE player1_id=$1 AND player2_id=$2`, p1, p2); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM player_system.friend_requests WHERE (sender_id=$1 AND receiver_id=$2) OR (sender_id=$2 AND receiver_id=$1)`, blockerID, blockedID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func UnblockPlayer(db *sqlx.DB, blockerID, blockedID int64) error {
+	_, err := db.Exec(`DELETE FROM player_system.player_blocks WHERE blocker_id=$1 AND blocked_id=$2`, blockerID, blockedID)
+	return err
+}
+
+func GetBlockedPlayers(db *sqlx.DB, blockerID int64) ([]models.BlockedPlayer, error) {
+	blocked := []models.BlockedPlayer{}
+	query := `SELECT b.blocked_id as player_id, p.name, b.created_at FROM player_system.player_blocks b
+		JOIN player_system.players p ON p.id = b.blocked_id
+		WHERE b.blocker_id = $1 ORDER BY b.created_at DESC`
+	err := db.Select(&blocked, query, blockerID)
+	return blocked, err
+}
+
+// IsBlocked reports whether either player has blocked the other.
+func IsBlocked(db *sqlx.DB, a, b int64) (bool, error) {
+	var count int
+	err := db.Get(&count, `SELECT COUNT(*) FROM player_system.player_blocks
+		WHERE (blocker_id=$1 AND blocked_id=$2) OR (blocker_id=$2 AND blocked_id=$1)`, a, b)
+	return count > 0, err
+}
+
+// GetBlockersOf returns the players who blocked playerID.
+func GetBlockersOf(db *sqlx.DB, playerID int64) ([]int64, error) {
+	ids := []int64{}
+	err := db.Select(&ids, `SELECT blocker_id FROM player_system.player_blocks WHERE blocked_id = $1`, playerID)
+	return ids, err
+}
+
+// -- Privacy Settings --
+
+// DefaultPrivacySettings returns the settings of a player who never changed them.
+func DefaultPrivacySettings(playerID int64) *models.PrivacySettings {
+	return &models.PrivacySettings{
+		PlayerID:       playerID,
+		FriendRequests: models.PrivacyEveryone,
+		Presence:       models.PrivacyFriends,
+		Messages:       models.PrivacyFriends,
+	}
+}
+
+// ValidatePrivacySettings checks that every setting uses a level it supports.
+func ValidatePrivacySettings(s *models.PrivacySettings) error {
+	switch s.FriendRequests {
+	case models.PrivacyEveryone, models.PrivacyFriendsOfFriends, models.PrivacyNobody:
+	default:
+		return fmt.Errorf("friend_requests must be everyone, friends_of_friends or nobody")
+	}
+	switch s.Presence {
+	case models.PrivacyFriends, models.PrivacyNobody:
+	default:
+		return fmt.Errorf("presence must be friends or nobody")
+	}
+	switch s.Messages {
+	case models.PrivacyEveryone, models.PrivacyFriends, models.PrivacyNobody:
+	default:
+		return fmt.Errorf("messages must be everyone, friends or nobody")
+	}
+	return nil
+}
+
+func GetPrivacySettings(db *sqlx.DB, playerID int64) (*models.PrivacySettings, error) {
+	var s models.PrivacySettings
+	err := db.Get(&s, `SELECT * FROM player_system.privacy_settings WHERE player_id = $1`, playerID)
+	if err == sql.ErrNoRows {
+		return DefaultPrivacySettings(playerID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func SetPrivacySettings(db *sqlx.DB, s *models.PrivacySettings) error {
+	if err := ValidatePrivacySettings(s); err != nil {
+		return err
+	}
+	s.UpdatedAt = time.Now().UTC()
+	query := `INSERT INTO player_system.privacy_settings (player_id, friend_requests, presence, messages, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (player_id) DO UPDATE SET friend_requests = EXCLUDED.friend_requests,
+			presence = EXCLUDED.presence, messages = EXCLUDED.messages, updated_at = EXCLUDED.updated_at`
+	_, err := db.Exec(query, s.PlayerID, s.FriendRequests, s.Presence, s.Messages, s.UpdatedAt)
+	return err
+}
+
+// HaveMutualFriend reports whether two players share at least one friend.
+func HaveMutualFriend(db *sqlx.DB, a, b int64) (bool, error) {
+	var count int
+	query := `
+		WITH fa AS (
+			SELECT CASE WHEN player1_id = $1 THEN player2_id ELSE player1_id END AS id
+			FROM player_system.friendships WHERE player1_id = $1 OR player2_id = $1
+		), fb AS (
+			SELECT CASE WHEN player1_id = $2 THEN player2_id ELSE player1_id END AS id
+			FROM player_system.friendships WHERE player1_id = $2 OR player2_id = $2
+		)
+		SELECT COUNT(*) FROM fa JOIN fb ON fa.id = fb.id`
+	err := db.Get(&count, query, a, b)
+	return count > 0, err
+}
+
+// CheckFriendRequest returns an error if sender may not send receiver a friend request.
+// Blocks are reported like a "nobody" setting so a player cannot tell they were blocked.
+func CheckFriendRequest(db *sqlx.DB, senderID, receiverID int64) error {
+	blocked, err := IsBlocked(db, senderID, receiverID)
+	if err != nil {
+		return err
+	}
+	settings, err := GetPrivacySettings(db, receiverID)
+	if err != nil {
+		return err
+	}
+	if blocked || settings.FriendRequests == models.PrivacyNobody {
+		return fmt.Errorf("player is not accepting friend requests")
+	}
+	if settings.FriendRequests == models.PrivacyFriendsOfFriends {
+		ok, err := HaveMutualFriend(db, senderID, receiverID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("player only accepts friend requests from friends of friends")
+		}
+	}
+	return nil
+}
+
+// CheckDirectMessage returns an error if sender may not send recipient a direct message.
+func CheckDirectMessage(db *sqlx.DB, senderID, recipientID int64) error {
+	blocked, err := IsBlocked(db, senderID, recipientID)
+	if err != nil {
+		return err
+	}
+	settings, err := GetPrivacySettings(db, recipientID)
+	if err != nil {
+		return err
+	}
+	if blocked || settings.Messages == models.PrivacyNobody {
+		return fmt.Errorf("player is not accepting messages")
+	}
+	if settings.Messages == models.PrivacyFriends {
+		ok, err := AreFriends(db, senderID, recipientID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("you can only message friends")
+		}
+	}
+	return nil
+}
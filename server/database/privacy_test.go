@@ -0,0 +1,166 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestValidatePrivacySettings(t *testing.T) {
+	if err := ValidatePrivacySettings(DefaultPrivacySettings(1)); err != nil {
+		t.Fatalf("defaults should be valid: %v", err)
+	}
+
+	cases := []models.PrivacySettings{
+		{FriendRequests: models.PrivacyFriends, Presence: models.PrivacyFriends, Messages: models.PrivacyFriends},
+		{FriendRequests: models.PrivacyEveryone, Presence: models.PrivacyEveryone, Messages: models.PrivacyFriends},
+		{FriendRequests: models.PrivacyEveryone, Presence: models.PrivacyFriends, Messages: models.PrivacyFriendsOfFriends},
+		{FriendRequests: "", Presence: models.PrivacyFriends, Messages: models.PrivacyFriends},
+	}
+	for i, s := range cases {
+		if err := ValidatePrivacySettings(&s); err == nil {
+			t.Errorf("case %d: expected an error for %+v", i, s)
+		}
+	}
+
+	ok := models.PrivacySettings{FriendRequests: models.PrivacyFriendsOfFriends, Presence: models.PrivacyNobody, Messages: models.PrivacyEveryone}
+	if err := ValidatePrivacySettings(&ok); err != nil {
+		t.Fatalf("expected valid settings: %v", err)
+	}
+}
+
+// playerSystemDB connects to the test database and creates the player system tables.
+func playerSystemDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv("DB_DSN")
+	if dsn == "" {
+		t.Skip("DB_DSN not set, skipping DB test")
+	}
+	if err := InitDB(dsn); err != nil {
+		t.Fatalf("init db: %v", err)
+	}
+	if err := InitPlayerSystem(DBConn); err != nil {
+		t.Fatalf("init player system: %v", err)
+	}
+	return DBConn
+}
+
+// testPlayer creates a player that is deleted when the test ends.
+func testPlayer(t *testing.T, db *sqlx.DB, name string) int64 {
+	t.Helper()
+	suffix := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	id, err := CreatePlayer(db, &models.Player{UID: "uid-" + suffix, Name: name, DeviceID: "dev-" + suffix})
+	if err != nil {
+		t.Fatalf("create player: %v", err)
+	}
+	t.Cleanup(func() { _ = DeletePlayer(db, id) })
+	return id
+}
+
+func setPrivacy(t *testing.T, db *sqlx.DB, playerID int64, friendRequests, messages string) {
+	t.Helper()
+	s := &models.PrivacySettings{PlayerID: playerID, FriendRequests: friendRequests, Presence: models.PrivacyFriends, Messages: messages}
+	if err := SetPrivacySettings(db, s); err != nil {
+		t.Fatalf("set privacy: %v", err)
+	}
+}
+
+func befriend(t *testing.T, db *sqlx.DB, a, b int64) {
+	t.Helper()
+	if err := SendFriendRequest(db, a, b); err != nil {
+		t.Fatalf("send friend request: %v", err)
+	}
+	if err := AcceptFriendRequest(db, a, b); err != nil {
+		t.Fatalf("accept friend request: %v", err)
+	}
+}
+
+func TestCheckFriendRequest(t *testing.T) {
+	db := playerSystemDB(t)
+	alice := testPlayer(t, db, "alice")
+	bob := testPlayer(t, db, "bob")
+	carol := testPlayer(t, db, "carol")
+
+	if err := CheckFriendRequest(db, alice, bob); err != nil {
+		t.Fatalf("default settings should accept requests: %v", err)
+	}
+
+	setPrivacy(t, db, bob, models.PrivacyNobody, models.PrivacyFriends)
+	if err := CheckFriendRequest(db, alice, bob); err == nil {
+		t.Error("expected nobody to refuse friend requests")
+	}
+
+	// Friends of friends: refused until alice and bob share carol
+	setPrivacy(t, db, bob, models.PrivacyFriendsOfFriends, models.PrivacyFriends)
+	if err := CheckFriendRequest(db, alice, bob); err == nil {
+		t.Error("expected friends_of_friends to refuse a stranger")
+	}
+	befriend(t, db, alice, carol)
+	befriend(t, db, bob, carol)
+	if err := CheckFriendRequest(db, alice, bob); err != nil {
+		t.Errorf("expected a friend of a friend to be accepted: %v", err)
+	}
+
+	// A block in either direction refuses, and reads like "nobody"
+	setPrivacy(t, db, bob, models.PrivacyEveryone, models.PrivacyFriends)
+	if err := BlockPlayer(db, alice, bob); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckFriendRequest(db, bob, alice)
+	if err == nil || err.Error() != "player is not accepting friend requests" {
+		t.Errorf("expected the blocked player to be refused as if by nobody, got %v", err)
+	}
+	if err := CheckFriendRequest(db, alice, bob); err == nil {
+		t.Error("expected the blocker to be refused too")
+	}
+	if err := UnblockPlayer(db, alice, bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckFriendRequest(db, alice, bob); err != nil {
+		t.Errorf("expected requests to be accepted after unblocking: %v", err)
+	}
+}
+
+func TestCheckDirectMessage(t *testing.T) {
+	db := playerSystemDB(t)
+	alice := testPlayer(t, db, "alice")
+	bob := testPlayer(t, db, "bob")
+
+	// Friends only by default
+	if err := CheckDirectMessage(db, alice, bob); err == nil {
+		t.Error("expected a stranger to be refused by default")
+	}
+	befriend(t, db, alice, bob)
+	if err := CheckDirectMessage(db, alice, bob); err != nil {
+		t.Errorf("expected a friend to be allowed: %v", err)
+	}
+
+	setPrivacy(t, db, bob, models.PrivacyEveryone, models.PrivacyNobody)
+	if err := CheckDirectMessage(db, alice, bob); err == nil {
+		t.Error("expected nobody to refuse a friend")
+	}
+
+	setPrivacy(t, db, bob, models.PrivacyEveryone, models.PrivacyEveryone)
+	if err := CheckDirectMessage(db, alice, bob); err != nil {
+		t.Errorf("expected everyone to allow messages: %v", err)
+	}
+
+	// Blocking ends the friendship and refuses messages both ways
+	if err := BlockPlayer(db, bob, alice); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckDirectMessage(db, alice, bob); err == nil {
+		t.Error("expected the blocked player to be refused")
+	}
+	if err := CheckDirectMessage(db, bob, alice); err == nil {
+		t.Error("expected the blocker to be refused")
+	}
+	if friends, err := AreFriends(db, alice, bob); err != nil || friends {
+		t.Errorf("expected the block to remove the friendship, got %v, %v", friends, err)
+	}
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// -- RedEye Threat Feeds --
+
+const feedColumns = `id, name, source, format, COALESCE(refresh_minutes, 60), enabled, COALESCE(entry_count, 0), COALESCE(hits, 0), last_refreshed, COALESCE(last_error, ''), created_at`
+
+func scanFeed(row rowScanner) (*models.RedEyeFeed, error) {
+	var f models.RedEyeFeed
+	var enabledInt int
+	var refreshedUnix sql.NullInt64
+	var createdUnix int64
+	if err := row.Scan(&f.ID, &f.Name, &f.Source, &f.Format, &f.RefreshMinutes, &enabledInt, &f.EntryCount, &f.Hits, &refreshedUnix, &f.LastError, &createdUnix); err != nil {
+		return nil, err
+	}
+	f.Enabled = enabledInt == 1
+	if refreshedUnix.Valid {
+		t := time.Unix(refreshedUnix.Int64, 0).UTC()
+		f.LastRefreshed = &t
+	}
+	f.CreatedAt = time.Unix(createdUnix, 0).UTC()
+	return &f, nil
+}
+
+func CreateRedEyeFeed(db *sqlx.DB, f *models.RedEyeFeed) (int, error) {
+	var id int
+	do := func() error {
+		query := `INSERT INTO redeye_feeds (name, source, format, refresh_minutes, enabled, created_at)
+                  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+		return db.QueryRow(query, f.Name, f.Source, f.Format, f.RefreshMinutes, boolToInt(f.Enabled), time.Now().Unix()).Scan(&id)
+	}
+	if err := execWithRetry(do); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func GetRedEyeFeeds(db *sqlx.DB) ([]models.RedEyeFeed, error) {
+	rows, err := db.Query(`SELECT ` + feedColumns + ` FROM redeye_feeds ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("query redeye feeds: %w", err)
+	}
+	defer rows.Close()
+
+	out := make([]models.RedEyeFeed, 0)
+	for rows.Next() {
+		f, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *f)
+	}
+	return out, rows.Err()
+}
+
+// GetRedEyeFeedByID returns nil if the feed does not exist.
+func GetRedEyeFeedByID(db *sqlx.DB, id int) (*models.RedEyeFeed, error) {
+	f, err := scanFeed(db.QueryRow(`SELECT `+feedColumns+` FROM redeye_feeds WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+func UpdateRedEyeFeed(db *sqlx.DB, f *models.RedEyeFeed) error {
+	do := func() error {
+		query := `UPDATE redeye_feeds SET name=$1, source=$2, format=$3, refresh_minutes=$4, enabled=$5 WHERE id=$6`
+		_, err := db.Exec(query, f.Name, f.Source, f.Format, f.RefreshMinutes, boolToInt(f.Enabled), f.ID)
+		return err
+	}
+	return execWithRetry(do)
+}
+
+// DeleteRedEyeFeed removes a feed and its entries.
+func DeleteRedEyeFeed(db *sqlx.DB, id int) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM redeye_feed_entries WHERE feed_id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM redeye_feeds WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// feedEntryBatch is how many entries go into one INSERT or DELETE, well under the
+// SQLite and Postgres bind parameter limits.
+const feedEntryBatch = 500
+
+// ReplaceRedEyeFeedEntries makes cidrs the feed's entry list: entries that dropped off
+// the feed are removed and new ones added, keeping the added_at of those that stayed.
+// The feed's entry count and refresh time are updated with them. Rows are written in
+// multi-row batches, so a refresh of a large list costs a handful of statements.
+func ReplaceRedEyeFeedEntries(db *sqlx.DB, feedID int, cidrs []string) (added, removed int, err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var existing []string
+	if err := tx.Select(&existing, `SELECT cidr FROM redeye_feed_entries WHERE feed_id = $1`, feedID); err != nil {
+		return 0, 0, err
+	}
+	have := make(map[string]bool, len(existing))
+	for _, c := range existing {
+		have[c] = true
+	}
+	keep := make(map[string]bool, len(cidrs))
+	var fresh []string
+	for _, c := range cidrs {
+		if keep[c] {
+			continue
+		}
+		keep[c] = true
+		if !have[c] {
+			fresh = append(fresh, c)
+		}
+	}
+	var stale []string
+	for _, c := range existing {
+		if !keep[c] {
+			stale = append(stale, c)
+		}
+	}
+
+	for batch := range slices.Chunk(stale, feedEntryBatch) {
+		args := []interface{}{feedID}
+		marks := make([]string, len(batch))
+		for i, c := range batch {
+			args = append(args, c)
+			marks[i] = fmt.Sprintf("$%d", len(args))
+		}
+		query := `DELETE FROM redeye_feed_entries WHERE feed_id = $1 AND cidr IN (` + strings.Join(marks, ", ") + `)`
+		if _, err := tx.Exec(query, args...); err != nil {
+			return 0, 0, err
+		}
+	}
+	now := time.Now().Unix()
+	for batch := range slices.Chunk(fresh, feedEntryBatch) {
+		args := []interface{}{feedID, now}
+		rows := make([]string, len(batch))
+		for i, c := range batch {
+			args = append(args, c)
+			rows[i] = fmt.Sprintf("($1, $2, $%d)", len(args))
+		}
+		// Numbered in order of first use, which is how SQLite binds them
+		query := `INSERT INTO redeye_feed_entries (feed_id, added_at, cidr) VALUES ` + strings.Join(rows, ", ")
+		if _, err := tx.Exec(query, args...); err != nil {
+			return 0, 0, err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE redeye_feeds SET entry_count = $1, last_refreshed = $2, last_error = '' WHERE id = $3`, len(keep), now, feedID); err != nil {
+		return 0, 0, err
+	}
+	return len(fresh), len(stale), tx.Commit()
+}
+
+// RecordRedEyeFeedError notes a failed refresh; the feed keeps its previous entries.
+func RecordRedEyeFeedError(db *sqlx.DB, feedID int, msg string) error {
+	do := func() error {
+		_, err := db.Exec(`UPDATE redeye_feeds SET last_error = $1 WHERE id = $2`, msg, feedID)
+		return err
+	}
+	return execWithRetry(do)
+}
+
+func GetRedEyeFeedEntries(db *sqlx.DB, feedID, limit int) ([]models.RedEyeFeedEntry, error) {
+	rows, err := db.Query(`SELECT feed_id, cidr, added_at FROM redeye_feed_entries WHERE feed_id = $1 ORDER BY cidr LIMIT $2`, feedID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.RedEyeFeedEntry, 0)
+	for rows.Next() {
+		var e models.RedEyeFeedEntry
+		var addedUnix int64
+		if err := rows.Scan(&e.FeedID, &e.CIDR, &addedUnix); err != nil {
+			return nil, err
+		}
+		e.AddedAt = time.Unix(addedUnix, 0).UTC()
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// GetActiveFeedEntries returns the entries of every enabled feed.
+func GetActiveFeedEntries(db *sqlx.DB) ([]models.RedEyeFeedEntry, error) {
+	rows, err := db.Query(`SELECT e.feed_id, e.cidr FROM redeye_feed_entries e
+		JOIN redeye_feeds f ON f.id = e.feed_id WHERE f.enabled = 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.RedEyeFeedEntry
+	for rows.Next() {
+		var e models.RedEyeFeedEntry
+		if err := rows.Scan(&e.FeedID, &e.CIDR); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// AddRedEyeFeedHits adds to a feed's hit counter.
+func AddRedEyeFeedHits(db *sqlx.DB, feedID int, n int64) error {
+	do := func() error {
+		_, err := db.Exec(`UPDATE redeye_feeds SET hits = hits + $1 WHERE id = $2`, n, feedID)
+		return err
+	}
+	return execWithRetry(do)
+}
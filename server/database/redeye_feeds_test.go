@@ -0,0 +1,70 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestReplaceRedEyeFeedEntries(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // Every connection would get its own in-memory database
+	if err := MigrateDB(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	feedID, err := CreateRedEyeFeed(db, &models.RedEyeFeed{Name: "drop", Source: "/tmp/drop.txt", Format: "plain", RefreshMinutes: 60, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// More entries than one batch holds
+	var first []string
+	for i := 0; i < 1200; i++ {
+		first = append(first, fmt.Sprintf("10.%d.%d.0/24", i/256, i%256))
+	}
+	added, removed, err := ReplaceRedEyeFeedEntries(db, feedID, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1200 || removed != 0 {
+		t.Errorf("first refresh: got +%d/-%d, want +1200/-0", added, removed)
+	}
+
+	// Half the list drops off and a few new ranges appear
+	second := append([]string{"192.0.2.0/24", "198.51.100.7"}, first[600:]...)
+	added, removed, err = ReplaceRedEyeFeedEntries(db, feedID, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 || removed != 600 {
+		t.Errorf("second refresh: got +%d/-%d, want +2/-600", added, removed)
+	}
+
+	entries, err := GetRedEyeFeedEntries(db, feedID, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		got[e.CIDR] = true
+	}
+	if len(got) != 602 || !got["198.51.100.7"] || !got[first[600]] || got[first[0]] {
+		t.Errorf("unexpected entries after refresh: %d entries", len(got))
+	}
+
+	f, err := GetRedEyeFeedByID(db, feedID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.EntryCount != 602 || f.LastRefreshed == nil {
+		t.Errorf("feed not updated: %+v", f)
+	}
+}
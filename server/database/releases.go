@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// -- Release Channels --
+
+const releaseChannelColumns = `id, name, priority, regions, tags, version_id, previous_version_id, rollout_percent,
+        wave_step, wave_interval_seconds, auto_rollback, max_failure_rate, status, status_reason, last_wave_at, updated_at`
+
+func scanReleaseChannel(row rowScanner) (*models.ReleaseChannel, error) {
+	var c models.ReleaseChannel
+	var regions, tags, status, reason sql.NullString
+	var lastWave, updated int64
+	if err := row.Scan(&c.ID, &c.Name, &c.Priority, &regions, &tags, &c.VersionID, &c.PreviousVersionID, &c.RolloutPercent,
+		&c.WaveStep, &c.WaveIntervalSeconds, &c.AutoRollback, &c.MaxFailureRate, &status, &reason, &lastWave, &updated); err != nil {
+		return nil, err
+	}
+	c.Regions = regions.String
+	c.Tags = tags.String
+	c.Status = status.String
+	c.StatusReason = reason.String
+	if lastWave > 0 {
+		c.LastWaveAt = time.Unix(lastWave, 0).UTC()
+	}
+	c.UpdatedAt = time.Unix(updated, 0).UTC()
+	return &c, nil
+}
+
+// ListReleaseChannels returns all channels, highest priority first.
+func ListReleaseChannels(db *sqlx.DB) ([]models.ReleaseChannel, error) {
+	rows, err := db.Query(`SELECT ` + releaseChannelColumns + ` FROM release_channels ORDER BY priority DESC, id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("query release channels: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]models.ReleaseChannel, 0)
+	for rows.Next() {
+		c, err := scanReleaseChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan release channel: %w", err)
+		}
+		out = append(out, *c)
+	}
+	return out, rows.Err()
+}
+
+// GetReleaseChannel returns a channel by ID, or nil if it does not exist.
+func GetReleaseChannel(db *sqlx.DB, id int) (*models.ReleaseChannel, error) {
+	c, err := scanReleaseChannel(db.QueryRow(`SELECT `+releaseChannelColumns+` FROM release_channels WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// SaveReleaseChannel inserts a new channel (ID == 0) or updates an existing one.
+func SaveReleaseChannel(db *sqlx.DB, c *models.ReleaseChannel) (int, error) {
+	if c == nil {
+		return 0, fmt.Errorf("nil release channel")
+	}
+	c.UpdatedAt = time.Now().UTC()
+	var lastWave int64
+	if !c.LastWaveAt.IsZero() {
+		lastWave = c.LastWaveAt.Unix()
+	}
+
+	do := func() error {
+		if c.ID == 0 {
+			var id int
+			err := db.QueryRow(`INSERT INTO release_channels (name, priority, regions, tags, version_id, previous_version_id, rollout_percent,
+                                wave_step, wave_interval_seconds, auto_rollback, max_failure_rate, status, status_reason, last_wave_at, updated_at)
+                                VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`,
+				c.Name, c.Priority, c.Regions, c.Tags, c.VersionID, c.PreviousVersionID, c.RolloutPercent,
+				c.WaveStep, c.WaveIntervalSeconds, boolToInt(c.AutoRollback), c.MaxFailureRate, c.Status, c.StatusReason, lastWave, c.UpdatedAt.Unix()).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("insert release channel: %w", err)
+			}
+			c.ID = id
+			return nil
+		}
+		_, err := db.Exec(`UPDATE release_channels SET name=$1, priority=$2, regions=$3, tags=$4, version_id=$5, previous_version_id=$6,
+                        rollout_percent=$7, wave_step=$8, wave_interval_seconds=$9, auto_rollback=$10, max_failure_rate=$11, status=$12,
+                        status_reason=$13, last_wave_at=$14, updated_at=$15 WHERE id=$16`,
+			c.Name, c.Priority, c.Regions, c.Tags, c.VersionID, c.PreviousVersionID, c.RolloutPercent,
+			c.WaveStep, c.WaveIntervalSeconds, boolToInt(c.AutoRollback), c.MaxFailureRate, c.Status, c.StatusReason, lastWave, c.UpdatedAt.Unix(), c.ID)
+		if err != nil {
+			return fmt.Errorf("update release channel: %w", err)
+		}
+		return nil
+	}
+	if err := execWithRetry(do); err != nil {
+		return 0, err
+	}
+	return c.ID, nil
+}
+
+// DeleteReleaseChannel removes a channel. Subscribed nodes fall back to the active version.
+func DeleteReleaseChannel(db *sqlx.DB, id int) error {
+	do := func() error {
+		_, err := db.Exec(`DELETE FROM release_channels WHERE id = $1`, id)
+		return err
+	}
+	return execWithRetry(do)
+}
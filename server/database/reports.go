@@ -0,0 +1,282 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// initReportTables adds the moderation workflow columns to the reports table and creates
+// the notes and warnings tables in the 'player_system' schema.
+func initReportTables(db *sqlx.DB) error {
+	queries := []string{
+		`ALTER TABLE player_system.reports ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'open'`,
+		`ALTER TABLE player_system.reports ADD COLUMN IF NOT EXISTS assigned_to TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE player_system.reports ADD COLUMN IF NOT EXISTS duplicate_of BIGINT REFERENCES player_system.reports(id) ON DELETE SET NULL`,
+		`ALTER TABLE player_system.reports ADD COLUMN IF NOT EXISTS action TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE player_system.reports ADD COLUMN IF NOT EXISTS resolved_by TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE player_system.reports ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP WITH TIME ZONE`,
+		`ALTER TABLE player_system.reports ADD COLUMN IF NOT EXISTS reporter_notified_at TIMESTAMP WITH TIME ZONE`,
+		`ALTER TABLE player_system.reports ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()`,
+		`CREATE INDEX IF NOT EXISTS idx_reports_reported ON player_system.reports(reported_user_id, status)`,
+		`CREATE INDEX IF NOT EXISTS idx_reports_status ON player_system.reports(status, timestamp DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_reports_duplicate ON player_system.reports(duplicate_of)`,
+		`CREATE TABLE IF NOT EXISTS player_system.report_notes (
+			id BIGSERIAL PRIMARY KEY,
+			report_id BIGINT NOT NULL REFERENCES player_system.reports(id) ON DELETE CASCADE,
+			author TEXT NOT NULL DEFAULT '',
+			body TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_report_notes_report ON player_system.report_notes(report_id)`,
+		`CREATE TABLE IF NOT EXISTS player_system.player_warnings (
+			id BIGSERIAL PRIMARY KEY,
+			player_id BIGINT NOT NULL REFERENCES player_system.players(id) ON DELETE CASCADE,
+			reason TEXT NOT NULL,
+			issued_by TEXT NOT NULL DEFAULT '',
+			report_id BIGINT REFERENCES player_system.reports(id) ON DELETE SET NULL,
+			created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+			delivered_at TIMESTAMP WITH TIME ZONE
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_player_warnings_player ON player_system.player_warnings(player_id, delivered_at)`,
+	}
+	for _, q := range queries {
+		if _, err := db.Exec(q); err != nil {
+			return fmt.Errorf("create report tables: %w", err)
+		}
+	}
+	return nil
+}
+
+// -- Reports CRUD --
+
+const reportColumns = `r.*, p1.name as reporter_name, p2.name as reported_user_name`
+
+const reportJoins = `
+	JOIN player_system.players p1 ON r.reporter_id = p1.id
+	JOIN player_system.players p2 ON r.reported_user_id = p2.id`
+
+// openStatuses is the SQL list of statuses of cases still being worked on.
+const openStatuses = `('open', 'investigating')`
+
+func CreateReport(db *sqlx.DB, r *models.Report) (int64, error) {
+	var id int64
+	query := `INSERT INTO player_system.reports (reporter_id, reported_user_id, reason, game_server_instance_id, timestamp, status, duplicate_of, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $5) RETURNING id`
+
+	r.Timestamp = time.Now().UTC()
+	r.UpdatedAt = r.Timestamp
+	if r.Status == "" {
+		r.Status = models.ReportOpen
+	}
+
+	err := db.QueryRow(query, r.ReporterID, r.ReportedUserID, r.Reason, r.GameServerInstanceID, r.Timestamp, r.Status, r.DuplicateOf).Scan(&id)
+	return id, err
+}
+
+func GetReportByID(db *sqlx.DB, id int64) (*models.Report, error) {
+	var r models.Report
+	query := `SELECT ` + reportColumns + ` FROM player_system.reports r` + reportJoins + ` WHERE r.id = $1`
+	if err := db.Get(&r, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListReports returns reports for triage, newest first. Grouped by case, each case is
+// listed once by its first report.
+func ListReports(db *sqlx.DB, f models.ReportFilter) ([]models.Report, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.Status != "" {
+		where = append(where, "r.status = "+arg(f.Status))
+	}
+	if f.AssignedTo != "" {
+		where = append(where, "r.assigned_to = "+arg(f.AssignedTo))
+	}
+	if f.ReportedUserID != 0 {
+		where = append(where, "r.reported_user_id = "+arg(f.ReportedUserID))
+	}
+	if f.GroupByCase {
+		where = append(where, "r.duplicate_of IS NULL")
+	}
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 500
+	}
+
+	query := `SELECT ` + reportColumns + `,
+		(SELECT COUNT(*) FROM player_system.reports d WHERE d.duplicate_of = r.id) as duplicate_count
+		FROM player_system.reports r` + reportJoins
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY r.timestamp DESC LIMIT " + arg(f.Limit)
+
+	reports := []models.Report{}
+	err := db.Select(&reports, query, args...)
+	return reports, err
+}
+
+// ListReportDuplicates returns the reports linked to a case, oldest first.
+func ListReportDuplicates(db *sqlx.DB, primaryID int64) ([]models.Report, error) {
+	reports := []models.Report{}
+	query := `SELECT ` + reportColumns + ` FROM player_system.reports r` + reportJoins + ` WHERE r.duplicate_of = $1 ORDER BY r.id`
+	err := db.Select(&reports, query, primaryID)
+	return reports, err
+}
+
+// FindOpenCase returns the first report of the open case against a player, or nil.
+func FindOpenCase(db *sqlx.DB, reportedID int64) (*models.Report, error) {
+	var reports []models.Report
+	query := `SELECT r.* FROM player_system.reports r
+		WHERE r.reported_user_id = $1 AND r.duplicate_of IS NULL AND r.status IN ` + openStatuses + `
+		ORDER BY r.id LIMIT 1`
+	if err := db.Select(&reports, query, reportedID); err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	return &reports[0], nil
+}
+
+// FindReportInCase returns the report a reporter already filed in a case, or nil.
+func FindReportInCase(db *sqlx.DB, primaryID, reporterID int64) (*models.Report, error) {
+	var reports []models.Report
+	query := `SELECT r.* FROM player_system.reports r
+		WHERE (r.id = $1 OR r.duplicate_of = $1) AND r.reporter_id = $2 ORDER BY r.id LIMIT 1`
+	if err := db.Select(&reports, query, primaryID, reporterID); err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	return &reports[0], nil
+}
+
+// UpdateReportCase sets the status and assignee of a case and all reports linked to it.
+func UpdateReportCase(db *sqlx.DB, primaryID int64, status, assignedTo string) error {
+	_, err := db.Exec(`UPDATE player_system.reports SET status = $2, assigned_to = $3, updated_at = NOW()
+		WHERE id = $1 OR duplicate_of = $1`, primaryID, status, assignedTo)
+	return err
+}
+
+// ResolveReportCase closes a case and all reports linked to it. It reports false if the
+// case was already closed, so only one moderator's action is applied.
+func ResolveReportCase(db *sqlx.DB, primaryID int64, status, action, by string) (bool, error) {
+	res, err := db.Exec(`UPDATE player_system.reports
+		SET status = $2, action = $3, resolved_by = $4, resolved_at = NOW(), reporter_notified_at = NULL, updated_at = NOW()
+		WHERE (id = $1 OR duplicate_of = $1) AND status IN `+openStatuses, primaryID, status, action, by)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ReopenReportCase undoes ResolveReportCase when the action could not be applied.
+func ReopenReportCase(db *sqlx.DB, primaryID int64, status string) error {
+	_, err := db.Exec(`UPDATE player_system.reports
+		SET status = $2, action = '', resolved_by = '', resolved_at = NULL, updated_at = NOW()
+		WHERE id = $1 OR duplicate_of = $1`, primaryID, status)
+	return err
+}
+
+// GetUnnotifiedOutcomes returns resolved reports whose reporter has not been told yet.
+func GetUnnotifiedOutcomes(db *sqlx.DB, reporterID int64) ([]models.Report, error) {
+	reports := []models.Report{}
+	query := `SELECT ` + reportColumns + ` FROM player_system.reports r` + reportJoins + `
+		WHERE r.reporter_id = $1 AND r.status IN ('actioned', 'dismissed') AND r.reporter_notified_at IS NULL
+		ORDER BY r.id`
+	err := db.Select(&reports, query, reporterID)
+	return reports, err
+}
+
+func MarkReportsNotified(db *sqlx.DB, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`UPDATE player_system.reports SET reporter_notified_at = NOW() WHERE id IN (?)`, ids)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(db.Rebind(query), args...)
+	return err
+}
+
+// GetMostReportedPlayers ranks players by distinct reporters since a time.
+func GetMostReportedPlayers(db *sqlx.DB, since time.Time, limit int) ([]models.ReportedPlayer, error) {
+	players := []models.ReportedPlayer{}
+	query := `
+		SELECT r.reported_user_id as player_id, p.name,
+			COUNT(*) as reports,
+			COUNT(DISTINCT r.reporter_id) as reporters,
+			COUNT(*) FILTER (WHERE r.status IN ` + openStatuses + `) as open_reports,
+			MAX(r.timestamp) as last_reported_at
+		FROM player_system.reports r
+		JOIN player_system.players p ON p.id = r.reported_user_id
+		WHERE r.timestamp >= $1
+		GROUP BY r.reported_user_id, p.name
+		ORDER BY reporters DESC, reports DESC
+		LIMIT $2`
+	err := db.Select(&players, query, since, limit)
+	return players, err
+}
+
+// -- Report Notes --
+
+func AddReportNote(db *sqlx.DB, n *models.ReportNote) (int64, error) {
+	n.CreatedAt = time.Now().UTC()
+	var id int64
+	err := db.QueryRow(`INSERT INTO player_system.report_notes (report_id, author, body, created_at)
+		VALUES ($1, $2, $3, $4) RETURNING id`, n.ReportID, n.Author, n.Body, n.CreatedAt).Scan(&id)
+	return id, err
+}
+
+// ListReportNotes returns the notes of a report, oldest first. Notes are kept on the
+// first report of a case.
+func ListReportNotes(db *sqlx.DB, reportID int64) ([]models.ReportNote, error) {
+	notes := []models.ReportNote{}
+	err := db.Select(&notes, `SELECT * FROM player_system.report_notes WHERE report_id = $1 ORDER BY id`, reportID)
+	return notes, err
+}
+
+// -- Warnings --
+
+func CreateWarning(db *sqlx.DB, w *models.PlayerWarning) (int64, error) {
+	w.CreatedAt = time.Now().UTC()
+	var id int64
+	err := db.QueryRow(`INSERT INTO player_system.player_warnings (player_id, reason, issued_by, report_id, created_at, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`, w.PlayerID, w.Reason, w.IssuedBy, w.ReportID, w.CreatedAt, w.DeliveredAt).Scan(&id)
+	return id, err
+}
+
+func ListPlayerWarnings(db *sqlx.DB, playerID int64) ([]models.PlayerWarning, error) {
+	warnings := []models.PlayerWarning{}
+	err := db.Select(&warnings, `SELECT * FROM player_system.player_warnings WHERE player_id = $1 ORDER BY id DESC`, playerID)
+	return warnings, err
+}
+
+func GetUndeliveredWarnings(db *sqlx.DB, playerID int64) ([]models.PlayerWarning, error) {
+	warnings := []models.PlayerWarning{}
+	err := db.Select(&warnings, `SELECT * FROM player_system.player_warnings WHERE player_id = $1 AND delivered_at IS NULL ORDER BY id`, playerID)
+	return warnings, err
+}
+
+func MarkWarningsDelivered(db *sqlx.DB, playerID, upToID int64) error {
+	_, err := db.Exec(`UPDATE player_system.player_warnings SET delivered_at = NOW()
+		WHERE player_id = $1 AND id <= $2 AND delivered_at IS NULL`, playerID, upToID)
+	return err
+}
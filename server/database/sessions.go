@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// -- Player Sessions --
+
+const playerSessionColumns = `id, player_id, node_id, instance_id, started_at, ended_at, end_reason`
+
+func scanPlayerSession(row rowScanner) (*models.PlayerSession, error) {
+	var s models.PlayerSession
+	var started, ended int64
+	var reason sql.NullString
+	if err := row.Scan(&s.ID, &s.PlayerID, &s.NodeID, &s.InstanceID, &started, &ended, &reason); err != nil {
+		return nil, err
+	}
+	s.StartedAt = time.Unix(started, 0).UTC()
+	s.EndReason = reason.String
+	if ended > 0 {
+		t := time.Unix(ended, 0).UTC()
+		s.EndedAt = &t
+		s.DurationSeconds = ended - started
+	} else {
+		s.Active = true
+		s.DurationSeconds = time.Now().Unix() - started
+	}
+	return &s, nil
+}
+
+func queryPlayerSessions(db *sqlx.DB, query string, args ...interface{}) ([]models.PlayerSession, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query player sessions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make([]models.PlayerSession, 0)
+	for rows.Next() {
+		s, err := scanPlayerSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan player session: %w", err)
+		}
+		out = append(out, *s)
+	}
+	return out, rows.Err()
+}
+
+// StartPlayerSession opens a session. Any session the player still has open elsewhere
+// is closed first with reason "moved", since a player is only on one server at a time.
+func StartPlayerSession(db *sqlx.DB, playerID int64, nodeID int, instanceID string, at time.Time) (*models.PlayerSession, error) {
+	s := &models.PlayerSession{PlayerID: playerID, NodeID: nodeID, InstanceID: instanceID, StartedAt: at.UTC(), Active: true}
+	do := func() error {
+		if _, err := db.Exec(`UPDATE player_sessions SET ended_at = $1, end_reason = 'moved' WHERE player_id = $2 AND ended_at = 0`,
+			at.Unix(), playerID); err != nil {
+			return fmt.Errorf("close previous sessions: %w", err)
+		}
+		var id int64
+		if err := db.QueryRow(`INSERT INTO player_sessions (player_id, node_id, instance_id, started_at, ended_at, end_reason)
+                        VALUES ($1, $2, $3, $4, 0, '') RETURNING id`, playerID, nodeID, instanceID, at.Unix()).Scan(&id); err != nil {
+			return fmt.Errorf("insert player session: %w", err)
+		}
+		s.ID = id
+		return nil
+	}
+	if err := execWithRetry(do); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// EndPlayerSession closes the player's open session on an instance. Returns false if
+// there was none (e.g. a leave without a matching join).
+func EndPlayerSession(db *sqlx.DB, playerID int64, nodeID int, instanceID string, at time.Time, reason string) (bool, error) {
+	var n int64
+	do := func() error {
+		res, err := db.Exec(`UPDATE player_sessions SET ended_at = $1, end_reason = $2
+                        WHERE player_id = $3 AND node_id = $4 AND instance_id = $5 AND ended_at = 0`,
+			at.Unix(), reason, playerID, nodeID, instanceID)
+		if err != nil {
+			return fmt.Errorf("end player session: %w", err)
+		}
+		n, _ = res.RowsAffected()
+		return nil
+	}
+	if err := execWithRetry(do); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// EndSessionByID closes a single open session.
+func EndSessionByID(db *sqlx.DB, id int64, at time.Time, reason string) error {
+	do := func() error {
+		_, err := db.Exec(`UPDATE player_sessions SET ended_at = $1, end_reason = $2 WHERE id = $3 AND ended_at = 0`, at.Unix(), reason, id)
+		return err
+	}
+	return execWithRetry(do)
+}
+
+// ListPlayerSessions returns a player's sessions, newest first.
+func ListPlayerSessions(db *sqlx.DB, playerID int64, limit int) ([]models.PlayerSession, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return queryPlayerSessions(db, `SELECT `+playerSessionColumns+` FROM player_sessions
+                WHERE player_id = $1 ORDER BY started_at DESC, id DESC LIMIT $2`, playerID, limit)
+}
+
+// ListInstanceSessions returns the sessions on an instance, newest first.
+func ListInstanceSessions(db *sqlx.DB, nodeID int, instanceID string, limit int) ([]models.PlayerSession, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return queryPlayerSessions(db, `SELECT `+playerSessionColumns+` FROM player_sessions
+                WHERE node_id = $1 AND instance_id = $2 ORDER BY started_at DESC, id DESC LIMIT $3`, nodeID, instanceID, limit)
+}
+
+// ListOpenSessions returns the sessions still open on a node (instanceID "" = all instances).
+func ListOpenSessions(db *sqlx.DB, nodeID int, instanceID string) ([]models.PlayerSession, error) {
+	if instanceID == "" {
+		return queryPlayerSessions(db, `SELECT `+playerSessionColumns+` FROM player_sessions
+                        WHERE node_id = $1 AND ended_at = 0 ORDER BY started_at ASC`, nodeID)
+	}
+	return queryPlayerSessions(db, `SELECT `+playerSessionColumns+` FROM player_sessions
+                WHERE node_id = $1 AND instance_id = $2 AND ended_at = 0 ORDER BY started_at ASC`, nodeID, instanceID)
+}
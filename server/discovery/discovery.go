@@ -0,0 +1,367 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/autoscaler"
+	"exile/server/models"
+	"exile/server/registry"
+	"exile/server/utils"
+	"exile/server/ws"
+	"exile/server/ws_player"
+)
+
+// =================================================================================
+// SERVER BROWSER: joinable instances for game clients (REST + player WS feed)
+// =================================================================================
+
+const (
+	// Instances whose game server has reported before but has gone quiet this long are hidden
+	staleTelemetryAfter = 60 * time.Second
+
+	listTimeout = 5 * time.Second
+
+	MsgSubscribe   = "SERVER_LIST_SUBSCRIBE"
+	MsgUnsubscribe = "SERVER_LIST_UNSUBSCRIBE"
+	MsgSnapshot    = "SERVER_LIST_SNAPSHOT"
+	MsgUpdate      = "SERVER_LIST_UPDATE"
+)
+
+// Server is one joinable instance as shown to game clients.
+type Server struct {
+	ID         string `json:"id"` // "<node_id>/<instance_id>", unique across the fleet
+	NodeID     int    `json:"node_id"`
+	InstanceID string `json:"instance_id"`
+	Region     string `json:"region"`
+	Version    string `json:"version"`
+	Address    string `json:"address"`
+	Port       int    `json:"port"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"max_players"` // 0 = unknown
+	Map        string `json:"map,omitempty"`
+	GameMode   string `json:"game_mode,omitempty"`
+	Full       bool   `json:"full"`
+}
+
+// Filter narrows the list. Empty fields match everything.
+type Filter struct {
+	Region   string `json:"region,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Map      string `json:"map,omitempty"`
+	GameMode string `json:"game_mode,omitempty"`
+	NotFull  bool   `json:"not_full,omitempty"`
+	Seats    int    `json:"seats,omitempty"` // Free slots needed, e.g. the size of a party
+}
+
+// Match reports whether s passes the filter.
+func (f Filter) Match(s Server) bool {
+	if f.Region != "" && !strings.EqualFold(s.Region, f.Region) {
+		return false
+	}
+	if f.Version != "" && s.Version != f.Version {
+		return false
+	}
+	if f.Map != "" && !strings.EqualFold(s.Map, f.Map) {
+		return false
+	}
+	if f.GameMode != "" && !strings.EqualFold(s.GameMode, f.GameMode) {
+		return false
+	}
+	if f.NotFull && s.Full {
+		return false
+	}
+	if f.Seats > 0 && (s.Full || (s.MaxPlayers > 0 && s.MaxPlayers-s.Players < f.Seats)) {
+		return false
+	}
+	return true
+}
+
+// Update is the diff pushed to subscribers after a refresh.
+type Update struct {
+	Updated []Server `json:"updated"` // New or changed servers
+	Removed []string `json:"removed"` // Server IDs no longer listed (or no longer matching the filter)
+}
+
+var (
+	mu        sync.RWMutex
+	servers   = map[string]Server{}
+	updatedAt time.Time
+
+	subsMu sync.Mutex
+	subs   = map[int64]Filter{}
+
+	done    chan struct{}
+	wg      sync.WaitGroup
+	running bool
+)
+
+// connectAddress prefers the node's public IP override over the address it registered with.
+func connectAddress(n *models.Node) string {
+	if n.PublicIP != "" {
+		return n.PublicIP
+	}
+	return n.Host
+}
+
+// nodeListable reports whether a node's instances may be shown at all.
+func nodeListable(n *models.Node) bool {
+	return n.Status == "Online" && !n.IsDraining
+}
+
+// instanceJoinable hides instances that are not running, being retired by the
+// autoscaler, or whose game server has stopped reporting.
+func instanceJoinable(nodeID int, inst models.GameInstance, now time.Time) bool {
+	if inst.Status != "Running" {
+		return false
+	}
+	if !inst.LastReportAt.IsZero() && now.Sub(inst.LastReportAt) > staleTelemetryAfter {
+		return false
+	}
+	return !autoscaler.IsInstanceDraining(nodeID, inst.ID)
+}
+
+// ServerFromInstance builds the client-facing entry for an instance on node n.
+func ServerFromInstance(n *models.Node, inst models.GameInstance) Server {
+	region := inst.Region
+	if region == "" {
+		region = n.Region
+	}
+	version := inst.Version
+	if version == "" {
+		version = n.GameVersion
+	}
+	return Server{
+		ID:         fmt.Sprintf("%d/%s", n.ID, inst.ID),
+		NodeID:     n.ID,
+		InstanceID: inst.ID,
+		Region:     region,
+		Version:    version,
+		Address:    connectAddress(n),
+		Port:       inst.Port,
+		Players:    inst.PlayerCount,
+		MaxPlayers: inst.MaxPlayers,
+		Map:        inst.Map,
+		GameMode:   inst.GameMode,
+		Full:       inst.MaxPlayers > 0 && inst.PlayerCount >= inst.MaxPlayers,
+	}
+}
+
+// List returns the servers matching f, most populated first.
+func List(f Filter) []Server {
+	mu.RLock()
+	defer mu.RUnlock()
+	return filterSorted(servers, f)
+}
+
+// Get returns a single listed server by ID.
+func Get(id string) (Server, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := servers[id]
+	return s, ok
+}
+
+// UpdatedAt is when the list was last rebuilt.
+func UpdatedAt() time.Time {
+	mu.RLock()
+	defer mu.RUnlock()
+	return updatedAt
+}
+
+func filterSorted(all map[string]Server, f Filter) []Server {
+	out := make([]Server, 0, len(all))
+	for _, s := range all {
+		if f.Match(s) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Players != out[j].Players {
+			return out[i].Players > out[j].Players
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// diff computes what a subscriber with filter f has to be told when the list goes from prev to next.
+func diff(prev, next map[string]Server, f Filter) Update {
+	u := Update{Updated: []Server{}, Removed: []string{}}
+	for id, s := range next {
+		if !f.Match(s) {
+			continue
+		}
+		if old, ok := prev[id]; !ok || !f.Match(old) || !reflect.DeepEqual(old, s) {
+			u.Updated = append(u.Updated, s)
+		}
+	}
+	for id, old := range prev {
+		if !f.Match(old) {
+			continue
+		}
+		if s, ok := next[id]; !ok || !f.Match(s) {
+			u.Removed = append(u.Removed, id)
+		}
+	}
+	sort.Slice(u.Updated, func(i, j int) bool { return u.Updated[i].ID < u.Updated[j].ID })
+	sort.Strings(u.Removed)
+	return u
+}
+
+// Refresh rebuilds the list from the registry and the nodes' instance reports, then
+// pushes the changes to subscribed players.
+func Refresh() {
+	now := time.Now()
+	nodes := registry.GlobalRegistry.List()
+
+	var (
+		collectMu sync.Mutex
+		collectWg sync.WaitGroup
+		next      = map[string]Server{}
+	)
+	for i := range nodes {
+		n := nodes[i]
+		if !nodeListable(&n) || !ws.GlobalWSManager.IsClientConnected(n.ID) {
+			continue
+		}
+		collectWg.Add(1)
+		go func() {
+			defer collectWg.Done()
+			instances, err := ws.GlobalWSManager.ListInstances(n.ID, listTimeout)
+			if err != nil {
+				// Treat an unreachable node as unhealthy: its servers drop out until it answers again
+				log.Printf("Discovery: list instances on node %d: %v", n.ID, err)
+				return
+			}
+			collectMu.Lock()
+			defer collectMu.Unlock()
+			for _, inst := range instances {
+				if instanceJoinable(n.ID, inst, now) {
+					s := ServerFromInstance(&n, inst)
+					next[s.ID] = s
+				}
+			}
+		}()
+	}
+	collectWg.Wait()
+
+	mu.Lock()
+	prev := servers
+	servers = next
+	updatedAt = now.UTC()
+	mu.Unlock()
+
+	publish(prev, next)
+}
+
+// -- Player WS feed --
+
+func publish(prev, next map[string]Server) {
+	if ws_player.GlobalPlayerWS == nil {
+		return
+	}
+	subsMu.Lock()
+	targets := make(map[int64]Filter, len(subs))
+	for id, f := range subs {
+		targets[id] = f
+	}
+	subsMu.Unlock()
+
+	for playerID, f := range targets {
+		u := diff(prev, next, f)
+		if len(u.Updated) == 0 && len(u.Removed) == 0 {
+			continue
+		}
+		ws_player.GlobalPlayerWS.SendMessage(playerID, ws_player.NewMessage(MsgUpdate, u))
+	}
+}
+
+func handleSubscribe(c *ws_player.PlayerConnection, payload json.RawMessage) {
+	var f Filter
+	if len(payload) > 0 && string(payload) != "null" {
+		if err := json.Unmarshal(payload, &f); err != nil {
+			ws_player.GlobalPlayerWS.SendError(c.PlayerID, "invalid server list filter")
+			return
+		}
+	}
+	if f.Seats == 0 {
+		// Party members browse for servers the whole party fits on
+		if n := ws_player.GlobalPlayerWS.PartySize(c.PlayerID); n > 1 {
+			f.Seats = n
+		}
+	}
+	subsMu.Lock()
+	subs[c.PlayerID] = f
+	subsMu.Unlock()
+
+	ws_player.GlobalPlayerWS.SendMessage(c.PlayerID, ws_player.NewMessage(MsgSnapshot, map[string]interface{}{
+		"servers":    List(f),
+		"updated_at": UpdatedAt(),
+	}))
+}
+
+func handleUnsubscribe(c *ws_player.PlayerConnection, _ json.RawMessage) {
+	unsubscribe(c.PlayerID)
+}
+
+func unsubscribe(playerID int64) {
+	subsMu.Lock()
+	delete(subs, playerID)
+	subsMu.Unlock()
+}
+
+// StartDiscovery registers the player WS messages and starts refreshing the list
+// every DISCOVERY_REFRESH_INTERVAL (default 5s).
+func StartDiscovery() {
+	if running {
+		return
+	}
+	if ws_player.GlobalPlayerWS != nil {
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgSubscribe, handleSubscribe)
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgUnsubscribe, handleUnsubscribe)
+		ws_player.GlobalPlayerWS.OnDisconnect(unsubscribe)
+	}
+
+	interval := utils.GetEnvDuration("DISCOVERY_REFRESH_INTERVAL", 5*time.Second)
+	if interval < time.Second {
+		interval = time.Second
+	}
+	done = make(chan struct{})
+	running = true
+	wg.Add(1)
+	go discoveryLoop(interval)
+}
+
+// StopDiscovery stops the refresh loop.
+func StopDiscovery() {
+	if !running {
+		return
+	}
+	close(done)
+	wg.Wait()
+	running = false
+}
+
+func discoveryLoop(interval time.Duration) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	Refresh()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			Refresh()
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"exile/server/models"
+)
+
+func TestServerFromInstance(t *testing.T) {
+	n := &models.Node{ID: 3, Region: "eu", Host: "10.0.0.3", PublicIP: "203.0.113.7", GameVersion: "1.2"}
+	s := ServerFromInstance(n, models.GameInstance{ID: "eu-7777", Port: 7777, PlayerCount: 16, MaxPlayers: 16})
+
+	if s.ID != "3/eu-7777" || s.Address != "203.0.113.7" {
+		t.Fatalf("unexpected identity/address: %+v", s)
+	}
+	if s.Region != "eu" || s.Version != "1.2" {
+		t.Fatalf("expected node region/version fallback, got %q/%q", s.Region, s.Version)
+	}
+	if !s.Full {
+		t.Fatal("expected server at max players to be full")
+	}
+
+	n.PublicIP = ""
+	if s := ServerFromInstance(n, models.GameInstance{ID: "a"}); s.Address != "10.0.0.3" || s.Full {
+		t.Fatalf("expected host fallback and unknown capacity to be joinable, got %+v", s)
+	}
+}
+
+func TestInstanceJoinable(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name string
+		inst models.GameInstance
+		want bool
+	}{
+		{"running, never reported", models.GameInstance{ID: "a", Status: "Running"}, true},
+		{"running, fresh telemetry", models.GameInstance{ID: "a", Status: "Running", LastReportAt: now.Add(-5 * time.Second)}, true},
+		{"running, stale telemetry", models.GameInstance{ID: "a", Status: "Running", LastReportAt: now.Add(-2 * time.Minute)}, false},
+		{"stopped", models.GameInstance{ID: "a", Status: "Stopped"}, false},
+		{"provisioning", models.GameInstance{ID: "a", Status: "Provisioning"}, false},
+	}
+	for _, tc := range cases {
+		if got := instanceJoinable(1, tc.inst, now); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	if nodeListable(&models.Node{Status: "Online", IsDraining: true}) {
+		t.Error("draining node should not be listed")
+	}
+	if nodeListable(&models.Node{Status: "Offline"}) {
+		t.Error("offline node should not be listed")
+	}
+}
+
+func TestFilterAndDiff(t *testing.T) {
+	prev := map[string]Server{
+		"1/a": {ID: "1/a", Region: "eu", Version: "1.0", Players: 2, MaxPlayers: 4},
+		"1/b": {ID: "1/b", Region: "eu", Version: "1.0", Players: 1, MaxPlayers: 4},
+		"2/c": {ID: "2/c", Region: "us", Version: "1.0"},
+	}
+	next := map[string]Server{
+		"1/a": {ID: "1/a", Region: "eu", Version: "1.0", Players: 4, MaxPlayers: 4, Full: true},
+		"1/b": {ID: "1/b", Region: "eu", Version: "1.0", Players: 1, MaxPlayers: 4},
+		"1/d": {ID: "1/d", Region: "EU", Version: "1.0"},
+	}
+
+	if got := filterSorted(next, Filter{Region: "eu", NotFull: true}); len(got) != 2 || got[0].ID != "1/b" {
+		t.Fatalf("unexpected filtered list: %+v", got)
+	}
+
+	u := diff(prev, next, Filter{Region: "eu", NotFull: true})
+	if len(u.Updated) != 1 || u.Updated[0].ID != "1/d" {
+		t.Errorf("expected only the new server as updated, got %+v", u.Updated)
+	}
+	if len(u.Removed) != 1 || u.Removed[0] != "1/a" {
+		t.Errorf("expected the now-full server removed, got %v", u.Removed)
+	}
+
+	u = diff(prev, next, Filter{})
+	if len(u.Updated) != 2 || len(u.Removed) != 1 || u.Removed[0] != "2/c" {
+		t.Errorf("unexpected unfiltered diff: %+v", u)
+	}
+}
+
+func TestFilterSeats(t *testing.T) {
+	f := Filter{Seats: 3}
+	cases := []struct {
+		s    Server
+		want bool
+	}{
+		{Server{Players: 5, MaxPlayers: 8}, true},
+		{Server{Players: 6, MaxPlayers: 8}, false},
+		{Server{Players: 40}, true}, // Unknown capacity
+		{Server{Players: 40, Full: true}, false},
+	}
+	for _, tc := range cases {
+		if got := f.Match(tc.s); got != tc.want {
+			t.Errorf("Match(%+v) = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+}
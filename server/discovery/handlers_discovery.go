@@ -0,0 +1,36 @@
+package discovery
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"exile/server/utils"
+)
+
+// -- Server Browser Handlers --
+
+// ListServersHandler returns joinable servers for game clients.
+//
+// Query parameters (all optional):
+//   - region, version, map, game_mode: exact (case-insensitive except version) match
+//   - not_full: "true" hides servers at max players
+//   - seats: hide servers with fewer free slots, e.g. the size of a party
+func ListServersHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := Filter{
+		Region:   q.Get("region"),
+		Version:  q.Get("version"),
+		Map:      q.Get("map"),
+		GameMode: q.Get("game_mode"),
+		NotFull:  strings.EqualFold(q.Get("not_full"), "true") || q.Get("not_full") == "1",
+	}
+	f.Seats, _ = strconv.Atoi(q.Get("seats"))
+
+	list := List(f)
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"servers":    list,
+		"count":      len(list),
+		"updated_at": UpdatedAt(),
+	})
+}
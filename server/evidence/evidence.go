@@ -0,0 +1,256 @@
+package evidence
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+)
+
+// =================================================================================
+// EVIDENCE: content-addressed anticheat evidence in an append-only hash chain
+// =================================================================================
+
+const (
+	// GenesisHash is the previous hash of the first chain entry.
+	GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	maxNameLen  = 200
+	verifyBatch = 1000
+)
+
+var (
+	// Dir holds the evidence content, one file per SHA-256 (EVIDENCE_DIR).
+	Dir = filepath.Join("files", "evidence")
+
+	// MaxSize is the largest item accepted (EVIDENCE_MAX_SIZE_MB).
+	MaxSize int64 = 32 << 20
+)
+
+// ErrTooLarge is returned for content over MaxSize.
+var ErrTooLarge = fmt.Errorf("evidence is too large")
+
+// InitEvidence reads the evidence settings and creates the content directory.
+func InitEvidence() error {
+	Dir = utils.GetEnv("EVIDENCE_DIR", Dir)
+	MaxSize = int64(utils.GetEnvInt("EVIDENCE_MAX_SIZE_MB", int(MaxSize>>20))) << 20
+	return os.MkdirAll(Dir, 0750)
+}
+
+// EntryHash is the chain hash of an item: SHA-256 over the previous entry's hash and
+// every field of the item except its ID.
+func EntryHash(prev string, e *models.Evidence) string {
+	var playerID int64
+	if e.PlayerID != nil {
+		playerID = *e.PlayerID
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%d\n%d\n%s\n%s\n%s\n%s\n%d\n%d",
+		prev, e.EventID, playerID, e.Kind, e.Name, e.ContentType, e.SHA256, e.Size, e.CreatedAt.UnixMicro())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// contentPath is where content with the given SHA-256 is stored.
+func contentPath(sum string) string {
+	return filepath.Join(Dir, sum[:2], sum)
+}
+
+// validSum reports whether s looks like a hex SHA-256.
+func validSum(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// store writes content under its SHA-256. Content that is already stored is kept as is.
+func store(r io.Reader) (sum string, size int64, err error) {
+	if err := os.MkdirAll(Dir, 0750); err != nil {
+		return "", 0, err
+	}
+	tmp, err := os.CreateTemp(Dir, ".upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, MaxSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if size > MaxSize {
+		return "", 0, ErrTooLarge
+	}
+
+	sum = hex.EncodeToString(h.Sum(nil))
+	dst := contentPath(sum)
+	if _, err := os.Stat(dst); err == nil {
+		return sum, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", 0, err
+	}
+	_ = os.Chmod(dst, 0440)
+	return sum, size, nil
+}
+
+// Open returns the content of an item, or an error if it is missing.
+func Open(e *models.Evidence) (*os.File, error) {
+	if !validSum(e.SHA256) {
+		return nil, fmt.Errorf("invalid content hash")
+	}
+	return os.Open(contentPath(e.SHA256))
+}
+
+// checkContent reports whether an item's stored content still hashes to its SHA-256.
+func checkContent(e *models.Evidence) error {
+	f, err := Open(e)
+	if err != nil {
+		return fmt.Errorf("content missing")
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("content unreadable")
+	}
+	if hex.EncodeToString(h.Sum(nil)) != e.SHA256 {
+		return fmt.Errorf("content modified")
+	}
+	return nil
+}
+
+// Attach stores content for an anticheat event and appends it to the chain. JSON
+// content is stored as a payload, re-encoded compactly so equal payloads share a hash;
+// anything else is stored as a blob.
+func Attach(eventID int64, name, contentType string, r io.Reader) (*models.Evidence, error) {
+	if database.DBConn == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	name = strings.TrimSpace(name)
+	if len(name) > maxNameLen {
+		return nil, fmt.Errorf("name is too long")
+	}
+	event, err := database.GetAnticheatEvent(database.DBConn, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		return nil, fmt.Errorf("event not found")
+	}
+
+	kind := models.EvidenceBlob
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0]); mediaType == "application/json" {
+		raw, err := io.ReadAll(io.LimitReader(r, MaxSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(raw)) > MaxSize {
+			return nil, ErrTooLarge
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON payload")
+		}
+		kind, contentType, r = models.EvidencePayload, mediaType, &compact
+	}
+
+	sum, size, err := store(r)
+	if err != nil {
+		return nil, err
+	}
+	e := &models.Evidence{
+		EventID:     eventID,
+		PlayerID:    event.AccountID,
+		Kind:        kind,
+		Name:        name,
+		ContentType: contentType,
+		SHA256:      sum,
+		Size:        size,
+		// Postgres keeps microseconds; the hash must cover what is stored
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := database.AppendEvidence(database.DBConn, e, GenesisHash, EntryHash); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Verification is the result of checking the evidence chain.
+type Verification struct {
+	Entries  int    `json:"entries"`
+	Valid    bool   `json:"valid"`
+	HeadHash string `json:"head_hash"`           // Hash of the last entry; record it to detect truncation later
+	BrokenAt int64  `json:"broken_at,omitempty"` // First entry that failed
+	Problem  string `json:"problem,omitempty"`
+}
+
+// verifyEntries checks that entries continue the chain from prev. Content is checked too
+// if content is set.
+func verifyEntries(v *Verification, prev string, items []models.Evidence, content bool) string {
+	for i := range items {
+		e := &items[i]
+		v.Entries++
+		switch {
+		case e.PrevHash != prev:
+			v.Problem = "entry does not follow the previous entry; entries were removed or reordered"
+		case EntryHash(prev, e) != e.EntryHash:
+			v.Problem = "entry was modified"
+		case content:
+			if err := checkContent(e); err != nil {
+				v.Problem = err.Error()
+			}
+		}
+		if v.Problem != "" {
+			v.Valid = false
+			v.BrokenAt = e.ID
+			return prev
+		}
+		prev = e.EntryHash
+	}
+	return prev
+}
+
+// Verify walks the whole chain and stops at the first broken entry. With content set,
+// every item's content is hashed as well.
+func Verify(content bool) (*Verification, error) {
+	if database.DBConn == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	v := &Verification{Valid: true}
+	prev := GenesisHash
+	var after int64
+	for {
+		items, err := database.ListEvidenceChain(database.DBConn, after, verifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		prev = verifyEntries(v, prev, items, content)
+		if !v.Valid || len(items) < verifyBatch {
+			break
+		}
+		after = items[len(items)-1].ID
+	}
+	v.HeadHash = prev
+	return v, nil
+}
@@ -0,0 +1,94 @@
+package evidence
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"exile/server/models"
+)
+
+// chain builds n linked entries the way AppendEvidence does.
+func chain(n int) []models.Evidence {
+	items := make([]models.Evidence, n)
+	prev := GenesisHash
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range items {
+		e := &items[i]
+		e.ID = int64(i + 1)
+		e.EventID = 7
+		e.Kind = models.EvidencePayload
+		e.SHA256 = strings.Repeat("ab", 32)
+		e.Size = int64(10 * i)
+		e.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		e.PrevHash = prev
+		e.EntryHash = EntryHash(prev, e)
+		prev = e.EntryHash
+	}
+	return items
+}
+
+func TestVerifyEntries(t *testing.T) {
+	items := chain(5)
+	v := &Verification{Valid: true}
+	if head := verifyEntries(v, GenesisHash, items, false); !v.Valid || head != items[4].EntryHash || v.Entries != 5 {
+		t.Fatalf("intact chain reported broken: %+v", v)
+	}
+
+	modified := chain(5)
+	modified[2].Size = 999
+	v = &Verification{Valid: true}
+	verifyEntries(v, GenesisHash, modified, false)
+	if v.Valid || v.BrokenAt != 3 || v.Problem != "entry was modified" {
+		t.Errorf("modified entry not detected: %+v", v)
+	}
+
+	removed := chain(5)
+	removed = append(removed[:1], removed[2:]...)
+	v = &Verification{Valid: true}
+	verifyEntries(v, GenesisHash, removed, false)
+	if v.Valid || v.BrokenAt != 3 {
+		t.Errorf("removed entry not detected: %+v", v)
+	}
+}
+
+func TestStoreIsContentAddressed(t *testing.T) {
+	Dir = t.TempDir()
+
+	sum, size, err := store(strings.NewReader("replay data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 11 || !validSum(sum) {
+		t.Fatalf("unexpected sum %q size %d", sum, size)
+	}
+	again, _, err := store(strings.NewReader("replay data"))
+	if err != nil || again != sum {
+		t.Fatalf("same content should share a hash: %q vs %q (%v)", again, sum, err)
+	}
+
+	e := &models.Evidence{SHA256: sum}
+	if err := checkContent(e); err != nil {
+		t.Fatalf("stored content should check out: %v", err)
+	}
+	path := contentPath(sum)
+	_ = os.Chmod(path, 0640)
+	if err := os.WriteFile(path, []byte("tampered"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkContent(e); err == nil || err.Error() != "content modified" {
+		t.Errorf("tampered content not detected: %v", err)
+	}
+}
+
+func TestStoreRejectsLargeContent(t *testing.T) {
+	Dir = t.TempDir()
+	old := MaxSize
+	MaxSize = 4
+	defer func() { MaxSize = old }()
+
+	if _, _, err := store(strings.NewReader("too large")); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+}
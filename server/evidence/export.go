@@ -0,0 +1,142 @@
+package evidence
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+)
+
+// Manifest describes a player's case in an export bundle. The bundle holds
+// manifest.json, manifest.sha256 and the content of every evidence item under
+// content/<sha256>.
+type Manifest struct {
+	GeneratedAt  time.Time                     `json:"generated_at"`
+	PlayerID     int64                         `json:"player_id"`
+	Player       *models.Player                `json:"player,omitempty"`
+	Bans         []models.Ban                  `json:"bans"`
+	Reports      []models.Report               `json:"reports"` // Reports against the player
+	Warnings     []models.PlayerWarning        `json:"warnings"`
+	CheatScore   *models.CheatScore            `json:"cheat_score,omitempty"`
+	CheatReviews []models.CheatReview          `json:"cheat_reviews"`
+	Events       []models.RedEyeAnticheatEvent `json:"anticheat_events"`
+	Evidence     []models.Evidence             `json:"evidence"`
+	Chain        *Verification                 `json:"chain"` // State of the whole chain when exported
+}
+
+// BuildManifest gathers everything on record about a player's case.
+func BuildManifest(playerID int64) (*Manifest, error) {
+	db := database.DBConn
+	if db == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	m := &Manifest{GeneratedAt: time.Now().UTC(), PlayerID: playerID}
+	var err error
+	if m.Player, err = database.GetPlayerByID(db, playerID); err != nil {
+		return nil, err
+	}
+	if m.Bans, err = database.ListPlayerBans(db, playerID); err != nil {
+		return nil, err
+	}
+	if m.Reports, err = database.ListReports(db, models.ReportFilter{ReportedUserID: playerID}); err != nil {
+		return nil, err
+	}
+	if m.Warnings, err = database.ListPlayerWarnings(db, playerID); err != nil {
+		return nil, err
+	}
+	if m.CheatScore, err = database.GetCheatScore(db, playerID); err != nil {
+		return nil, err
+	}
+	if m.CheatReviews, err = database.ListPlayerCheatReviews(db, playerID); err != nil {
+		return nil, err
+	}
+	if m.Events, err = database.GetPlayerAnticheatEvents(db, playerID, 10000); err != nil {
+		return nil, err
+	}
+	if m.Evidence, err = database.ListPlayerEvidence(db, playerID); err != nil {
+		return nil, err
+	}
+	if m.Chain, err = Verify(false); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ExportCase writes a player's case as a zip bundle. Content that fails its hash
+// check is left out and the manifest's chain result says so.
+func ExportCase(playerID int64, w io.Writer) error {
+	m, err := BuildManifest(playerID)
+	if err != nil {
+		return err
+	}
+
+	// Check the player's content before it goes into the bundle
+	bad := map[string]bool{}
+	for i := range m.Evidence {
+		e := &m.Evidence[i]
+		if err := checkContent(e); err != nil {
+			bad[e.SHA256] = true
+			if m.Chain.Valid {
+				m.Chain.Valid = false
+				m.Chain.BrokenAt = e.ID
+				m.Chain.Problem = err.Error()
+			}
+		}
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(manifest)
+
+	zw := zip.NewWriter(w)
+	if err := writeFile(zw, "manifest.json", manifest); err != nil {
+		return err
+	}
+	if err := writeFile(zw, "manifest.sha256", []byte(hex.EncodeToString(sum[:])+"  manifest.json\n")); err != nil {
+		return err
+	}
+
+	written := map[string]bool{}
+	for i := range m.Evidence {
+		e := &m.Evidence[i]
+		if written[e.SHA256] || bad[e.SHA256] {
+			continue
+		}
+		written[e.SHA256] = true
+		if err := copyContent(zw, e); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeFile(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func copyContent(zw *zip.Writer, e *models.Evidence) error {
+	src, err := Open(e)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := zw.Create("content/" + e.SHA256)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,166 @@
+package evidence
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Evidence Handlers --
+
+// AttachEvidenceHandler lets a game server attach evidence to an anticheat event.
+// A JSON body is stored as a structured payload; any other body is stored as a blob
+// with its Content-Type. Query "name" labels the item (e.g. "replay.bin").
+func AttachEvidenceHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if r.ContentLength > MaxSize {
+		utils.WriteError(w, r, http.StatusRequestEntityTooLarge, ErrTooLarge.Error())
+		return
+	}
+
+	e, err := Attach(int64(id), r.URL.Query().Get("name"), r.Header.Get("Content-Type"), r.Body)
+	if errors.Is(err, ErrTooLarge) {
+		utils.WriteError(w, r, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "failed to attach evidence: "+err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, e)
+}
+
+// ListEventEvidenceHandler returns the evidence attached to an anticheat event.
+func ListEventEvidenceHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, err := database.ListEventEvidence(database.DBConn, int64(id))
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, items)
+}
+
+// GetEvidenceContentHandler serves an item's content after checking it against its hash.
+func GetEvidenceContentHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	e, err := database.GetEvidence(database.DBConn, int64(id))
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if e == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "evidence not found")
+		return
+	}
+	if err := checkContent(e); err != nil {
+		utils.WriteError(w, r, http.StatusConflict, "evidence "+err.Error())
+		return
+	}
+	f, err := Open(e)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", e.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(e.Size, 10))
+	w.Header().Set("X-Evidence-SHA256", e.SHA256)
+	w.Header().Set("X-Content-Type-Options", "nosniff") // Content comes from game servers
+	if e.Kind != models.EvidencePayload {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.SHA256))
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("Evidence: failed to send content of %d: %v", e.ID, err)
+	}
+}
+
+// VerifyEvidenceHandler checks the whole evidence chain. Query "content=true" also
+// re-hashes every item's content.
+func VerifyEvidenceHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	v, err := Verify(r.URL.Query().Get("content") == "true")
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, v)
+}
+
+// ExportPlayerCaseHandler downloads a player's case as a zip bundle: bans, reports,
+// warnings, anticheat history, evidence metadata and content, and the chain state.
+func ExportPlayerCaseHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Build into a temp file so errors can still be reported as JSON
+	tmp, err := os.CreateTemp("", "case-*.zip")
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := ExportCase(int64(id), tmp); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to export case: "+err.Error())
+		return
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"player-%d-case.zip\"", id))
+	if _, err := io.Copy(w, tmp); err != nil {
+		log.Printf("Evidence: failed to send case export of player %d: %v", id, err)
+	}
+}
@@ -0,0 +1,501 @@
+package fleet
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/registry"
+	"exile/server/releases"
+	"exile/server/sse"
+	"exile/server/utils"
+	"exile/server/ws"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// =================================================================================
+// FLEET UPDATES: batched, health-gated rolling instance updates
+// =================================================================================
+
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobPaused    = "paused"
+	JobCompleted = "completed"
+	JobCancelled = "cancelled"
+
+	TargetPending  = "pending"
+	TargetUpdating = "updating"
+	TargetWaiting  = "waiting"
+	TargetHealthy  = "healthy"
+	TargetFailed   = "failed"
+
+	defaultHealthTimeout = 120 * time.Second
+	healthPollInterval   = 3 * time.Second
+	updateTimeout        = 300 * time.Second
+)
+
+// nodeDispatcher sends commands to nodes. It is the WebSocket manager outside tests.
+type nodeDispatcher interface {
+	SendCommandSync(nodeID int, msgType string, payload interface{}, timeout time.Duration) (ws.WSResponse, error)
+	ListInstances(nodeID int, timeout time.Duration) ([]models.GameInstance, error)
+}
+
+// FleetManager runs at most one fleet update job at a time so jobs never fight over instances.
+type FleetManager struct {
+	mu      sync.Mutex
+	db      *sqlx.DB
+	hub     *sse.SSEHub
+	nodes   nodeDispatcher
+	current *models.FleetUpdateJob // Job owned by the running worker, nil when idle
+	pause   bool
+	cancel  bool
+}
+
+var fleetManager *FleetManager
+
+// InitializeFleetManager sets up the global manager. Jobs that were running when the
+// master stopped are marked paused so an operator can resume them explicitly.
+func InitializeFleetManager(db *sqlx.DB, hub *sse.SSEHub) {
+	fleetManager = &FleetManager{db: db, hub: hub, nodes: ws.GlobalWSManager}
+
+	jobs, err := database.ListFleetUpdateJobsByStatus(db, JobRunning)
+	if err != nil {
+		log.Printf("Fleet: failed to load interrupted jobs: %v", err)
+		return
+	}
+	for i := range jobs {
+		j := &jobs[i]
+		j.Status = JobPaused
+		j.Error = "interrupted by master restart"
+		for k := range j.Targets {
+			if t := &j.Targets[k]; t.Status == TargetUpdating || t.Status == TargetWaiting {
+				t.Status = TargetPending
+			}
+		}
+		if err := database.SaveFleetUpdateJob(db, j); err != nil {
+			log.Printf("Fleet: failed to pause job %d: %v", j.ID, err)
+		}
+	}
+}
+
+// ResolveTargets expands a filter into the concrete instances a job will update.
+func (m *FleetManager) ResolveTargets(filter models.FleetUpdateFilter) ([]models.FleetUpdateTarget, error) {
+	nodeSet := map[int]bool{}
+	for _, id := range filter.NodeIDs {
+		nodeSet[id] = true
+	}
+	instanceSet := map[string]bool{}
+	for _, id := range filter.InstanceIDs {
+		instanceSet[id] = true
+	}
+
+	targets := []models.FleetUpdateTarget{}
+	for _, n := range registry.GlobalRegistry.List() {
+		if n.Status != "Online" || !matchesNode(filter, &n, nodeSet) {
+			continue
+		}
+
+		instances, err := m.nodes.ListInstances(n.ID, 5*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", n.Name, err)
+		}
+
+		targetVersion := ""
+		if filter.SkipUpToDate {
+			nodeCopy := n
+			if v, _, err := releases.ResolveNodeVersion(m.db, &nodeCopy); err == nil && v != nil {
+				targetVersion = v.Version
+			}
+		}
+
+		for _, inst := range instances {
+			if len(instanceSet) > 0 && !instanceSet[inst.ID] {
+				continue
+			}
+			if targetVersion != "" && inst.Version == targetVersion {
+				continue
+			}
+			targets = append(targets, models.FleetUpdateTarget{
+				NodeID:      n.ID,
+				NodeName:    n.Name,
+				InstanceID:  inst.ID,
+				WasRunning:  inst.Status == "Running",
+				FromVersion: inst.Version,
+				Status:      TargetPending,
+				UpdatedAt:   time.Now().UTC(),
+			})
+		}
+	}
+	return targets, nil
+}
+
+func matchesNode(filter models.FleetUpdateFilter, n *models.Node, nodeSet map[int]bool) bool {
+	if n.IsDraining && !filter.IncludeDraining {
+		return false
+	}
+	if len(nodeSet) > 0 && !nodeSet[n.ID] {
+		return false
+	}
+	if len(filter.Regions) > 0 {
+		found := false
+		for _, r := range filter.Regions {
+			if strings.EqualFold(r, n.Region) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(filter.Tags) > 0 {
+		have := map[string]bool{}
+		for _, t := range utils.ParseTags(n.Tags) {
+			have[t] = true
+		}
+		for _, t := range filter.Tags {
+			if !have[strings.ToLower(strings.TrimSpace(t))] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// CreateJob resolves targets, persists the job and starts it.
+func (m *FleetManager) CreateJob(job *models.FleetUpdateJob) error {
+	if job.BatchSize <= 0 {
+		job.BatchSize = 1
+	}
+	if job.MaxUnavailable <= 0 || job.MaxUnavailable > job.BatchSize {
+		job.MaxUnavailable = job.BatchSize
+	}
+	if job.HealthTimeoutSeconds <= 0 {
+		job.HealthTimeoutSeconds = int(defaultHealthTimeout / time.Second)
+	}
+
+	m.mu.Lock()
+	busy := m.current != nil
+	m.mu.Unlock()
+	if busy {
+		return fmt.Errorf("another fleet update is already running")
+	}
+
+	targets, err := m.ResolveTargets(job.Filter)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no instances match the filter")
+	}
+	job.Targets = targets
+	job.Status = JobPending
+	if err := database.SaveFleetUpdateJob(m.db, job); err != nil {
+		return err
+	}
+	return m.start(job)
+}
+
+// Resume restarts a paused job. Failed targets are retried.
+func (m *FleetManager) Resume(id int) (*models.FleetUpdateJob, error) {
+	job, err := database.GetFleetUpdateJob(m.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, fmt.Errorf("job not found")
+	}
+	if err := m.resume(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// resume resets a paused job's failed targets and starts it again.
+func (m *FleetManager) resume(job *models.FleetUpdateJob) error {
+	if job.Status != JobPaused {
+		return fmt.Errorf("job is %s", job.Status)
+	}
+	for i := range job.Targets {
+		if job.Targets[i].Status == TargetFailed {
+			job.Targets[i].Status = TargetPending
+			job.Targets[i].Error = ""
+		}
+	}
+	job.Error = ""
+	return m.start(job)
+}
+
+// Pause stops the running job after the current batch finishes.
+func (m *FleetManager) Pause(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current == nil || m.current.ID != id {
+		return fmt.Errorf("job is not running")
+	}
+	m.pause = true
+	return nil
+}
+
+// Cancel stops a running job after the current batch, or cancels a paused one immediately.
+func (m *FleetManager) Cancel(id int) error {
+	m.mu.Lock()
+	if m.current != nil && m.current.ID == id {
+		m.cancel = true
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	job, err := database.GetFleetUpdateJob(m.db, id)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		return fmt.Errorf("job not found")
+	}
+	if job.Status != JobPaused && job.Status != JobPending {
+		return fmt.Errorf("job is %s", job.Status)
+	}
+	job.Status = JobCancelled
+	if err := database.SaveFleetUpdateJob(m.db, job); err != nil {
+		return err
+	}
+	m.broadcast(job)
+	return nil
+}
+
+// Get returns the live copy of a running job, or the stored one.
+func (m *FleetManager) Get(id int) (*models.FleetUpdateJob, error) {
+	m.mu.Lock()
+	if m.current != nil && m.current.ID == id {
+		snapshot := m.snapshot()
+		m.mu.Unlock()
+		return snapshot, nil
+	}
+	m.mu.Unlock()
+	return database.GetFleetUpdateJob(m.db, id)
+}
+
+func (m *FleetManager) start(job *models.FleetUpdateJob) error {
+	m.mu.Lock()
+	if m.current != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("another fleet update is already running")
+	}
+	m.current = job
+	m.pause = false
+	m.cancel = false
+	job.Status = JobRunning
+	m.mu.Unlock()
+
+	m.persist()
+	go m.run()
+	return nil
+}
+
+// snapshot copies the current job; callers must hold m.mu.
+func (m *FleetManager) snapshot() *models.FleetUpdateJob {
+	c := *m.current
+	c.Targets = append([]models.FleetUpdateTarget(nil), m.current.Targets...)
+	return &c
+}
+
+// persist saves and broadcasts the current job state.
+func (m *FleetManager) persist() {
+	m.mu.Lock()
+	if m.current == nil {
+		m.mu.Unlock()
+		return
+	}
+	snapshot := m.snapshot()
+	m.mu.Unlock()
+
+	if m.db != nil {
+		if err := database.SaveFleetUpdateJob(m.db, snapshot); err != nil {
+			log.Printf("Fleet: failed to save job %d: %v", snapshot.ID, err)
+		}
+	}
+	m.broadcast(snapshot)
+}
+
+func (m *FleetManager) broadcast(job *models.FleetUpdateJob) {
+	if m.hub != nil {
+		m.hub.Broadcast("fleet_update", job)
+	}
+}
+
+func (m *FleetManager) setTarget(idx int, status, errStr string) {
+	m.mu.Lock()
+	t := &m.current.Targets[idx]
+	t.Status = status
+	t.Error = errStr
+	t.UpdatedAt = time.Now().UTC()
+	m.mu.Unlock()
+	m.persist()
+}
+
+// finish records the final job state and releases the worker slot.
+func (m *FleetManager) finish(status, errStr string) {
+	m.mu.Lock()
+	m.current.Status = status
+	m.current.Error = errStr
+	m.mu.Unlock()
+	m.persist()
+
+	m.mu.Lock()
+	log.Printf("Fleet: job %d %s %s", m.current.ID, status, errStr)
+	m.current = nil
+	m.mu.Unlock()
+}
+
+func (m *FleetManager) run() {
+	for {
+		m.mu.Lock()
+		cancel, pause := m.cancel, m.pause
+		batch := []int{}
+		for i, t := range m.current.Targets {
+			if t.Status == TargetPending {
+				batch = append(batch, i)
+				if len(batch) == m.current.BatchSize {
+					break
+				}
+			}
+		}
+		maxUnavailable := m.current.MaxUnavailable
+		healthTimeout := time.Duration(m.current.HealthTimeoutSeconds) * time.Second
+		m.mu.Unlock()
+
+		switch {
+		case cancel:
+			m.finish(JobCancelled, "")
+			return
+		case pause:
+			m.finish(JobPaused, "paused by operator")
+			return
+		case len(batch) == 0:
+			m.finish(JobCompleted, "")
+			return
+		}
+
+		// Run the batch, never taking more than maxUnavailable instances down at once
+		sem := make(chan struct{}, maxUnavailable)
+		var wg sync.WaitGroup
+		for _, idx := range batch {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(idx int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				m.updateTarget(idx, healthTimeout)
+			}(idx)
+		}
+		wg.Wait()
+
+		m.mu.Lock()
+		failed := 0
+		for _, idx := range batch {
+			if m.current.Targets[idx].Status == TargetFailed {
+				failed++
+			}
+		}
+		m.mu.Unlock()
+		if failed > 0 {
+			m.finish(JobPaused, fmt.Sprintf("%d instance(s) failed in the last batch", failed))
+			return
+		}
+	}
+}
+
+// updateTarget updates one instance, restarts it if it was running and waits for it to report healthy.
+func (m *FleetManager) updateTarget(idx int, healthTimeout time.Duration) {
+	m.mu.Lock()
+	t := m.current.Targets[idx]
+	m.mu.Unlock()
+
+	m.setTarget(idx, TargetUpdating, "")
+	payload := map[string]string{"instance_id": t.InstanceID}
+	resp, err := m.nodes.SendCommandSync(t.NodeID, "update_instance", payload, updateTimeout)
+	if err == nil && resp.Status != "success" {
+		err = fmt.Errorf("update failed: %s", resp.Error)
+	}
+	m.recordAction(t, "update", err)
+	if err != nil {
+		m.setTarget(idx, TargetFailed, err.Error())
+		return
+	}
+
+	if t.WasRunning {
+		resp, err := m.nodes.SendCommandSync(t.NodeID, "start_instance", payload, 10*time.Second)
+		if err == nil && resp.Status != "success" {
+			err = fmt.Errorf("start failed: %s", resp.Error)
+		}
+		m.recordAction(t, "start", err)
+		if err != nil {
+			m.setTarget(idx, TargetFailed, err.Error())
+			return
+		}
+	}
+
+	m.setTarget(idx, TargetWaiting, "")
+	version, err := m.waitHealthy(t, healthTimeout)
+	if err != nil {
+		m.setTarget(idx, TargetFailed, err.Error())
+		return
+	}
+
+	m.mu.Lock()
+	m.current.Targets[idx].ToVersion = version
+	m.mu.Unlock()
+	m.setTarget(idx, TargetHealthy, "")
+}
+
+// waitHealthy polls the node until the instance is back in its expected state.
+func (m *FleetManager) waitHealthy(t models.FleetUpdateTarget, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	lastState := "unknown"
+	for time.Now().Before(deadline) {
+		instances, err := m.nodes.ListInstances(t.NodeID, 5*time.Second)
+		if err == nil {
+			lastState = "missing"
+			for _, inst := range instances {
+				if inst.ID != t.InstanceID {
+					continue
+				}
+				lastState = inst.Status
+				if inst.Status == "Error" {
+					return "", fmt.Errorf("instance crashed after update")
+				}
+				if !t.WasRunning || inst.Status == "Running" {
+					return inst.Version, nil
+				}
+			}
+		}
+		time.Sleep(healthPollInterval)
+	}
+	return "", fmt.Errorf("instance not healthy after %s (last state: %s)", timeout, lastState)
+}
+
+func (m *FleetManager) recordAction(t models.FleetUpdateTarget, action string, err error) {
+	if m.db == nil {
+		return
+	}
+	status, details := "success", "fleet update"
+	if err != nil {
+		status, details = "failed", err.Error()
+	}
+	database.SaveInstanceAction(m.db, &models.InstanceAction{
+		NodeID:     t.NodeID,
+		InstanceID: t.InstanceID,
+		Action:     action,
+		Timestamp:  time.Now().UTC(),
+		Status:     status,
+		Details:    details,
+	})
+}
@@ -0,0 +1,312 @@
+package fleet
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"exile/server/models"
+	"exile/server/registry"
+	"exile/server/ws"
+)
+
+func TestMatchesNode(t *testing.T) {
+	node := &models.Node{ID: 4, Region: "EU-West", Tags: "ranked, eu"}
+
+	cases := []struct {
+		name   string
+		filter models.FleetUpdateFilter
+		nodes  map[int]bool
+		want   bool
+	}{
+		{"empty filter", models.FleetUpdateFilter{}, nil, true},
+		{"region match is case-insensitive", models.FleetUpdateFilter{Regions: []string{"eu-west"}}, nil, true},
+		{"region mismatch", models.FleetUpdateFilter{Regions: []string{"us-east"}}, nil, false},
+		{"all tags required", models.FleetUpdateFilter{Tags: []string{"ranked", "EU"}}, nil, true},
+		{"missing tag", models.FleetUpdateFilter{Tags: []string{"ranked", "casual"}}, nil, false},
+		{"node id filter", models.FleetUpdateFilter{}, map[int]bool{5: true}, false},
+	}
+	for _, tc := range cases {
+		if got := matchesNode(tc.filter, node, tc.nodes); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	draining := &models.Node{ID: 1, IsDraining: true}
+	if matchesNode(models.FleetUpdateFilter{}, draining, nil) {
+		t.Error("draining node should be skipped by default")
+	}
+	if !matchesNode(models.FleetUpdateFilter{IncludeDraining: true}, draining, nil) {
+		t.Error("draining node should match with include_draining")
+	}
+}
+
+// fakeNodes stands in for the WebSocket manager. Updates succeed unless the instance is
+// listed in failUpdate; updated instances report version "2.0".
+type fakeNodes struct {
+	mu          sync.Mutex
+	inventory   map[int][]models.GameInstance // Instances by node, before any update
+	failUpdate  map[string]bool
+	crash       map[string]bool // Instances that report Error after updating
+	gate        chan struct{}   // When set, each update waits for a value
+	entered     chan string     // When set, receives each instance as its update starts
+	updated     []string
+	started     []string
+	inFlight    int
+	maxInFlight int
+}
+
+func (f *fakeNodes) SendCommandSync(nodeID int, msgType string, payload interface{}, timeout time.Duration) (ws.WSResponse, error) {
+	id := payload.(map[string]string)["instance_id"]
+	switch msgType {
+	case "update_instance":
+		f.mu.Lock()
+		f.inFlight++
+		f.maxInFlight = max(f.maxInFlight, f.inFlight)
+		f.mu.Unlock()
+		if f.entered != nil {
+			f.entered <- id
+		}
+		if f.gate != nil {
+			<-f.gate
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.inFlight--
+		if f.failUpdate[id] {
+			return ws.WSResponse{Status: "error", Error: "disk full"}, nil
+		}
+		f.updated = append(f.updated, id)
+	case "start_instance":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.started = append(f.started, id)
+	default:
+		return ws.WSResponse{}, fmt.Errorf("unexpected command %s", msgType)
+	}
+	return ws.WSResponse{Status: "success"}, nil
+}
+
+func (f *fakeNodes) ListInstances(nodeID int, timeout time.Duration) ([]models.GameInstance, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := append([]models.GameInstance(nil), f.inventory[nodeID]...)
+	for _, id := range f.updated {
+		status := "Running"
+		if f.crash[id] {
+			status = "Error"
+		}
+		out = append(out, models.GameInstance{ID: id, Status: status, Version: "2.0"})
+	}
+	return out, nil
+}
+
+func newTestJob(batchSize, maxUnavailable int, instances ...string) *models.FleetUpdateJob {
+	job := &models.FleetUpdateJob{ID: 1, BatchSize: batchSize, MaxUnavailable: maxUnavailable, HealthTimeoutSeconds: 5}
+	for _, id := range instances {
+		job.Targets = append(job.Targets, models.FleetUpdateTarget{NodeID: 1, InstanceID: id, WasRunning: true, FromVersion: "1.0", Status: TargetPending})
+	}
+	return job
+}
+
+// waitIdle waits for the worker to finish the job.
+func waitIdle(t *testing.T, m *FleetManager) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		m.mu.Lock()
+		idle := m.current == nil
+		m.mu.Unlock()
+		if idle {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("job did not finish")
+}
+
+func targetStatuses(job *models.FleetUpdateJob) string {
+	var s []string
+	for _, t := range job.Targets {
+		s = append(s, t.InstanceID+"="+t.Status)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestRunUpdatesEveryTarget(t *testing.T) {
+	nodes := &fakeNodes{}
+	m := &FleetManager{nodes: nodes}
+	job := newTestJob(2, 1, "a", "b", "c", "d", "e")
+
+	if err := m.start(job); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, m)
+
+	if job.Status != JobCompleted {
+		t.Fatalf("expected completed, got %s (%s)", job.Status, job.Error)
+	}
+	if got := targetStatuses(job); got != "a=healthy b=healthy c=healthy d=healthy e=healthy" {
+		t.Errorf("unexpected targets: %s", got)
+	}
+	for _, tg := range job.Targets {
+		if tg.ToVersion != "2.0" {
+			t.Errorf("%s: expected to_version 2.0, got %q", tg.InstanceID, tg.ToVersion)
+		}
+	}
+	if nodes.maxInFlight > 1 {
+		t.Errorf("max_unavailable 1 exceeded: %d updates at once", nodes.maxInFlight)
+	}
+	if len(nodes.started) != 5 {
+		t.Errorf("expected running instances to be restarted, got %v", nodes.started)
+	}
+}
+
+func TestFailedBatchHaltsJob(t *testing.T) {
+	nodes := &fakeNodes{failUpdate: map[string]bool{"b": true}}
+	m := &FleetManager{nodes: nodes}
+	job := newTestJob(2, 2, "a", "b", "c", "d")
+
+	if err := m.start(job); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, m)
+
+	if job.Status != JobPaused || !strings.Contains(job.Error, "1 instance(s) failed") {
+		t.Fatalf("expected the job to pause on failure, got %s (%s)", job.Status, job.Error)
+	}
+	if got := targetStatuses(job); got != "a=healthy b=failed c=pending d=pending" {
+		t.Errorf("later batches should not run after a failure: %s", got)
+	}
+
+	// Resuming retries the failed target and carries on
+	nodes.mu.Lock()
+	nodes.failUpdate = nil
+	nodes.mu.Unlock()
+	if err := m.resume(job); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, m)
+	if job.Status != JobCompleted || job.Targets[1].Error != "" {
+		t.Fatalf("expected resume to complete the job, got %s: %s", job.Status, targetStatuses(job))
+	}
+}
+
+func TestCrashAfterUpdateFailsTarget(t *testing.T) {
+	nodes := &fakeNodes{crash: map[string]bool{"a": true}}
+	m := &FleetManager{nodes: nodes}
+	job := newTestJob(1, 1, "a", "b")
+
+	if err := m.start(job); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, m)
+
+	if job.Status != JobPaused || job.Targets[0].Status != TargetFailed || !strings.Contains(job.Targets[0].Error, "crashed") {
+		t.Fatalf("expected a crashed instance to fail the batch, got %s: %s", job.Status, targetStatuses(job))
+	}
+	if job.Targets[1].Status != TargetPending {
+		t.Errorf("second batch should not have run: %s", targetStatuses(job))
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	nodes := &fakeNodes{gate: make(chan struct{}), entered: make(chan string, 1)}
+	m := &FleetManager{nodes: nodes}
+	job := newTestJob(1, 1, "a", "b", "c")
+
+	if err := m.start(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.start(newTestJob(1, 1, "x")); err == nil {
+		t.Error("expected a second job to be refused while one runs")
+	}
+
+	<-nodes.entered
+	if err := m.Pause(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	nodes.gate <- struct{}{}
+	waitIdle(t, m)
+
+	if job.Status != JobPaused || job.Error != "paused by operator" {
+		t.Fatalf("expected paused by operator, got %s (%s)", job.Status, job.Error)
+	}
+	if got := targetStatuses(job); got != "a=healthy b=pending c=pending" {
+		t.Errorf("pause should take effect after the current batch: %s", got)
+	}
+	if err := m.Pause(job.ID); err == nil {
+		t.Error("expected pausing an idle job to fail")
+	}
+
+	nodes.gate, nodes.entered = nil, nil
+	if err := m.resume(job); err != nil {
+		t.Fatal(err)
+	}
+	waitIdle(t, m)
+	if job.Status != JobCompleted {
+		t.Fatalf("expected resume to complete the job, got %s: %s", job.Status, targetStatuses(job))
+	}
+	if err := m.resume(job); err == nil {
+		t.Error("expected resuming a completed job to fail")
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	nodes := &fakeNodes{gate: make(chan struct{}), entered: make(chan string, 1)}
+	m := &FleetManager{nodes: nodes}
+	job := newTestJob(1, 1, "a", "b")
+
+	if err := m.start(job); err != nil {
+		t.Fatal(err)
+	}
+	<-nodes.entered
+	if err := m.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	nodes.gate <- struct{}{}
+	waitIdle(t, m)
+
+	if job.Status != JobCancelled {
+		t.Fatalf("expected cancelled, got %s", job.Status)
+	}
+	if got := targetStatuses(job); got != "a=healthy b=pending" {
+		t.Errorf("cancel should take effect after the current batch: %s", got)
+	}
+}
+
+func TestResolveTargets(t *testing.T) {
+	registry.GlobalRegistry.Reset()
+	t.Cleanup(registry.GlobalRegistry.Reset)
+	registry.SetItem(1, &models.Node{ID: 1, Name: "eu-1", Region: "eu", Status: "Online"})
+	registry.SetItem(2, &models.Node{ID: 2, Name: "us-1", Region: "us", Status: "Online"})
+	registry.SetItem(3, &models.Node{ID: 3, Name: "eu-2", Region: "eu", Status: "Offline"})
+
+	nodes := &fakeNodes{inventory: map[int][]models.GameInstance{
+		1: {{ID: "a", Status: "Running", Version: "1.0"}, {ID: "b", Status: "Stopped", Version: "1.0"}},
+		2: {{ID: "c", Status: "Running", Version: "1.0"}},
+		3: {{ID: "d", Status: "Running", Version: "1.0"}},
+	}}
+	m := &FleetManager{nodes: nodes}
+
+	targets, err := m.ResolveTargets(models.FleetUpdateFilter{Regions: []string{"EU"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].InstanceID != "a" || targets[1].InstanceID != "b" {
+		t.Fatalf("expected a and b on the online eu node, got %+v", targets)
+	}
+	if !targets[0].WasRunning || targets[1].WasRunning || targets[0].FromVersion != "1.0" || targets[0].Status != TargetPending {
+		t.Errorf("unexpected target details: %+v", targets)
+	}
+
+	targets, err = m.ResolveTargets(models.FleetUpdateFilter{InstanceIDs: []string{"c"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].NodeID != 2 {
+		t.Errorf("expected only instance c, got %+v", targets)
+	}
+}
@@ -0,0 +1,167 @@
+package fleet
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Fleet Update Handlers --
+
+func ListFleetUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil || fleetManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	jobs, err := database.ListFleetUpdateJobs(database.DBConn, limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, jobs)
+}
+
+func CreateFleetUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil || fleetManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	var req struct {
+		Filter               models.FleetUpdateFilter `json:"filter"`
+		BatchSize            int                      `json:"batch_size"`
+		MaxUnavailable       int                      `json:"max_unavailable"`
+		HealthTimeoutSeconds int                      `json:"health_timeout_seconds"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job := &models.FleetUpdateJob{
+		Filter:               req.Filter,
+		BatchSize:            req.BatchSize,
+		MaxUnavailable:       req.MaxUnavailable,
+		HealthTimeoutSeconds: req.HealthTimeoutSeconds,
+		CreatedBy:            "admin",
+	}
+	if err := fleetManager.CreateJob(job); err != nil {
+		writeFleetError(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, job)
+}
+
+func GetFleetUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil || fleetManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job, err := fleetManager.Get(id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if job == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "job not found")
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, job)
+}
+
+func PauseFleetUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if fleetManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := fleetManager.Pause(id); err != nil {
+		writeFleetError(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "pausing after current batch"})
+}
+
+func ResumeFleetUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if fleetManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	job, err := fleetManager.Resume(id)
+	if err != nil {
+		writeFleetError(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, job)
+}
+
+func CancelFleetUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if fleetManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := fleetManager.Cancel(id); err != nil {
+		writeFleetError(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, map[string]string{"status": "cancelling"})
+}
+
+func writeFleetError(w http.ResponseWriter, r *http.Request, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		utils.WriteError(w, r, http.StatusNotFound, msg)
+	case strings.HasPrefix(msg, "job is"), strings.HasPrefix(msg, "another fleet update"):
+		utils.WriteError(w, r, http.StatusConflict, msg)
+	case strings.HasPrefix(msg, "no instances"):
+		utils.WriteError(w, r, http.StatusBadRequest, msg)
+	default:
+		utils.WriteError(w, r, http.StatusBadGateway, msg)
+	}
+}
@@ -0,0 +1,196 @@
+// Package gamepkg inspects uploaded game server packages before they can be served to nodes.
+package gamepkg
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	StatusValid     = "valid"
+	StatusInvalid   = "invalid"
+	StatusUnchecked = ""
+
+	// Outcomes of the game binary check, reported apart from the package status
+	BinaryPassed    = "passed"
+	BinaryFailed    = "failed"
+	BinaryUnchecked = "unchecked"
+
+	versionFile   = "version.txt"
+	buildInfoFile = "build_info.json"
+
+	maxMetadataFileSize = 64 << 10 // version.txt / build_info.json are tiny; refuse anything bigger
+)
+
+// Report is the outcome of inspecting a package.
+type Report struct {
+	Status      string   `json:"status"`
+	BinaryCheck string   `json:"binary_check"` // passed, failed, or unchecked when no binary is configured
+	Errors      []string `json:"errors,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Metadata    Metadata `json:"metadata"`
+}
+
+// Metadata is build information extracted from the archive.
+type Metadata struct {
+	Version           string                 `json:"version,omitempty"` // Contents of version.txt
+	FileCount         int                    `json:"file_count"`
+	UncompressedBytes uint64                 `json:"uncompressed_bytes"`
+	Binary            string                 `json:"binary,omitempty"`
+	BinaryBytes       uint64                 `json:"binary_bytes,omitempty"`
+	Engine            string                 `json:"engine,omitempty"`     // e.g. "unity" when UnityPlayer is bundled
+	BuildInfo         map[string]interface{} `json:"build_info,omitempty"` // Parsed build_info.json, if shipped
+}
+
+// Valid reports whether the package passed every check.
+func (r *Report) Valid() bool {
+	return r.Status == StatusValid
+}
+
+func (r *Report) fail(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// UnsafePath reports why an archive entry name could escape the extraction directory ("" if safe).
+func UnsafePath(name string) string {
+	if name == "" {
+		return "empty entry name"
+	}
+	if strings.Contains(name, `\`) {
+		return "backslash in path"
+	}
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "absolute path"
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "parent directory traversal"
+		}
+	}
+	return ""
+}
+
+// Inspect opens the archive at zipPath and checks it. binaryPath is the game server
+// binary relative to the package root (as nodes are configured); when it is empty the
+// binary check is skipped, which the report shows as unchecked with a warning.
+func Inspect(zipPath, binaryPath string) Report {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return Report{Status: StatusInvalid, Errors: []string{fmt.Sprintf("not a valid zip archive: %v", err)}}
+	}
+	defer zr.Close()
+	return inspect(&zr.Reader, binaryPath)
+}
+
+// InspectReader is Inspect for an archive that is already in memory or on another reader.
+func InspectReader(r io.ReaderAt, size int64, binaryPath string) Report {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Report{Status: StatusInvalid, Errors: []string{fmt.Sprintf("not a valid zip archive: %v", err)}}
+	}
+	return inspect(zr, binaryPath)
+}
+
+func inspect(zr *zip.Reader, binaryPath string) Report {
+	var rep Report
+	binaryPath = strings.TrimPrefix(path.Clean(strings.ReplaceAll(binaryPath, `\`, "/")), "./")
+
+	var versionEntry, buildInfoEntry, binaryEntry *zip.File
+	for _, f := range zr.File {
+		if reason := UnsafePath(f.Name); reason != "" {
+			rep.fail("unsafe entry %q: %s", f.Name, reason)
+			continue
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			rep.fail("symlink entry %q is not allowed", f.Name)
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		rep.Metadata.FileCount++
+		rep.Metadata.UncompressedBytes += f.UncompressedSize64
+
+		name := strings.TrimPrefix(f.Name, "./")
+		switch {
+		case name == versionFile:
+			versionEntry = f
+		case name == buildInfoFile:
+			buildInfoEntry = f
+		case binaryPath != "" && binaryPath != "." && name == binaryPath:
+			binaryEntry = f
+		}
+		base := path.Base(name)
+		if base == "UnityPlayer.so" || base == "UnityPlayer.dll" {
+			rep.Metadata.Engine = "unity"
+		}
+	}
+
+	if rep.Metadata.FileCount == 0 {
+		rep.fail("archive is empty")
+	}
+
+	if versionEntry == nil {
+		rep.fail("%s is missing from the package root", versionFile)
+	} else if content, err := readSmall(versionEntry); err != nil {
+		rep.fail("cannot read %s: %v", versionFile, err)
+	} else if v := strings.TrimSpace(string(content)); v == "" {
+		rep.fail("%s is empty", versionFile)
+	} else {
+		rep.Metadata.Version = v
+	}
+
+	switch {
+	case binaryPath == "" || binaryPath == ".":
+		rep.BinaryCheck = BinaryUnchecked
+		rep.warn("no game binary configured (game.binary_path); binary presence was not checked")
+	case binaryEntry == nil:
+		rep.BinaryCheck = BinaryFailed
+		rep.fail("game binary %q is missing", binaryPath)
+	case binaryEntry.UncompressedSize64 == 0:
+		rep.BinaryCheck = BinaryFailed
+		rep.fail("game binary %q is empty", binaryPath)
+	default:
+		rep.BinaryCheck = BinaryPassed
+		rep.Metadata.Binary = binaryPath
+		rep.Metadata.BinaryBytes = binaryEntry.UncompressedSize64
+	}
+
+	if buildInfoEntry != nil {
+		content, err := readSmall(buildInfoEntry)
+		if err == nil {
+			err = json.Unmarshal(content, &rep.Metadata.BuildInfo)
+		}
+		if err != nil {
+			rep.warn("ignoring unreadable %s: %v", buildInfoFile, err)
+		}
+	}
+
+	rep.Status = StatusValid
+	if len(rep.Errors) > 0 {
+		rep.Status = StatusInvalid
+	}
+	return rep
+}
+
+func readSmall(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxMetadataFileSize {
+		return nil, fmt.Errorf("file too large")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxMetadataFileSize))
+}
@@ -0,0 +1,97 @@
+package gamepkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestInspect(t *testing.T) {
+	cases := []struct {
+		name    string
+		files   map[string]string
+		binary  string
+		valid   bool
+		errPart string
+	}{
+		{"valid package", map[string]string{"version.txt": "1.4.0\n", "Server.x86_64": "ELF", "Server_Data/app.info": "x"}, "Server.x86_64", true, ""},
+		{"binary in subdirectory", map[string]string{"version.txt": "2", "bin/server": "ELF"}, "./bin/server", true, ""},
+		{"zip slip", map[string]string{"version.txt": "1", "server": "ELF", "../../etc/cron.d/x": "boom"}, "server", false, "parent directory traversal"},
+		{"absolute path", map[string]string{"version.txt": "1", "server": "ELF", "/etc/passwd": "x"}, "server", false, "absolute path"},
+		{"missing binary", map[string]string{"version.txt": "1"}, "server", false, "game binary"},
+		{"missing version.txt", map[string]string{"server": "ELF"}, "server", false, "version.txt is missing"},
+		{"empty version.txt", map[string]string{"version.txt": "  \n", "server": "ELF"}, "server", false, "version.txt is empty"},
+	}
+	for _, tc := range cases {
+		r := buildZip(t, tc.files)
+		rep := InspectReader(r, r.Size(), tc.binary)
+		if rep.Valid() != tc.valid {
+			t.Errorf("%s: valid=%v, want %v (errors: %v)", tc.name, rep.Valid(), tc.valid, rep.Errors)
+			continue
+		}
+		if tc.errPart != "" && !strings.Contains(strings.Join(rep.Errors, "\n"), tc.errPart) {
+			t.Errorf("%s: errors %v do not mention %q", tc.name, rep.Errors, tc.errPart)
+		}
+	}
+}
+
+func TestInspectWithoutBinaryConfigured(t *testing.T) {
+	r := buildZip(t, map[string]string{"version.txt": "1", "server": "ELF"})
+	rep := InspectReader(r, r.Size(), "")
+	if !rep.Valid() {
+		t.Fatalf("expected the package to stay valid, got %v", rep.Errors)
+	}
+	if rep.BinaryCheck != BinaryUnchecked || !strings.Contains(strings.Join(rep.Warnings, "\n"), "binary presence was not checked") {
+		t.Errorf("expected the binary check to be reported as skipped, got %q %v", rep.BinaryCheck, rep.Warnings)
+	}
+
+	r = buildZip(t, map[string]string{"version.txt": "1"})
+	if rep := InspectReader(r, r.Size(), "server"); rep.BinaryCheck != BinaryFailed {
+		t.Errorf("expected a missing binary to fail the check, got %q", rep.BinaryCheck)
+	}
+}
+
+func TestInspectMetadata(t *testing.T) {
+	r := buildZip(t, map[string]string{
+		"version.txt":     "1.4.0",
+		"server":          "ELF",
+		"UnityPlayer.so":  "lib",
+		"build_info.json": `{"commit":"abc123","branch":"main"}`,
+	})
+	rep := InspectReader(r, r.Size(), "server")
+	if !rep.Valid() {
+		t.Fatalf("expected valid package, got %v", rep.Errors)
+	}
+	m := rep.Metadata
+	if m.Version != "1.4.0" || m.Engine != "unity" || m.FileCount != 4 || m.BinaryBytes != 3 {
+		t.Errorf("unexpected metadata: %+v", m)
+	}
+	if m.BuildInfo["commit"] != "abc123" {
+		t.Errorf("build_info.json not parsed: %v", m.BuildInfo)
+	}
+}
+
+func TestInspectNotZip(t *testing.T) {
+	r := bytes.NewReader([]byte("definitely not a zip"))
+	if rep := InspectReader(r, r.Size(), "server"); rep.Valid() {
+		t.Fatal("expected a non-zip upload to be invalid")
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"exile/server/database"
+	"exile/server/gamepkg"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// gameBinaryConfigKey is the server_config key holding the game binary path inside a package.
+// It must match what nodes are started with (GAME_BINARY_PATH / -game-binary).
+const gameBinaryConfigKey = "game.binary_path"
+
+// expectedGameBinary returns the binary every package must contain, from config or GAME_BINARY_PATH.
+// GAME_BINARY_PATH is usually only set on nodes; with neither set the binary check is skipped.
+func expectedGameBinary() string {
+	if database.DBConn != nil {
+		if cfg, err := database.GetConfigByKey(database.DBConn, gameBinaryConfigKey); err == nil && cfg != nil && strings.TrimSpace(cfg.Value) != "" {
+			return strings.TrimSpace(cfg.Value)
+		}
+	}
+	return utils.GetEnv("GAME_BINARY_PATH", "")
+}
+
+// inspectVersionPackage runs the package checks on a stored version and copies the result onto it.
+func inspectVersionPackage(v *models.GameServerVersion) gamepkg.Report {
+	rep := gamepkg.Inspect(filepath.Join("files", v.Filename), expectedGameBinary())
+	applyPackageReport(v, rep)
+	return rep
+}
+
+func applyPackageReport(v *models.GameServerVersion, rep gamepkg.Report) {
+	v.ValidationStatus = rep.Status
+	v.ValidationErrors = rep.Errors
+	v.ValidatedAt = time.Now().UTC()
+	v.BuildMetadata = nil
+	// Round-trip through JSON so the stored metadata has the same shape the API returns
+	if b, err := json.Marshal(rep.Metadata); err == nil {
+		_ = json.Unmarshal(b, &v.BuildMetadata)
+	}
+}
+
+// HandleValidateVersion re-inspects a stored package, e.g. for versions uploaded before validation existed
+// or after game.binary_path changed.
+func HandleValidateVersion(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "Database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+	v, err := database.GetServerVersionByID(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to load version")
+		return
+	}
+	if v == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "Version not found")
+		return
+	}
+
+	rep := inspectVersionPackage(v)
+	if err := database.UpdateServerVersionValidation(database.DBConn, v); err != nil {
+		log.Printf("HandleValidateVersion: failed to store result for version %d: %v", v.ID, err)
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to save validation result")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"version":    v,
+		"validation": rep,
+	})
+}
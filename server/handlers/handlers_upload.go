@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
@@ -8,22 +10,35 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"exile/server/database"
+	"exile/server/gamepkg"
 	"exile/server/models"
+	"exile/server/registry"
+	"exile/server/releases"
 	"exile/server/utils"
 
 	"github.com/gorilla/mux"
 )
 
-// ServeGameServerFile serves the currently active game_server.zip to nodes.
+// ServeGameServerFile serves the node's release channel version (or the active one) to nodes.
 func ServeGameServerFile(w http.ResponseWriter, r *http.Request) {
 	// If DB is connected, try to find the active version
 	var filename string = "game_server.zip" // default fallback
 
 	if database.DBConn != nil {
-		active, err := database.GetActiveServerVersion(database.DBConn)
+		// Nodes authenticate with their own key; resolve their release channel version
+		var node *models.Node
+		if nodeID, ok := registry.GlobalRegistry.ValidateNodeKey(r.Header.Get("X-API-Key")); ok {
+			if n, found := registry.GlobalRegistry.Get(nodeID); found {
+				copyN := *n
+				node = &copyN
+			}
+		}
+
+		active, _, err := releases.ResolveNodeVersion(database.DBConn, node)
 		if err != nil {
 			// Log error but attempt fallback
 			log.Printf("ServeGameServerFile: Error getting active version: %v", err)
@@ -54,7 +69,8 @@ func ServeGameServerFile(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, path)
 }
 
-// HandleUploadGameServer accepts a file upload and saves it as a new version.
+// HandleUploadGameServer accepts a single-request file upload and saves it as a new version.
+// Packages larger than the multipart limit must use the chunked upload endpoints.
 func HandleUploadGameServer(w http.ResponseWriter, r *http.Request) {
 	if database.DBConn == nil {
 		utils.WriteError(w, r, http.StatusServiceUnavailable, "Database not connected, cannot track versions")
@@ -63,7 +79,7 @@ func HandleUploadGameServer(w http.ResponseWriter, r *http.Request) {
 
 	// 1. Parse Multipart Form (100MB limit)
 	if err := r.ParseMultipartForm(100 << 20); err != nil {
-		utils.WriteError(w, r, http.StatusBadRequest, "File too large or invalid form")
+		utils.WriteError(w, r, http.StatusBadRequest, "File too large or invalid form (use /api/upload/init for large packages)")
 		return
 	}
 
@@ -75,65 +91,94 @@ func HandleUploadGameServer(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	comment := r.FormValue("comment")
-	versionStr := r.FormValue("version")
-
-	// 3. Generate unique filename
-	timestamp := time.Now().Unix()
-	ext := filepath.Ext(handler.Filename)
-	if ext == "" {
-		ext = ".zip"
-	}
-	newFilename := fmt.Sprintf("game_server_%d%s", timestamp, ext)
-
-	// 4. Ensure files directory exists
-	if err := os.MkdirAll("./files", 0755); err != nil {
+	// 3. Stage the upload, hashing as we copy
+	if err := os.MkdirAll(uploadTempDir, 0755); err != nil {
 		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to create directory")
 		return
 	}
-
-	// 5. Create the destination file
-	dstPath := filepath.Join("files", newFilename)
-	dst, err := os.Create(dstPath)
+	tmp, err := os.CreateTemp(uploadTempDir, "direct-*")
 	if err != nil {
 		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to create file on server")
 		return
 	}
-	defer dst.Close()
-
-	// 6. Copy the uploaded file
-	if _, err := io.Copy(dst, file); err != nil {
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), file)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
 		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to save file")
 		return
 	}
 
-	// 7. Save metadata to DB
+	// 4. Move into place and save metadata
+	version, err := finalizeUpload(tmp.Name(), handler.Filename, r.FormValue("version"), r.FormValue("comment"), hex.EncodeToString(hasher.Sum(nil)), size)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "File uploaded successfully",
+		"filename": version.Filename,
+		"version":  version,
+	})
+}
+
+// finalizeUpload moves a fully received package from staging into ./files and records it as a version.
+// The staged file is removed on failure.
+func finalizeUpload(stagedPath, originalName, versionStr, comment, sum string, size int64) (*models.GameServerVersion, error) {
+	// Generate unique filename
+	ext := filepath.Ext(originalName)
+	if ext == "" {
+		ext = ".zip"
+	}
+	newFilename := fmt.Sprintf("game_server_%d%s", time.Now().UnixNano(), ext)
+
+	if err := os.MkdirAll("./files", 0755); err != nil {
+		os.Remove(stagedPath)
+		return nil, fmt.Errorf("Failed to create directory")
+	}
+	dstPath := filepath.Join("files", newFilename)
+	if err := os.Rename(stagedPath, dstPath); err != nil {
+		os.Remove(stagedPath)
+		return nil, fmt.Errorf("Failed to save file")
+	}
+
 	version := &models.GameServerVersion{
 		Filename:   newFilename,
 		Version:    versionStr,
 		Comment:    comment,
 		UploadedAt: time.Now().UTC(),
 		IsActive:   false, // Default to inactive, user must activate
+		SHA256:     sum,
+		SizeBytes:  size,
+	}
+
+	// Broken packages are still recorded so the admin can see why, but they can never be activated
+	rep := inspectVersionPackage(version)
+	if version.Version == "" {
+		version.Version = rep.Metadata.Version
+	}
+	if !rep.Valid() {
+		log.Printf("Upload %s failed package validation: %s", newFilename, strings.Join(rep.Errors, "; "))
+	}
+	if rep.BinaryCheck == gamepkg.BinaryUnchecked {
+		log.Printf("Upload %s: game binary not checked; set %s to the binary path nodes use", newFilename, gameBinaryConfigKey)
 	}
 
 	// If it's the first version, maybe make it active?
 	// For safety, let's keep it inactive unless it's the very first one.
 	versions, _ := database.ListServerVersions(database.DBConn)
-	if len(versions) == 0 {
+	if len(versions) == 0 && rep.Valid() {
 		version.IsActive = true
 	}
 
 	if err := database.SaveServerVersion(database.DBConn, version); err != nil {
 		// Try to cleanup file if DB insert fails
 		os.Remove(dstPath)
-		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to save version metadata")
-		return
+		return nil, fmt.Errorf("Failed to save version metadata")
 	}
-
-	utils.WriteJSON(w, http.StatusOK, map[string]string{
-		"message":  "File uploaded successfully",
-		"filename": newFilename,
-	})
+	return version, nil
 }
 
 func ListVersions(w http.ResponseWriter, r *http.Request) {
@@ -165,6 +210,30 @@ func HandleSetActiveVersion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	version, err := database.GetServerVersionByID(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to load version")
+		return
+	}
+	if version == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "Version not found")
+		return
+	}
+	// Versions uploaded before validation existed are inspected on first activation
+	if version.ValidationStatus == gamepkg.StatusUnchecked {
+		inspectVersionPackage(version)
+		if err := database.UpdateServerVersionValidation(database.DBConn, version); err != nil {
+			log.Printf("HandleSetActiveVersion: failed to store validation for version %d: %v", id, err)
+		}
+	}
+	if version.ValidationStatus != gamepkg.StatusValid {
+		utils.WriteJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":             "Package failed validation and cannot be activated",
+			"validation_errors": version.ValidationErrors,
+		})
+		return
+	}
+
 	if err := database.SetActiveVersion(database.DBConn, id); err != nil {
 		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to set active version")
 		return
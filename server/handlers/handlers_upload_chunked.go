@@ -0,0 +1,498 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// Chunked upload protocol:
+//   POST   /api/upload/init               -> create a session (filename, size, sha256)
+//   PUT    /api/upload/{id}/parts/{index} -> raw chunk body, X-Chunk-SHA256 header
+//   GET    /api/upload/{id}               -> session status (which parts are still missing)
+//   POST   /api/upload/{id}/complete      -> verify the whole file and register the version
+//   DELETE /api/upload/{id}               -> abort
+//
+// Parts may arrive in any order and be retried; each is written at its offset in a
+// sparse staging file, so nothing is ever held in memory beyond one chunk.
+
+const (
+	uploadTempDir        = "files/.uploads"
+	defaultChunkSize     = 8 << 20  // 8MB
+	minChunkSize         = 1 << 20  // 1MB
+	maxChunkSize         = 64 << 20 // 64MB
+	defaultMaxUploadSize = 20 << 30 // 20GB
+)
+
+// uploadSession tracks one in-progress chunked upload. It is persisted next to the
+// staging file so uploads survive a master restart.
+type uploadSession struct {
+	ID           string    `json:"id"`
+	Filename     string    `json:"filename"`
+	Version      string    `json:"version"`
+	Comment      string    `json:"comment"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	ChunkSize    int64     `json:"chunk_size"`
+	TotalChunks  int       `json:"total_chunks"`
+	Received     []bool    `json:"received"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastActivity time.Time `json:"last_activity"`
+
+	mu         sync.Mutex
+	completing bool
+
+	// Held shared while a chunk is written and exclusively by complete, so the final
+	// checksum never reads a part that is still being written
+	writes sync.RWMutex
+}
+
+// UploadManager owns chunked upload sessions and expires abandoned ones.
+type UploadManager struct {
+	mu       sync.Mutex
+	sessions map[string]*uploadSession
+	ttl      time.Duration
+	maxSize  int64
+}
+
+var uploadManager *UploadManager
+
+// InitializeUploadManager creates the global upload manager, restores sessions left on
+// disk and starts the expiry loop.
+func InitializeUploadManager() {
+	uploadManager = NewUploadManager(
+		utils.GetEnvDuration("UPLOAD_SESSION_TTL", 2*time.Hour),
+		int64(utils.GetEnvInt("UPLOAD_MAX_SIZE_MB", defaultMaxUploadSize>>20))<<20,
+	)
+}
+
+// NewUploadManager creates an UploadManager and starts its cleanup goroutine.
+func NewUploadManager(ttl time.Duration, maxSize int64) *UploadManager {
+	m := &UploadManager{
+		sessions: make(map[string]*uploadSession),
+		ttl:      ttl,
+		maxSize:  maxSize,
+	}
+	m.restore()
+	go m.cleanupLoop()
+	return m
+}
+
+func sessionDir(id string) string {
+	return filepath.Join(uploadTempDir, id)
+}
+
+func (s *uploadSession) dataPath() string {
+	return filepath.Join(sessionDir(s.ID), "data.part")
+}
+
+// save writes the session manifest; callers must hold s.mu.
+func (s *uploadSession) save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(sessionDir(s.ID), "session.json.tmp")
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(sessionDir(s.ID), "session.json"))
+}
+
+func (s *uploadSession) missing() []int {
+	out := []int{}
+	for i, ok := range s.Received {
+		if !ok {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (s *uploadSession) status() map[string]interface{} {
+	missing := s.missing()
+	return map[string]interface{}{
+		"upload_id":      s.ID,
+		"filename":       s.Filename,
+		"size":           s.Size,
+		"chunk_size":     s.ChunkSize,
+		"total_chunks":   s.TotalChunks,
+		"received":       s.TotalChunks - len(missing),
+		"missing_chunks": missing,
+		"expires_at":     s.LastActivity.Add(uploadManager.ttl),
+	}
+}
+
+// restore reloads sessions from disk after a restart; unreadable ones are removed.
+func (m *UploadManager) restore() {
+	entries, err := os.ReadDir(uploadTempDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(uploadTempDir, e.Name(), "session.json"))
+		var s uploadSession
+		if err != nil || json.Unmarshal(data, &s) != nil || s.ID != e.Name() {
+			os.RemoveAll(filepath.Join(uploadTempDir, e.Name()))
+			continue
+		}
+		m.sessions[s.ID] = &s
+	}
+}
+
+func (m *UploadManager) cleanupLoop() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.expire()
+	}
+}
+
+// expire drops sessions idle for longer than the TTL, plus stray staging files.
+func (m *UploadManager) expire() {
+	m.mu.Lock()
+	expired := []*uploadSession{}
+	for id, s := range m.sessions {
+		s.mu.Lock()
+		idle := time.Since(s.LastActivity) > m.ttl && !s.completing
+		s.mu.Unlock()
+		if idle {
+			expired = append(expired, s)
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, s := range expired {
+		log.Printf("Upload: session %s (%s) expired", s.ID, s.Filename)
+		os.RemoveAll(sessionDir(s.ID))
+	}
+
+	// Direct uploads that died mid-copy leave temp files behind
+	if entries, err := os.ReadDir(uploadTempDir); err == nil {
+		for _, e := range entries {
+			info, err := e.Info()
+			if err == nil && !e.IsDir() && time.Since(info.ModTime()) > m.ttl {
+				os.Remove(filepath.Join(uploadTempDir, e.Name()))
+			}
+		}
+	}
+}
+
+func (m *UploadManager) get(id string) (*uploadSession, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+func (m *UploadManager) remove(id string) {
+	m.mu.Lock()
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	os.RemoveAll(sessionDir(id))
+}
+
+// extendDeadlines lifts the server-wide 15s timeouts for long chunk transfers and hashing.
+func extendDeadlines(w http.ResponseWriter, d time.Duration) {
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Now().Add(d))
+	_ = rc.SetWriteDeadline(time.Now().Add(d))
+}
+
+func isHexSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// -- Chunked Upload Handlers --
+
+func InitChunkedUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil || uploadManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "Database not connected, cannot track versions")
+		return
+	}
+
+	var req struct {
+		Filename  string `json:"filename"`
+		Size      int64  `json:"size"`
+		SHA256    string `json:"sha256"`
+		ChunkSize int64  `json:"chunk_size"`
+		Version   string `json:"version"`
+		Comment   string `json:"comment"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req.SHA256 = strings.ToLower(strings.TrimSpace(req.SHA256))
+	if req.Size <= 0 || req.Size > uploadManager.maxSize {
+		utils.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("size must be between 1 and %d bytes", uploadManager.maxSize))
+		return
+	}
+	if !isHexSHA256(req.SHA256) {
+		utils.WriteError(w, r, http.StatusBadRequest, "sha256 must be a hex-encoded SHA-256 digest")
+		return
+	}
+	if err := utils.ValidateFilename(filepath.Base(req.Filename)); err != nil || req.Filename == "" {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid filename")
+		return
+	}
+	if req.ChunkSize == 0 {
+		req.ChunkSize = defaultChunkSize
+	}
+	if req.ChunkSize < minChunkSize || req.ChunkSize > maxChunkSize {
+		utils.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("chunk_size must be between %d and %d bytes", minChunkSize, maxChunkSize))
+		return
+	}
+
+	now := time.Now().UTC()
+	total := int((req.Size + req.ChunkSize - 1) / req.ChunkSize)
+	s := &uploadSession{
+		ID:           utils.GenerateRandomString(16),
+		Filename:     filepath.Base(req.Filename),
+		Version:      req.Version,
+		Comment:      req.Comment,
+		Size:         req.Size,
+		SHA256:       req.SHA256,
+		ChunkSize:    req.ChunkSize,
+		TotalChunks:  total,
+		Received:     make([]bool, total),
+		CreatedAt:    now,
+		LastActivity: now,
+	}
+
+	if err := os.MkdirAll(sessionDir(s.ID), 0755); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to create upload directory")
+		return
+	}
+	f, err := os.Create(s.dataPath())
+	if err == nil {
+		// Reserve the full length up front so parts can be written at any offset
+		err = f.Truncate(s.Size)
+		f.Close()
+	}
+	if err == nil {
+		err = s.save()
+	}
+	if err != nil {
+		os.RemoveAll(sessionDir(s.ID))
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to allocate upload storage")
+		return
+	}
+
+	uploadManager.mu.Lock()
+	uploadManager.sessions[s.ID] = s
+	uploadManager.mu.Unlock()
+
+	utils.WriteJSON(w, http.StatusCreated, s.status())
+}
+
+func GetChunkedUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if uploadManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "Uploads not available")
+		return
+	}
+
+	s, ok := uploadManager.get(mux.Vars(r)["upload_id"])
+	if !ok {
+		utils.WriteError(w, r, http.StatusNotFound, "upload not found or expired")
+		return
+	}
+
+	s.mu.Lock()
+	status := s.status()
+	s.mu.Unlock()
+	utils.WriteJSON(w, http.StatusOK, status)
+}
+
+func UploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+	if uploadManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "Uploads not available")
+		return
+	}
+
+	vars := mux.Vars(r)
+	s, ok := uploadManager.get(vars["upload_id"])
+	if !ok {
+		utils.WriteError(w, r, http.StatusNotFound, "upload not found or expired")
+		return
+	}
+	index, err := strconv.Atoi(vars["index"])
+	if err != nil || index < 0 || index >= s.TotalChunks {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid chunk index")
+		return
+	}
+	s.writes.RLock()
+	defer s.writes.RUnlock()
+	s.mu.Lock()
+	completing := s.completing
+	s.mu.Unlock()
+	if completing {
+		utils.WriteError(w, r, http.StatusConflict, "upload is being completed")
+		return
+	}
+	wantSum := strings.ToLower(r.Header.Get("X-Chunk-SHA256"))
+	if !isHexSHA256(wantSum) {
+		utils.WriteError(w, r, http.StatusBadRequest, "X-Chunk-SHA256 header is required")
+		return
+	}
+
+	offset := int64(index) * s.ChunkSize
+	expected := s.ChunkSize
+	if offset+expected > s.Size {
+		expected = s.Size - offset
+	}
+
+	extendDeadlines(w, 10*time.Minute)
+
+	f, err := os.OpenFile(s.dataPath(), os.O_WRONLY, 0)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to open upload storage")
+		return
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	body := http.MaxBytesReader(w, r.Body, expected+1)
+	n, err := io.Copy(io.MultiWriter(io.NewOffsetWriter(f, offset), hasher), body)
+	if err != nil && n <= expected {
+		utils.WriteError(w, r, http.StatusBadRequest, "Failed to read chunk")
+		return
+	}
+	if n != expected {
+		utils.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("chunk %d must be exactly %d bytes, got %d", index, expected, n))
+		return
+	}
+	if got := hex.EncodeToString(hasher.Sum(nil)); got != wantSum {
+		utils.WriteError(w, r, http.StatusUnprocessableEntity, fmt.Sprintf("chunk %d checksum mismatch", index))
+		return
+	}
+	if err := f.Sync(); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to persist chunk")
+		return
+	}
+
+	s.mu.Lock()
+	s.Received[index] = true
+	s.LastActivity = time.Now().UTC()
+	if err := s.save(); err != nil {
+		log.Printf("Upload: failed to persist session %s: %v", s.ID, err)
+	}
+	status := s.status()
+	s.mu.Unlock()
+
+	utils.WriteJSON(w, http.StatusOK, status)
+}
+
+func CompleteChunkedUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil || uploadManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "Database not connected, cannot track versions")
+		return
+	}
+
+	s, ok := uploadManager.get(mux.Vars(r)["upload_id"])
+	if !ok {
+		utils.WriteError(w, r, http.StatusNotFound, "upload not found or expired")
+		return
+	}
+
+	s.mu.Lock()
+	if s.completing {
+		s.mu.Unlock()
+		utils.WriteError(w, r, http.StatusConflict, "upload is already being completed")
+		return
+	}
+	if missing := s.missing(); len(missing) > 0 {
+		status := s.status()
+		s.mu.Unlock()
+		utils.WriteJSON(w, http.StatusConflict, map[string]interface{}{"error": "upload is incomplete", "status": status})
+		return
+	}
+	s.completing = true
+	s.mu.Unlock()
+
+	// Wait for chunk writes already in flight; new ones see completing and are refused
+	s.writes.Lock()
+	s.writes.Unlock()
+
+	extendDeadlines(w, 30*time.Minute)
+
+	// Final integrity check over the assembled file
+	f, err := os.Open(s.dataPath())
+	if err != nil {
+		s.mu.Lock()
+		s.completing = false
+		s.mu.Unlock()
+		utils.WriteError(w, r, http.StatusInternalServerError, "Failed to open upload storage")
+		return
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, f)
+	f.Close()
+	if err != nil || hex.EncodeToString(hasher.Sum(nil)) != s.SHA256 {
+		// The assembled file is unusable; drop the session so the client starts over
+		uploadManager.remove(s.ID)
+		utils.WriteError(w, r, http.StatusUnprocessableEntity, "final checksum mismatch, upload discarded")
+		return
+	}
+
+	version, err := finalizeUpload(s.dataPath(), s.Filename, s.Version, s.Comment, s.SHA256, s.Size)
+	uploadManager.remove(s.ID)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message":  "File uploaded successfully",
+		"filename": version.Filename,
+		"version":  version,
+	})
+}
+
+func AbortChunkedUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if uploadManager == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "Uploads not available")
+		return
+	}
+
+	id := mux.Vars(r)["upload_id"]
+	s, ok := uploadManager.get(id)
+	if !ok {
+		utils.WriteError(w, r, http.StatusNotFound, "upload not found or expired")
+		return
+	}
+	s.mu.Lock()
+	busy := s.completing
+	s.mu.Unlock()
+	if busy {
+		utils.WriteError(w, r, http.StatusConflict, "upload is being completed")
+		return
+	}
+
+	uploadManager.remove(id)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "aborted"})
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 
 	"exile/server/auth"
+	"exile/server/bans"
 	"exile/server/database"
 	"exile/server/models"
 	"exile/server/utils"
@@ -30,6 +32,8 @@ import (
 // Response (JSON):
 //   - accountexist: Boolean indicating if the player account exists
 //   - ws_auth_key: WebSocket authentication key for real-time connection
+//
+// Players with an account-wide ban get 403 with the ban's scope, reason and expiry.
 func AuthenticatePlayerHandler(w http.ResponseWriter, r *http.Request) {
 	// ==================== Validation ====================
 
@@ -85,6 +89,9 @@ func AuthenticatePlayerHandler(w http.ResponseWriter, r *http.Request) {
 	accountexist := false
 	if p != nil {
 		accountexist = true
+		if !checkNotBanned(w, r, p.ID) {
+			return
+		}
 	}
 
 	// ==================== WebSocket Session ====================
@@ -132,6 +139,9 @@ func CreateOrGetPlayerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if p != nil {
+		if !checkNotBanned(w, r, p.ID) {
+			return
+		}
 		// Update basic info if provided
 		if req.Name != "" && req.Name != p.Name {
 			p.Name = req.Name
@@ -185,6 +195,8 @@ func GetPlayerDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	p.Friends = friends
 	p.IncomingFriendRequests = incoming
 	p.OutgoingFriendRequests = outgoing
+	p.Bans, _ = database.ListPlayerBans(database.DBConn, id)
+	p.Warnings, _ = database.ListPlayerWarnings(database.DBConn, id)
 
 	utils.WriteJSON(w, http.StatusOK, p)
 }
@@ -276,6 +288,17 @@ func DeletePlayerHandler(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "player deleted"})
 }
 
+// BanPlayerHandler bans a player or lifts their bans.
+//
+// Request (JSON):
+//   - banned (required): true to ban, false to lift every active ban
+//   - scope: all (default), game or chat
+//   - reason: Required when banning; shown to the player
+//   - duration: Go duration such as "24h"; empty for a permanent ban
+//   - evidence_links: URLs to clips, screenshots, reports
+//   - issued_by: Who issued or lifted the ban (defaults to "dashboard")
+//
+// Response (JSON): the player with their ban history.
 func BanPlayerHandler(w http.ResponseWriter, r *http.Request) {
 	if database.DBConn == nil {
 		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
@@ -291,12 +314,20 @@ func BanPlayerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Banned bool `json:"banned"`
+		Banned        bool     `json:"banned"`
+		Scope         string   `json:"scope"`
+		Reason        string   `json:"reason"`
+		Duration      string   `json:"duration"`
+		EvidenceLinks []string `json:"evidence_links"`
+		IssuedBy      string   `json:"issued_by"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.WriteError(w, r, http.StatusBadRequest, "invalid request")
 		return
 	}
+	if req.IssuedBy == "" {
+		req.IssuedBy = "dashboard"
+	}
 
 	p, err := database.GetPlayerByID(database.DBConn, id)
 	if err != nil {
@@ -308,15 +339,56 @@ func BanPlayerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p.Banned = req.Banned
-	if err := database.UpdatePlayer(database.DBConn, p); err != nil {
+	if req.Banned {
+		var duration time.Duration
+		if req.Duration != "" {
+			if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+				utils.WriteError(w, r, http.StatusBadRequest, "invalid duration")
+				return
+			}
+		}
+		if _, err := bans.Issue(bans.Request{
+			PlayerID:      id,
+			Scope:         req.Scope,
+			Reason:        req.Reason,
+			EvidenceLinks: req.EvidenceLinks,
+			Duration:      duration,
+			IssuedBy:      req.IssuedBy,
+		}); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else if _, err := bans.LiftAll(id, req.IssuedBy, req.Reason); err != nil {
 		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	p, err = database.GetPlayerByID(database.DBConn, id)
+	if err != nil || p == nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to reload player")
+		return
+	}
+	p.Bans, _ = database.ListPlayerBans(database.DBConn, id)
 	utils.WriteJSON(w, http.StatusOK, p)
 }
 
+// checkNotBanned writes a 403 and returns false if the player has an account-wide ban.
+func checkNotBanned(w http.ResponseWriter, r *http.Request, playerID int64) bool {
+	ban, err := bans.Active(playerID, models.BanScopeAll)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to check ban status")
+		return false
+	}
+	if ban != nil {
+		utils.WriteJSON(w, http.StatusForbidden, map[string]interface{}{
+			"error": "player is banned",
+			"ban":   bans.NoticeFor(ban),
+		})
+		return false
+	}
+	return true
+}
+
 // -- Friend System Handlers --
 
 func SendFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
@@ -363,6 +435,7 @@ func AcceptFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
 		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
+	ws_player.GlobalPlayerWS.FriendsChanged(req.SenderID, req.ReceiverID)
 
 	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "request accepted"})
 }
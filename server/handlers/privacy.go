@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"exile/server/database"
+	"exile/server/utils"
+	"exile/server/ws_player"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Block List & Privacy Handlers --
+
+// parsePlayerID reads a player ID route variable.
+func parsePlayerID(r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+	return id, err == nil && id > 0
+}
+
+// GetBlockListHandler returns the players a player has blocked.
+func GetBlockListHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, ok := parsePlayerID(r, "id")
+	if !ok {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	blocked, err := database.GetBlockedPlayers(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"blocked": blocked})
+}
+
+// BlockPlayerHandler adds a player to the block list. This also ends any friendship
+// between the two.
+//
+// Request (JSON):
+//   - player_id (required): Player to block
+func BlockPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, ok := parsePlayerID(r, "id")
+	if !ok {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req struct {
+		PlayerID int64 `json:"player_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PlayerID <= 0 {
+		utils.WriteError(w, r, http.StatusBadRequest, "player_id is required")
+		return
+	}
+
+	if err := ws_player.GlobalPlayerWS.BlockPlayer(id, req.PlayerID); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "player blocked"})
+}
+
+// UnblockPlayerHandler removes a player from the block list.
+func UnblockPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, ok := parsePlayerID(r, "id")
+	if !ok {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+	blockedID, ok := parsePlayerID(r, "blocked_id")
+	if !ok {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid blocked_id")
+		return
+	}
+
+	if err := ws_player.GlobalPlayerWS.UnblockPlayer(id, blockedID); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"message": "player unblocked"})
+}
+
+// GetPrivacySettingsHandler returns a player's privacy settings (defaults if never set).
+func GetPrivacySettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, ok := parsePlayerID(r, "id")
+	if !ok {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	s, err := database.GetPrivacySettings(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, s)
+}
+
+// UpdatePrivacySettingsHandler changes some or all privacy settings.
+//
+// Request (JSON, all optional):
+//   - friend_requests: everyone, friends_of_friends or nobody
+//   - presence: friends or nobody (appear offline)
+//   - messages: everyone, friends or nobody
+func UpdatePrivacySettingsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, ok := parsePlayerID(r, "id")
+	if !ok {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req ws_player.PrivacyUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	s, err := ws_player.GlobalPlayerWS.UpdatePrivacy(id, req)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, s)
+}
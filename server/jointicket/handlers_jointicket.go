@@ -0,0 +1,47 @@
+package jointicket
+
+import (
+	"net/http"
+
+	"exile/server/utils"
+)
+
+// -- Join Ticket Handlers --
+
+// GetPublicKeyHandler returns the key game servers use to check tickets offline.
+func GetPublicKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if !Enabled() {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "join tickets not initialized")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, PublicKeyInfo())
+}
+
+// VerifyTicketHandler checks a ticket online, including revocations. When instance_id
+// (and optionally node_id) is given, the ticket must have been issued for that instance.
+func VerifyTicketHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Ticket     string `json:"ticket"`
+		NodeID     int    `json:"node_id"`
+		InstanceID string `json:"instance_id"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Ticket == "" {
+		utils.WriteError(w, r, http.StatusBadRequest, "ticket is required")
+		return
+	}
+
+	c, err := Verify(req.Ticket)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if (req.InstanceID != "" && c.InstanceID != req.InstanceID) || (req.NodeID != 0 && c.NodeID != req.NodeID) {
+		utils.WriteError(w, r, http.StatusUnauthorized, "ticket was issued for another instance")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"valid": true, "claims": c})
+}
@@ -0,0 +1,360 @@
+package jointicket
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/discovery"
+	"exile/server/models"
+	"exile/server/utils"
+	"exile/server/ws"
+	"exile/server/ws_player"
+)
+
+// =================================================================================
+// JOIN TICKETS: short-lived ed25519-signed proof that a player was sent to an instance
+// =================================================================================
+//
+// A ticket is "<base64url(claims JSON)>.<base64url(signature)>". Game servers check it
+// through their node's instance WebSocket, or offline with the public key from
+// GET /api/game/join-tickets/key. Offline checks cannot see revocations, which is why
+// tickets only live for a couple of minutes.
+
+const (
+	Algorithm = "ed25519"
+
+	MsgTicketRequest = "JOIN_TICKET_REQUEST"
+	MsgTicket        = "JOIN_TICKET"
+
+	// Node WS commands
+	cmdSetKey = "set_join_ticket_key"
+	cmdRevoke = "revoke_join_tickets"
+)
+
+var (
+	ErrMalformed = errors.New("malformed ticket")
+	ErrSignature = errors.New("invalid ticket signature")
+	ErrExpired   = errors.New("ticket expired")
+	ErrRevoked   = errors.New("ticket revoked")
+)
+
+// Claims is the signed content of a ticket.
+type Claims struct {
+	PlayerID   int64  `json:"pid"`
+	NodeID     int    `json:"nid"`
+	InstanceID string `json:"iid"`
+	IssuedAt   int64  `json:"iat"` // Unix seconds
+	ExpiresAt  int64  `json:"exp"` // Unix seconds
+	ID         string `json:"jti"`
+	KeyID      string `json:"kid"`
+}
+
+// KeyInfo is what verifiers need to check tickets offline.
+type KeyInfo struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	PublicKey string `json:"public_key"` // Standard base64
+}
+
+var (
+	keyMu      sync.RWMutex
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+	keyID      string
+	ttl        = 2 * time.Minute
+
+	// Tickets issued to a player before this time are rejected
+	revokedMu sync.Mutex
+	revoked   = map[int64]time.Time{}
+)
+
+// InitJoinTickets loads the signing key from JOIN_TICKET_SIGNING_KEY (base64 ed25519
+// seed) or JOIN_TICKET_KEY_FILE (default join_ticket.key, created on first start), and
+// hooks tickets into the player and node WebSockets.
+func InitJoinTickets() error {
+	seed, err := loadSeed()
+	if err != nil {
+		return err
+	}
+	setKey(ed25519.NewKeyFromSeed(seed))
+	ttl = utils.GetEnvDuration("JOIN_TICKET_TTL", 2*time.Minute)
+
+	ws.GlobalWSManager.OnRegister(sendKey)
+	if ws_player.GlobalPlayerWS != nil {
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgTicketRequest, handleTicketRequest)
+	}
+	return nil
+}
+
+func loadSeed() ([]byte, error) {
+	if raw := strings.TrimSpace(os.Getenv("JOIN_TICKET_SIGNING_KEY")); raw != "" {
+		seed, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("JOIN_TICKET_SIGNING_KEY must be a base64 %d-byte ed25519 seed", ed25519.SeedSize)
+		}
+		return seed, nil
+	}
+
+	path := utils.GetEnv("JOIN_TICKET_KEY_FILE", "join_ticket.key")
+	if data, err := os.ReadFile(path); err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%s does not contain a valid ed25519 seed", path)
+		}
+		return seed, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(seed)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("write %s: %w", path, err)
+	}
+	log.Printf("JoinTickets: generated new signing key in %s", path)
+	return seed, nil
+}
+
+func setKey(priv ed25519.PrivateKey) {
+	pub := priv.Public().(ed25519.PublicKey)
+	sum := sha256.Sum256(pub)
+
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	privateKey, publicKey = priv, pub
+	keyID = hex.EncodeToString(sum[:8])
+}
+
+// Enabled reports whether a signing key is loaded.
+func Enabled() bool {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	return privateKey != nil
+}
+
+// PublicKeyInfo returns the verification key.
+func PublicKeyInfo() KeyInfo {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	return KeyInfo{Algorithm: Algorithm, KeyID: keyID, PublicKey: base64.StdEncoding.EncodeToString(publicKey)}
+}
+
+// Issue signs a ticket admitting playerID to an instance. Banned players get none.
+func Issue(playerID int64, nodeID int, instanceID string) (string, *Claims, error) {
+	keyMu.RLock()
+	priv, kid := privateKey, keyID
+	keyMu.RUnlock()
+	if priv == nil {
+		return "", nil, fmt.Errorf("join tickets not initialized")
+	}
+
+	if database.DBConn != nil {
+		p, err := database.GetPlayerByID(database.DBConn, playerID)
+		if err != nil {
+			return "", nil, fmt.Errorf("lookup player: %w", err)
+		}
+		if p == nil {
+			return "", nil, fmt.Errorf("player not found")
+		}
+		ban, err := database.GetActiveBan(database.DBConn, playerID, models.BanScopeAll, models.BanScopeGame)
+		if err != nil {
+			return "", nil, fmt.Errorf("check bans: %w", err)
+		}
+		if p.Banned || ban != nil {
+			return "", nil, fmt.Errorf("player is banned")
+		}
+	}
+
+	now := time.Now()
+	c := &Claims{
+		PlayerID:   playerID,
+		NodeID:     nodeID,
+		InstanceID: instanceID,
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  now.Add(ttl).Unix(),
+		ID:         utils.GenerateRandomString(16),
+		KeyID:      kid,
+	}
+	token, err := sign(priv, c)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, c, nil
+}
+
+func sign(priv ed25519.PrivateKey, c *Claims) (string, error) {
+	body, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(body) + "." + enc.EncodeToString(ed25519.Sign(priv, body)), nil
+}
+
+// parse checks the signature and expiry of a token.
+func parse(pub ed25519.PublicKey, token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrMalformed
+	}
+	enc := base64.RawURLEncoding
+	body, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !ed25519.Verify(pub, body, sig) {
+		return nil, ErrSignature
+	}
+	var c Claims
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, ErrMalformed
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return &c, nil
+}
+
+// Verify checks a ticket's signature, expiry and revocation.
+func Verify(token string) (*Claims, error) {
+	keyMu.RLock()
+	pub := publicKey
+	keyMu.RUnlock()
+	if pub == nil {
+		return nil, fmt.Errorf("join tickets not initialized")
+	}
+
+	c, err := parse(pub, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if isRevoked(c) {
+		return nil, ErrRevoked
+	}
+	return c, nil
+}
+
+func isRevoked(c *Claims) bool {
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	before, ok := revoked[c.PlayerID]
+	return ok && c.IssuedAt <= before.Unix()
+}
+
+// Revoke invalidates every ticket already issued to a player, here and on all
+// connected nodes (which also tell their game servers to drop the player).
+func Revoke(playerID int64) {
+	now := time.Now()
+
+	revokedMu.Lock()
+	revoked[playerID] = now
+	// Entries older than the ticket lifetime can't match anything anymore
+	for id, at := range revoked {
+		if now.Sub(at) > ttl {
+			delete(revoked, id)
+		}
+	}
+	revokedMu.Unlock()
+
+	ws.GlobalWSManager.SendToAll(cmdRevoke, map[string]interface{}{
+		"player_id":     playerID,
+		"issued_before": now.Unix(),
+	})
+}
+
+// sendKey hands a node the public key (and any live revocations) when it registers.
+func sendKey(c *ws.NodeConnection) {
+	info := PublicKeyInfo()
+	if info.KeyID == "" {
+		return
+	}
+
+	revokedMu.Lock()
+	revocations := make(map[string]int64, len(revoked))
+	for id, at := range revoked {
+		revocations[fmt.Sprint(id)] = at.Unix()
+	}
+	revokedMu.Unlock()
+
+	if err := c.Send(cmdSetKey, map[string]interface{}{
+		"algorithm":   info.Algorithm,
+		"key_id":      info.KeyID,
+		"public_key":  info.PublicKey,
+		"node_id":     c.ID,
+		"revocations": revocations,
+	}); err != nil {
+		log.Printf("JoinTickets: failed to send key to node %d: %v", c.ID, err)
+	}
+}
+
+// -- Player WS --
+
+// handleTicketRequest issues a ticket for a server picked from the server browser.
+func handleTicketRequest(c *ws_player.PlayerConnection, payload json.RawMessage) {
+	var req struct {
+		ServerID string `json:"server_id"`
+	}
+	if err := json.Unmarshal(payload, &req); err != nil || req.ServerID == "" {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "server_id is required")
+		return
+	}
+
+	s, ok := discovery.Get(req.ServerID)
+	if !ok {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "server not available")
+		return
+	}
+	if s.Full {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, "server is full")
+		return
+	}
+
+	// A party leader brings the party along: every member gets a ticket for the same server
+	players := []int64{c.PlayerID}
+	partyID := ""
+	if p, ok := ws_player.GlobalPlayerWS.PartyOf(c.PlayerID); ok && p.LeaderID == c.PlayerID {
+		players, partyID = p.MemberIDs(), p.ID
+		if !(discovery.Filter{Seats: len(players)}).Match(s) {
+			ws_player.GlobalPlayerWS.SendError(c.PlayerID, "not enough free slots for your party")
+			return
+		}
+	}
+
+	for _, id := range players {
+		token, claims, err := Issue(id, s.NodeID, s.InstanceID)
+		if err != nil {
+			if id == c.PlayerID {
+				ws_player.GlobalPlayerWS.SendError(c.PlayerID, fmt.Sprintf("Join ticket failed: %v", err))
+				return
+			}
+			ws_player.GlobalPlayerWS.SendError(c.PlayerID, fmt.Sprintf("Join ticket failed for party member %d: %v", id, err))
+			continue
+		}
+		ws_player.GlobalPlayerWS.SendMessage(id, ws_player.NewMessage(MsgTicket, map[string]interface{}{
+			"server_id":  s.ID,
+			"address":    s.Address,
+			"port":       s.Port,
+			"ticket":     token,
+			"expires_at": claims.ExpiresAt,
+			"party_id":   partyID,
+		}))
+	}
+}
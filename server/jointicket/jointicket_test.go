@@ -0,0 +1,93 @@
+package jointicket
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testKey(t *testing.T) {
+	t.Helper()
+	setKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+}
+
+func TestIssueAndVerify(t *testing.T) {
+	testKey(t)
+
+	token, claims, err := Issue(42, 3, "eu-7777")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ExpiresAt <= claims.IssuedAt || claims.KeyID == "" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	got, err := Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PlayerID != 42 || got.NodeID != 3 || got.InstanceID != "eu-7777" {
+		t.Fatalf("claims did not round-trip: %+v", got)
+	}
+
+	// Offline verification only needs the published key
+	pub, _ := base64.StdEncoding.DecodeString(PublicKeyInfo().PublicKey)
+	if _, err := parse(ed25519.PublicKey(pub), token, time.Now()); err != nil {
+		t.Fatalf("offline verification failed: %v", err)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	testKey(t)
+	token, _, err := Issue(42, 3, "eu-7777")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged, _, _ := Issue(43, 3, "eu-7777")
+	parts, forgedParts := strings.Split(token, "."), strings.Split(forged, ".")
+	if _, err := Verify(forgedParts[0] + "." + parts[1]); err != ErrSignature {
+		t.Fatalf("expected signature error for swapped claims, got %v", err)
+	}
+	if _, err := Verify("not-a-ticket"); err != ErrMalformed {
+		t.Fatalf("expected malformed error, got %v", err)
+	}
+
+	other := ed25519.NewKeyFromSeed([]byte(strings.Repeat("x", ed25519.SeedSize)))
+	if _, err := parse(other.Public().(ed25519.PublicKey), token, time.Now()); err != ErrSignature {
+		t.Fatalf("expected signature error with another key, got %v", err)
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	testKey(t)
+	token, claims, err := Issue(42, 3, "eu-7777")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyMu.RLock()
+	pub := publicKey
+	keyMu.RUnlock()
+	if _, err := parse(pub, token, time.Unix(claims.ExpiresAt, 0)); err != ErrExpired {
+		t.Fatalf("expected expiry, got %v", err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	testKey(t)
+	token, _, err := Issue(77, 1, "us-7777")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := Issue(78, 1, "us-7777")
+
+	Revoke(77)
+	if _, err := Verify(token); err != ErrRevoked {
+		t.Fatalf("expected revoked ticket, got %v", err)
+	}
+	if _, err := Verify(other); err != nil {
+		t.Fatalf("revocation must only hit the banned player, got %v", err)
+	}
+}
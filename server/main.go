@@ -23,14 +23,28 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 
+	"exile/server/appeals"
 	"exile/server/auth"
+	"exile/server/autoscaler"
+	"exile/server/bans"
+	"exile/server/chat"
 	"exile/server/config"
 	"exile/server/database"
+	"exile/server/discovery"
 	"exile/server/enrollment"
+	"exile/server/evidence"
+	"exile/server/fleet"
 	"exile/server/handlers"
+	"exile/server/jointicket"
+	"exile/server/matchmaking"
 	"exile/server/middleware"
+	"exile/server/moderation"
+	"exile/server/placement"
 	"exile/server/redeye"
 	"exile/server/registry"
+	"exile/server/releases"
+	"exile/server/retention"
+	"exile/server/sessions"
 	"exile/server/sse"
 	"exile/server/utils"
 	"exile/server/ws"
@@ -56,6 +70,11 @@ func (w GzipResponseWriter) Flush() {
 	}
 }
 
+// Unwrap lets http.ResponseController reach the underlying connection (e.g. to extend deadlines)
+func (w GzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // GzipMiddleware handles gzip compression for responses
 func GzipMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -135,6 +154,10 @@ func run() error {
 	enrollment.InitializeEnrollmentManager()
 	utils.PrintSection("Enrollment Manager", "ready", true)
 
+	// Initialize chunked upload sessions (resumes sessions staged before a restart)
+	handlers.InitializeUploadManager()
+	utils.PrintSection("Upload Manager", "ready", true)
+
 	// Initialize SSE hub for real-time dashboard updates
 	sseHub := sse.NewSSEHub()
 	go sseHub.Run()
@@ -229,6 +252,39 @@ func run() error {
 		}
 	}
 
+	// Initialize Release Channel Monitor (staged rollouts & auto-rollback)
+	if database.DBConn != nil {
+		releases.StartReleaseMonitor(database.DBConn)
+		utils.PrintSection("Release Channels", "ready", true)
+	}
+
+	// Initialize Fleet Update Manager (rolling instance updates, progress over SSE)
+	if database.DBConn != nil {
+		fleet.InitializeFleetManager(database.DBConn, sseHub)
+		utils.PrintSection("Fleet Updates", "ready", true)
+	}
+
+	// Initialize Autoscaler (warm buffer of idle servers per region/tag policy)
+	if database.DBConn != nil {
+		autoscaler.StartAutoscaler(database.DBConn)
+		if autoscaler.GlobalDryRun() {
+			utils.PrintSection("Autoscaler", "dry-run", true)
+		} else {
+			utils.PrintSection("Autoscaler", "ready", true)
+		}
+	}
+
+	// Initialize Version Retention (garbage collection of old builds in ./files)
+	if database.DBConn != nil {
+		retention.LoadPolicyFromEnv()
+		retention.StartRetentionJob(database.DBConn)
+		if retention.GetPolicy().DryRun {
+			utils.PrintSection("Version Retention", "dry-run", true)
+		} else {
+			utils.PrintSection("Version Retention", "ready", true)
+		}
+	}
+
 	// Initialize Firebase Remote Config
 	_ = auth.InitFirebase()
 	if auth.FirebaseMgr != nil && auth.FirebaseMgr.Connected {
@@ -255,6 +311,68 @@ func run() error {
 	// Initialize Player WS Manager
 	ws_player.InitPlayerWS()
 
+	// Initialize Server Browser (joinable instances, pushed to subscribed players)
+	discovery.StartDiscovery()
+	utils.PrintSection("Server Browser", "ready", true)
+
+	// Initialize Join Tickets (signed proof a player was sent to an instance)
+	if err := jointicket.InitJoinTickets(); err != nil {
+		utils.PrintSection("Join Tickets", "failed", false)
+		log.Printf("Join tickets disabled: %v", err)
+	} else {
+		utils.PrintSection("Join Tickets", "ready", true)
+	}
+
+	// Initialize Player Sessions (join/leave events reported by game servers)
+	if database.DBConn != nil {
+		sessions.InitSessions(database.DBConn)
+		utils.PrintSection("Player Sessions", "ready", true)
+	}
+
+	// Initialize Chat (direct messages and channels over the player WS)
+	if database.DBConn != nil {
+		if err := chat.InitChat(); err != nil {
+			utils.PrintSection("Chat", "failed", false)
+			log.Printf("Chat disabled: %v", err)
+		} else {
+			utils.PrintSection("Chat", "ready", true)
+		}
+	}
+
+	// Initialize Bans (expiry of timed bans)
+	if database.DBConn != nil {
+		bans.StartBans(database.DBConn)
+		utils.PrintSection("Bans", "ready", true)
+	}
+
+	// Initialize Moderation (warnings and report outcomes for players who were offline)
+	if database.DBConn != nil {
+		moderation.InitModeration()
+		utils.PrintSection("Moderation", "ready", true)
+	}
+
+	// Initialize Anticheat (per-player cheat scores and policies)
+	if database.DBConn != nil {
+		redeye.InitAnticheat()
+		utils.PrintSection("Anticheat", "ready", true)
+	}
+
+	// Initialize Evidence (content-addressed anticheat evidence)
+	if database.DBConn != nil {
+		if err := evidence.InitEvidence(); err != nil {
+			utils.PrintSection("Evidence", "failed", false)
+			log.Printf("Evidence store unavailable: %v", err)
+		} else {
+			utils.PrintSection("Evidence", "ready ("+evidence.Dir+")", true)
+		}
+	}
+
+	// Initialize Matchmaking (queue over the player WS, allocation through placement)
+	matchmaking.LoadConfigFromEnv()
+	matchmaking.SetPartyResolver(ws_player.GlobalPlayerWS.PartyQueueMembers)
+	matchmaking.StartMatchmaking()
+	utils.PrintSection("Matchmaking", "ready", true)
+
 	if isProduction {
 		if apiKey == "" {
 			log.Fatal("FATAL: MASTER_API_KEY must be set in production mode")
@@ -301,6 +419,8 @@ func run() error {
 	apiRouter.HandleFunc("/{id}/instances/{instance_id:.+}/backup/delete", handlers.DeleteNodeBackup).Methods("POST")
 	apiRouter.HandleFunc("/{id}/instances/{instance_id:.+}/stats/history", handlers.GetInstanceHistory).Methods("GET")
 	apiRouter.HandleFunc("/{id}/instances/{instance_id:.+}/history", handlers.GetInstanceHistoryActions).Methods("GET")
+	apiRouter.HandleFunc("/{id}/instances/{instance_id:.+}/roster", sessions.GetInstanceRosterHandler).Methods("GET")
+	apiRouter.HandleFunc("/anticheat/events/{id}/evidence", evidence.AttachEvidenceHandler).Methods("POST") // Game servers, with their node's key
 	apiRouter.HandleFunc("/{id}/update-template", handlers.UpdateNodeTemplate).Methods("POST")
 
 	// Game client routes - Secured via Game API Key
@@ -314,7 +434,17 @@ func run() error {
 	gameRouter.Handle("/players/{id}", http.HandlerFunc(handlers.GetPlayerDetailsHandler)).Methods("GET")
 	gameRouter.Handle("/friends/request", http.HandlerFunc(handlers.SendFriendRequestHandler)).Methods("POST")
 	gameRouter.Handle("/friends/accept", http.HandlerFunc(handlers.AcceptFriendRequestHandler)).Methods("POST")
-	gameRouter.Handle("/reports", http.HandlerFunc(handlers.CreateReportHandler)).Methods("POST")
+	gameRouter.Handle("/players/{id}/blocks", http.HandlerFunc(handlers.GetBlockListHandler)).Methods("GET")
+	gameRouter.Handle("/players/{id}/blocks", http.HandlerFunc(handlers.BlockPlayerHandler)).Methods("POST")
+	gameRouter.Handle("/players/{id}/blocks/{blocked_id}", http.HandlerFunc(handlers.UnblockPlayerHandler)).Methods("DELETE")
+	gameRouter.Handle("/players/{id}/privacy", http.HandlerFunc(handlers.GetPrivacySettingsHandler)).Methods("GET")
+	gameRouter.Handle("/players/{id}/privacy", http.HandlerFunc(handlers.UpdatePrivacySettingsHandler)).Methods("PUT")
+	gameRouter.Handle("/reports", http.HandlerFunc(moderation.CreateReportHandler)).Methods("POST")
+	gameRouter.Handle("/appeals", http.HandlerFunc(appeals.SubmitAppealHandler)).Methods("POST")
+	gameRouter.Handle("/appeals/status", http.HandlerFunc(appeals.GetAppealStatusHandler)).Methods("POST")
+	gameRouter.Handle("/servers", http.HandlerFunc(discovery.ListServersHandler)).Methods("GET")
+	gameRouter.Handle("/join-tickets/key", http.HandlerFunc(jointicket.GetPublicKeyHandler)).Methods("GET")
+	gameRouter.Handle("/join-tickets/verify", http.HandlerFunc(jointicket.VerifyTicketHandler)).Methods("POST")
 
 	// Liveness check
 	router.HandleFunc("/health", handlers.Health).Methods("GET")
@@ -349,12 +479,35 @@ func run() error {
 		router.Handle("/api/errors", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.ClearErrorsAPI))).Methods("DELETE")
 		router.Handle("/events", auth.AuthMiddleware(authConfig, sessionStore)(sseHandler)) // Replaced /ws with /events
 		router.Handle("/api/upload", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.HandleUploadGameServer))).Methods("POST")
+		router.Handle("/api/upload/init", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.InitChunkedUploadHandler))).Methods("POST")
+		router.Handle("/api/upload/{upload_id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.GetChunkedUploadHandler))).Methods("GET")
+		router.Handle("/api/upload/{upload_id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.AbortChunkedUploadHandler))).Methods("DELETE")
+		router.Handle("/api/upload/{upload_id}/parts/{index}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.UploadChunkHandler))).Methods("PUT")
+		router.Handle("/api/upload/{upload_id}/complete", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.CompleteChunkedUploadHandler))).Methods("POST")
 
 		// Version Management Routes
 		router.Handle("/api/versions", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.ListVersions))).Methods("GET")
 		router.Handle("/api/versions/{id}/active", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.HandleSetActiveVersion))).Methods("POST")
+		router.Handle("/api/versions/retention", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(retention.GetRetentionHandler))).Methods("GET")
+		router.Handle("/api/versions/retention/policy", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(retention.UpdateRetentionPolicyHandler))).Methods("PUT")
+		router.Handle("/api/versions/retention/run", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(retention.RunRetentionHandler))).Methods("POST")
+		router.Handle("/api/versions/{id}/pin", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(retention.PinVersionHandler))).Methods("POST")
+		router.Handle("/api/versions/{id}/validate", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.HandleValidateVersion))).Methods("POST")
 		router.Handle("/api/versions/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.HandleDeleteVersion))).Methods("DELETE")
 
+		// Release Channels & Staged Rollouts
+		router.Handle("/api/releases/channels", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.ListChannelsHandler))).Methods("GET")
+		router.Handle("/api/releases/channels", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.CreateChannelHandler))).Methods("POST")
+		router.Handle("/api/releases/channels/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.UpdateChannelHandler))).Methods("PUT")
+		router.Handle("/api/releases/channels/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.DeleteChannelHandler))).Methods("DELETE")
+		router.Handle("/api/releases/channels/{id}/rollout", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.StartRolloutHandler))).Methods("POST")
+		router.Handle("/api/releases/channels/{id}/percent", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.SetRolloutPercentHandler))).Methods("POST")
+		router.Handle("/api/releases/channels/{id}/pause", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.PauseRolloutHandler))).Methods("POST")
+		router.Handle("/api/releases/channels/{id}/resume", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.ResumeRolloutHandler))).Methods("POST")
+		router.Handle("/api/releases/channels/{id}/rollback", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.RollbackHandler))).Methods("POST")
+		router.Handle("/api/releases/channels/{id}/health", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.GetChannelHealthHandler))).Methods("GET")
+		router.Handle("/api/releases/nodes", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(releases.ListNodeAssignmentsHandler))).Methods("GET")
+
 		// Configuration Management Routes
 		router.Handle("/api/config", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(config.GetAllConfigHandler))).Methods("GET")
 		router.Handle("/api/config", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(config.CreateConfigHandler))).Methods("POST")
@@ -459,6 +612,22 @@ func run() error {
 
 		// Fleet Management
 		router.Handle("/api/instances", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.ListAllInstances))).Methods("GET")
+		router.Handle("/api/instances", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(placement.SpawnInstanceHandler))).Methods("POST")
+		router.Handle("/api/instances/placement", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(placement.PreviewPlacementHandler))).Methods("POST")
+		router.Handle("/api/fleet/updates", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(fleet.ListFleetUpdatesHandler))).Methods("GET")
+		router.Handle("/api/fleet/updates", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(fleet.CreateFleetUpdateHandler))).Methods("POST")
+		router.Handle("/api/fleet/updates/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(fleet.GetFleetUpdateHandler))).Methods("GET")
+		router.Handle("/api/fleet/updates/{id}/pause", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(fleet.PauseFleetUpdateHandler))).Methods("POST")
+		router.Handle("/api/fleet/updates/{id}/resume", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(fleet.ResumeFleetUpdateHandler))).Methods("POST")
+		router.Handle("/api/fleet/updates/{id}/cancel", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(fleet.CancelFleetUpdateHandler))).Methods("POST")
+		router.Handle("/api/autoscaler/policies", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(autoscaler.ListPoliciesHandler))).Methods("GET")
+		router.Handle("/api/autoscaler/policies", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(autoscaler.CreatePolicyHandler))).Methods("POST")
+		router.Handle("/api/autoscaler/policies/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(autoscaler.UpdatePolicyHandler))).Methods("PUT")
+		router.Handle("/api/autoscaler/policies/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(autoscaler.DeletePolicyHandler))).Methods("DELETE")
+		router.Handle("/api/autoscaler/policies/{id}/evaluate", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(autoscaler.EvaluatePolicyHandler))).Methods("POST")
+		router.Handle("/api/autoscaler/decisions", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(autoscaler.ListDecisionsHandler))).Methods("GET")
+		router.Handle("/api/matchmaking", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(matchmaking.GetMatchmakingHandler))).Methods("GET")
+		router.Handle("/api/matchmaking/config", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(matchmaking.UpdateMatchmakingConfigHandler))).Methods("PUT")
 
 		// RedEye Security System
 		router.Handle("/api/redeye/stats", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.GetRedEyeStatsHandler))).Methods("GET")
@@ -466,17 +635,36 @@ func run() error {
 		router.Handle("/api/redeye/config", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.UpdateRedEyeConfigHandler))).Methods("PUT")
 		router.Handle("/api/redeye/rules", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ListRedEyeRulesHandler))).Methods("GET")
 		router.Handle("/api/redeye/rules", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.CreateRedEyeRuleHandler))).Methods("POST")
+		router.Handle("/api/redeye/rules/evaluate", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.EvaluateRulesHandler))).Methods("POST")
 		router.Handle("/api/redeye/rules/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.UpdateRedEyeRuleHandler))).Methods("PUT")
 		router.Handle("/api/redeye/rules/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.DeleteRedEyeRuleHandler))).Methods("DELETE")
 		router.Handle("/api/redeye/logs", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ListRedEyeLogsHandler))).Methods("GET")
 		router.Handle("/api/redeye/logs", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ClearRedEyeLogsHandler))).Methods("DELETE")
 
 		router.Handle("/api/redeye/bans", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ListBannedIPsHandler))).Methods("GET")
-		router.Handle("/api/redeye/bans/{ip}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.UnbanIPHandler))).Methods("DELETE")
+		router.Handle("/api/redeye/bans", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.BanIPHandler))).Methods("POST")
+		router.Handle("/api/redeye/bans/{ip:.+}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.UnbanIPHandler))).Methods("DELETE")
+		router.Handle("/api/redeye/feeds", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ListFeedsHandler))).Methods("GET")
+		router.Handle("/api/redeye/feeds", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.CreateFeedHandler))).Methods("POST")
+		router.Handle("/api/redeye/feeds/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.UpdateFeedHandler))).Methods("PUT")
+		router.Handle("/api/redeye/feeds/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.DeleteFeedHandler))).Methods("DELETE")
+		router.Handle("/api/redeye/feeds/{id}/refresh", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.RefreshFeedHandler))).Methods("POST")
+		router.Handle("/api/redeye/feeds/{id}/entries", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ListFeedEntriesHandler))).Methods("GET")
 
 		// RedEye Anti-Cheat
 		router.Handle("/api/redeye/anticheat/report", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ReportAnticheatEventHandler))).Methods("POST")
 		router.Handle("/api/redeye/anticheat/events", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.GetAnticheatEventsHandler))).Methods("GET")
+		router.Handle("/api/redeye/anticheat/events/{id}/evidence", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(evidence.ListEventEvidenceHandler))).Methods("GET")
+		router.Handle("/api/redeye/anticheat/evidence/verify", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(evidence.VerifyEvidenceHandler))).Methods("GET")
+		router.Handle("/api/redeye/anticheat/evidence/{id}/content", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(evidence.GetEvidenceContentHandler))).Methods("GET")
+		router.Handle("/api/redeye/anticheat/players", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ListCheatScoresHandler))).Methods("GET")
+		router.Handle("/api/redeye/anticheat/players/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.GetPlayerCheatHandler))).Methods("GET")
+		router.Handle("/api/redeye/anticheat/policies", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ListCheatPoliciesHandler))).Methods("GET")
+		router.Handle("/api/redeye/anticheat/policies", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.CreateCheatPolicyHandler))).Methods("POST")
+		router.Handle("/api/redeye/anticheat/policies/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.UpdateCheatPolicyHandler))).Methods("PUT")
+		router.Handle("/api/redeye/anticheat/policies/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.DeleteCheatPolicyHandler))).Methods("DELETE")
+		router.Handle("/api/redeye/anticheat/reviews", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ListCheatReviewsHandler))).Methods("GET")
+		router.Handle("/api/redeye/anticheat/reviews/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(redeye.ResolveCheatReviewHandler))).Methods("POST")
 
 		// AI Bot API
 		router.Handle("/api/ai/chat", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.AIChatHandler))).Methods("POST")
@@ -487,9 +675,24 @@ func run() error {
 		router.Handle("/api/admin/players/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.UpdatePlayerDetailsHandler))).Methods("PUT")
 		router.Handle("/api/admin/players/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.DeletePlayerHandler))).Methods("DELETE")
 		router.Handle("/api/admin/players/{id}/ban", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.BanPlayerHandler))).Methods("POST")
+		router.Handle("/api/admin/players/{id}/sessions", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(sessions.GetPlayerSessionsHandler))).Methods("GET")
+		router.Handle("/api/admin/players/{id}/bans", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(bans.GetPlayerBansHandler))).Methods("GET")
+		router.Handle("/api/admin/players/{id}/case-export", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(evidence.ExportPlayerCaseHandler))).Methods("GET")
+		router.Handle("/api/admin/bans", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(bans.ListActiveBansHandler))).Methods("GET")
+		router.Handle("/api/admin/bans/{id}/lift", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(bans.LiftBanHandler))).Methods("POST")
+		router.Handle("/api/admin/appeals", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(appeals.ListAppealsHandler))).Methods("GET")
+		router.Handle("/api/admin/appeals/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(appeals.GetAppealHandler))).Methods("GET")
+		router.Handle("/api/admin/appeals/{id}/decision", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(appeals.DecideAppealHandler))).Methods("POST")
 
 		// Dashboard: Reports (Session Protected)
-		router.Handle("/api/reports", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(handlers.ListReportsHandler))).Methods("GET")
+		router.Handle("/api/reports", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(moderation.ListReportsHandler))).Methods("GET")
+		router.Handle("/api/reports/most-reported", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(moderation.MostReportedHandler))).Methods("GET")
+		router.Handle("/api/reports/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(moderation.GetReportHandler))).Methods("GET")
+		router.Handle("/api/reports/{id}", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(moderation.UpdateReportHandler))).Methods("PUT")
+		router.Handle("/api/reports/{id}/notes", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(moderation.AddReportNoteHandler))).Methods("POST")
+		router.Handle("/api/reports/{id}/action", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(moderation.ReportActionHandler))).Methods("POST")
+		router.Handle("/api/reports/{id}/messages", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(chat.ReportMessagesHandler))).Methods("GET")
+		router.Handle("/api/admin/chat/messages", auth.AuthMiddleware(authConfig, sessionStore)(http.HandlerFunc(chat.SearchMessagesHandler))).Methods("GET")
 
 		// Game client routes - Secured via Game API Key
 		gameRouter := router.PathPrefix("/api/game").Subrouter()
@@ -576,6 +779,12 @@ func run() error {
 
 	// Stop RedEye Engine
 	redeye.StopRedEye()
+	releases.StopReleaseMonitor()
+	retention.StopRetentionJob()
+	autoscaler.StopAutoscaler()
+	discovery.StopDiscovery()
+	matchmaking.StopMatchmaking()
+	bans.StopBans()
 
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("error during server shutdown: %v", err)
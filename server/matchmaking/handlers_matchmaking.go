@@ -0,0 +1,34 @@
+package matchmaking
+
+import (
+	"net/http"
+
+	"exile/server/utils"
+)
+
+// -- Matchmaking Handlers --
+
+// GetMatchmakingHandler returns the settings, the waiting tickets and the latest matches.
+func GetMatchmakingHandler(w http.ResponseWriter, r *http.Request) {
+	tickets, matches := Snapshot()
+	players := 0
+	for _, t := range tickets {
+		players += len(t.Players)
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"config":         GetConfig(),
+		"queued_players": players,
+		"tickets":        tickets,
+		"recent_matches": matches,
+	})
+}
+
+func UpdateMatchmakingConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var c Config
+	if err := utils.DecodeJSON(r, &c); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	SetConfig(c)
+	utils.WriteJSON(w, http.StatusOK, GetConfig())
+}
@@ -0,0 +1,792 @@
+package matchmaking
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/discovery"
+	"exile/server/jointicket"
+	"exile/server/models"
+	"exile/server/placement"
+	"exile/server/registry"
+	"exile/server/utils"
+	"exile/server/ws_player"
+)
+
+// =================================================================================
+// MATCHMAKING: queue players over the player WS and allocate them a server
+// =================================================================================
+
+const (
+	MsgJoin       = "MATCHMAKING_JOIN"
+	MsgCancel     = "MATCHMAKING_CANCEL"
+	MsgQueued     = "MATCHMAKING_QUEUED"
+	MsgStatus     = "MATCHMAKING_STATUS"
+	MsgCancelled  = "MATCHMAKING_CANCELLED"
+	MsgMatchFound = "MATCH_FOUND"
+
+	tickInterval = 2 * time.Second
+
+	// Seats handed out on a server are held until its game server has had time to report the players
+	seatReservationTTL = 60 * time.Second
+
+	recentMatchesKept = 50
+
+	// Tickets whose allocation failed sit out before being grouped again, doubling per failure
+	allocRetryBase = 5 * time.Second
+	allocRetryMax  = 60 * time.Second
+)
+
+// Config tunes how tickets are grouped. Durations are in seconds so the dashboard can edit them.
+type Config struct {
+	MinPlayers        int   `json:"min_players"`         // Smallest match that will be started
+	MaxPlayers        int   `json:"max_players"`         // Largest match (and the assumed capacity of servers that haven't reported one)
+	SkillRange        int64 `json:"skill_range"`         // Allowed skill difference when a ticket is new
+	SkillRangeGrowth  int64 `json:"skill_range_growth"`  // Added to the range every widen_after_seconds waited
+	WidenAfterSeconds int   `json:"widen_after_seconds"` // After this wait a ticket also matches other regions
+	TimeoutSeconds    int   `json:"timeout_seconds"`     // Tickets waiting longer are cancelled
+}
+
+func (c Config) widenAfter() time.Duration {
+	return time.Duration(c.WidenAfterSeconds) * time.Second
+}
+
+func (c Config) timeout() time.Duration {
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// Ticket is one queue entry: a solo player or a whole party that must be placed together.
+type Ticket struct {
+	ID         string    `json:"id"`
+	LeaderID   int64     `json:"leader_id"`
+	Players    []int64   `json:"players"`
+	Region     string    `json:"region,omitempty"` // Empty = any region
+	GameMode   string    `json:"game_mode,omitempty"`
+	Skill      int64     `json:"skill"` // Average of the members
+	EnqueuedAt time.Time `json:"enqueued_at"`
+
+	// Shadow tickets hold a shadow-queued player and only match each other. Players
+	// are not told.
+	Shadow bool `json:"-"`
+
+	widenNotified bool
+	matchID       string    // Set while the ticket's match is being allocated
+	withdrawn     bool      // Cancelled while its match was being allocated
+	failures      int       // Allocations that failed for this ticket
+	retryAt       time.Time // Not grouped again before this after a failed allocation
+}
+
+func (t *Ticket) widened(cfg Config, now time.Time) bool {
+	return cfg.WidenAfterSeconds > 0 && now.Sub(t.EnqueuedAt) >= cfg.widenAfter()
+}
+
+func (t *Ticket) skillWindow(cfg Config, now time.Time) int64 {
+	if cfg.WidenAfterSeconds <= 0 {
+		return cfg.SkillRange
+	}
+	steps := int64(now.Sub(t.EnqueuedAt) / cfg.widenAfter())
+	return cfg.SkillRange + steps*cfg.SkillRangeGrowth
+}
+
+// backingOff reports whether t is waiting out a failed allocation.
+func (t *Ticket) backingOff(now time.Time) bool {
+	return now.Before(t.retryAt)
+}
+
+// allocationBackoff is how long a ticket sits out after its nth failed allocation.
+func allocationBackoff(failures int) time.Duration {
+	d := allocRetryBase
+	for i := 1; i < failures && d < allocRetryMax; i++ {
+		d *= 2
+	}
+	if d > allocRetryMax {
+		d = allocRetryMax
+	}
+	return d
+}
+
+// acceptsRegion reports whether t is willing to play in region.
+func (t *Ticket) acceptsRegion(region string, cfg Config, now time.Time) bool {
+	return t.Region == "" || region == "" || strings.EqualFold(t.Region, region) || t.widened(cfg, now)
+}
+
+// compatible reports whether two tickets may share a match; both sides have to agree.
+func compatible(a, b *Ticket, cfg Config, now time.Time) bool {
+	if a.Shadow != b.Shadow || !strings.EqualFold(a.GameMode, b.GameMode) {
+		return false
+	}
+	if !a.acceptsRegion(b.Region, cfg, now) || !b.acceptsRegion(a.Region, cfg, now) {
+		return false
+	}
+	diff := a.Skill - b.Skill
+	if diff < 0 {
+		diff = -diff
+	}
+	window := a.skillWindow(cfg, now)
+	if w := b.skillWindow(cfg, now); w < window {
+		window = w
+	}
+	return diff <= window
+}
+
+// formMatches groups queued tickets, oldest first, into matches of MinPlayers..MaxPlayers.
+// Tickets that could not be grouped are returned in rest, in queue order.
+func formMatches(queue []*Ticket, cfg Config, now time.Time) (groups [][]*Ticket, rest []*Ticket) {
+	ordered := append([]*Ticket(nil), queue...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].EnqueuedAt.Before(ordered[j].EnqueuedAt) })
+
+	used := map[*Ticket]bool{}
+	for i, anchor := range ordered {
+		if used[anchor] {
+			continue
+		}
+		group := []*Ticket{anchor}
+		count := len(anchor.Players)
+		for _, t := range ordered[i+1:] {
+			if used[t] || count+len(t.Players) > cfg.MaxPlayers {
+				continue
+			}
+			fits := true
+			for _, g := range group {
+				if !compatible(g, t, cfg, now) {
+					fits = false
+					break
+				}
+			}
+			if fits {
+				group = append(group, t)
+				count += len(t.Players)
+			}
+		}
+		if count >= cfg.MinPlayers && count <= cfg.MaxPlayers {
+			for _, t := range group {
+				used[t] = true
+			}
+			groups = append(groups, group)
+		}
+	}
+
+	for _, t := range queue {
+		if !used[t] {
+			rest = append(rest, t)
+		}
+	}
+	return groups, rest
+}
+
+// matchRegion is where a group plays. A ticket that has not widened pins the match to its
+// region; otherwise the oldest ticket's region is only preferred and anyRegion is set, since
+// every member also accepts other regions.
+func matchRegion(group []*Ticket, cfg Config, now time.Time) (region string, anyRegion bool) {
+	for _, t := range group {
+		if t.Region == "" {
+			continue
+		}
+		if !t.widened(cfg, now) {
+			return t.Region, false
+		}
+		if region == "" {
+			region = t.Region
+		}
+	}
+	return region, true
+}
+
+// Match is a group of tickets and the server they were sent to.
+type Match struct {
+	ID        string            `json:"id"`
+	Region    string            `json:"region,omitempty"`
+	AnyRegion bool              `json:"any_region,omitempty"` // Region is only preferred; any server will do
+	GameMode  string            `json:"game_mode,omitempty"`
+	Players   []int64           `json:"players"`
+	Tickets   []*Ticket         `json:"tickets"`
+	Server    *discovery.Server `json:"server,omitempty"`
+	Spawned   bool              `json:"spawned"` // A new instance was started for this match
+	Status    string            `json:"status"`  // allocating, found, failed
+	Error     string            `json:"error,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// PartyResolver returns the players that must queue together with playerID (including
+// them), or nil to queue solo. An error rejects the request, e.g. when only the party
+// leader may queue.
+type PartyResolver func(playerID int64) ([]int64, error)
+
+// SkillFunc rates a player for grouping.
+type SkillFunc func(playerID int64) int64
+
+// ShadowFunc reports whether a player may only be matched with other shadow-queued players.
+type ShadowFunc func(playerID int64) bool
+
+var (
+	configMu sync.RWMutex
+	config   = Config{MinPlayers: 2, MaxPlayers: 10, SkillRange: 200, SkillRangeGrowth: 200, WidenAfterSeconds: 30, TimeoutSeconds: 300}
+
+	mu       sync.Mutex
+	queue    []*Ticket
+	byPlayer = map[int64]*Ticket{}
+	recent   []*Match
+
+	hooksMu       sync.RWMutex
+	partyResolver PartyResolver
+	skillOf       SkillFunc = xpSkill
+	shadowOf      ShadowFunc
+
+	seatsMu sync.Mutex
+	seats   = map[string][]seatReservation{}
+
+	done    chan struct{}
+	wg      sync.WaitGroup
+	running bool
+)
+
+type seatReservation struct {
+	count int
+	at    time.Time
+}
+
+// LoadConfigFromEnv reads MATCHMAKING_* settings.
+func LoadConfigFromEnv() {
+	c := Config{
+		MinPlayers:       utils.GetEnvInt("MATCHMAKING_MIN_PLAYERS", 2),
+		MaxPlayers:       utils.GetEnvInt("MATCHMAKING_MAX_PLAYERS", 10),
+		SkillRange:       int64(utils.GetEnvInt("MATCHMAKING_SKILL_RANGE", 200)),
+		SkillRangeGrowth: int64(utils.GetEnvInt("MATCHMAKING_SKILL_RANGE_GROWTH", 200)),
+	}
+	c.WidenAfterSeconds = int(utils.GetEnvDuration("MATCHMAKING_WIDEN_AFTER", 30*time.Second).Seconds())
+	c.TimeoutSeconds = int(utils.GetEnvDuration("MATCHMAKING_TIMEOUT", 5*time.Minute).Seconds())
+	SetConfig(c)
+}
+
+// GetConfig returns the current settings.
+func GetConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// SetConfig replaces the settings; out-of-range values are clamped.
+func SetConfig(c Config) {
+	if c.MinPlayers < 1 {
+		c.MinPlayers = 1
+	}
+	if c.MaxPlayers < c.MinPlayers {
+		c.MaxPlayers = c.MinPlayers
+	}
+	if c.SkillRange < 0 {
+		c.SkillRange = 0
+	}
+	if c.SkillRangeGrowth < 0 {
+		c.SkillRangeGrowth = 0
+	}
+	if c.WidenAfterSeconds < 0 {
+		c.WidenAfterSeconds = 0
+	}
+	if c.TimeoutSeconds < 0 {
+		c.TimeoutSeconds = 0
+	}
+	configMu.Lock()
+	config = c
+	configMu.Unlock()
+}
+
+// SetPartyResolver lets the party system decide who queues together.
+func SetPartyResolver(r PartyResolver) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	partyResolver = r
+}
+
+// SetSkillFunc replaces the skill rating used for grouping.
+func SetSkillFunc(f SkillFunc) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	skillOf = f
+}
+
+// SetShadowFunc lets the anticheat decide who is shadow-queued.
+func SetShadowFunc(f ShadowFunc) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	shadowOf = f
+}
+
+// xpSkill rates players by XP until a dedicated rating exists.
+func xpSkill(playerID int64) int64 {
+	if database.DBConn == nil {
+		return 0
+	}
+	p, err := database.GetPlayerByID(database.DBConn, playerID)
+	if err != nil || p == nil {
+		return 0
+	}
+	return p.XP
+}
+
+// IsQueued reports whether a player is waiting in (or being matched by) the queue.
+func IsQueued(playerID int64) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	_, ok := byPlayer[playerID]
+	return ok
+}
+
+// Snapshot returns copies of the waiting tickets and the recent matches.
+func Snapshot() (tickets []Ticket, matches []Match) {
+	mu.Lock()
+	defer mu.Unlock()
+	tickets = make([]Ticket, 0, len(queue))
+	for _, t := range queue {
+		tickets = append(tickets, *t)
+	}
+	matches = make([]Match, 0, len(recent))
+	for i := len(recent) - 1; i >= 0; i-- {
+		matches = append(matches, *recent[i])
+	}
+	return tickets, matches
+}
+
+// Enqueue adds a player (and their party, if any) to the queue.
+func Enqueue(playerID int64, region, gameMode string) (*Ticket, error) {
+	cfg := GetConfig()
+
+	hooksMu.RLock()
+	resolve, skill, shadowed := partyResolver, skillOf, shadowOf
+	hooksMu.RUnlock()
+
+	members := []int64{playerID}
+	if resolve != nil {
+		party, err := resolve(playerID)
+		if err != nil {
+			return nil, err
+		}
+		if len(party) > 0 {
+			members = party
+		}
+	}
+	if len(members) > cfg.MaxPlayers {
+		return nil, fmt.Errorf("party of %d is larger than a match (%d)", len(members), cfg.MaxPlayers)
+	}
+	if database.DBConn != nil {
+		for _, id := range members {
+			ban, err := database.GetActiveBan(database.DBConn, id, models.BanScopeAll, models.BanScopeGame)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check bans")
+			}
+			if ban != nil {
+				if id == playerID {
+					return nil, fmt.Errorf("you are banned from matches")
+				}
+				return nil, fmt.Errorf("party member %d is banned from matches", id)
+			}
+		}
+	}
+
+	var total int64
+	shadow := false
+	for _, id := range members {
+		total += skill(id)
+		if shadowed != nil && shadowed(id) {
+			shadow = true
+		}
+	}
+
+	t := &Ticket{
+		ID:         utils.GenerateRandomString(16),
+		LeaderID:   playerID,
+		Players:    members,
+		Region:     strings.TrimSpace(region),
+		GameMode:   strings.TrimSpace(gameMode),
+		Skill:      total / int64(len(members)),
+		EnqueuedAt: time.Now(),
+		Shadow:     shadow,
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, id := range members {
+		if _, queued := byPlayer[id]; queued {
+			if id == playerID {
+				return nil, fmt.Errorf("already in matchmaking")
+			}
+			return nil, fmt.Errorf("party member %d is already in matchmaking", id)
+		}
+	}
+	queue = append(queue, t)
+	for _, id := range members {
+		byPlayer[id] = t
+	}
+	return t, nil
+}
+
+// Cancel removes the ticket holding playerID. A ticket whose match is being allocated
+// is withdrawn from it: it is neither requeued nor sent the match when allocation ends.
+func Cancel(playerID int64) (*Ticket, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := byPlayer[playerID]
+	if !ok {
+		return nil, fmt.Errorf("not in matchmaking")
+	}
+	if t.matchID != "" {
+		t.withdrawn = true
+	}
+	removeTicketLocked(t)
+	return t, nil
+}
+
+func removeTicketLocked(t *Ticket) {
+	for i, q := range queue {
+		if q == t {
+			queue = append(queue[:i], queue[i+1:]...)
+			break
+		}
+	}
+	for _, id := range t.Players {
+		if byPlayer[id] == t {
+			delete(byPlayer, id)
+		}
+	}
+}
+
+// tick expires old tickets, announces widening and starts allocation for new matches.
+func tick() {
+	cfg := GetConfig()
+	now := time.Now()
+
+	var expired, widened []*Ticket
+	var matches []*Match
+
+	mu.Lock()
+	waiting := make([]*Ticket, 0, len(queue))
+	var held []*Ticket
+	for _, t := range queue {
+		if cfg.TimeoutSeconds > 0 && now.Sub(t.EnqueuedAt) >= cfg.timeout() {
+			expired = append(expired, t)
+			for _, id := range t.Players {
+				delete(byPlayer, id)
+			}
+			continue
+		}
+		if !t.widenNotified && t.Region != "" && t.widened(cfg, now) {
+			t.widenNotified = true
+			widened = append(widened, t)
+		}
+		if t.backingOff(now) {
+			held = append(held, t)
+			continue
+		}
+		waiting = append(waiting, t)
+	}
+
+	groups, rest := formMatches(waiting, cfg, now)
+	queue = append(rest, held...)
+	for _, g := range groups {
+		region, anyRegion := matchRegion(g, cfg, now)
+		m := &Match{ID: utils.GenerateRandomString(16), Region: region, AnyRegion: anyRegion, GameMode: g[0].GameMode,
+			Tickets: g, Status: "allocating", CreatedAt: now.UTC()}
+		for _, t := range g {
+			t.matchID = m.ID
+			m.Players = append(m.Players, t.Players...)
+		}
+		matches = append(matches, m)
+	}
+	mu.Unlock()
+
+	for _, t := range expired {
+		notifyTicket(t, MsgCancelled, map[string]interface{}{"ticket_id": t.ID, "reason": "timeout"})
+	}
+	for _, t := range widened {
+		notifyTicket(t, MsgStatus, map[string]interface{}{
+			"ticket_id":   t.ID,
+			"waited":      int(now.Sub(t.EnqueuedAt).Seconds()),
+			"any_region":  true,
+			"skill_range": t.skillWindow(cfg, now),
+		})
+	}
+	for _, m := range matches {
+		go allocateMatch(m, cfg)
+	}
+}
+
+func allocateMatch(m *Match, cfg Config) {
+	srv, spawned, err := allocate(m, cfg)
+
+	mu.Lock()
+	var tickets []*Ticket
+	var players []int64
+	if err != nil {
+		// Put the tickets back with their original wait, out of grouping for a while so a
+		// failing placement isn't retried every tick; they expire through the normal timeout
+		m.Status, m.Error = "failed", err.Error()
+		now := time.Now()
+		for _, t := range m.Tickets {
+			t.matchID = ""
+			if t.withdrawn {
+				continue
+			}
+			t.failures++
+			t.retryAt = now.Add(allocationBackoff(t.failures))
+			queue = append(queue, t)
+		}
+	} else {
+		m.Status, m.Server, m.Spawned = "found", &srv, spawned
+		for _, t := range m.Tickets {
+			removeTicketLocked(t)
+			if !t.withdrawn {
+				tickets = append(tickets, t)
+				players = append(players, t.Players...)
+			}
+		}
+	}
+	recent = append(recent, m)
+	if len(recent) > recentMatchesKept {
+		recent = recent[len(recent)-recentMatchesKept:]
+	}
+	mu.Unlock()
+
+	if err != nil {
+		log.Printf("Matchmaking: allocation for match %s (%d players, region %q) failed: %v", m.ID, len(m.Players), m.Region, err)
+		return
+	}
+	log.Printf("Matchmaking: match %s -> %s (%d players)", m.ID, srv.ID, len(m.Players))
+
+	if ws_player.GlobalPlayerWS == nil {
+		return
+	}
+	for _, t := range tickets {
+		for _, playerID := range t.Players {
+			payload := map[string]interface{}{
+				"match_id":    m.ID,
+				"ticket_id":   t.ID,
+				"server_id":   srv.ID,
+				"node_id":     srv.NodeID,
+				"instance_id": srv.InstanceID,
+				"address":     srv.Address,
+				"port":        srv.Port,
+				"region":      srv.Region,
+				"version":     srv.Version,
+				"game_mode":   m.GameMode,
+				"players":     players,
+				"starting":    spawned, // A fresh instance may take a few seconds to accept connections
+			}
+			// Each player gets their own join ticket for the game server to check
+			if jointicket.Enabled() {
+				token, claims, err := jointicket.Issue(playerID, srv.NodeID, srv.InstanceID)
+				if err != nil {
+					log.Printf("Matchmaking: no join ticket for player %d in match %s: %v", playerID, m.ID, err)
+				} else {
+					payload["join_ticket"] = token
+					payload["join_ticket_expires_at"] = claims.ExpiresAt
+				}
+			}
+			ws_player.GlobalPlayerWS.SendMessage(playerID, ws_player.NewMessage(MsgMatchFound, payload))
+		}
+	}
+}
+
+// allocate reuses a listed server with enough free seats, or spawns a new instance. A
+// match that may play anywhere tries its preferred region first, then any region.
+func allocate(m *Match, cfg Config) (discovery.Server, bool, error) {
+	need := len(m.Players)
+	regions := []string{m.Region}
+	if m.AnyRegion && m.Region != "" {
+		regions = append(regions, "")
+	}
+
+	for _, region := range regions {
+		candidates := discovery.List(discovery.Filter{Region: region, NotFull: true})
+		if s, ok := pickServer(candidates, need, m.GameMode, cfg.MaxPlayers, reservedSeats); ok {
+			reserveSeats(s.ID, need)
+			return s, false, nil
+		}
+	}
+
+	var res *placement.Result
+	var err error
+	for _, region := range regions {
+		if res, err = placement.Spawn(placement.Request{Region: region}); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return discovery.Server{}, false, err
+	}
+	n, ok := registry.GlobalRegistry.Get(res.NodeID)
+	if !ok {
+		return discovery.Server{}, false, fmt.Errorf("node %d vanished after spawn", res.NodeID)
+	}
+	s := discovery.ServerFromInstance(n, res.Instance)
+	reserveSeats(s.ID, need)
+	return s, true, nil
+}
+
+// pickServer chooses the fullest server that still seats need players, so matches fill
+// existing servers before spreading out. Servers that haven't reported a capacity are
+// assumed to hold defaultCapacity.
+func pickServer(servers []discovery.Server, need int, gameMode string, defaultCapacity int, reserved func(id string) int) (discovery.Server, bool) {
+	best, found, bestFree := discovery.Server{}, false, 0
+	for _, s := range servers {
+		if gameMode != "" && !strings.EqualFold(s.GameMode, gameMode) {
+			continue
+		}
+		capacity := s.MaxPlayers
+		if capacity <= 0 {
+			capacity = defaultCapacity
+		}
+		free := capacity - s.Players - reserved(s.ID)
+		if free < need {
+			continue
+		}
+		if !found || free < bestFree {
+			best, found, bestFree = s, true, free
+		}
+	}
+	return best, found
+}
+
+func reserveSeats(serverID string, count int) {
+	seatsMu.Lock()
+	defer seatsMu.Unlock()
+	seats[serverID] = append(seats[serverID], seatReservation{count: count, at: time.Now()})
+}
+
+func reservedSeats(serverID string) int {
+	now := time.Now()
+	seatsMu.Lock()
+	defer seatsMu.Unlock()
+	total := 0
+	live := seats[serverID][:0]
+	for _, r := range seats[serverID] {
+		if now.Sub(r.at) < seatReservationTTL {
+			live = append(live, r)
+			total += r.count
+		}
+	}
+	if len(live) == 0 {
+		delete(seats, serverID)
+	} else {
+		seats[serverID] = live
+	}
+	return total
+}
+
+func notifyTicket(t *Ticket, msgType string, payload interface{}) {
+	if ws_player.GlobalPlayerWS == nil {
+		return
+	}
+	msg := ws_player.NewMessage(msgType, payload)
+	for _, id := range t.Players {
+		ws_player.GlobalPlayerWS.SendMessage(id, msg)
+	}
+}
+
+// -- Player WS messages --
+
+func handleJoin(c *ws_player.PlayerConnection, payload json.RawMessage) {
+	var req struct {
+		Region   string `json:"region"`
+		GameMode string `json:"game_mode"`
+	}
+	if len(payload) > 0 && string(payload) != "null" {
+		if err := json.Unmarshal(payload, &req); err != nil {
+			ws_player.GlobalPlayerWS.SendError(c.PlayerID, "invalid matchmaking request")
+			return
+		}
+	}
+
+	t, err := Enqueue(c.PlayerID, req.Region, req.GameMode)
+	if err != nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, fmt.Sprintf("Matchmaking failed: %v", err))
+		return
+	}
+	cfg := GetConfig()
+	notifyTicket(t, MsgQueued, map[string]interface{}{
+		"ticket_id":       t.ID,
+		"region":          t.Region,
+		"game_mode":       t.GameMode,
+		"players":         t.Players,
+		"timeout_seconds": cfg.TimeoutSeconds,
+	})
+}
+
+func handleCancel(c *ws_player.PlayerConnection, _ json.RawMessage) {
+	t, err := Cancel(c.PlayerID)
+	if err != nil {
+		ws_player.GlobalPlayerWS.SendError(c.PlayerID, fmt.Sprintf("Cancel failed: %v", err))
+		return
+	}
+	notifyTicket(t, MsgCancelled, map[string]interface{}{"ticket_id": t.ID, "reason": "cancelled", "by": c.PlayerID})
+}
+
+// handleDisconnect drops the player's ticket; party members queued with them are told why.
+func handleDisconnect(playerID int64) {
+	t, err := Cancel(playerID)
+	if err != nil {
+		return
+	}
+	notifyTicket(t, MsgCancelled, map[string]interface{}{"ticket_id": t.ID, "reason": "disconnected", "by": playerID})
+}
+
+// handlePartyChange drops a queued party's ticket when its members change, since the
+// ticket was sized and rated for the old group.
+func handlePartyChange(ev ws_player.PartyEvent) {
+	if ev.Type == ws_player.PartyCreated {
+		return
+	}
+	for _, id := range append(ev.Party.MemberIDs(), ev.PlayerID) {
+		if t, err := Cancel(id); err == nil {
+			notifyTicket(t, MsgCancelled, map[string]interface{}{"ticket_id": t.ID, "reason": "party_changed", "by": ev.PlayerID})
+			return
+		}
+	}
+}
+
+// StartMatchmaking registers the player WS messages and starts the matching loop.
+func StartMatchmaking() {
+	if running {
+		return
+	}
+	if ws_player.GlobalPlayerWS != nil {
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgJoin, handleJoin)
+		ws_player.GlobalPlayerWS.RegisterHandler(MsgCancel, handleCancel)
+		ws_player.GlobalPlayerWS.OnDisconnect(handleDisconnect)
+		ws_player.GlobalPlayerWS.OnPartyChange(handlePartyChange)
+	}
+	done = make(chan struct{})
+	running = true
+	wg.Add(1)
+	go matchmakingLoop()
+}
+
+// StopMatchmaking stops the matching loop. Queued tickets are dropped with the process.
+func StopMatchmaking() {
+	if !running {
+		return
+	}
+	close(done)
+	wg.Wait()
+	running = false
+}
+
+func matchmakingLoop() {
+	defer wg.Done()
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			tick()
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package matchmaking
+
+import (
+	"testing"
+	"time"
+
+	"exile/server/discovery"
+)
+
+func ticket(id, region string, skill int64, waited time.Duration, now time.Time, players ...int64) *Ticket {
+	return &Ticket{ID: id, Region: region, Skill: skill, Players: players, EnqueuedAt: now.Add(-waited)}
+}
+
+func ids(group []*Ticket) []string {
+	var out []string
+	for _, t := range group {
+		out = append(out, t.ID)
+	}
+	return out
+}
+
+func TestFormMatchesGroupsByRegionAndSkill(t *testing.T) {
+	cfg := Config{MinPlayers: 2, MaxPlayers: 4, SkillRange: 100, SkillRangeGrowth: 100, WidenAfterSeconds: 30}
+	now := time.Now()
+
+	queue := []*Ticket{
+		ticket("eu-a", "eu", 1000, 5*time.Second, now, 1),
+		ticket("us-a", "us", 1000, 4*time.Second, now, 2),
+		ticket("eu-b", "eu", 1050, 3*time.Second, now, 3),
+		ticket("eu-pro", "eu", 3000, 2*time.Second, now, 4),
+	}
+	groups, rest := formMatches(queue, cfg, now)
+	if len(groups) != 1 || len(groups[0]) != 2 || groups[0][0].ID != "eu-a" || groups[0][1].ID != "eu-b" {
+		t.Fatalf("expected eu-a+eu-b, got %v", groups)
+	}
+	if len(rest) != 2 || rest[0].ID != "us-a" || rest[1].ID != "eu-pro" {
+		t.Fatalf("unexpected leftovers: %v", ids(rest))
+	}
+}
+
+func TestFormMatchesRegionWidening(t *testing.T) {
+	cfg := Config{MinPlayers: 2, MaxPlayers: 4, SkillRange: 100, WidenAfterSeconds: 30}
+	now := time.Now()
+
+	// Only one side has waited long enough: both must accept the other's region
+	queue := []*Ticket{
+		ticket("eu", "eu", 1000, 40*time.Second, now, 1),
+		ticket("us", "us", 1000, 5*time.Second, now, 2),
+	}
+	if groups, _ := formMatches(queue, cfg, now); len(groups) != 0 {
+		t.Fatalf("fresh ticket should not be pulled into another region, got %v", groups)
+	}
+
+	queue[1].EnqueuedAt = now.Add(-35 * time.Second)
+	groups, _ := formMatches(queue, cfg, now)
+	if len(groups) != 1 {
+		t.Fatalf("expected widened tickets to match, got %v", groups)
+	}
+	if region, anyRegion := matchRegion(groups[0], cfg, now); region != "eu" || !anyRegion {
+		t.Fatalf("expected the oldest ticket's region to be preferred but not required, got %q %v", region, anyRegion)
+	}
+}
+
+func TestMatchRegionPinnedByFreshTicket(t *testing.T) {
+	cfg := Config{MinPlayers: 2, MaxPlayers: 4, SkillRange: 100, WidenAfterSeconds: 30}
+	now := time.Now()
+
+	group := []*Ticket{
+		ticket("us", "us", 1000, 40*time.Second, now, 1),
+		ticket("eu", "eu", 1000, 5*time.Second, now, 2),
+	}
+	if region, anyRegion := matchRegion(group, cfg, now); region != "eu" || anyRegion {
+		t.Fatalf("a ticket that hasn't widened should pin its region, got %q %v", region, anyRegion)
+	}
+	if region, anyRegion := matchRegion([]*Ticket{ticket("a", "", 0, time.Second, now, 3)}, cfg, now); region != "" || !anyRegion {
+		t.Fatalf("tickets without a region should match anywhere, got %q %v", region, anyRegion)
+	}
+}
+
+func TestFormMatchesSkillWindowGrows(t *testing.T) {
+	cfg := Config{MinPlayers: 2, MaxPlayers: 2, SkillRange: 100, SkillRangeGrowth: 100, WidenAfterSeconds: 30}
+	now := time.Now()
+
+	a := ticket("a", "", 1000, 10*time.Second, now, 1)
+	b := ticket("b", "", 1250, 10*time.Second, now, 2)
+	if groups, _ := formMatches([]*Ticket{a, b}, cfg, now); len(groups) != 0 {
+		t.Fatal("250 apart should not match with a 100 window")
+	}
+	a.EnqueuedAt, b.EnqueuedAt = now.Add(-65*time.Second), now.Add(-65*time.Second)
+	if groups, _ := formMatches([]*Ticket{a, b}, cfg, now); len(groups) != 1 {
+		t.Fatal("window should have grown to 300 after two widen steps")
+	}
+}
+
+func TestFormMatchesKeepsPartiesTogether(t *testing.T) {
+	cfg := Config{MinPlayers: 4, MaxPlayers: 4, SkillRange: 1000}
+	now := time.Now()
+
+	queue := []*Ticket{
+		ticket("trio", "eu", 0, 10*time.Second, now, 1, 2, 3),
+		ticket("duo", "eu", 0, 9*time.Second, now, 4, 5),
+		ticket("solo", "eu", 0, 8*time.Second, now, 6),
+	}
+	groups, rest := formMatches(queue, cfg, now)
+	if len(groups) != 1 || len(groups[0]) != 2 || groups[0][1].ID != "solo" {
+		t.Fatalf("expected trio+solo (duo does not fit), got %v", groups)
+	}
+	if len(rest) != 1 || rest[0].ID != "duo" {
+		t.Fatalf("expected duo to keep waiting, got %v", ids(rest))
+	}
+}
+
+func TestFormMatchesGameMode(t *testing.T) {
+	cfg := Config{MinPlayers: 2, MaxPlayers: 4, SkillRange: 1000}
+	now := time.Now()
+
+	a := ticket("a", "", 0, time.Second, now, 1)
+	b := ticket("b", "", 0, time.Second, now, 2)
+	a.GameMode, b.GameMode = "tdm", "ctf"
+	if groups, _ := formMatches([]*Ticket{a, b}, cfg, now); len(groups) != 0 {
+		t.Fatal("different game modes must not be grouped")
+	}
+}
+
+func TestFormMatchesShadow(t *testing.T) {
+	cfg := Config{MinPlayers: 2, MaxPlayers: 4, SkillRange: 1000}
+	now := time.Now()
+
+	a := ticket("a", "", 0, 3*time.Second, now, 1)
+	b := ticket("b", "", 0, 2*time.Second, now, 2)
+	c := ticket("c", "", 0, time.Second, now, 3)
+	b.Shadow, c.Shadow = true, true
+	groups, rest := formMatches([]*Ticket{a, b, c}, cfg, now)
+	if len(groups) != 1 || len(groups[0]) != 2 || groups[0][0].ID != "b" || groups[0][1].ID != "c" {
+		t.Fatalf("expected shadow tickets b+c, got %v", groups)
+	}
+	if len(rest) != 1 || rest[0].ID != "a" {
+		t.Fatalf("unexpected leftovers: %v", ids(rest))
+	}
+}
+
+func TestPickServer(t *testing.T) {
+	servers := []discovery.Server{
+		{ID: "1/a", Players: 2, MaxPlayers: 10},
+		{ID: "1/b", Players: 7, MaxPlayers: 10},
+		{ID: "2/c", Players: 0, MaxPlayers: 0, GameMode: "ctf"},
+	}
+	none := func(string) int { return 0 }
+
+	s, ok := pickServer(servers, 3, "", 10, none)
+	if !ok || s.ID != "1/b" {
+		t.Fatalf("expected fullest server that fits, got %v %v", s.ID, ok)
+	}
+
+	held := func(id string) int {
+		if id == "1/b" {
+			return 2
+		}
+		return 0
+	}
+	if s, _ := pickServer(servers, 3, "", 10, held); s.ID != "1/a" {
+		t.Fatalf("reserved seats should be counted, got %v", s.ID)
+	}
+
+	if s, ok := pickServer(servers, 6, "ctf", 8, none); !ok || s.ID != "2/c" {
+		t.Fatalf("expected unreported capacity to use the default and mode filter to apply, got %v %v", s.ID, ok)
+	}
+	if _, ok := pickServer(servers, 9, "", 8, none); ok {
+		t.Fatal("no server has 9 free seats")
+	}
+}
+
+func TestEnqueueAndCancel(t *testing.T) {
+	SetSkillFunc(func(id int64) int64 { return id * 10 })
+	defer SetSkillFunc(xpSkill)
+	SetPartyResolver(func(id int64) ([]int64, error) {
+		if id == 100 {
+			return []int64{100, 101}, nil
+		}
+		return nil, nil
+	})
+	defer SetPartyResolver(nil)
+
+	tk, err := Enqueue(100, "eu", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tk.Players) != 2 || tk.Skill != 1005 {
+		t.Fatalf("unexpected party ticket: %+v", tk)
+	}
+	if _, err := Enqueue(101, "eu", ""); err == nil {
+		t.Fatal("party member should already be queued")
+	}
+	if !IsQueued(101) {
+		t.Fatal("expected member to be queued")
+	}
+
+	if _, err := Cancel(101); err != nil {
+		t.Fatal(err)
+	}
+	if IsQueued(100) || IsQueued(101) {
+		t.Fatal("cancel should drop the whole party ticket")
+	}
+	if _, err := Cancel(100); err == nil {
+		t.Fatal("expected error cancelling twice")
+	}
+}
+
+func TestAllocationBackoff(t *testing.T) {
+	if d := allocationBackoff(1); d != allocRetryBase {
+		t.Fatalf("expected %v after the first failure, got %v", allocRetryBase, d)
+	}
+	if d := allocationBackoff(2); d != 2*allocRetryBase {
+		t.Fatalf("expected backoff to double, got %v", d)
+	}
+	if d := allocationBackoff(20); d != allocRetryMax {
+		t.Fatalf("expected backoff to be capped at %v, got %v", allocRetryMax, d)
+	}
+}
+
+func TestFailedAllocationBacksOffAndDropsWithdrawn(t *testing.T) {
+	cfg := Config{MinPlayers: 2, MaxPlayers: 4, SkillRange: 1000}
+	now := time.Now()
+
+	mu.Lock()
+	queue, byPlayer = nil, map[int64]*Ticket{}
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		queue, byPlayer, recent = nil, map[int64]*Ticket{}, nil
+		mu.Unlock()
+	}()
+
+	// Two tickets are being allocated; one player leaves before allocation ends
+	a := ticket("a", "eu", 0, 10*time.Second, now, 1)
+	b := ticket("b", "eu", 0, 10*time.Second, now, 2)
+	m := &Match{ID: "m", Region: "eu", Tickets: []*Ticket{a, b}, Players: []int64{1, 2}, Status: "allocating"}
+	mu.Lock()
+	for _, tk := range m.Tickets {
+		tk.matchID = m.ID
+		byPlayer[tk.Players[0]] = tk
+	}
+	mu.Unlock()
+
+	if _, err := Cancel(2); err != nil {
+		t.Fatalf("cancel during allocation should succeed: %v", err)
+	}
+	if IsQueued(2) {
+		t.Fatal("withdrawn player should no longer be queued")
+	}
+
+	// No nodes are registered, so placement fails
+	allocateMatch(m, cfg)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if m.Status != "failed" {
+		t.Fatalf("expected allocation to fail, got %q", m.Status)
+	}
+	if len(queue) != 1 || queue[0] != a {
+		t.Fatalf("expected only the remaining ticket to be requeued, got %v", ids(queue))
+	}
+	if a.matchID != "" || a.failures != 1 || !a.backingOff(time.Now()) {
+		t.Fatalf("requeued ticket should back off: %+v", a)
+	}
+}
@@ -24,6 +24,11 @@ type statusResponseWriter struct {
 	body       []byte
 }
 
+// Unwrap lets http.ResponseController reach the underlying connection (e.g. to extend deadlines)
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (w *statusResponseWriter) WriteHeader(statusCode int) {
 	w.statusCode = statusCode
 	w.ResponseWriter.WriteHeader(statusCode)
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // ErrorResponse is a minimal JSON structure used for error payloads.
 type ErrorResponse struct {
@@ -36,6 +39,25 @@ type Node struct {
 	GameVersion string  `json:"game_version"`
 }
 
+// GameInstance mirrors the instance summary a node returns for "list_instances".
+type GameInstance struct {
+	ID          string    `json:"id"`
+	Port        int       `json:"port"`
+	PID         int       `json:"pid"`
+	Status      string    `json:"status"` // "Running", "Stopped", "Error"
+	Region      string    `json:"region"`
+	Version     string    `json:"version"`
+	StartTime   time.Time `json:"start_time"`
+	Path        string    `json:"path"`
+	PlayerCount int       `json:"player_count"`
+	MaxPlayers  int       `json:"max_players"`
+
+	// Reported by the game server through its node
+	Map          string    `json:"map,omitempty"`
+	GameMode     string    `json:"game_mode,omitempty"`
+	LastReportAt time.Time `json:"last_report_at"`
+}
+
 // GameServerVersion represents a specific uploaded version of the game server package.
 type GameServerVersion struct {
 	ID         int       `json:"id" db:"id"`
@@ -44,6 +66,108 @@ type GameServerVersion struct {
 	Comment    string    `json:"comment" db:"comment"`
 	UploadedAt time.Time `json:"uploaded_at" db:"-"` // Handled via unix timestamp in DB
 	IsActive   bool      `json:"is_active" db:"is_active"`
+	Pinned     bool      `json:"pinned" db:"pinned"`         // Never removed by retention
+	SHA256     string    `json:"sha256" db:"sha256"`         // Hex digest of the package, verified on upload
+	SizeBytes  int64     `json:"size_bytes" db:"size_bytes"` // Package size on disk
+
+	ValidationStatus string                 `json:"validation_status" db:"validation_status"` // "valid", "invalid" or empty if never inspected
+	ValidationErrors []string               `json:"validation_errors,omitempty" db:"-"`       // Why the package was rejected
+	BuildMetadata    map[string]interface{} `json:"build_metadata,omitempty" db:"-"`          // Extracted from version.txt / build_info.json
+	ValidatedAt      time.Time              `json:"validated_at,omitempty" db:"-"`
+}
+
+// ReleaseChannel pins a group of nodes (matched by region or tag) to a game server
+// version and rolls new versions out to them in percentage-based waves.
+type ReleaseChannel struct {
+	ID                  int       `json:"id" db:"id"`
+	Name                string    `json:"name" db:"name"`                                   // e.g. "stable", "beta", "canary"
+	Priority            int       `json:"priority" db:"priority"`                           // Higher wins when a node matches several channels
+	Regions             string    `json:"regions" db:"regions"`                             // CSV of subscribed regions
+	Tags                string    `json:"tags" db:"tags"`                                   // CSV of subscribed node tags
+	VersionID           int       `json:"version_id" db:"version_id"`                       // Version being rolled out
+	PreviousVersionID   int       `json:"previous_version_id" db:"previous_version_id"`     // Version served to nodes outside the rollout
+	RolloutPercent      int       `json:"rollout_percent" db:"rollout_percent"`             // 0-100
+	WaveStep            int       `json:"wave_step" db:"wave_step"`                         // Percent added per automatic wave (0 = manual)
+	WaveIntervalSeconds int       `json:"wave_interval_seconds" db:"wave_interval_seconds"` // Soak time between waves
+	AutoRollback        bool      `json:"auto_rollback" db:"auto_rollback"`
+	MaxFailureRate      float64   `json:"max_failure_rate" db:"max_failure_rate"` // 0-1, crash/health failure ratio that triggers rollback
+	Status              string    `json:"status" db:"status"`                     // idle, rolling_out, paused, completed, rolled_back
+	StatusReason        string    `json:"status_reason" db:"status_reason"`
+	LastWaveAt          time.Time `json:"last_wave_at" db:"-"` // Handled via unix timestamp in DB
+	UpdatedAt           time.Time `json:"updated_at" db:"-"`   // Handled via unix timestamp in DB
+}
+
+// FleetUpdateFilter selects which instances a fleet update job touches. Empty fields match everything.
+type FleetUpdateFilter struct {
+	NodeIDs         []int    `json:"node_ids,omitempty"`
+	Regions         []string `json:"regions,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	InstanceIDs     []string `json:"instance_ids,omitempty"`
+	IncludeDraining bool     `json:"include_draining,omitempty"`
+	SkipUpToDate    bool     `json:"skip_up_to_date,omitempty"` // Skip instances already on the node's target version
+}
+
+// FleetUpdateTarget is a single instance within a fleet update job.
+type FleetUpdateTarget struct {
+	NodeID      int       `json:"node_id"`
+	NodeName    string    `json:"node_name"`
+	InstanceID  string    `json:"instance_id"`
+	WasRunning  bool      `json:"was_running"`
+	FromVersion string    `json:"from_version"`
+	ToVersion   string    `json:"to_version,omitempty"`
+	Status      string    `json:"status"` // pending, updating, waiting, healthy, failed
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// FleetUpdateJob is a master-orchestrated rolling update across many instances.
+type FleetUpdateJob struct {
+	ID                   int                 `json:"id"`
+	Filter               FleetUpdateFilter   `json:"filter"`
+	BatchSize            int                 `json:"batch_size"`             // Instances per batch
+	MaxUnavailable       int                 `json:"max_unavailable"`        // Instances updated concurrently within a batch
+	HealthTimeoutSeconds int                 `json:"health_timeout_seconds"` // How long a batch may take to become healthy
+	Status               string              `json:"status"`                 // pending, running, paused, completed, cancelled
+	Error                string              `json:"error,omitempty"`
+	Targets              []FleetUpdateTarget `json:"targets"`
+	CreatedBy            string              `json:"created_by"`
+	CreatedAt            time.Time           `json:"created_at"`
+	UpdatedAt            time.Time           `json:"updated_at"`
+}
+
+// AutoscalePolicy keeps a group of nodes (matched by region and/or tags) within instance
+// bounds while maintaining a buffer of idle, ready servers.
+type AutoscalePolicy struct {
+	ID                       int       `json:"id"`
+	Name                     string    `json:"name"`
+	Region                   string    `json:"region"`                      // Empty matches any region
+	Tags                     string    `json:"tags"`                        // CSV; nodes must carry all of them
+	MinInstances             int       `json:"min_instances"`               // Never scale below
+	MaxInstances             int       `json:"max_instances"`               // Never scale above
+	TargetIdle               int       `json:"target_idle"`                 // Running instances with no players to keep warm
+	Strategy                 string    `json:"strategy"`                    // Placement strategy for scale-ups
+	ScaleUpCooldownSeconds   int       `json:"scale_up_cooldown_seconds"`   // Minimum time between scale-ups
+	ScaleDownCooldownSeconds int       `json:"scale_down_cooldown_seconds"` // Minimum time between scale-downs
+	Enabled                  bool      `json:"enabled"`
+	DryRun                   bool      `json:"dry_run"` // Record decisions without acting
+	LastScaleUpAt            time.Time `json:"last_scale_up_at,omitempty"`
+	LastScaleDownAt          time.Time `json:"last_scale_down_at,omitempty"`
+	UpdatedAt                time.Time `json:"updated_at"`
+}
+
+// AutoscaleDecision records one autoscaler evaluation outcome.
+type AutoscaleDecision struct {
+	ID         int       `json:"id"`
+	PolicyID   int       `json:"policy_id"`
+	Action     string    `json:"action"` // scale_up, drain, remove, hold
+	NodeID     int       `json:"node_id,omitempty"`
+	InstanceID string    `json:"instance_id,omitempty"`
+	Reason     string    `json:"reason"`
+	Total      int       `json:"total"` // Instances in scope at evaluation time
+	Idle       int       `json:"idle"`  // Idle ready (or provisioning) instances at evaluation time
+	DryRun     bool      `json:"dry_run"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 // ServerConfig represents a configuration setting for the server.
@@ -166,13 +290,46 @@ type RedEyeRule struct {
 	Port        string    `json:"port" db:"port"`                 // "80", "80-90", "*"
 	PathPattern string    `json:"path_pattern" db:"path_pattern"` // Regex or prefix for path matching
 	Protocol    string    `json:"protocol" db:"protocol"`         // "TCP", "UDP", "ICMP", "ANY"
-	Action      string    `json:"action" db:"action"`             // "ALLOW", "DENY", "RATE_LIMIT"
+	Method      string    `json:"method" db:"method"`             // "GET", "GET,POST", "" or "*" for any
+	Action      string    `json:"action" db:"action"`             // "ALLOW", "DENY", "RATE_LIMIT", "LOG_ONLY"
+	Priority    int       `json:"priority" db:"priority"`         // Lower is evaluated first; ties by ID
 	RateLimit   int       `json:"rate_limit" db:"rate_limit"`     // Requests per second (if Action=RATE_LIMIT)
 	Burst       int       `json:"burst" db:"burst"`               // Burst size
 	Enabled     bool      `json:"enabled" db:"enabled"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
+// RedEyeFeed is a threat-intelligence blocklist RedEye pulls on a schedule. Its entries
+// are kept apart from the manual rules and block the listed addresses outright.
+type RedEyeFeed struct {
+	ID             int        `json:"id" db:"id"`
+	Name           string     `json:"name" db:"name"`
+	Source         string     `json:"source" db:"source"`                   // http(s) URL or local file path
+	Format         string     `json:"format" db:"format"`                   // "plain", "firehol" or "spamhaus"
+	RefreshMinutes int        `json:"refresh_minutes" db:"refresh_minutes"` // How often to re-fetch
+	Enabled        bool       `json:"enabled" db:"enabled"`
+	EntryCount     int        `json:"entry_count" db:"entry_count"`
+	Hits           int64      `json:"hits" db:"hits"` // Requests blocked by this feed
+	LastRefreshed  *time.Time `json:"last_refreshed,omitempty" db:"last_refreshed"`
+	LastError      string     `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// RedEyeFeedEntry is an IP or CIDR listed by a feed.
+type RedEyeFeedEntry struct {
+	FeedID  int       `json:"feed_id" db:"feed_id"`
+	CIDR    string    `json:"cidr" db:"cidr"`
+	AddedAt time.Time `json:"added_at" db:"added_at"`
+}
+
+// IsHostWide reports whether the rule covers all traffic from its CIDR: any port, path
+// and method. Only host-wide DENY rules are also enforced by the OS firewall.
+func (r *RedEyeRule) IsHostWide() bool {
+	anyValue := func(v string) bool { return v == "" || v == "*" }
+	protocol := strings.ToUpper(r.Protocol)
+	return anyValue(r.Port) && anyValue(r.PathPattern) && anyValue(r.Method) && (protocol == "" || protocol == "ANY")
+}
+
 // RedEyeLog represents a log entry for a RedEye event.
 type RedEyeLog struct {
 	ID        int       `json:"id" db:"id"`
@@ -188,7 +345,8 @@ type RedEyeLog struct {
 // RedEyeAnticheatEvent represents a suspicious activity reported by game servers.
 type RedEyeAnticheatEvent struct {
 	ID           int       `json:"id" db:"id"`
-	PlayerID     string    `json:"player_id" db:"player_id"`
+	PlayerID     string    `json:"player_id" db:"player_id"`             // Player ID or Firebase UID as sent by the game server
+	AccountID    *int64    `json:"account_id,omitempty" db:"account_id"` // Linked player_system.players row, if found
 	GameServerID int       `json:"game_server_id" db:"game_server_id"`
 	EventType    string    `json:"event_type" db:"event_type"` // e.g. "SPEEDHACK"
 	Details      string    `json:"details" db:"details"`
@@ -206,4 +364,5 @@ type RedEyeIPReputation struct {
 	IsBanned        bool       `json:"is_banned" db:"is_banned"`
 	BanReason       string     `json:"ban_reason" db:"ban_reason"`
 	BanExpiresAt    *time.Time `json:"ban_expires_at" db:"ban_expires_at"`
+	BanCount        int        `json:"ban_count" db:"ban_count"` // Bans so far, including the current one
 }
@@ -17,9 +17,42 @@ type Player struct {
 	Online           bool      `json:"online"` // Computed field, not in DB
 
 	// Enriched fields (not in DB table directly)
-	Friends                []Player `json:"friends,omitempty"`
-	IncomingFriendRequests []Player `json:"incoming_friend_requests,omitempty"`
-	OutgoingFriendRequests []Player `json:"outgoing_friend_requests,omitempty"`
+	Friends                []Player        `json:"friends,omitempty"`
+	IncomingFriendRequests []Player        `json:"incoming_friend_requests,omitempty"`
+	OutgoingFriendRequests []Player        `json:"outgoing_friend_requests,omitempty"`
+	Bans                   []Ban           `json:"bans,omitempty"` // Ban history, newest first
+	Warnings               []PlayerWarning `json:"warnings,omitempty"`
+}
+
+// Ban scopes: what a ban keeps a player from doing.
+const (
+	BanScopeAll  = "all"  // Signing in, the player WebSocket and joining servers
+	BanScopeGame = "game" // Joining servers and matchmaking
+	BanScopeChat = "chat" // Sending chat messages
+)
+
+// Ban is one ban record. Bans are never deleted: expired and lifted bans stay as history.
+type Ban struct {
+	ID            int64      `json:"id" db:"id"`
+	PlayerID      int64      `json:"player_id" db:"player_id"`
+	Scope         string     `json:"scope" db:"scope"`
+	Reason        string     `json:"reason" db:"reason"`
+	EvidenceLinks []string   `json:"evidence_links" db:"-"`
+	Evidence      string     `json:"-" db:"evidence"` // JSON array of EvidenceLinks
+	IssuedBy      string     `json:"issued_by" db:"issued_by"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" db:"expires_at"` // Nil for permanent bans
+	LiftedAt      *time.Time `json:"lifted_at,omitempty" db:"lifted_at"`
+	LiftedBy      string     `json:"lifted_by,omitempty" db:"lifted_by"`
+	LiftReason    string     `json:"lift_reason,omitempty" db:"lift_reason"`
+
+	// Computed fields
+	Active bool `json:"active" db:"-"`
+}
+
+// IsActive reports whether the ban is in force at the given time.
+func (b *Ban) IsActive(now time.Time) bool {
+	return b.LiftedAt == nil && (b.ExpiresAt == nil || b.ExpiresAt.After(now))
 }
 
 // Friendship represents an established link between two players.
@@ -38,16 +71,279 @@ type FriendRequest struct {
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
-// Report represents a user report.
+// PlayerSession is one stay of a player on a game server, reported by the game server through its node.
+type PlayerSession struct {
+	ID              int64      `json:"id"`
+	PlayerID        int64      `json:"player_id"`
+	NodeID          int        `json:"node_id"`
+	InstanceID      string     `json:"instance_id"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"` // Nil while the player is still connected
+	EndReason       string     `json:"end_reason,omitempty"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	Active          bool       `json:"active"`
+}
+
+// Report statuses
+const (
+	ReportOpen          = "open"
+	ReportInvestigating = "investigating"
+	ReportActioned      = "actioned"
+	ReportDismissed     = "dismissed"
+)
+
+// Report actions a moderator can take
+const (
+	ReportActionWarn    = "warn"
+	ReportActionMute    = "mute"
+	ReportActionBan     = "ban"
+	ReportActionDismiss = "dismiss"
+)
+
+// Report represents a user report. Reports against a player that already has an open
+// case are linked to the case's first report (DuplicateOf) and handled with it.
 type Report struct {
-	ID                   int64     `json:"id" db:"id"`
-	ReporterID           int64     `json:"reporter_id" db:"reporter_id"`
-	ReportedUserID       int64     `json:"reported_user_id" db:"reported_user_id"`
-	Reason               string    `json:"reason" db:"reason"`
-	GameServerInstanceID string    `json:"game_server_instance_id" db:"game_server_instance_id"`
-	Timestamp            time.Time `json:"timestamp" db:"timestamp"`
+	ID                   int64      `json:"id" db:"id"`
+	ReporterID           int64      `json:"reporter_id" db:"reporter_id"`
+	ReportedUserID       int64      `json:"reported_user_id" db:"reported_user_id"`
+	Reason               string     `json:"reason" db:"reason"`
+	GameServerInstanceID string     `json:"game_server_instance_id" db:"game_server_instance_id"`
+	Timestamp            time.Time  `json:"timestamp" db:"timestamp"`
+	Status               string     `json:"status" db:"status"` // 'open', 'investigating', 'actioned', 'dismissed'
+	AssignedTo           string     `json:"assigned_to" db:"assigned_to"`
+	DuplicateOf          *int64     `json:"duplicate_of,omitempty" db:"duplicate_of"`
+	Action               string     `json:"action,omitempty" db:"action"` // 'warn', 'mute', 'ban' once actioned
+	ResolvedBy           string     `json:"resolved_by,omitempty" db:"resolved_by"`
+	ResolvedAt           *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+	ReporterNotifiedAt   *time.Time `json:"reporter_notified_at,omitempty" db:"reporter_notified_at"`
+	UpdatedAt            time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Enriched fields
+	ReporterName     string       `json:"reporter_name,omitempty" db:"reporter_name"`
+	ReportedUserName string       `json:"reported_user_name,omitempty" db:"reported_user_name"`
+	DuplicateCount   int          `json:"duplicate_count" db:"duplicate_count"`
+	Notes            []ReportNote `json:"notes,omitempty" db:"-"`
+	Duplicates       []Report     `json:"duplicates,omitempty" db:"-"`
+}
+
+// ReportNote is a moderator's note on a report.
+type ReportNote struct {
+	ID        int64     `json:"id" db:"id"`
+	ReportID  int64     `json:"report_id" db:"report_id"`
+	Author    string    `json:"author" db:"author"`
+	Body      string    `json:"body" db:"body"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReportFilter selects reports for the triage list.
+type ReportFilter struct {
+	Status         string // Empty = any
+	AssignedTo     string
+	ReportedUserID int64
+	GroupByCase    bool // List only the first report of each case
+	Limit          int
+}
+
+// ReportedPlayer is a row of the most-reported players view.
+type ReportedPlayer struct {
+	PlayerID       int64     `json:"player_id" db:"player_id"`
+	Name           string    `json:"name" db:"name"`
+	Reports        int       `json:"reports" db:"reports"`
+	Reporters      int       `json:"reporters" db:"reporters"` // Distinct reporters
+	OpenReports    int       `json:"open_reports" db:"open_reports"`
+	LastReportedAt time.Time `json:"last_reported_at" db:"last_reported_at"`
+}
+
+// PlayerWarning is a warning issued by a moderator. It is shown to the player once.
+type PlayerWarning struct {
+	ID          int64      `json:"id" db:"id"`
+	PlayerID    int64      `json:"player_id" db:"player_id"`
+	Reason      string     `json:"reason" db:"reason"`
+	IssuedBy    string     `json:"issued_by" db:"issued_by"`
+	ReportID    *int64     `json:"report_id,omitempty" db:"report_id"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+}
+
+// ChatMessage is a direct message (RecipientID set) or a channel message (ChannelID set).
+type ChatMessage struct {
+	ID          int64      `json:"id" db:"id"`
+	SenderID    int64      `json:"sender_id" db:"sender_id"`
+	RecipientID *int64     `json:"recipient_id,omitempty" db:"recipient_id"`
+	ChannelID   *int64     `json:"channel_id,omitempty" db:"channel_id"`
+	Body        string     `json:"body" db:"body"`
+	Filtered    bool       `json:"filtered" db:"filtered"` // Body was changed by the word filter
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty" db:"delivered_at"` // Direct messages only
+	ReadAt      *time.Time `json:"read_at,omitempty" db:"read_at"`           // Direct messages only
 
 	// Enriched fields
-	ReporterName     string `json:"reporter_name,omitempty" db:"reporter_name"`
-	ReportedUserName string `json:"reported_user_name,omitempty" db:"reported_user_name"`
+	SenderName string `json:"sender_name,omitempty" db:"sender_name"`
+}
+
+// ChatChannel is a group conversation, e.g. a party's chat.
+type ChatChannel struct {
+	ID        int64     `json:"id" db:"id"`
+	Kind      string    `json:"kind" db:"kind"` // 'group', 'party'
+	Name      string    `json:"name" db:"name"`
+	OwnerID   int64     `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+
+	// Enriched fields
+	Members []int64 `json:"members,omitempty"`
+	Unread  int     `json:"unread" db:"unread"`
+}
+
+// ChatSearch filters messages for moderators.
+type ChatSearch struct {
+	PlayerID int64     // Sender, or either side when OtherID is set
+	OtherID  int64     // Conversation partner (direct messages between PlayerID and OtherID)
+	Query    string    // Case-insensitive substring of the body
+	From     time.Time // Zero = unbounded
+	To       time.Time
+	Limit    int
+}
+
+// Privacy levels used by PrivacySettings. Not every level applies to every setting.
+const (
+	PrivacyEveryone         = "everyone"
+	PrivacyFriendsOfFriends = "friends_of_friends"
+	PrivacyFriends          = "friends"
+	PrivacyNobody           = "nobody"
+)
+
+// PrivacySettings controls who can reach a player. Players without a stored row use
+// the defaults: anyone can send friend requests, friends see presence and can message.
+type PrivacySettings struct {
+	PlayerID       int64     `json:"player_id" db:"player_id"`
+	FriendRequests string    `json:"friend_requests" db:"friend_requests"` // 'everyone', 'friends_of_friends', 'nobody'
+	Presence       string    `json:"presence" db:"presence"`               // 'friends', 'nobody' (appear offline)
+	Messages       string    `json:"messages" db:"messages"`               // 'everyone', 'friends', 'nobody'
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BlockedPlayer is an entry of a player's block list.
+type BlockedPlayer struct {
+	PlayerID  int64     `json:"player_id" db:"player_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Account actions a cheat policy can take.
+const (
+	CheatActionFlag        = "flag"         // Queue the player for review only
+	CheatActionShadowQueue = "shadow_queue" // Match the player only with other shadow-queued players
+	CheatActionBan         = "ban"          // Timed (or permanent) account ban
+)
+
+// Cheat review statuses
+const (
+	CheatReviewPending   = "pending"
+	CheatReviewConfirmed = "confirmed"
+	CheatReviewReverted  = "reverted" // The action was undone
+)
+
+// CheatScore is a player's anticheat score. Every linked event adds its severity; the
+// score halves every ANTICHEAT_SCORE_HALF_LIFE. Score is the value as of UpdatedAt.
+type CheatScore struct {
+	PlayerID     int64      `json:"player_id" db:"player_id"`
+	Score        float64    `json:"score" db:"score"`
+	Events       int        `json:"events" db:"events"`
+	ShadowQueued bool       `json:"shadow_queued" db:"shadow_queued"`
+	LastEventAt  *time.Time `json:"last_event_at,omitempty" db:"last_event_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Enriched fields
+	Name string `json:"name,omitempty" db:"name"`
+}
+
+// CheatPolicy maps a cheat score, optionally for one event type, to an account action.
+type CheatPolicy struct {
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	EventType   string    `json:"event_type" db:"event_type"` // Empty = any event
+	MinScore    float64   `json:"min_score" db:"min_score"`
+	Action      string    `json:"action" db:"action"`             // 'flag', 'shadow_queue', 'ban'
+	BanDuration int64     `json:"ban_duration" db:"ban_duration"` // Seconds; 0 = permanent
+	Enabled     bool      `json:"enabled" db:"enabled"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// CheatReview is an entry of the anticheat review queue, created whenever a policy fires.
+type CheatReview struct {
+	ID         int64      `json:"id" db:"id"`
+	PlayerID   int64      `json:"player_id" db:"player_id"`
+	PolicyID   *int64     `json:"policy_id,omitempty" db:"policy_id"`
+	EventID    *int64     `json:"event_id,omitempty" db:"event_id"` // Event that triggered the policy
+	Action     string     `json:"action" db:"action"`
+	Score      float64    `json:"score" db:"score"` // Score when the policy fired
+	BanID      *int64     `json:"ban_id,omitempty" db:"ban_id"`
+	Status     string     `json:"status" db:"status"`
+	ReviewedBy string     `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewNote string     `json:"review_note,omitempty" db:"review_note"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+
+	// Enriched fields
+	PlayerName string `json:"player_name,omitempty" db:"player_name"`
+	PolicyName string `json:"policy_name,omitempty" db:"policy_name"`
+}
+
+// Evidence kinds
+const (
+	EvidencePayload = "payload" // Structured JSON sent by a game server
+	EvidenceBlob    = "blob"    // Binary data such as a replay snippet or input trace
+)
+
+// Evidence is an item attached to an anticheat event. Its content is stored under its
+// SHA-256, and every item is an entry of an append-only hash chain: EntryHash covers
+// the item and the previous entry's hash, so editing, removing or reordering entries
+// breaks the chain.
+type Evidence struct {
+	ID          int64     `json:"id" db:"id"`
+	EventID     int64     `json:"event_id" db:"event_id"`
+	PlayerID    *int64    `json:"player_id,omitempty" db:"player_id"` // Account linked to the event
+	Kind        string    `json:"kind" db:"kind"`                     // 'payload', 'blob'
+	Name        string    `json:"name" db:"name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	SHA256      string    `json:"sha256" db:"sha256"`
+	Size        int64     `json:"size" db:"size"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	PrevHash    string    `json:"prev_hash" db:"prev_hash"`
+	EntryHash   string    `json:"entry_hash" db:"entry_hash"`
+}
+
+// Appeal statuses
+const (
+	AppealPending  = "pending"
+	AppealApproved = "approved" // The ban was lifted
+	AppealDenied   = "denied"
+)
+
+// Appeal is a player's request to lift a ban. Each ban can be appealed once.
+type Appeal struct {
+	ID           int64      `json:"id" db:"id"`
+	BanID        int64      `json:"ban_id" db:"ban_id"`
+	PlayerID     int64      `json:"player_id" db:"player_id"`
+	Message      string     `json:"message" db:"message"`
+	Status       string     `json:"status" db:"status"`
+	DecidedBy    string     `json:"decided_by,omitempty" db:"decided_by"`
+	DecisionNote string     `json:"decision_note,omitempty" db:"decision_note"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty" db:"decided_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+
+	// Enriched fields
+	PlayerName string             `json:"player_name,omitempty" db:"player_name"`
+	Ban        *Ban               `json:"ban,omitempty" db:"-"`
+	Audit      []AppealAuditEntry `json:"audit,omitempty" db:"-"`
+}
+
+// AppealAuditEntry records one step of an appeal: submission, decision, ban lifted.
+type AppealAuditEntry struct {
+	ID        int64     `json:"id" db:"id"`
+	AppealID  int64     `json:"appeal_id" db:"appeal_id"`
+	Actor     string    `json:"actor" db:"actor"` // "player:<id>" or the moderator
+	Action    string    `json:"action" db:"action"`
+	Note      string    `json:"note,omitempty" db:"note"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
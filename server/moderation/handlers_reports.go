@@ -0,0 +1,288 @@
+package moderation
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Reports Handlers --
+
+// CreateReportHandler files a player report. A reporter reporting the same player again
+// while the case is open gets their earlier report back (200, "duplicate": true).
+func CreateReportHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	var req models.Report
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+	report := models.Report{
+		ReporterID:           req.ReporterID,
+		ReportedUserID:       req.ReportedUserID,
+		Reason:               req.Reason,
+		GameServerInstanceID: req.GameServerInstanceID,
+	}
+
+	created, duplicate, err := SubmitReport(&report)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "failed to create report: "+err.Error())
+		return
+	}
+	if duplicate {
+		utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"report": created, "duplicate": true})
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, created)
+}
+
+// ListReportsHandler returns reports for triage, newest first, each with the number of
+// reports linked to it.
+//
+// Query:
+//   - status: open, investigating, actioned or dismissed
+//   - assigned_to: Moderator the case is assigned to
+//   - reported_id: Only reports against this player
+//   - group_by_case: "true" lists one report per case instead of every report
+//   - limit: Max reports (default and max 500)
+func ListReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := models.ReportFilter{
+		Status:      q.Get("status"),
+		AssignedTo:  q.Get("assigned_to"),
+		GroupByCase: q.Get("group_by_case") == "true",
+	}
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+	if v := q.Get("reported_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, "invalid reported_id")
+			return
+		}
+		filter.ReportedUserID = id
+	}
+
+	reports, err := database.ListReports(database.DBConn, filter)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, reports)
+}
+
+// GetReportHandler returns a report's case with its notes and linked reports.
+func GetReportHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := caseOf(int64(id))
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if report == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "report not found")
+		return
+	}
+	if report.Notes, err = database.ListReportNotes(database.DBConn, report.ID); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if report.Duplicates, err = database.ListReportDuplicates(database.DBConn, report.ID); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	report.DuplicateCount = len(report.Duplicates)
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// UpdateReportHandler changes the status and assignee of a report's case.
+//
+// Request (JSON, fields optional):
+//   - status: open or investigating
+//   - assigned_to: Moderator to assign; empty unassigns
+func UpdateReportHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req struct {
+		Status     *string `json:"status"`
+		AssignedTo *string `json:"assigned_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	report, err := UpdateCase(int64(id), req.Status, req.AssignedTo)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if report == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "report not found")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// AddReportNoteHandler adds a moderator note to a report's case.
+//
+// Request (JSON):
+//   - body: The note
+//   - author: Who wrote it (defaults to "dashboard")
+func AddReportNoteHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req struct {
+		Author string `json:"author"`
+		Body   string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+	if req.Author == "" {
+		req.Author = "dashboard"
+	}
+
+	note, err := AddNote(int64(id), req.Author, req.Body)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if note == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "report not found")
+		return
+	}
+	utils.WriteJSON(w, http.StatusCreated, note)
+}
+
+// ReportActionHandler acts on a report's case and closes it.
+//
+// Request (JSON):
+//   - action: warn, mute, ban or dismiss
+//   - reason: Shown to the reported player (defaults to the report's reason)
+//   - duration: Go duration for mute (default 24h) and ban (empty = permanent)
+//   - scope: Ban scope, all (default) or game
+//   - moderator: Who took the action (defaults to "dashboard")
+func ReportActionHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req struct {
+		Action    string `json:"action"`
+		Reason    string `json:"reason"`
+		Duration  string `json:"duration"`
+		Scope     string `json:"scope"`
+		Moderator string `json:"moderator"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+	if req.Moderator == "" {
+		req.Moderator = "dashboard"
+	}
+	if req.Action == models.ReportActionBan && req.Scope == models.BanScopeChat {
+		utils.WriteError(w, r, http.StatusBadRequest, "use the mute action for chat bans")
+		return
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		if duration, err = time.ParseDuration(req.Duration); err != nil || duration <= 0 {
+			utils.WriteError(w, r, http.StatusBadRequest, "invalid duration")
+			return
+		}
+	}
+
+	report, err := TakeAction(int64(id), ActionRequest{
+		Action:    req.Action,
+		Reason:    req.Reason,
+		Duration:  duration,
+		Scope:     req.Scope,
+		Moderator: req.Moderator,
+	})
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if report == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "report not found")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, report)
+}
+
+// MostReportedHandler ranks players by how many distinct players reported them.
+//
+// Query:
+//   - days: Look-back window (default 30)
+//   - limit: Max players (default 20, max 100)
+func MostReportedHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+	if days <= 0 {
+		days = 30
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	players, err := database.GetMostReportedPlayers(database.DBConn, since, limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, players)
+}
@@ -0,0 +1,311 @@
+package moderation
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"exile/server/bans"
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/ws_player"
+)
+
+// =================================================================================
+// MODERATION: report triage, moderator actions and player notifications
+// =================================================================================
+
+const (
+	// Player WS messages
+	MsgWarning       = "MODERATION_WARNING" // A moderator warned the player
+	MsgReportOutcome = "REPORT_OUTCOME"     // A report the player filed was handled
+
+	// DefaultMuteDuration applies to mutes issued without a duration
+	DefaultMuteDuration = 24 * time.Hour
+
+	maxReasonLen = 500
+	maxNoteLen   = 4000
+)
+
+// ActionRequest is a moderator's decision on a report.
+type ActionRequest struct {
+	Action    string        // warn, mute, ban or dismiss
+	Reason    string        // Shown to the reported player; defaults to the report's reason
+	Duration  time.Duration // Mute and ban length; 0 = default mute / permanent ban
+	Scope     string        // Ban scope, default all
+	Moderator string
+}
+
+// InitModeration delivers warnings and report outcomes that came in while players were offline.
+func InitModeration() {
+	if ws_player.GlobalPlayerWS != nil {
+		ws_player.GlobalPlayerWS.OnConnect(deliverPending)
+	}
+}
+
+// SubmitReport files a report. Reports against a player with an open case join that
+// case; a reporter who already reported the player in the open case gets their earlier
+// report back with duplicate set.
+func SubmitReport(r *models.Report) (report *models.Report, duplicate bool, err error) {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.ReporterID == 0 || r.ReportedUserID == 0 || r.Reason == "" {
+		return nil, false, fmt.Errorf("missing required fields")
+	}
+	if r.ReporterID == r.ReportedUserID {
+		return nil, false, fmt.Errorf("cannot report yourself")
+	}
+	if len(r.Reason) > maxReasonLen {
+		return nil, false, fmt.Errorf("reason is too long")
+	}
+
+	primary, err := database.FindOpenCase(database.DBConn, r.ReportedUserID)
+	if err != nil {
+		return nil, false, err
+	}
+	if primary != nil {
+		existing, err := database.FindReportInCase(database.DBConn, primary.ID, r.ReporterID)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing != nil {
+			return existing, true, nil
+		}
+		r.DuplicateOf = &primary.ID
+	}
+
+	r.Status = models.ReportOpen
+	if primary != nil {
+		r.Status = primary.Status
+	}
+	id, err := database.CreateReport(database.DBConn, r)
+	if err != nil {
+		return nil, false, err
+	}
+	r.ID = id
+	return r, false, nil
+}
+
+// caseOf returns the first report of the case a report belongs to.
+func caseOf(reportID int64) (*models.Report, error) {
+	r, err := database.GetReportByID(database.DBConn, reportID)
+	if err != nil || r == nil {
+		return r, err
+	}
+	if r.DuplicateOf != nil {
+		return database.GetReportByID(database.DBConn, *r.DuplicateOf)
+	}
+	return r, nil
+}
+
+// UpdateCase changes the status (open or investigating) and assignee of a case.
+func UpdateCase(reportID int64, status, assignedTo *string) (*models.Report, error) {
+	primary, err := caseOf(reportID)
+	if err != nil || primary == nil {
+		return nil, err
+	}
+	newStatus, newAssignee := primary.Status, primary.AssignedTo
+	if status != nil {
+		if *status != models.ReportOpen && *status != models.ReportInvestigating {
+			return nil, fmt.Errorf("status must be open or investigating; use an action to close a report")
+		}
+		newStatus = *status
+	}
+	if assignedTo != nil {
+		newAssignee = strings.TrimSpace(*assignedTo)
+	}
+	if err := database.UpdateReportCase(database.DBConn, primary.ID, newStatus, newAssignee); err != nil {
+		return nil, err
+	}
+	return database.GetReportByID(database.DBConn, primary.ID)
+}
+
+// AddNote attaches a moderator note to a case.
+func AddNote(reportID int64, author, body string) (*models.ReportNote, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, fmt.Errorf("note is empty")
+	}
+	if len(body) > maxNoteLen {
+		return nil, fmt.Errorf("note is too long")
+	}
+	primary, err := caseOf(reportID)
+	if err != nil || primary == nil {
+		return nil, err
+	}
+	n := &models.ReportNote{ReportID: primary.ID, Author: author, Body: body}
+	id, err := database.AddReportNote(database.DBConn, n)
+	if err != nil {
+		return nil, err
+	}
+	n.ID = id
+	return n, nil
+}
+
+// TakeAction applies a moderator's decision to the reported player, closes the case with
+// all its linked reports and tells the reporters. The case is closed first, so two
+// moderators acting at once cannot both punish the player; if the action then fails
+// the case is reopened.
+func TakeAction(reportID int64, req ActionRequest) (*models.Report, error) {
+	primary, err := caseOf(reportID)
+	if err != nil || primary == nil {
+		return nil, err
+	}
+	if primary.Status == models.ReportActioned || primary.Status == models.ReportDismissed {
+		return nil, fmt.Errorf("report is already %s", primary.Status)
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = primary.Reason
+	}
+	evidence := []string{fmt.Sprintf("/api/reports/%d", primary.ID)}
+
+	status, action := models.ReportActioned, req.Action
+	switch req.Action {
+	case models.ReportActionWarn, models.ReportActionMute, models.ReportActionBan:
+	case models.ReportActionDismiss:
+		status, action = models.ReportDismissed, ""
+	default:
+		return nil, fmt.Errorf("action must be warn, mute, ban or dismiss")
+	}
+
+	closed, err := database.ResolveReportCase(database.DBConn, primary.ID, status, action, req.Moderator)
+	if err != nil {
+		return nil, err
+	}
+	if !closed {
+		return nil, fmt.Errorf("report was already handled")
+	}
+
+	switch req.Action {
+	case models.ReportActionWarn:
+		err = Warn(primary.ReportedUserID, reason, req.Moderator, &primary.ID)
+	case models.ReportActionMute:
+		duration := req.Duration
+		if duration == 0 {
+			duration = DefaultMuteDuration
+		}
+		_, err = bans.Issue(bans.Request{
+			PlayerID:      primary.ReportedUserID,
+			Scope:         models.BanScopeChat,
+			Reason:        reason,
+			EvidenceLinks: evidence,
+			Duration:      duration,
+			IssuedBy:      req.Moderator,
+		})
+	case models.ReportActionBan:
+		_, err = bans.Issue(bans.Request{
+			PlayerID:      primary.ReportedUserID,
+			Scope:         req.Scope,
+			Reason:        reason,
+			EvidenceLinks: evidence,
+			Duration:      req.Duration,
+			IssuedBy:      req.Moderator,
+		})
+	}
+	if err != nil {
+		if reopenErr := database.ReopenReportCase(database.DBConn, primary.ID, primary.Status); reopenErr != nil {
+			log.Printf("Moderation: failed to reopen report %d after a failed %s: %v", primary.ID, req.Action, reopenErr)
+		}
+		return nil, err
+	}
+	log.Printf("Moderation: report %d against player %d closed as %s by %s", primary.ID, primary.ReportedUserID, status, req.Moderator)
+
+	notifyReporters(primary.ID)
+	return database.GetReportByID(database.DBConn, primary.ID)
+}
+
+// Warn records a warning and shows it to the player now or when they next connect.
+func Warn(playerID int64, reason, by string, reportID *int64) error {
+	w := &models.PlayerWarning{PlayerID: playerID, Reason: reason, IssuedBy: by, ReportID: reportID}
+	online := ws_player.GlobalPlayerWS != nil && ws_player.GlobalPlayerWS.IsPlayerOnline(playerID)
+	if online {
+		now := time.Now().UTC()
+		w.DeliveredAt = &now
+	}
+	id, err := database.CreateWarning(database.DBConn, w)
+	if err != nil {
+		return err
+	}
+	w.ID = id
+	if online {
+		ws_player.GlobalPlayerWS.SendMessage(playerID, ws_player.NewMessage(MsgWarning, warningPayload(w)))
+	}
+	return nil
+}
+
+func warningPayload(w *models.PlayerWarning) map[string]interface{} {
+	return map[string]interface{}{"id": w.ID, "reason": w.Reason, "created_at": w.CreatedAt}
+}
+
+// outcomePayload tells a reporter their report was handled, without saying how.
+func outcomePayload(r *models.Report) map[string]interface{} {
+	return map[string]interface{}{
+		"report_id":          r.ID,
+		"reported_user_id":   r.ReportedUserID,
+		"reported_user_name": r.ReportedUserName,
+		"outcome":            r.Status,
+	}
+}
+
+// notifyReporters tells the online reporters of a case about its outcome. Offline
+// reporters are told when they next connect.
+func notifyReporters(primaryID int64) {
+	if ws_player.GlobalPlayerWS == nil {
+		return
+	}
+	reports, err := database.ListReportDuplicates(database.DBConn, primaryID)
+	if err != nil {
+		log.Printf("Moderation: failed to load reports of case %d: %v", primaryID, err)
+		return
+	}
+	if primary, err := database.GetReportByID(database.DBConn, primaryID); err == nil && primary != nil {
+		reports = append([]models.Report{*primary}, reports...)
+	}
+
+	var notified []int64
+	for i := range reports {
+		r := &reports[i]
+		if !ws_player.GlobalPlayerWS.IsPlayerOnline(r.ReporterID) {
+			continue
+		}
+		ws_player.GlobalPlayerWS.SendMessage(r.ReporterID, ws_player.NewMessage(MsgReportOutcome, outcomePayload(r)))
+		notified = append(notified, r.ID)
+	}
+	if err := database.MarkReportsNotified(database.DBConn, notified); err != nil {
+		log.Printf("Moderation: failed to mark reports notified: %v", err)
+	}
+}
+
+// deliverPending sends a connecting player their unseen warnings and report outcomes.
+func deliverPending(playerID int64) {
+	if database.DBConn == nil {
+		return
+	}
+
+	warnings, err := database.GetUndeliveredWarnings(database.DBConn, playerID)
+	if err != nil {
+		log.Printf("Moderation: failed to load warnings of %d: %v", playerID, err)
+	} else if len(warnings) > 0 {
+		for i := range warnings {
+			ws_player.GlobalPlayerWS.SendMessage(playerID, ws_player.NewMessage(MsgWarning, warningPayload(&warnings[i])))
+		}
+		if err := database.MarkWarningsDelivered(database.DBConn, playerID, warnings[len(warnings)-1].ID); err != nil {
+			log.Printf("Moderation: failed to mark warnings delivered: %v", err)
+		}
+	}
+
+	outcomes, err := database.GetUnnotifiedOutcomes(database.DBConn, playerID)
+	if err != nil {
+		log.Printf("Moderation: failed to load report outcomes of %d: %v", playerID, err)
+		return
+	}
+	ids := make([]int64, 0, len(outcomes))
+	for i := range outcomes {
+		ws_player.GlobalPlayerWS.SendMessage(playerID, ws_player.NewMessage(MsgReportOutcome, outcomePayload(&outcomes[i])))
+		ids = append(ids, outcomes[i].ID)
+	}
+	if err := database.MarkReportsNotified(database.DBConn, ids); err != nil {
+		log.Printf("Moderation: failed to mark reports notified: %v", err)
+	}
+}
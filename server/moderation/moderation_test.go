@@ -0,0 +1,39 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+
+	"exile/server/models"
+)
+
+func TestSubmitReportValidation(t *testing.T) {
+	cases := []struct {
+		name   string
+		report models.Report
+		want   string
+	}{
+		{"no reporter", models.Report{ReportedUserID: 2, Reason: "cheating"}, "missing required fields"},
+		{"blank reason", models.Report{ReporterID: 1, ReportedUserID: 2, Reason: "   "}, "missing required fields"},
+		{"self", models.Report{ReporterID: 1, ReportedUserID: 1, Reason: "cheating"}, "cannot report yourself"},
+		{"long reason", models.Report{ReporterID: 1, ReportedUserID: 2, Reason: strings.Repeat("x", maxReasonLen+1)}, "reason is too long"},
+	}
+	for _, c := range cases {
+		r := c.report
+		if _, _, err := SubmitReport(&r); err == nil || err.Error() != c.want {
+			t.Errorf("%s: got %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestOutcomePayloadHidesAction(t *testing.T) {
+	p := outcomePayload(&models.Report{ID: 7, ReportedUserID: 2, Status: models.ReportActioned, Action: models.ReportActionBan})
+	if p["outcome"] != models.ReportActioned {
+		t.Errorf("outcome = %v, want %s", p["outcome"], models.ReportActioned)
+	}
+	for _, v := range p {
+		if v == models.ReportActionBan {
+			t.Error("payload must not reveal the action taken")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package placement
+
+import (
+	"errors"
+	"net/http"
+
+	"exile/server/registry"
+	"exile/server/utils"
+)
+
+// -- Placement Handlers --
+
+// SpawnInstanceHandler spawns an instance on whichever node the placement strategy picks.
+func SpawnInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if r.ContentLength > 0 {
+		if err := utils.DecodeJSON(r, &req); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	res, err := Spawn(req)
+	if err != nil {
+		var pe *PlacementError
+		if errors.As(err, &pe) {
+			utils.WriteJSON(w, http.StatusServiceUnavailable, pe)
+			return
+		}
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, res)
+}
+
+// PreviewPlacementHandler shows how each node would be judged, without spawning anything.
+func PreviewPlacementHandler(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if r.ContentLength > 0 {
+		if err := utils.DecodeJSON(r, &req); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	candidates, err := Evaluate(registry.GlobalRegistry.List(), req, pendingSpawns)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"strategies": Strategies(),
+		"candidates": candidates,
+	})
+}
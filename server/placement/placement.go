@@ -0,0 +1,385 @@
+package placement
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/registry"
+	"exile/server/utils"
+	"exile/server/ws"
+)
+
+// =================================================================================
+// PLACEMENT: choose a node for a new game instance
+// =================================================================================
+
+const (
+	StrategySpread      = "spread"       // Fewest instances first, so load is distributed across nodes
+	StrategyBinpack     = "binpack"      // Fullest node that still fits, keeping other nodes empty
+	StrategyLeastLoaded = "least_loaded" // Lowest combined CPU/memory usage from heartbeats
+
+	defaultMinFreeCPUPercent = 10.0
+	defaultMinFreeMemoryMB   = 512
+
+	// Spawns are counted against a node until its heartbeat has had time to report them
+	reservationTTL = 30 * time.Second
+
+	spawnTimeout = 30 * time.Second
+	maxAttempts  = 3
+)
+
+// Request describes where an instance may run.
+type Request struct {
+	Region            string   `json:"region,omitempty"`
+	Tags              []string `json:"tags,omitempty"`     // Node must carry all of them
+	Strategy          string   `json:"strategy,omitempty"` // spread (default), binpack, least_loaded
+	MinFreeCPUPercent *float64 `json:"min_free_cpu_percent,omitempty"`
+	MinFreeMemoryMB   *uint64  `json:"min_free_memory_mb,omitempty"`
+	ExcludeNodeIDs    []int    `json:"exclude_node_ids,omitempty"`
+}
+
+// Candidate is the placement verdict for one node.
+type Candidate struct {
+	NodeID   int      `json:"node_id"`
+	NodeName string   `json:"node_name"`
+	Region   string   `json:"region"`
+	Eligible bool     `json:"eligible"`
+	Reasons  []string `json:"reasons,omitempty"` // Why the node was rejected
+	Score    float64  `json:"score"`             // Lower is better within the chosen strategy
+	Load     Load     `json:"load"`
+}
+
+// Load is the capacity snapshot a decision was based on.
+type Load struct {
+	Instances    int     `json:"instances"` // Including spawns not yet reported by heartbeat
+	MaxInstances int     `json:"max_instances"`
+	CPUPercent   float64 `json:"cpu_percent"`
+	MemPercent   float64 `json:"mem_percent"`
+	FreeMemoryMB uint64  `json:"free_memory_mb"`
+}
+
+// Strategy ranks eligible nodes; lower scores are preferred.
+type Strategy func(l Load) float64
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = map[string]Strategy{
+		StrategySpread: func(l Load) float64 {
+			return float64(l.Instances) + utilization(l)
+		},
+		StrategyBinpack: func(l Load) float64 {
+			return -utilization(l)
+		},
+		StrategyLeastLoaded: func(l Load) float64 {
+			return (l.CPUPercent + l.MemPercent) / 2
+		},
+	}
+
+	// Serializes choosing a node with reserving a slot on it, so concurrent spawns
+	// cannot all take the last free slot
+	placementMu sync.Mutex
+
+	reservationsMu sync.Mutex
+	reservations   = map[int][]reservation{}
+	reservationSeq uint64
+)
+
+type reservation struct {
+	id uint64
+	at time.Time
+}
+
+// RegisterStrategy adds (or replaces) a named placement strategy.
+func RegisterStrategy(name string, s Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	strategies[strings.ToLower(name)] = s
+}
+
+// Strategies lists the registered strategy names.
+func Strategies() []string {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	out := make([]string, 0, len(strategies))
+	for name := range strategies {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func lookupStrategy(name string) (Strategy, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = StrategySpread
+	}
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	s, ok := strategies[name]
+	if !ok {
+		return nil, name, fmt.Errorf("unknown strategy %q", name)
+	}
+	return s, name, nil
+}
+
+func utilization(l Load) float64 {
+	if l.MaxInstances <= 0 {
+		return 0
+	}
+	return float64(l.Instances) / float64(l.MaxInstances)
+}
+
+// reserve counts a spawn against a node until heartbeats catch up. The returned ID
+// identifies the reservation for release.
+func reserve(nodeID int) uint64 {
+	reservationsMu.Lock()
+	defer reservationsMu.Unlock()
+	reservationSeq++
+	reservations[nodeID] = append(reservations[nodeID], reservation{id: reservationSeq, at: time.Now()})
+	return reservationSeq
+}
+
+// release gives back a reservation whose spawn failed.
+func release(nodeID int, id uint64) {
+	reservationsMu.Lock()
+	defer reservationsMu.Unlock()
+	for i, r := range reservations[nodeID] {
+		if r.id == id {
+			reservations[nodeID] = append(reservations[nodeID][:i], reservations[nodeID][i+1:]...)
+			return
+		}
+	}
+}
+
+func pendingSpawns(nodeID int) int {
+	now := time.Now()
+	reservationsMu.Lock()
+	defer reservationsMu.Unlock()
+	live := reservations[nodeID][:0]
+	for _, r := range reservations[nodeID] {
+		if now.Sub(r.at) < reservationTTL {
+			live = append(live, r)
+		}
+	}
+	if len(live) == 0 {
+		delete(reservations, nodeID)
+		return 0
+	}
+	reservations[nodeID] = live
+	return len(live)
+}
+
+func loadOf(n *models.Node, pending int) Load {
+	l := Load{
+		Instances:    n.CurrentInstances + pending,
+		MaxInstances: n.MaxInstances,
+		CPUPercent:   n.CpuUsage,
+	}
+	if n.MemTotal > 0 {
+		l.MemPercent = float64(n.MemUsed) / float64(n.MemTotal) * 100
+		if n.MemTotal > n.MemUsed {
+			l.FreeMemoryMB = (n.MemTotal - n.MemUsed) / (1024 * 1024)
+		}
+	}
+	return l
+}
+
+// Evaluate checks every node against the request and ranks the eligible ones.
+// Eligible candidates come first, best first; the rest keep their rejection reasons.
+func Evaluate(nodes []models.Node, req Request, pending func(nodeID int) int) ([]Candidate, error) {
+	strategy, _, err := lookupStrategy(req.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	minCPU := defaultMinFreeCPUPercent
+	if req.MinFreeCPUPercent != nil {
+		minCPU = *req.MinFreeCPUPercent
+	}
+	minMem := uint64(defaultMinFreeMemoryMB)
+	if req.MinFreeMemoryMB != nil {
+		minMem = *req.MinFreeMemoryMB
+	}
+	excluded := map[int]bool{}
+	for _, id := range req.ExcludeNodeIDs {
+		excluded[id] = true
+	}
+
+	out := make([]Candidate, 0, len(nodes))
+	for i := range nodes {
+		n := &nodes[i]
+		p := 0
+		if pending != nil {
+			p = pending(n.ID)
+		}
+		c := Candidate{NodeID: n.ID, NodeName: n.Name, Region: n.Region, Load: loadOf(n, p)}
+
+		if excluded[n.ID] {
+			c.Reasons = append(c.Reasons, "excluded by request")
+		}
+		if n.Status != "Online" {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("node is %s", strings.ToLower(n.Status)))
+		}
+		if n.IsDraining {
+			c.Reasons = append(c.Reasons, "node is draining")
+		}
+		if req.Region != "" && !strings.EqualFold(n.Region, req.Region) {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("region %q does not match %q", n.Region, req.Region))
+		}
+		if missing := missingTags(utils.ParseTags(n.Tags), req.Tags); len(missing) > 0 {
+			c.Reasons = append(c.Reasons, "missing tags: "+strings.Join(missing, ", "))
+		}
+		if n.MaxInstances <= 0 || c.Load.Instances >= n.MaxInstances {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("at capacity (%d/%d instances)", c.Load.Instances, n.MaxInstances))
+		}
+		if free := 100 - n.CpuUsage; free < minCPU {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("cpu headroom %.1f%% below %.1f%%", free, minCPU))
+		}
+		if n.MemTotal > 0 && c.Load.FreeMemoryMB < minMem {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("free memory %dMB below %dMB", c.Load.FreeMemoryMB, minMem))
+		}
+
+		c.Eligible = len(c.Reasons) == 0
+		if c.Eligible {
+			c.Score = strategy(c.Load)
+		}
+		out = append(out, c)
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Eligible != out[j].Eligible {
+			return out[i].Eligible
+		}
+		if out[i].Eligible && out[i].Score != out[j].Score {
+			return out[i].Score < out[j].Score
+		}
+		return out[i].NodeID < out[j].NodeID
+	})
+	return out, nil
+}
+
+func missingTags(have, want []string) []string {
+	set := map[string]bool{}
+	for _, t := range have {
+		set[t] = true
+	}
+	var missing []string
+	for _, t := range want {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !set[t] {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+// Result is the outcome of a placed spawn.
+type Result struct {
+	NodeID     int                 `json:"node_id"`
+	Strategy   string              `json:"strategy"`
+	Instance   models.GameInstance `json:"instance"`
+	Candidates []Candidate         `json:"candidates"`
+}
+
+// PlacementError carries the per-node verdicts when no node could take the instance.
+type PlacementError struct {
+	Message    string      `json:"error"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+func (e *PlacementError) Error() string { return e.Message }
+
+// Spawn places and starts a new instance, falling back to the next best node if a spawn fails.
+func Spawn(req Request) (*Result, error) {
+	_, strategyName, err := lookupStrategy(req.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	failed := map[int]string{}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		candidates, c, reserved, err := claim(req, failed)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return nil, &PlacementError{Message: "no eligible node for placement", Candidates: candidates}
+		}
+
+		inst, err := ws.GlobalWSManager.SpawnInstance(c.NodeID, spawnTimeout)
+		if err != nil {
+			release(c.NodeID, reserved)
+			failed[c.NodeID] = "spawn failed: " + err.Error()
+			continue
+		}
+		recordSpawn(c.NodeID, inst.ID)
+		return &Result{NodeID: c.NodeID, Strategy: strategyName, Instance: *inst, Candidates: candidates}, nil
+	}
+
+	candidates, err := evaluateExcluding(req, failed)
+	if err != nil {
+		return nil, err
+	}
+	return nil, &PlacementError{Message: "no eligible node for placement", Candidates: candidates}
+}
+
+// claim picks the best connected node and reserves a slot on it before anyone else can
+// be placed. Nodes whose spawn already failed are marked with the failure. It returns
+// a nil candidate when no node is left.
+func claim(req Request, failed map[int]string) ([]Candidate, *Candidate, uint64, error) {
+	placementMu.Lock()
+	defer placementMu.Unlock()
+
+	candidates, err := evaluateExcluding(req, failed)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	for i := range candidates {
+		c := &candidates[i]
+		if !c.Eligible {
+			continue
+		}
+		if !ws.GlobalWSManager.IsClientConnected(c.NodeID) {
+			c.Eligible = false
+			c.Reasons = append(c.Reasons, "node websocket not connected")
+			continue
+		}
+		return candidates, c, reserve(c.NodeID), nil
+	}
+	return candidates, nil, 0, nil
+}
+
+// evaluateExcluding evaluates the registered nodes, rejecting those in failed with
+// their failure.
+func evaluateExcluding(req Request, failed map[int]string) ([]Candidate, error) {
+	candidates, err := Evaluate(registry.GlobalRegistry.List(), req, pendingSpawns)
+	if err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		if reason, ok := failed[candidates[i].NodeID]; ok {
+			candidates[i].Eligible = false
+			candidates[i].Reasons = append(candidates[i].Reasons, reason)
+		}
+	}
+	return candidates, nil
+}
+
+func recordSpawn(nodeID int, instanceID string) {
+	if database.DBConn == nil || instanceID == "" {
+		return
+	}
+	database.SaveInstanceAction(database.DBConn, &models.InstanceAction{
+		NodeID:     nodeID,
+		InstanceID: instanceID,
+		Action:     "spawn",
+		Timestamp:  time.Now().UTC(),
+		Status:     "success",
+		Details:    "automatic placement",
+	})
+}
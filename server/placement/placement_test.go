@@ -0,0 +1,104 @@
+package placement
+
+import (
+	"strings"
+	"testing"
+
+	"exile/server/models"
+)
+
+func testNodes() []models.Node {
+	const gb = 1024 * 1024 * 1024
+	return []models.Node{
+		{ID: 1, Name: "eu-1", Region: "eu", Status: "Online", MaxInstances: 10, CurrentInstances: 6, CpuUsage: 40, MemUsed: 2 * gb, MemTotal: 8 * gb},
+		{ID: 2, Name: "eu-2", Region: "eu", Status: "Online", MaxInstances: 10, CurrentInstances: 2, CpuUsage: 70, MemUsed: 6 * gb, MemTotal: 8 * gb, Tags: "ranked"},
+		{ID: 3, Name: "eu-3", Region: "eu", Status: "Online", MaxInstances: 4, CurrentInstances: 4},
+		{ID: 4, Name: "us-1", Region: "us", Status: "Online", MaxInstances: 10},
+		{ID: 5, Name: "eu-4", Region: "eu", Status: "Online", MaxInstances: 10, IsDraining: true},
+		{ID: 6, Name: "eu-5", Region: "eu", Status: "Offline", MaxInstances: 10},
+		{ID: 7, Name: "eu-6", Region: "eu", Status: "Online", MaxInstances: 10, CpuUsage: 95},
+	}
+}
+
+func eligibleIDs(cs []Candidate) []int {
+	var ids []int
+	for _, c := range cs {
+		if c.Eligible {
+			ids = append(ids, c.NodeID)
+		}
+	}
+	return ids
+}
+
+func TestEvaluateStrategies(t *testing.T) {
+	cases := []struct {
+		strategy string
+		want     []int
+	}{
+		{StrategySpread, []int{2, 1}},
+		{StrategyBinpack, []int{1, 2}},
+		{StrategyLeastLoaded, []int{1, 2}},
+	}
+	for _, tc := range cases {
+		cs, err := Evaluate(testNodes(), Request{Region: "EU", Strategy: tc.strategy}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := eligibleIDs(cs)
+		if len(got) != len(tc.want) || got[0] != tc.want[0] || got[1] != tc.want[1] {
+			t.Errorf("%s: got order %v, want %v", tc.strategy, got, tc.want)
+		}
+	}
+}
+
+func TestEvaluateReasons(t *testing.T) {
+	cs, err := Evaluate(testNodes(), Request{Region: "eu", Tags: []string{"ranked"}}, func(id int) int {
+		if id == 2 {
+			return 8 // Spawns in flight fill node 2 up
+		}
+		return 0
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := eligibleIDs(cs); len(ids) != 0 {
+		t.Fatalf("expected no eligible node, got %v", ids)
+	}
+
+	want := map[int]string{
+		1: "missing tags: ranked",
+		2: "at capacity (10/10 instances)",
+		4: `region "us" does not match "eu"`,
+		5: "node is draining",
+		6: "node is offline",
+		7: "cpu headroom",
+	}
+	for _, c := range cs {
+		if w, ok := want[c.NodeID]; ok && !strings.Contains(strings.Join(c.Reasons, "; "), w) {
+			t.Errorf("node %d: reasons %v do not mention %q", c.NodeID, c.Reasons, w)
+		}
+	}
+
+	if _, err := Evaluate(testNodes(), Request{Strategy: "random"}, nil); err == nil {
+		t.Error("expected unknown strategy to be rejected")
+	}
+}
+
+func TestReserveAndRelease(t *testing.T) {
+	const node = 42
+	first := reserve(node)
+	reserve(node)
+	if n := pendingSpawns(node); n != 2 {
+		t.Fatalf("expected 2 pending spawns, got %d", n)
+	}
+
+	// A failed spawn gives its slot back; the other reservation stays
+	release(node, first)
+	if n := pendingSpawns(node); n != 1 {
+		t.Errorf("expected 1 pending spawn after release, got %d", n)
+	}
+	release(node, first)
+	if n := pendingSpawns(node); n != 1 {
+		t.Errorf("releasing twice must not drop another reservation, got %d", n)
+	}
+}
@@ -0,0 +1,252 @@
+package redeye
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"exile/server/bans"
+	"exile/server/database"
+	"exile/server/matchmaking"
+	"exile/server/models"
+	"exile/server/utils"
+)
+
+// =================================================================================
+// ANTICHEAT: per-player cheat scores and the account actions policies take on them
+// =================================================================================
+
+const (
+	// Who anticheat bans and reviews are attributed to
+	anticheatIssuer = "anticheat"
+
+	maxPolicyNameLen = 100
+)
+
+var (
+	// ScoreHalfLife is how long it takes a cheat score to halve (ANTICHEAT_SCORE_HALF_LIFE).
+	ScoreHalfLife = 24 * time.Hour
+)
+
+// InitAnticheat reads the anticheat settings and lets matchmaking keep shadow-queued
+// players apart.
+func InitAnticheat() {
+	ScoreHalfLife = utils.GetEnvDuration("ANTICHEAT_SCORE_HALF_LIFE", ScoreHalfLife)
+	if ScoreHalfLife <= 0 {
+		ScoreHalfLife = 24 * time.Hour
+	}
+	matchmaking.SetShadowFunc(IsShadowQueued)
+}
+
+// decayScore returns what a score is worth after elapsed time.
+func decayScore(score float64, elapsed, halfLife time.Duration) float64 {
+	if elapsed <= 0 || halfLife <= 0 {
+		return score
+	}
+	return score * math.Pow(0.5, elapsed.Seconds()/halfLife.Seconds())
+}
+
+// CurrentScore decays a stored score to now.
+func CurrentScore(s *models.CheatScore) float64 {
+	return decayScore(s.Score, time.Since(s.UpdatedAt), ScoreHalfLife)
+}
+
+// IsShadowQueued reports whether a player may only be matched with other shadow-queued players.
+func IsShadowQueued(playerID int64) bool {
+	if database.DBConn == nil {
+		return false
+	}
+	shadow, err := database.IsShadowQueued(database.DBConn, playerID)
+	if err != nil {
+		log.Printf("RedEye: failed to check shadow queue of player %d: %v", playerID, err)
+		return false
+	}
+	return shadow
+}
+
+// ResolvePlayer finds the account a game server means by a player reference: a
+// player ID or a Firebase UID. It returns nil if there is no such player.
+func ResolvePlayer(ref string) (*models.Player, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || database.DBConn == nil {
+		return nil, nil
+	}
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		p, err := database.GetPlayerByID(database.DBConn, id)
+		if err != nil || p != nil {
+			return p, err
+		}
+	}
+	return database.GetPlayerByUID(database.DBConn, ref)
+}
+
+// policyMatches reports whether a policy applies to an event at a score.
+func policyMatches(p *models.CheatPolicy, eventType string, score float64) bool {
+	if !p.Enabled || score < p.MinScore {
+		return false
+	}
+	return p.EventType == "" || strings.EqualFold(p.EventType, eventType)
+}
+
+// validatePolicy checks and normalizes a policy from the dashboard.
+func validatePolicy(p *models.CheatPolicy) error {
+	p.Name = strings.TrimSpace(p.Name)
+	p.EventType = strings.ToUpper(strings.TrimSpace(p.EventType))
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(p.Name) > maxPolicyNameLen {
+		return fmt.Errorf("name is too long")
+	}
+	if p.MinScore <= 0 {
+		return fmt.Errorf("min_score must be positive")
+	}
+	switch p.Action {
+	case models.CheatActionFlag, models.CheatActionShadowQueue:
+		p.BanDuration = 0
+	case models.CheatActionBan:
+		if p.BanDuration < 0 {
+			return fmt.Errorf("ban_duration must not be negative")
+		}
+	default:
+		return fmt.Errorf("action must be flag, shadow_queue or ban")
+	}
+	return nil
+}
+
+// ProcessPlayerEvent adds a linked event to its player's cheat score and applies the
+// policies the new score reaches. It returns the updated score and the reviews of the
+// actions taken.
+func ProcessPlayerEvent(e *models.RedEyeAnticheatEvent) (*models.CheatScore, []models.CheatReview, error) {
+	if database.DBConn == nil || e.AccountID == nil {
+		return nil, nil, nil
+	}
+	severity := e.Severity
+	if severity < 1 {
+		severity = 1
+	}
+	if severity > 100 {
+		severity = 100
+	}
+
+	score, err := database.AddCheatScore(database.DBConn, *e.AccountID, float64(severity), ScoreHalfLife.Seconds())
+	if err != nil {
+		return nil, nil, fmt.Errorf("update cheat score: %w", err)
+	}
+	policies, err := database.ListCheatPolicies(database.DBConn)
+	if err != nil {
+		return score, nil, fmt.Errorf("load cheat policies: %w", err)
+	}
+
+	reviews := []models.CheatReview{}
+	for i := range policies {
+		p := &policies[i]
+		if !policyMatches(p, e.EventType, score.Score) {
+			continue
+		}
+		r, err := applyPolicy(p, score, e)
+		if err != nil {
+			log.Printf("RedEye: policy %q failed for player %d: %v", p.Name, score.PlayerID, err)
+			continue
+		}
+		if r != nil {
+			reviews = append(reviews, *r)
+		}
+	}
+	return score, reviews, nil
+}
+
+// applyPolicy takes a policy's action unless it is already in effect, and queues it for
+// review. It returns nil if nothing was done.
+func applyPolicy(p *models.CheatPolicy, score *models.CheatScore, e *models.RedEyeAnticheatEvent) (*models.CheatReview, error) {
+	pending, err := database.HasPendingCheatReview(database.DBConn, score.PlayerID, p.ID)
+	if err != nil || pending {
+		return nil, err
+	}
+
+	eventID := int64(e.ID)
+	r := &models.CheatReview{
+		PlayerID: score.PlayerID,
+		PolicyID: &p.ID,
+		EventID:  &eventID,
+		Action:   p.Action,
+		Score:    score.Score,
+	}
+
+	switch p.Action {
+	case models.CheatActionShadowQueue:
+		if score.ShadowQueued {
+			return nil, nil
+		}
+		if err := database.SetShadowQueued(database.DBConn, score.PlayerID, true); err != nil {
+			return nil, err
+		}
+		score.ShadowQueued = true
+	case models.CheatActionBan:
+		if active, err := bans.Active(score.PlayerID, models.BanScopeGame); err != nil || active != nil {
+			return nil, err
+		}
+		b, err := bans.Issue(bans.Request{
+			PlayerID:      score.PlayerID,
+			Scope:         models.BanScopeGame,
+			Reason:        fmt.Sprintf("Anticheat: %s (%s, score %.0f)", p.Name, e.EventType, score.Score),
+			EvidenceLinks: []string{fmt.Sprintf("/api/redeye/anticheat/players/%d", score.PlayerID)},
+			Duration:      time.Duration(p.BanDuration) * time.Second,
+			IssuedBy:      anticheatIssuer,
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.BanID = &b.ID
+	}
+
+	id, err := database.CreateCheatReview(database.DBConn, r)
+	if err != nil {
+		return nil, err
+	}
+	r.ID = id
+	r.Status = models.CheatReviewPending
+	log.Printf("RedEye: policy %q (%s) fired for player %d at score %.0f", p.Name, p.Action, score.PlayerID, score.Score)
+	return r, nil
+}
+
+// ReviewAction closes a review. Confirming keeps the action; reverting undoes it: the
+// ban is lifted or the player leaves the shadow queue.
+func ReviewAction(reviewID int64, confirm bool, by, note string) (*models.CheatReview, error) {
+	r, err := database.GetCheatReview(database.DBConn, reviewID)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if r.Status != models.CheatReviewPending {
+		return nil, fmt.Errorf("review is already %s", r.Status)
+	}
+
+	status := models.CheatReviewConfirmed
+	if !confirm {
+		status = models.CheatReviewReverted
+		switch r.Action {
+		case models.CheatActionShadowQueue:
+			if err := database.SetShadowQueued(database.DBConn, r.PlayerID, false); err != nil {
+				return nil, err
+			}
+		case models.CheatActionBan:
+			if r.BanID != nil {
+				// The ban may have expired or been lifted already
+				if _, err := bans.Lift(*r.BanID, by, "Anticheat review reverted"); err != nil && !errors.Is(err, sql.ErrNoRows) {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := database.ResolveCheatReview(database.DBConn, reviewID, status, by, strings.TrimSpace(note)); err != nil {
+		return nil, err
+	}
+	log.Printf("RedEye: review %d of player %d %s by %s", r.ID, r.PlayerID, status, by)
+	return database.GetCheatReview(database.DBConn, reviewID)
+}
@@ -0,0 +1,67 @@
+package redeye
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"exile/server/models"
+)
+
+func TestDecayScore(t *testing.T) {
+	cases := []struct {
+		elapsed time.Duration
+		want    float64
+	}{
+		{0, 100},
+		{24 * time.Hour, 50},
+		{48 * time.Hour, 25},
+		{12 * time.Hour, 100 / math.Sqrt2},
+	}
+	for _, c := range cases {
+		if got := decayScore(100, c.elapsed, 24*time.Hour); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("after %v: got %v, want %v", c.elapsed, got, c.want)
+		}
+	}
+}
+
+func TestPolicyMatches(t *testing.T) {
+	anyEvent := &models.CheatPolicy{MinScore: 50, Enabled: true}
+	speed := &models.CheatPolicy{EventType: "SPEEDHACK", MinScore: 50, Enabled: true}
+	off := &models.CheatPolicy{MinScore: 50}
+
+	if !policyMatches(anyEvent, "AIMBOT", 50) {
+		t.Error("a policy without event type should match any event at its score")
+	}
+	if policyMatches(anyEvent, "AIMBOT", 49.9) {
+		t.Error("a policy must not match below its score")
+	}
+	if !policyMatches(speed, "speedhack", 80) || policyMatches(speed, "AIMBOT", 80) {
+		t.Error("event type should be matched case-insensitively and exclusively")
+	}
+	if policyMatches(off, "AIMBOT", 80) {
+		t.Error("disabled policies must not match")
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	p := &models.CheatPolicy{Name: " Speed ", EventType: " speedhack ", MinScore: 10, Action: models.CheatActionFlag, BanDuration: 60}
+	if err := validatePolicy(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Speed" || p.EventType != "SPEEDHACK" || p.BanDuration != 0 {
+		t.Errorf("policy not normalized: %+v", p)
+	}
+
+	bad := []models.CheatPolicy{
+		{MinScore: 10, Action: models.CheatActionFlag},
+		{Name: "x", Action: models.CheatActionFlag},
+		{Name: "x", MinScore: 10, Action: "kick"},
+		{Name: "x", MinScore: 10, Action: models.CheatActionBan, BanDuration: -1},
+	}
+	for i := range bad {
+		if err := validatePolicy(&bad[i]); err == nil {
+			t.Errorf("policy %d should be rejected", i)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package redeye
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// -- Threat Intelligence Feeds --
+//
+// Feeds are blocklists fetched on a schedule. Their entries go into the firewall
+// snapshot next to the bans and block matching clients unless an ALLOW rule decides the
+// request first, which is how a false positive is let through. Feed entries are only
+// enforced by the middleware: lists run to tens of thousands of ranges, too many for ufw.
+
+const (
+	FeedFormatPlain    = "plain"    // One IP or CIDR per line, "#" comments
+	FeedFormatFireHOL  = "firehol"  // FireHOL .ipset/.netset: as plain
+	FeedFormatSpamhaus = "spamhaus" // Spamhaus DROP/EDROP: "CIDR ; SBL123", ";" comments
+
+	feedCheckInterval = time.Minute
+	feedFetchTimeout  = 30 * time.Second
+	feedMaxBytes      = 64 << 20
+	feedMaxEntries    = 500000
+	minRefreshMinutes = 5
+)
+
+var (
+	feedClient = &http.Client{Timeout: feedFetchTimeout}
+
+	// Refreshes in progress, so a manual refresh and the schedule do not overlap
+	refreshingFeeds sync.Map
+
+	// Last fetch attempt by feed ID, so a failing feed is retried on its interval
+	// rather than every check
+	feedAttempts sync.Map // int -> time.Time
+
+	// Hits not yet written to the database, by feed ID
+	feedHits sync.Map // int -> *atomic.Int64
+
+	// A feed with no content at all, not even comments
+	errEmptyFeed = errors.New("feed is empty")
+)
+
+// parseFeed reads a feed's entries, canonicalized: bare IPs for single addresses, CIDR
+// notation for ranges. Lines that do not parse are counted and skipped; a feed with
+// content but no valid entry is an error, so a format change upstream does not wipe
+// the list. A blank feed returns errEmptyFeed, while one holding only comments is a
+// list with nothing on it.
+func parseFeed(r io.Reader, format string) ([]string, int, error) {
+	comment := "#"
+	if format == FeedFormatSpamhaus {
+		comment = ";"
+	}
+	var entries []string
+	skipped := 0
+	seen := make(map[string]bool)
+	blank := true
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) != "" {
+			blank = false
+		}
+		line, _, _ := strings.Cut(sc.Text(), comment)
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		prefixes, err := parsePrefixes(fields[0])
+		if err != nil || len(prefixes) != 1 || prefixes[0].Bits() == 0 {
+			// Never let a feed block every address
+			skipped++
+			continue
+		}
+		entry := prefixes[0].String()
+		if prefixes[0].IsSingleIP() {
+			entry = prefixes[0].Addr().String()
+		}
+		if seen[entry] {
+			continue
+		}
+		if len(entries) == feedMaxEntries {
+			return nil, skipped, fmt.Errorf("feed has more than %d entries", feedMaxEntries)
+		}
+		seen[entry] = true
+		entries = append(entries, entry)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, skipped, err
+	}
+	if blank {
+		return nil, 0, errEmptyFeed
+	}
+	if len(entries) == 0 && skipped > 0 {
+		return nil, skipped, fmt.Errorf("no valid entries (%d lines skipped); check the format", skipped)
+	}
+	return entries, skipped, nil
+}
+
+// openFeed opens a feed source: an http(s) URL, a file:// URL or a local path.
+func openFeed(source string) (io.ReadCloser, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		resp, err := feedClient.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetch %s: %s", source, resp.Status)
+		}
+		return resp.Body, nil
+	}
+	return os.Open(strings.TrimPrefix(source, "file://"))
+}
+
+// validateFeed checks a feed before it is stored, filling in defaults.
+func validateFeed(f *models.RedEyeFeed) error {
+	f.Name = strings.TrimSpace(f.Name)
+	f.Source = strings.TrimSpace(f.Source)
+	if f.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if f.Source == "" {
+		return fmt.Errorf("source is required")
+	}
+	if strings.Contains(f.Source, "://") {
+		u, err := url.Parse(f.Source)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+			return fmt.Errorf("source must be an http(s) URL, a file:// URL or a file path")
+		}
+	}
+	if f.Format == "" {
+		f.Format = FeedFormatPlain
+	}
+	switch f.Format {
+	case FeedFormatPlain, FeedFormatFireHOL, FeedFormatSpamhaus:
+	default:
+		return fmt.Errorf("format must be plain, firehol or spamhaus")
+	}
+	if f.RefreshMinutes == 0 {
+		f.RefreshMinutes = 60
+	}
+	if f.RefreshMinutes < minRefreshMinutes {
+		return fmt.Errorf("refresh_minutes must be at least %d", minRefreshMinutes)
+	}
+	return nil
+}
+
+// FeedRefresh is the outcome of refreshing a feed.
+type FeedRefresh struct {
+	Entries int `json:"entries"`
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Skipped int `json:"skipped"` // Lines that did not parse
+}
+
+// RefreshFeed fetches a feed and replaces its entries. On failure the error is recorded
+// on the feed and its previous entries stay in force. The caller refreshes the ban
+// cache.
+func RefreshFeed(db *sqlx.DB, f *models.RedEyeFeed) (*FeedRefresh, error) {
+	if _, busy := refreshingFeeds.LoadOrStore(f.ID, true); busy {
+		return nil, fmt.Errorf("feed %d is already being refreshed", f.ID)
+	}
+	defer refreshingFeeds.Delete(f.ID)
+	feedAttempts.Store(f.ID, time.Now())
+
+	res, err := fetchFeed(db, f)
+	if err != nil {
+		if recErr := database.RecordRedEyeFeedError(db, f.ID, err.Error()); recErr != nil {
+			log.Printf("RedEye: Failed to record error for feed %q: %v", f.Name, recErr)
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func fetchFeed(db *sqlx.DB, f *models.RedEyeFeed) (*FeedRefresh, error) {
+	body, err := openFeed(f.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	entries, skipped, err := parseFeed(io.LimitReader(body, feedMaxBytes), f.Format)
+	if errors.Is(err, errEmptyFeed) {
+		// A blank response is more likely an upstream fault than an emptied list, so
+		// only a feed with nothing on it yet accepts one
+		current, getErr := database.GetRedEyeFeedByID(db, f.ID)
+		if getErr != nil {
+			return nil, getErr
+		}
+		if current != nil && current.EntryCount > 0 {
+			return nil, fmt.Errorf("%w; keeping its %d entries", err, current.EntryCount)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	added, removed, err := database.ReplaceRedEyeFeedEntries(db, f.ID, entries)
+	if err != nil {
+		return nil, err
+	}
+	return &FeedRefresh{Entries: len(entries), Added: added, Removed: removed, Skipped: skipped}, nil
+}
+
+// refreshDueFeeds refreshes the enabled feeds whose refresh interval has passed.
+func refreshDueFeeds(db *sqlx.DB) {
+	feeds, err := database.GetRedEyeFeeds(db)
+	if err != nil {
+		log.Printf("RedEye: Failed to list feeds: %v", err)
+		return
+	}
+	changed := false
+	now := time.Now()
+	for i := range feeds {
+		f := &feeds[i]
+		if !f.Enabled {
+			continue
+		}
+		last := time.Time{}
+		if f.LastRefreshed != nil {
+			last = *f.LastRefreshed
+		}
+		if t, ok := feedAttempts.Load(f.ID); ok && t.(time.Time).After(last) {
+			last = t.(time.Time)
+		}
+		if now.Sub(last) < time.Duration(f.RefreshMinutes)*time.Minute {
+			continue
+		}
+		res, err := RefreshFeed(db, f)
+		if err != nil {
+			log.Printf("RedEye: Feed %q refresh failed: %v", f.Name, err)
+			continue
+		}
+		log.Printf("RedEye: Feed %q refreshed: %d entries (+%d/-%d, %d skipped)", f.Name, res.Entries, res.Added, res.Removed, res.Skipped)
+		changed = changed || res.Added > 0 || res.Removed > 0
+	}
+	if changed {
+		RefreshBanCache(db)
+	}
+}
+
+func feedLoop(db *sqlx.DB) {
+	wg.Add(1)
+	defer wg.Done()
+
+	ticker := time.NewTicker(feedCheckInterval)
+	defer ticker.Stop()
+
+	refreshDueFeeds(db)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			refreshDueFeeds(db)
+		}
+	}
+}
+
+// recordFeedHit counts a blocked request against a feed.
+func recordFeedHit(feedID int) {
+	c, _ := feedHits.LoadOrStore(feedID, new(atomic.Int64))
+	c.(*atomic.Int64).Add(1)
+}
+
+// flushFeedHits writes the pending hit counts to the database.
+func flushFeedHits(db *sqlx.DB) {
+	feedHits.Range(func(key, value any) bool {
+		if n := value.(*atomic.Int64).Swap(0); n > 0 {
+			if err := database.AddRedEyeFeedHits(db, key.(int), n); err != nil {
+				value.(*atomic.Int64).Add(n)
+				log.Printf("RedEye: Failed to save hits for feed %d: %v", key.(int), err)
+			}
+		}
+		return true
+	})
+}
+
+// buildFeedTrie indexes feed entries by feed ID.
+func buildFeedTrie(entries []models.RedEyeFeedEntry) (*ipTrie[int], []error) {
+	t := newIPTrie[int]()
+	var errs []error
+	for _, e := range entries {
+		prefixes, err := parsePrefixes(e.CIDR)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("feed %d entry %q: %w", e.FeedID, e.CIDR, err))
+			continue
+		}
+		for _, p := range prefixes {
+			t.Insert(p, e.FeedID)
+		}
+	}
+	return t, errs
+}
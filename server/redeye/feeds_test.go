@@ -0,0 +1,229 @@
+package redeye
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"exile/server/database"
+	"exile/server/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestParseFeedFormats(t *testing.T) {
+	cases := []struct {
+		name    string
+		format  string
+		body    string
+		want    string
+		skipped int
+		wantErr bool
+	}{
+		{
+			name:   "plain",
+			format: FeedFormatPlain,
+			body:   "# Blocklist\n198.51.100.7\n\n203.0.113.0/24  # scanners\n198.51.100.7\n2001:db8::/32\n",
+			want:   "198.51.100.7 203.0.113.0/24 2001:db8::/32",
+		},
+		{
+			name:   "firehol",
+			format: FeedFormatFireHOL,
+			body:   "#\n# firehol_level1\n#\n1.0.0.0/24\n5.6.7.8\n10.1.2.3/8\n",
+			want:   "1.0.0.0/24 5.6.7.8 10.0.0.0/8",
+		},
+		{
+			name:   "spamhaus",
+			format: FeedFormatSpamhaus,
+			body:   "; Spamhaus DROP List 2026/10/18\n; Last-Modified: Sun, 18 Oct 2026\n1.10.16.0/20 ; SBL256894\n2.56.192.0/22 ; SBL459831\n",
+			want:   "1.10.16.0/20 2.56.192.0/22",
+		},
+		{
+			name:    "bad lines are skipped",
+			format:  FeedFormatPlain,
+			body:    "198.51.100.7\nnot-an-ip\n0.0.0.0/0\n",
+			want:    "198.51.100.7",
+			skipped: 2,
+		},
+		{
+			name:    "wrong format",
+			format:  FeedFormatPlain,
+			body:    "<html><body>Moved</body></html>\n",
+			skipped: 1,
+			wantErr: true,
+		},
+		{
+			name:   "only comments",
+			format: FeedFormatPlain,
+			body:   "# nothing listed today\n",
+		},
+		{
+			name:    "blank",
+			format:  FeedFormatPlain,
+			body:    "\n  \n",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		got, skipped, err := parseFeed(strings.NewReader(c.body), c.format)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", c.name, err, c.wantErr)
+			continue
+		}
+		if strings.Join(got, " ") != c.want || skipped != c.skipped {
+			t.Errorf("%s: got %v (%d skipped), want %s (%d skipped)", c.name, got, skipped, c.want, c.skipped)
+		}
+	}
+}
+
+func TestValidateFeed(t *testing.T) {
+	f := &models.RedEyeFeed{Name: " DROP ", Source: "https://www.spamhaus.org/drop/drop.txt"}
+	if err := validateFeed(f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "DROP" || f.Format != FeedFormatPlain || f.RefreshMinutes != 60 {
+		t.Errorf("defaults not applied: %+v", f)
+	}
+
+	for _, bad := range []models.RedEyeFeed{
+		{Source: "/etc/blocklist.txt"},
+		{Name: "x"},
+		{Name: "x", Source: "ftp://example.com/list"},
+		{Name: "x", Source: "/etc/blocklist.txt", Format: "csv"},
+		{Name: "x", Source: "/etc/blocklist.txt", RefreshMinutes: 1},
+	} {
+		if err := validateFeed(&bad); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestFeedBlocksUnlessAllowed(t *testing.T) {
+	compiled, errs := compileRules([]models.RedEyeRule{
+		{ID: 1, CIDR: "203.0.113.5", Action: "ALLOW", Enabled: true},
+	})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	feeds, errs := buildFeedTrie([]models.RedEyeFeedEntry{
+		{FeedID: 7, CIDR: "203.0.113.0/24"},
+		{FeedID: 8, CIDR: "203.0.113.9"},
+	})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	f := newFirewall(compiled, newIPTrie[string]())
+	f.feeds, f.feedNames = feeds, map[int]string{7: "firehol_level1", 8: "drop"}
+
+	if got := f.listed(netip.MustParseAddr("203.0.113.9")); len(got) != 2 || got[0] != 8 || got[1] != 7 {
+		t.Errorf("expected feeds 8 then 7, got %v", got)
+	}
+
+	prev := activeFirewall.Swap(f)
+	defer activeFirewall.Store(prev)
+
+	ev, err := Evaluate(SimulatedRequest{IP: "203.0.113.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Decision != "DENY" || ev.Reason != "feed" || strings.Join(ev.Feeds, ",") != "drop,firehol_level1" {
+		t.Errorf("expected a feed block, got %+v", ev)
+	}
+
+	// The ALLOW rule overrides the feed for a false positive
+	ev, err = Evaluate(SimulatedRequest{IP: "203.0.113.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Decision != "ALLOW" || ev.Reason != "rule" {
+		t.Errorf("expected the ALLOW rule to win, got %+v", ev)
+	}
+}
+
+func TestOpenFeedHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/drop.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("1.10.16.0/20 ; SBL256894\n"))
+	}))
+	defer srv.Close()
+
+	body, err := openFeed(srv.URL + "/drop.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _, err := parseFeed(body, FeedFormatSpamhaus)
+	body.Close()
+	if err != nil || len(entries) != 1 || entries[0] != "1.10.16.0/20" {
+		t.Errorf("got %v, %v", entries, err)
+	}
+
+	if _, err := openFeed(srv.URL + "/missing.txt"); err == nil {
+		t.Error("expected an error for a 404")
+	}
+}
+
+func TestBlankFeedKeepsEntries(t *testing.T) {
+	db, err := sqlx.Connect("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if err := database.MigrateDB(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	source := filepath.Join(t.TempDir(), "blocklist.txt")
+	feed := &models.RedEyeFeed{Name: "local", Source: source, Format: FeedFormatPlain, RefreshMinutes: 60, Enabled: true}
+	if feed.ID, err = database.CreateRedEyeFeed(db, feed); err != nil {
+		t.Fatal(err)
+	}
+	refresh := func(body string) (*FeedRefresh, error) {
+		t.Helper()
+		if err := os.WriteFile(source, []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return RefreshFeed(db, feed)
+	}
+	count := func() int {
+		t.Helper()
+		f, err := database.GetRedEyeFeedByID(db, feed.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f.EntryCount
+	}
+
+	// A new feed may start out blank
+	if _, err := refresh(""); err != nil {
+		t.Fatalf("blank first fetch: %v", err)
+	}
+	if _, err := refresh("198.51.100.7\n203.0.113.0/24\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once it has entries, a blank body is refused and the entries stay
+	if _, err := refresh(""); !errors.Is(err, errEmptyFeed) {
+		t.Errorf("expected a blank body to be refused, got %v", err)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("expected the 2 entries to be kept, got %d", n)
+	}
+
+	// A list that says it is empty clears the feed
+	res, err := refresh("# no entries today\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 2 || count() != 0 {
+		t.Errorf("expected the feed to be cleared, got %+v with %d entries", res, count())
+	}
+}
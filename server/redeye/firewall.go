@@ -0,0 +1,129 @@
+package redeye
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sync/atomic"
+)
+
+// firewall is a compiled snapshot of the banned IPs and enabled rules. RefreshBanCache
+// builds a new one and swaps it in atomically, so requests never wait on a rebuild and
+// never see a half-built one.
+type firewall struct {
+	rules     []compiledRule  // Evaluation order
+	ruleIPs   *ipTrie[int]    // Rule CIDRs to indexes into rules
+	bans      *ipTrie[string] // Banned IPs and ranges to their ban entry
+	feeds     *ipTrie[int]    // Threat feed entries to feed IDs
+	feedNames map[int]string
+}
+
+var (
+	activeFirewall atomic.Pointer[firewall]
+	emptyFirewall  = newFirewall(nil, newIPTrie[string]())
+)
+
+// currentFirewall returns the snapshot in use.
+func currentFirewall() *firewall {
+	if f := activeFirewall.Load(); f != nil {
+		return f
+	}
+	return emptyFirewall
+}
+
+// newFirewall indexes rules by CIDR. The rules are sorted into evaluation order in place.
+func newFirewall(rules []compiledRule, bans *ipTrie[string]) *firewall {
+	sortRules(rules)
+	f := &firewall{rules: rules, ruleIPs: newIPTrie[int](), bans: bans, feeds: newIPTrie[int]()}
+	for i := range rules {
+		for _, p := range rules[i].prefixes {
+			f.ruleIPs.Insert(p, i)
+		}
+	}
+	return f
+}
+
+// buildBanTrie indexes ban entries, which are IPs or CIDR ranges. Entries that do not
+// parse are skipped.
+func buildBanTrie(entries []string) (*ipTrie[string], []error) {
+	t := newIPTrie[string]()
+	var errs []error
+	for _, e := range entries {
+		prefixes, err := parsePrefixes(e)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ban %q: %w", e, err))
+			continue
+		}
+		for _, p := range prefixes {
+			t.Insert(p, e)
+		}
+	}
+	return t, errs
+}
+
+// banned returns the most specific ban covering addr.
+func (f *firewall) banned(addr netip.Addr) (string, bool) {
+	_, entries, ok := f.bans.MostSpecific(addr)
+	if !ok {
+		return "", false
+	}
+	return entries[0], true
+}
+
+// listed returns the IDs of the feeds listing addr, most specific entry first.
+func (f *firewall) listed(addr netip.Addr) []int {
+	var ids []int
+	f.feeds.Lookup(addr, func(_ netip.Prefix, feeds []int) {
+		for _, id := range feeds {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	})
+	slices.Reverse(ids)
+	return ids
+}
+
+// feedName returns a feed's name for logs, falling back to its ID.
+func (f *firewall) feedName(id int) string {
+	if name, ok := f.feedNames[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("#%d", id)
+}
+
+// candidates appends to buf the indexes of the rules whose CIDR contains addr, in
+// evaluation order. A rule is only stored once per address family, so there are no
+// duplicates.
+func (f *firewall) candidates(addr netip.Addr, buf []int) []int {
+	f.ruleIPs.Lookup(addr, func(_ netip.Prefix, idx []int) { buf = append(buf, idx...) })
+	slices.Sort(buf)
+	return buf
+}
+
+// evaluate walks the rules covering addr in order. LOG_ONLY rules are recorded and
+// evaluation carries on; the first other matching rule decides. With full set, rules
+// after the deciding one are listed as shadowed; otherwise evaluation stops there.
+func (f *firewall) evaluate(addr netip.Addr, port int, method, path string, full bool) *Evaluation {
+	ev := &Evaluation{Decision: "ALLOW", Reason: "default", Chain: []RuleMatch{}}
+	var buf [16]int
+	for _, i := range f.candidates(addr, buf[:0]) {
+		c := &f.rules[i]
+		if !c.matchesRequest(port, method, path) {
+			continue
+		}
+		m := RuleMatch{Rule: c.rule}
+		switch {
+		case ev.Rule != nil:
+			m.Shadowed = true
+		case c.rule.Action != "LOG_ONLY":
+			m.Applied = true
+			ev.Decision, ev.Reason, ev.Rule = c.rule.Action, "rule", c.rule
+		}
+		ev.Chain = append(ev.Chain, m)
+		if ev.Rule != nil && !full {
+			break
+		}
+	}
+	return ev
+}
@@ -0,0 +1,260 @@
+package redeye
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Player Anticheat Handlers --
+
+// ListCheatScoresHandler returns shadow-queued players and the highest cheat scores,
+// decayed to now.
+func ListCheatScoresHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	scores, err := database.ListCheatScores(database.DBConn, ScoreHalfLife.Seconds(), limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, scores)
+}
+
+// GetPlayerCheatHandler returns a player's cheat score, linked events, reviews and evidence.
+func GetPlayerCheatHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	score, err := database.GetCheatScore(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if score != nil {
+		score.Score = CurrentScore(score)
+	}
+	events, err := database.GetPlayerAnticheatEvents(database.DBConn, id, 200)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	reviews, err := database.ListPlayerCheatReviews(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	evidence, err := database.ListPlayerEvidence(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"score":    score,
+		"events":   events,
+		"reviews":  reviews,
+		"evidence": evidence,
+	})
+}
+
+// -- Cheat Policy Handlers --
+
+func ListCheatPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	policies, err := database.ListCheatPolicies(database.DBConn)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, policies)
+}
+
+// CreateCheatPolicyHandler adds a policy.
+//
+// Request (JSON):
+//   - name: Label shown in the review queue
+//   - event_type: Only events of this type (e.g. "SPEEDHACK"); empty for any
+//   - min_score: Score (after decay) at which the policy fires
+//   - action: flag, shadow_queue or ban
+//   - ban_duration: Ban length in seconds; 0 = permanent
+//   - enabled: Whether the policy is evaluated
+func CreateCheatPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	var p models.CheatPolicy
+	if err := utils.DecodeJSON(r, &p); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validatePolicy(&p); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := database.CreateCheatPolicy(database.DBConn, &p)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p.ID = id
+	utils.WriteJSON(w, http.StatusCreated, p)
+}
+
+func UpdateCheatPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var p models.CheatPolicy
+	if err := utils.DecodeJSON(r, &p); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	p.ID = id
+	if err := validatePolicy(&p); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := database.UpdateCheatPolicy(database.DBConn, &p); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteError(w, r, http.StatusNotFound, "policy not found")
+			return
+		}
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	updated, err := database.GetCheatPolicy(database.DBConn, id)
+	if err != nil || updated == nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to reload policy")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, updated)
+}
+
+func DeleteCheatPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+	if err := database.DeleteCheatPolicy(database.DBConn, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.WriteError(w, r, http.StatusNotFound, "policy not found")
+			return
+		}
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// -- Cheat Review Handlers --
+
+// ListCheatReviewsHandler returns the review queue, newest first. Query "status"
+// defaults to pending; "all" lists every review.
+func ListCheatReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = models.CheatReviewPending
+	case "all":
+		status = ""
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	reviews, err := database.ListCheatReviews(database.DBConn, status, limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, reviews)
+}
+
+// ResolveCheatReviewHandler closes a review.
+//
+// Request (JSON):
+//   - decision: confirm (keep the action) or revert (undo it)
+//   - reviewed_by: Who reviewed it (defaults to "dashboard")
+//   - note: Optional comment
+func ResolveCheatReviewHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid id")
+		return
+	}
+	var req struct {
+		Decision   string `json:"decision"`
+		ReviewedBy string `json:"reviewed_by"`
+		Note       string `json:"note"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Decision != "confirm" && req.Decision != "revert" {
+		utils.WriteError(w, r, http.StatusBadRequest, "decision must be confirm or revert")
+		return
+	}
+	if req.ReviewedBy == "" {
+		req.ReviewedBy = "dashboard"
+	}
+
+	review, err := ReviewAction(id, req.Decision == "confirm", req.ReviewedBy, req.Note)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if review == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "review not found")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, review)
+}
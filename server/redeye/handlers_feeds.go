@@ -0,0 +1,212 @@
+package redeye
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Threat Feed Handlers --
+
+// ListFeedsHandler lists the threat feeds with their entry counts, hits and last
+// refresh. Feed entries are listed per feed, apart from the rules.
+func ListFeedsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	flushFeedHits(database.DBConn)
+	feeds, err := database.GetRedEyeFeeds(database.DBConn)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, feeds)
+}
+
+// CreateFeedHandler adds a feed and starts its first fetch in the background, as a large
+// list can take a while to download and store. A failed first fetch is recorded on the
+// feed, which is kept so it can be corrected.
+//
+// Request (JSON):
+//   - name (required): Display name
+//   - source (required): http(s) URL, file:// URL or local file path
+//   - format: plain, firehol or spamhaus (defaults to plain)
+//   - refresh_minutes: How often to re-fetch (defaults to 60, at least 5)
+//   - enabled: Whether the feed's entries are enforced
+func CreateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	var feed models.RedEyeFeed
+	if err := utils.DecodeJSON(r, &feed); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateFeed(&feed); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id, err := database.CreateRedEyeFeed(database.DBConn, &feed)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	feed.ID = id
+
+	go func() {
+		res, err := RefreshFeed(database.DBConn, &feed)
+		if err != nil {
+			log.Printf("RedEye: First fetch of feed %q failed: %v", feed.Name, err)
+			return
+		}
+		log.Printf("RedEye: Feed %q fetched: %d entries (%d skipped)", feed.Name, res.Entries, res.Skipped)
+		RefreshBanCache(database.DBConn)
+	}()
+
+	utils.WriteJSON(w, http.StatusCreated, map[string]interface{}{"id": id, "status": "refreshing"})
+}
+
+func UpdateFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid ID")
+		return
+	}
+
+	var feed models.RedEyeFeed
+	if err := utils.DecodeJSON(r, &feed); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	feed.ID = id
+	if err := validateFeed(&feed); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existing, err := database.GetRedEyeFeedByID(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if existing == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "feed not found")
+		return
+	}
+
+	if err := database.UpdateRedEyeFeed(database.DBConn, &feed); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// A new source or format makes the stored entries stale
+	if feed.Enabled && (feed.Source != existing.Source || feed.Format != existing.Format) {
+		if _, err := RefreshFeed(database.DBConn, &feed); err != nil {
+			RefreshBanCache(database.DBConn)
+			utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated", "error": err.Error()})
+			return
+		}
+	}
+
+	RefreshBanCache(database.DBConn)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+func DeleteFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid ID")
+		return
+	}
+
+	if err := database.DeleteRedEyeFeed(database.DBConn, id); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	feedHits.Delete(id)
+	feedAttempts.Delete(id)
+
+	RefreshBanCache(database.DBConn)
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// RefreshFeedHandler fetches a feed now, outside its schedule.
+func RefreshFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid ID")
+		return
+	}
+
+	feed, err := database.GetRedEyeFeedByID(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if feed == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "feed not found")
+		return
+	}
+
+	res, err := RefreshFeed(database.DBConn, feed)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	RefreshBanCache(database.DBConn)
+	utils.WriteJSON(w, http.StatusOK, res)
+}
+
+// ListFeedEntriesHandler lists a feed's entries, up to limit (default 500).
+func ListFeedEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, "invalid ID")
+		return
+	}
+	limit := 500
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 10000 {
+		limit = l
+	}
+
+	entries, err := database.GetRedEyeFeedEntries(database.DBConn, id, limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, entries)
+}
@@ -2,6 +2,7 @@ package redeye
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -81,13 +82,8 @@ func CreateRedEyeRuleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if rule.CIDR == "" {
-		utils.WriteError(w, r, http.StatusBadRequest, "CIDR is required")
-		return
-	}
-
-	if rule.Action != "ALLOW" && rule.Action != "DENY" && rule.Action != "RATE_LIMIT" {
-		utils.WriteError(w, r, http.StatusBadRequest, "Action must be ALLOW, DENY, or RATE_LIMIT")
+	if err := validateRule(&rule); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -124,6 +120,11 @@ func UpdateRedEyeRuleHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	rule.ID = id
 
+	if err := validateRule(&rule); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	if err := database.UpdateRedEyeRule(database.DBConn, &rule); err != nil {
 		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
 		return
@@ -135,6 +136,31 @@ func UpdateRedEyeRuleHandler(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusOK, rule)
 }
 
+// EvaluateRulesHandler shows how a hypothetical request would be handled: every
+// matching rule in evaluation order and the final decision.
+//
+// Request (JSON):
+//   - ip (required): Client IP
+//   - port: Port the request arrives on (defaults to 80)
+//   - method: HTTP method (defaults to GET)
+//   - path: Request path (defaults to "/")
+//   - rule: Optional candidate rule to evaluate as if it were saved; with an id it
+//     replaces that rule
+func EvaluateRulesHandler(w http.ResponseWriter, r *http.Request) {
+	var req SimulatedRequest
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ev, err := Evaluate(req)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, ev)
+}
+
 func DeleteRedEyeRuleHandler(w http.ResponseWriter, r *http.Request) {
 	if database.DBConn == nil {
 		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
@@ -218,18 +244,38 @@ func ReportAnticheatEventHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	event.Timestamp = time.Now()
 
-	// 1. Persist detailed evidence to permanent record
+	// 1. Link the event to the player's account, if the reference resolves to one
+	event.AccountID = nil
+	if p, err := ResolvePlayer(event.PlayerID); err == nil && p != nil {
+		event.AccountID = &p.ID
+	}
+
+	// 2. Persist detailed evidence to permanent record
 	if err := database.SaveAnticheatEvent(database.DBConn, &event); err != nil {
 		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// 2. Feed the Real-time Engine
+	// 3. Feed the Real-time Engine
 	// Severity 1-100. We multiply by 2 for reputation impact (e.g. 50 severity = 100 score = instant ban).
 	details := fmt.Sprintf("[%s] Player: %s - %s", event.EventType, event.PlayerID, event.Details)
 	IngestSignal(event.ClientIP, SignalTypeReport, event.Severity * 2, details)
 
-	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "reported", "action": "processed_by_engine"})
+	response := map[string]interface{}{"status": "reported", "action": "processed_by_engine", "account_id": event.AccountID}
+
+	// 4. Score the account and apply the cheat policies it reaches
+	if event.AccountID != nil {
+		score, reviews, err := ProcessPlayerEvent(&event)
+		if err != nil {
+			log.Printf("RedEye: failed to score anticheat event %d: %v", event.ID, err)
+		}
+		if score != nil {
+			response["score"] = score.Score
+		}
+		response["actions"] = reviews
+	}
+
+	utils.WriteJSON(w, http.StatusOK, response)
 }
 
 func GetAnticheatEventsHandler(w http.ResponseWriter, r *http.Request) {
@@ -263,7 +309,7 @@ func GetRedEyeConfigHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	config := map[string]interface{}{}
-	keys := []string{"redeye.auto_ban_enabled", "redeye.auto_ban_threshold", "redeye.alert_enabled"}
+	keys := []string{"redeye.auto_ban_enabled", "redeye.auto_ban_threshold", "redeye.alert_enabled", "redeye.ban_durations", "redeye.score_half_life"}
 	for _, key := range keys {
 		// Note: we might need config package here, but for simplicity we assume database has GetConfigByKey
 		// Wait, I should use database.GetConfigByKey
@@ -272,6 +318,8 @@ func GetRedEyeConfigHandler(w http.ResponseWriter, r *http.Request) {
 			if key == "redeye.auto_ban_threshold" {
 				val, _ := strconv.Atoi(cfg.Value)
 				config[key] = val
+			} else if key == "redeye.ban_durations" || key == "redeye.score_half_life" {
+				config[key] = cfg.Value
 			} else {
 				config[key] = cfg.Value == "true"
 			}
@@ -297,6 +345,22 @@ func UpdateRedEyeConfigHandler(w http.ResponseWriter, r *http.Request) {
 		"redeye.auto_ban_enabled":   true,
 		"redeye.auto_ban_threshold": true,
 		"redeye.alert_enabled":      true,
+		"redeye.ban_durations":      true,
+		"redeye.score_half_life":    true,
+	}
+
+	// Reject malformed durations up front; syncConfig would only log and ignore them
+	if v, ok := payload["redeye.ban_durations"]; ok {
+		if _, err := parseBanDurations(fmt.Sprintf("%v", v)); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, "redeye.ban_durations: "+err.Error())
+			return
+		}
+	}
+	if v, ok := payload["redeye.score_half_life"]; ok {
+		if _, err := parseDuration(fmt.Sprintf("%v", v)); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, "redeye.score_half_life: "+err.Error())
+			return
+		}
 	}
 
 	for k, v := range payload {
@@ -330,6 +394,81 @@ func ListBannedIPsHandler(w http.ResponseWriter, r *http.Request) {
 	utils.WriteJSON(w, http.StatusOK, bans)
 }
 
+// BanIPHandler bans an IP or a CIDR range. Ranged bans are matched by the ban trie, so
+// one entry covers every address in the range.
+//
+// Request (JSON):
+//   - ip (required): IP or CIDR range, e.g. "203.0.113.0/24"
+//   - reason: Shown in the ban list
+//   - duration: How long the ban lasts, e.g. "24h" or "7d" (defaults to permanent)
+func BanIPHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	var req struct {
+		IP       string `json:"ip"`
+		Reason   string `json:"reason"`
+		Duration string `json:"duration"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	prefixes, err := parsePrefixes(req.IP)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(prefixes) != 1 || prefixes[0].Bits() == 0 {
+		utils.WriteError(w, r, http.StatusBadRequest, "refusing to ban every address")
+		return
+	}
+	// Store single IPs bare, as auto-bans do, and ranges in canonical form
+	ip := prefixes[0].String()
+	if prefixes[0].IsSingleIP() {
+		ip = prefixes[0].Addr().String()
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		if duration, err = parseDuration(req.Duration); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "Manual ban"
+	}
+
+	rep, err := database.GetIPReputation(database.DBConn, ip)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	now := time.Now().UTC()
+	rep.ReputationScore = 100
+	rep.LastSeen = now
+	rep.IsBanned = true
+	rep.BanReason = req.Reason
+	rep.BanCount++
+	rep.BanExpiresAt = nil
+	if duration > 0 {
+		expires := now.Add(duration)
+		rep.BanExpiresAt = &expires
+	}
+	if err := database.UpdateIPReputation(database.DBConn, rep); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := utils.BlockIPSystem(ip); err != nil {
+		log.Printf("RedEye: Failed to execute OS block for %s: %v", ip, err)
+	}
+
+	RefreshBanCache(database.DBConn)
+	utils.WriteJSON(w, http.StatusCreated, map[string]string{"status": "banned", "ip": ip})
+}
+
 func UnbanIPHandler(w http.ResponseWriter, r *http.Request) {
 	if database.DBConn == nil {
 		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
@@ -343,7 +482,7 @@ func UnbanIPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := database.UnbanIP(database.DBConn, ip); err != nil {
+	if err := liftIPBan(database.DBConn, ip); err != nil {
 		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
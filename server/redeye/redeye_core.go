@@ -3,11 +3,10 @@ package redeye
 import (
 	"fmt"
 	"log"
-	"net"
 	"net/http"
+	"net/netip"
 	"os/exec"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -29,13 +28,13 @@ const (
 	SignalTypeRateLimit = "RATE_LIMIT"
 	SignalTypeReport    = "REPORT"
 	SignalTypeAuthFail  = "AUTH_FAIL"
+	SignalTypeLogOnly   = "LOG_ONLY" // A LOG_ONLY rule matched; the request was not affected
 )
 
 var (
-	// State Caches
-	BannedIPCache = make(map[string]bool)
-	RuleCache     = []models.RedEyeRule{}
-	BanCacheMu    sync.RWMutex
+	// State Caches; bans and compiled rules live in the firewall snapshot
+	RuleCache  = []models.RedEyeRule{}
+	BanCacheMu sync.RWMutex // Guards RuleCache
 	
 	// System Status
 	RedEyeActive = false
@@ -48,9 +47,11 @@ var (
 	configMu       sync.RWMutex
 	autoBanEnabled = true
 	banThreshold   = 100
+	banDurations   = defaultBanDurations
+	scoreHalfLife  = defaultHalfLife
 	
-	// IP Reputation Tracker (In-Memory)
-	ipScores   = make(map[string]int)
+	// IP Reputation Tracker (In-Memory), decaying with scoreHalfLife
+	ipScores   = make(map[string]*ipScore)
 	scoreMu    sync.RWMutex
 
 	// Rate Limiters
@@ -140,6 +141,7 @@ func StartRedEyeBackground(db *sqlx.DB) {
 
 	go analysisLoop(db)
 	go maintenanceLoop(db)
+	go feedLoop(db)
 }
 
 // StopRedEye gracefully shuts down the engine and flushes logs
@@ -186,7 +188,7 @@ func analysisLoop(db *sqlx.DB) {
 			enabled := autoBanEnabled
 			configMu.RUnlock()
 
-			if enabled && newScore >= limit {
+			if enabled && sig.Severity > 0 && newScore >= limit {
 				go banIP(db, sig.IP, fmt.Sprintf("Auto-ban: Reputation %d exceeded threshold. Trigger: %s", newScore, sig.Type))
 			}
 
@@ -217,16 +219,6 @@ func analysisLoop(db *sqlx.DB) {
 	}
 }
 
-func updateScore(ip string, change int) int {
-	scoreMu.Lock()
-	defer scoreMu.Unlock()
-	if ip == "127.0.0.1" || ip == "::1" {
-		return 0
-	}
-	ipScores[ip] += change
-	return ipScores[ip]
-}
-
 func maintenanceLoop(db *sqlx.DB) {
 	wg.Add(1)
 	defer wg.Done()
@@ -242,19 +234,12 @@ func maintenanceLoop(db *sqlx.DB) {
 			return
 
 		case <-decayTicker.C:
-			scoreMu.Lock()
-			for ip, score := range ipScores {
-				if score > 0 {
-					ipScores[ip] = score / 2
-					if ipScores[ip] < 5 {
-						delete(ipScores, ip)
-					}
-				}
-			}
-			scoreMu.Unlock()
+			decayScores(time.Now())
 			cleanupLimiters()
 
 		case <-syncTicker.C:
+			flushFeedHits(db)
+			expireBans(db)
 			RefreshBanCache(db)
 			syncConfig(db)
 		}
@@ -272,17 +257,43 @@ func RefreshBanCache(db *sqlx.DB) {
 		log.Printf("RedEye: Failed to refresh rule cache: %v", err)
 	}
 
-	newCache := make(map[string]bool)
-	for _, ip := range ips {
-		newCache[ip] = true
+	bans, errs := buildBanTrie(ips)
+	var compiled []compiledRule
+	if err == nil {
+		var ruleErrs []error
+		compiled, ruleErrs = compileRules(rules)
+		errs = append(errs, ruleErrs...)
+	} else {
+		// Keep the rules we have; the snapshot in use must not be re-sorted in place
+		compiled = append([]compiledRule(nil), currentFirewall().rules...)
+	}
+	feeds, feedNames := currentFirewall().feeds, currentFirewall().feedNames
+	if entries, feedErr := database.GetActiveFeedEntries(db); feedErr != nil {
+		log.Printf("RedEye: Failed to refresh feed cache: %v", feedErr)
+	} else if list, feedErr := database.GetRedEyeFeeds(db); feedErr != nil {
+		log.Printf("RedEye: Failed to refresh feed cache: %v", feedErr)
+	} else {
+		var feedErrs []error
+		feeds, feedErrs = buildFeedTrie(entries)
+		errs = append(errs, feedErrs...)
+		feedNames = make(map[int]string, len(list))
+		for _, f := range list {
+			feedNames[f.ID] = f.Name
+		}
+	}
+
+	for _, e := range errs {
+		log.Printf("RedEye: Skipping %v", e)
 	}
 
-	BanCacheMu.Lock()
-	BannedIPCache = newCache
 	if err == nil {
+		BanCacheMu.Lock()
 		RuleCache = rules
+		BanCacheMu.Unlock()
 	}
-	BanCacheMu.Unlock()
+	f := newFirewall(compiled, bans)
+	f.feeds, f.feedNames = feeds, feedNames
+	activeFirewall.Store(f)
 
 	registry.GlobalStats.UpdateRedEyeActiveBans(len(ips))
 }
@@ -294,39 +305,44 @@ func banIP(db *sqlx.DB, ip string, reason string) {
 	}
 	defer banningIPs.Delete(ip)
 
-	BanCacheMu.RLock()
-	if BannedIPCache[ip] {
-		BanCacheMu.RUnlock()
-		return
+	if addr, err := netip.ParseAddr(ip); err == nil {
+		if _, banned := currentFirewall().banned(addr); banned {
+			return
+		}
 	}
-	BanCacheMu.RUnlock()
 
-	log.Printf("RedEye: BANNING %s - %s", ip, reason)
+	// Repeat offenders get longer bans
+	rep, err := database.GetIPReputation(db, ip)
+	if err != nil {
+		log.Printf("RedEye: Failed to load reputation for %s: %v", ip, err)
+		return
+	}
+	configMu.RLock()
+	duration := banDuration(banDurations, rep.BanCount+1)
+	configMu.RUnlock()
+
+	now := time.Now().UTC()
+	rep.ReputationScore = 100
+	rep.TotalEvents++
+	rep.LastSeen = now
+	rep.IsBanned = true
+	rep.BanReason = reason
+	rep.BanCount++
+	rep.BanExpiresAt = nil
+	if duration > 0 {
+		expires := now.Add(duration)
+		rep.BanExpiresAt = &expires
+		log.Printf("RedEye: BANNING %s for %s (ban #%d) - %s", ip, duration, rep.BanCount, reason)
+	} else {
+		log.Printf("RedEye: BANNING %s permanently (ban #%d) - %s", ip, rep.BanCount, reason)
+	}
 
 	if err := utils.BlockIPSystem(ip); err != nil {
 		log.Printf("RedEye: Failed to execute OS block: %v", err)
 	}
-
-	rule := &models.RedEyeRule{
-		Name:      fmt.Sprintf("Auto-Ban %s", ip),
-		CIDR:      ip,
-		Port:      "*",
-		Protocol:  "ANY",
-		Action:    "DENY",
-		Enabled:   true,
-		CreatedAt: time.Now().UTC(),
-	}
-	database.CreateRedEyeRule(db, rule)
-
-	rep := &models.RedEyeIPReputation{
-		IP:              ip,
-		ReputationScore: 100,
-		TotalEvents:     1,
-		LastSeen:        time.Now().UTC(),
-		IsBanned:        true,
-		BanReason:       reason,
+	if err := database.UpdateIPReputation(db, rep); err != nil {
+		log.Printf("RedEye: Failed to record ban for %s: %v", ip, err)
 	}
-	database.UpdateIPReputation(db, rep)
 
 	RefreshBanCache(db)
 }
@@ -346,9 +362,29 @@ func syncConfig(db *sqlx.DB) {
 		}
 	}
 
+	durations := defaultBanDurations
+	if cfg, err := database.GetConfigByKey(db, "redeye.ban_durations"); err == nil && cfg != nil {
+		if d, err := parseBanDurations(cfg.Value); err == nil {
+			durations = d
+		} else {
+			log.Printf("RedEye: Ignoring redeye.ban_durations: %v", err)
+		}
+	}
+
+	halfLife := defaultHalfLife
+	if cfg, err := database.GetConfigByKey(db, "redeye.score_half_life"); err == nil && cfg != nil {
+		if d, err := parseDuration(cfg.Value); err == nil {
+			halfLife = d
+		} else {
+			log.Printf("RedEye: Ignoring redeye.score_half_life: %v", err)
+		}
+	}
+
 	configMu.Lock()
 	autoBanEnabled = enabled
 	banThreshold = threshold
+	banDurations = durations
+	scoreHalfLife = halfLife
 	configMu.Unlock()
 }
 
@@ -365,13 +401,15 @@ func RedEyeMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		BanCacheMu.RLock()
-		isBanned := BannedIPCache[clientIP]
-		rules := RuleCache
-		BanCacheMu.RUnlock()
+		f := currentFirewall()
+		addr, _ := netip.ParseAddr(clientIP)
 
-		if isBanned {
-			IngestSignal(clientIP, SignalTypeBlock, 0, "Blocked (Cached)")
+		if ban, isBanned := f.banned(addr); isBanned {
+			details := "Blocked (Cached)"
+			if ban != clientIP {
+				details = "Blocked (Range " + ban + ")"
+			}
+			IngestSignal(clientIP, SignalTypeBlock, 0, details)
 			http.Error(w, "Access Denied (Banned)", http.StatusForbidden)
 			return
 		}
@@ -380,27 +418,35 @@ func RedEyeMiddleware(next http.Handler) http.Handler {
 		rateLimited := false
 		var matchedRule *models.RedEyeRule
 
-		for i := range rules {
-			rule := &rules[i]
-			if !rule.Enabled {
-				continue
+		ev := f.evaluate(addr, getPort(r), r.Method, r.URL.Path, false)
+		for _, m := range ev.Chain {
+			if m.Rule.Action == "LOG_ONLY" {
+				IngestSignal(clientIP, SignalTypeLogOnly, 0, fmt.Sprintf("Log-only rule #%d (%s): %s %s", m.Rule.ID, m.Rule.Name, r.Method, r.URL.Path))
 			}
-			if ipMatch(clientIP, rule.CIDR) {
-				matchedRule = rule
-				if rule.Action == "DENY" {
-					blocked = true
-					break
-				}
-				if rule.Action == "RATE_LIMIT" {
-					if !checkRateLimit(clientIP, rule.RateLimit, rule.Burst) {
-						rateLimited = true
-						break
-					}
-					break
-				}
-				if rule.Action == "ALLOW" {
-					break
+		}
+		// Threat feeds block unless an ALLOW rule lets the client through
+		if ev.Rule == nil || ev.Rule.Action != "ALLOW" {
+			if feedIDs := f.listed(addr); len(feedIDs) > 0 {
+				for _, id := range feedIDs {
+					recordFeedHit(id)
 				}
+				// Logged without reputation impact: the feed already blocks the address,
+				// and escalating to a ban would put third-party lists into ufw
+				IngestSignal(clientIP, SignalTypeBlock, 0, "Feed: "+f.feedName(feedIDs[0]))
+				http.Error(w, "Access Denied (RedEye)", http.StatusForbidden)
+				return
+			}
+		}
+
+		if rule := ev.Rule; rule != nil {
+			matchedRule = rule
+			switch rule.Action {
+			case "DENY":
+				blocked = true
+			case "RATE_LIMIT":
+				// Each rule has its own bucket so a strict limit on one path does not
+				// eat into the allowance of another
+				rateLimited = !checkRateLimit(clientIP+"#"+strconv.Itoa(rule.ID), rule.RateLimit, rule.Burst)
 			}
 		}
 
@@ -423,29 +469,29 @@ func RedEyeMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// Rate Limiting Logic
+// Rate Limiting Logic (one token bucket per key)
 type rateLimiter struct {
 	tokens     float64
 	lastUpdate time.Time
 	mu         sync.Mutex
 }
 
-func checkRateLimit(ip string, limit int, burst int) bool {
+func checkRateLimit(key string, limit int, burst int) bool {
 	if limit <= 0 { return true }
 	if burst <= 0 { burst = limit }
 
 	limitMu.RLock()
-	lim, exists := limiters[ip]
+	lim, exists := limiters[key]
 	limitMu.RUnlock()
 
 	if !exists {
 		limitMu.Lock()
-		if lim, exists = limiters[ip]; !exists {
+		if lim, exists = limiters[key]; !exists {
 			lim = &rateLimiter{
 				tokens:     float64(burst),
 				lastUpdate: time.Now(),
 			}
-			limiters[ip] = lim
+			limiters[key] = lim
 		}
 		limitMu.Unlock()
 	}
@@ -478,30 +524,6 @@ func cleanupLimiters() {
 	limitMu.Unlock()
 }
 
-// Helpers
-func ipMatch(ip, cidr string) bool {
-	if cidr == "*" || cidr == "0.0.0.0/0" || cidr == "::/0" {
-		return true
-	}
-	if !strings.Contains(cidr, "/") {
-		return ip == cidr
-	}
-	_, ipnet, err := net.ParseCIDR(cidr)
-	if err != nil {
-		return false
-	}
-	return ipnet.Contains(net.ParseIP(ip))
-}
-
-func getPort(r *http.Request) int {
-	_, portStr, err := net.SplitHostPort(r.Host)
-	if err != nil {
-		return 80
-	}
-	p, _ := strconv.Atoi(portStr)
-	return p
-}
-
 // GetEngineStats returns real-time metrics from the memory engine
 func GetEngineStats() map[string]interface{} {
 	scoreMu.RLock()
@@ -510,13 +532,16 @@ func GetEngineStats() map[string]interface{} {
 	
 	BanCacheMu.RLock()
 	activeRules := len(RuleCache)
-	cachedBans := len(BannedIPCache)
 	BanCacheMu.RUnlock()
+	f := currentFirewall()
+	cachedBans := f.bans.Len()
+	cachedFeedEntries := f.feeds.Len()
 
 	return map[string]interface{}{
 		"active_trackers": activeTrackers,
 		"cached_rules":    activeRules,
 		"cached_bans":     cachedBans,
+		"cached_feeds":    cachedFeedEntries,
 		"queue_depth":     len(signalChan),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,179 @@
+package redeye
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"exile/server/database"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// -- Reputation Decay --
+
+// ipScore is an IP's reputation score as of updated. It decays exponentially, so it is
+// brought up to date whenever it is read.
+type ipScore struct {
+	value   float64
+	updated time.Time
+}
+
+// minTrackedScore is the score below which an IP is forgotten.
+const minTrackedScore = 5
+
+var (
+	defaultHalfLife     = 10 * time.Minute
+	defaultBanDurations = []time.Duration{time.Hour, 24 * time.Hour, 7 * 24 * time.Hour, 0}
+)
+
+// decayed returns a score of value after elapsed has passed. A non-positive half-life
+// disables decay.
+func decayed(value float64, elapsed, halfLife time.Duration) float64 {
+	if halfLife <= 0 || elapsed <= 0 {
+		return value
+	}
+	return value * math.Exp2(-float64(elapsed)/float64(halfLife))
+}
+
+// updateScore decays an IP's score to now, adds change and returns the new score.
+func updateScore(ip string, change int) int {
+	if ip == "127.0.0.1" || ip == "::1" {
+		return 0
+	}
+	configMu.RLock()
+	halfLife := scoreHalfLife
+	configMu.RUnlock()
+
+	now := time.Now()
+	scoreMu.Lock()
+	defer scoreMu.Unlock()
+	s, ok := ipScores[ip]
+	if !ok {
+		s = &ipScore{updated: now}
+		ipScores[ip] = s
+	}
+	s.value = decayed(s.value, now.Sub(s.updated), halfLife) + float64(change)
+	s.updated = now
+	return int(math.Round(s.value))
+}
+
+// decayScores forgets IPs whose score has decayed below minTrackedScore.
+func decayScores(now time.Time) {
+	configMu.RLock()
+	halfLife := scoreHalfLife
+	configMu.RUnlock()
+
+	scoreMu.Lock()
+	for ip, s := range ipScores {
+		if decayed(s.value, now.Sub(s.updated), halfLife) < minTrackedScore {
+			delete(ipScores, ip)
+		}
+	}
+	scoreMu.Unlock()
+}
+
+// resetScore forgets an IP's score, so a lifted ban does not trip again on old events.
+func resetScore(ip string) {
+	scoreMu.Lock()
+	delete(ipScores, ip)
+	scoreMu.Unlock()
+}
+
+// -- Ban Durations --
+
+// parseDuration parses a Go duration, with "d" accepted for days ("7d"). "permanent"
+// (or "perm", "0") returns 0.
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "permanent", "perm", "0":
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// parseBanDurations parses the escalation ladder, e.g. "1h,24h,7d,permanent". A
+// permanent step must be the last.
+func parseBanDurations(spec string) ([]time.Duration, error) {
+	var out []time.Duration
+	for _, part := range strings.Split(spec, ",") {
+		d, err := parseDuration(part)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > 0 && out[len(out)-1] == 0 {
+			return nil, fmt.Errorf("permanent must be the last ban duration")
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// banDuration returns how long the nth ban (1-based) of an IP lasts; 0 is permanent.
+// Bans past the end of the ladder repeat its last step.
+func banDuration(ladder []time.Duration, n int) time.Duration {
+	if len(ladder) == 0 {
+		return 0
+	}
+	return ladder[min(max(n, 1), len(ladder))-1]
+}
+
+// -- Ban Expiry --
+
+// liftIPBan clears an IP's ban and OS block, and removes the DENY rule older versions
+// created alongside each auto-ban, which would otherwise keep blocking the IP.
+func liftIPBan(db *sqlx.DB, ip string) error {
+	if err := database.UnbanIP(db, ip); err != nil {
+		return err
+	}
+	BanCacheMu.RLock()
+	var legacy []int
+	for _, rule := range RuleCache {
+		if rule.CIDR == ip && rule.Name == "Auto-Ban "+ip {
+			legacy = append(legacy, rule.ID)
+		}
+	}
+	BanCacheMu.RUnlock()
+	for _, id := range legacy {
+		if err := database.DeleteRedEyeRule(db, id); err != nil {
+			log.Printf("RedEye: Failed to remove auto-ban rule #%d for %s: %v", id, ip, err)
+		}
+	}
+	resetScore(ip)
+	return nil
+}
+
+// expireBans lifts timed bans that have run out.
+func expireBans(db *sqlx.DB) {
+	ips, err := database.GetExpiredBanList(db, time.Now().UTC())
+	if err != nil {
+		log.Printf("RedEye: Failed to list expired bans: %v", err)
+		return
+	}
+	if len(ips) == 0 {
+		return
+	}
+	for _, ip := range ips {
+		if err := liftIPBan(db, ip); err != nil {
+			log.Printf("RedEye: Failed to lift expired ban on %s: %v", ip, err)
+			continue
+		}
+		log.Printf("RedEye: Ban on %s expired", ip)
+	}
+	RefreshBanCache(db)
+}
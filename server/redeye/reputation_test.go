@@ -0,0 +1,93 @@
+package redeye
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseBanDurations(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    []time.Duration
+		wantErr bool
+	}{
+		{in: "1h,24h,7d,permanent", want: []time.Duration{time.Hour, 24 * time.Hour, 168 * time.Hour, 0}},
+		{in: "30m, 2h", want: []time.Duration{30 * time.Minute, 2 * time.Hour}},
+		{in: "perm", want: []time.Duration{0}},
+		{in: "1h,permanent,24h", wantErr: true},
+		{in: "1h,-5m", wantErr: true},
+		{in: "0d", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := parseBanDurations(c.in)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", c.in, err, c.wantErr)
+			continue
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%q: got %v, want %v", c.in, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%q: got %v, want %v", c.in, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestBanDurationEscalates(t *testing.T) {
+	ladder := []time.Duration{time.Hour, 24 * time.Hour, 168 * time.Hour, 0}
+	for n, want := range map[int]time.Duration{1: time.Hour, 2: 24 * time.Hour, 3: 168 * time.Hour, 4: 0, 9: 0} {
+		if got := banDuration(ladder, n); got != want {
+			t.Errorf("ban #%d: got %v, want %v", n, got, want)
+		}
+	}
+	// Without a permanent step the longest duration repeats
+	if got := banDuration([]time.Duration{time.Hour, 2 * time.Hour}, 5); got != 2*time.Hour {
+		t.Errorf("expected the last step to repeat, got %v", got)
+	}
+}
+
+func TestScoreDecay(t *testing.T) {
+	if got := decayed(80, 10*time.Minute, 10*time.Minute); math.Abs(got-40) > 1e-9 {
+		t.Errorf("one half-life: got %v, want 40", got)
+	}
+	if got := decayed(80, 30*time.Minute, 10*time.Minute); math.Abs(got-10) > 1e-9 {
+		t.Errorf("three half-lives: got %v, want 10", got)
+	}
+	if got := decayed(80, time.Hour, 0); got != 80 {
+		t.Errorf("decay disabled: got %v, want 80", got)
+	}
+
+	configMu.Lock()
+	prev := scoreHalfLife
+	scoreHalfLife = time.Minute
+	configMu.Unlock()
+	defer func() {
+		configMu.Lock()
+		scoreHalfLife = prev
+		configMu.Unlock()
+		resetScore("198.51.100.9")
+	}()
+
+	// Old events count for less, so they no longer add up to a ban
+	updateScore("198.51.100.9", 60)
+	scoreMu.Lock()
+	ipScores["198.51.100.9"].updated = time.Now().Add(-2 * time.Minute)
+	scoreMu.Unlock()
+	if got := updateScore("198.51.100.9", 60); got != 75 {
+		t.Errorf("expected 60/4 + 60 = 75, got %d", got)
+	}
+
+	decayScores(time.Now().Add(10 * time.Minute))
+	scoreMu.RLock()
+	_, tracked := ipScores["198.51.100.9"]
+	scoreMu.RUnlock()
+	if tracked {
+		t.Error("expected a decayed score to be forgotten")
+	}
+}
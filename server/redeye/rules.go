@@ -0,0 +1,334 @@
+package redeye
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"exile/server/models"
+)
+
+// portRange is an inclusive range of ports; a single port has lo == hi.
+type portRange struct {
+	lo, hi int
+}
+
+// compiledRule is a rule with its CIDR, port ranges, methods and path pattern parsed
+// once, so matching a request does no parsing. The compiled rules are cached alongside
+// RuleCache, in the firewall snapshot, and rebuilt with it.
+type compiledRule struct {
+	rule     *models.RedEyeRule
+	prefixes []netip.Prefix // One per address family the CIDR covers
+	bits     int            // CIDR prefix length; longer is more specific
+	ports    []portRange    // Nil matches any port
+	methods  []string       // Nil matches any method
+	prefix   string         // Path prefix, used when pattern is nil
+	pattern  *regexp.Regexp // Path regex
+	http     bool           // False for UDP/ICMP rules, which never match HTTP traffic
+}
+
+// parsePorts parses "80", "80-90", "80,443,8000-8100" or "*". An empty or "*" spec
+// matches any port and returns nil.
+func parsePorts(spec string) ([]portRange, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "*" {
+		return nil, nil
+	}
+	var ranges []portRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "*" {
+			return nil, nil
+		}
+		loStr, hiStr, isRange := strings.Cut(part, "-")
+		lo, err := strconv.Atoi(strings.TrimSpace(loStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(strings.TrimSpace(hiStr)); err != nil {
+				return nil, fmt.Errorf("invalid port range %q", part)
+			}
+		}
+		if lo < 1 || hi > 65535 || lo > hi {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, portRange{lo, hi})
+	}
+	return ranges, nil
+}
+
+// parseMethods parses "GET" or "GET,POST". An empty or "*" spec matches any method
+// and returns nil.
+func parseMethods(spec string) ([]string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "*" {
+		return nil, nil
+	}
+	var methods []string
+	for _, m := range strings.Split(spec, ",") {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m == "*" {
+			return nil, nil
+		}
+		if m == "" || strings.ContainsAny(m, " \t/") {
+			return nil, fmt.Errorf("invalid method %q", m)
+		}
+		methods = append(methods, m)
+	}
+	return methods, nil
+}
+
+// parsePath turns a path pattern into a prefix or a regex. Patterns without regex
+// metacharacters are prefixes, with a trailing "*" allowed ("/api/game/*"); anything
+// else is a regex matched against the path ("^/api/v[0-9]+/admin").
+func parsePath(spec string) (string, *regexp.Regexp, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "*" {
+		return "", nil, nil
+	}
+	if prefix := strings.TrimSuffix(spec, "*"); regexp.QuoteMeta(prefix) == prefix {
+		return prefix, nil, nil
+	}
+	re, err := regexp.Compile(spec)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid path pattern: %v", err)
+	}
+	return "", re, nil
+}
+
+// compileRule parses a rule's match fields.
+func compileRule(rule *models.RedEyeRule) (*compiledRule, error) {
+	c := &compiledRule{rule: rule}
+	switch strings.ToUpper(strings.TrimSpace(rule.Protocol)) {
+	case "", "ANY", "TCP":
+		c.http = true
+	case "UDP", "ICMP":
+	default:
+		return nil, fmt.Errorf("protocol must be TCP, UDP, ICMP or ANY")
+	}
+	var err error
+	if c.prefixes, err = parsePrefixes(rule.CIDR); err != nil {
+		return nil, err
+	}
+	c.bits = c.prefixes[0].Bits()
+	if c.ports, err = parsePorts(rule.Port); err != nil {
+		return nil, err
+	}
+	if c.methods, err = parseMethods(rule.Method); err != nil {
+		return nil, err
+	}
+	if c.prefix, c.pattern, err = parsePath(rule.PathPattern); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validateRule checks a rule before it is stored.
+func validateRule(rule *models.RedEyeRule) error {
+	if rule.CIDR == "" {
+		return fmt.Errorf("CIDR is required")
+	}
+	switch rule.Action {
+	case "ALLOW", "DENY", "RATE_LIMIT", "LOG_ONLY":
+	default:
+		return fmt.Errorf("Action must be ALLOW, DENY, RATE_LIMIT or LOG_ONLY")
+	}
+	_, err := compileRule(rule)
+	return err
+}
+
+// compileRules compiles the enabled rules. Rules that no longer compile are skipped.
+func compileRules(rules []models.RedEyeRule) ([]compiledRule, []error) {
+	out := make([]compiledRule, 0, len(rules))
+	var errs []error
+	for i := range rules {
+		if !rules[i].Enabled {
+			continue
+		}
+		c, err := compileRule(&rules[i])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %d (%s): %w", rules[i].ID, rules[i].Name, err))
+			continue
+		}
+		out = append(out, *c)
+	}
+	return out, errs
+}
+
+// matchesRequest reports whether a request to port falls under the rule. The client IP
+// is matched by the firewall's trie.
+func (c *compiledRule) matchesRequest(port int, method, path string) bool {
+	if !c.http {
+		return false
+	}
+	if c.ports != nil {
+		inRange := false
+		for _, pr := range c.ports {
+			if port >= pr.lo && port <= pr.hi {
+				inRange = true
+				break
+			}
+		}
+		if !inRange {
+			return false
+		}
+	}
+	if c.methods != nil {
+		found := false
+		for _, m := range c.methods {
+			if m == method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.pattern != nil {
+		return c.pattern.MatchString(path)
+	}
+	return strings.HasPrefix(path, c.prefix)
+}
+
+// getPort returns the port a request came in on: the listener's port, else the port in
+// the Host header, else the scheme's default.
+func getPort(r *http.Request) int {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if _, portStr, err := net.SplitHostPort(addr.String()); err == nil {
+			if p, err := strconv.Atoi(portStr); err == nil {
+				return p
+			}
+		}
+	}
+	if _, portStr, err := net.SplitHostPort(r.Host); err == nil {
+		if p, err := strconv.Atoi(portStr); err == nil {
+			return p
+		}
+	}
+	if r.TLS != nil {
+		return 443
+	}
+	return 80
+}
+
+// RuleMatch is a rule that matched a request, in evaluation order.
+type RuleMatch struct {
+	Rule     *models.RedEyeRule `json:"rule"`
+	Applied  bool               `json:"applied"`  // This rule decided the request
+	Shadowed bool               `json:"shadowed"` // Matched after the deciding rule, so it has no effect
+}
+
+// Evaluation is how RedEye handles a request.
+type Evaluation struct {
+	Decision string             `json:"decision"`        // ALLOW, DENY or RATE_LIMIT
+	Reason   string             `json:"reason"`          // rule, banned, feed, loopback or default
+	Rule     *models.RedEyeRule `json:"rule,omitempty"`  // The deciding rule
+	Feeds    []string           `json:"feeds,omitempty"` // Threat feeds listing the IP
+	Chain    []RuleMatch        `json:"chain"`
+}
+
+// ruleOrder reports whether a is evaluated before b: lower priority first, then the
+// more specific CIDR, then lower ID. A rule without an ID (a candidate being simulated)
+// goes after the stored rules it ties with, where it would land once created.
+func ruleOrder(a, b *compiledRule) bool {
+	if a.rule.Priority != b.rule.Priority {
+		return a.rule.Priority < b.rule.Priority
+	}
+	if a.bits != b.bits {
+		return a.bits > b.bits
+	}
+	if a.rule.ID == 0 || b.rule.ID == 0 {
+		return b.rule.ID == 0 && a.rule.ID != 0
+	}
+	return a.rule.ID < b.rule.ID
+}
+
+// sortRules puts compiled rules in evaluation order.
+func sortRules(rules []compiledRule) {
+	sort.SliceStable(rules, func(i, j int) bool { return ruleOrder(&rules[i], &rules[j]) })
+}
+
+// SimulatedRequest is a hypothetical request to run through the rules.
+type SimulatedRequest struct {
+	IP     string             `json:"ip"`
+	Port   int                `json:"port"`
+	Method string             `json:"method"`
+	Path   string             `json:"path"`
+	Rule   *models.RedEyeRule `json:"rule,omitempty"` // Candidate rule, evaluated as if saved and enabled
+}
+
+// Evaluate runs a hypothetical request through the cached bans and rules the way
+// RedEyeMiddleware would, listing every matching rule. Rate limits report the rule that
+// applies without using any tokens. A candidate rule with the ID of a stored rule
+// replaces it; without an ID it is added.
+func Evaluate(req SimulatedRequest) (*Evaluation, error) {
+	addr, err := netip.ParseAddr(req.IP)
+	if err != nil {
+		return nil, fmt.Errorf("ip must be an IP address")
+	}
+	if req.Port == 0 {
+		req.Port = 80
+	}
+	if req.Port < 1 || req.Port > 65535 {
+		return nil, fmt.Errorf("port must be between 1 and 65535")
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	req.Method = strings.ToUpper(req.Method)
+	if req.Path == "" {
+		req.Path = "/"
+	}
+
+	f := currentFirewall()
+	if req.Rule != nil {
+		if err := validateRule(req.Rule); err != nil {
+			return nil, fmt.Errorf("rule: %v", err)
+		}
+		candidate, _ := compileRule(req.Rule)
+		rules := make([]compiledRule, 0, len(f.rules)+1)
+		for _, c := range f.rules {
+			if req.Rule.ID == 0 || c.rule.ID != req.Rule.ID {
+				rules = append(rules, c)
+			}
+		}
+		withCandidate := newFirewall(append(rules, *candidate), f.bans)
+		withCandidate.feeds, withCandidate.feedNames = f.feeds, f.feedNames
+		f = withCandidate
+	}
+
+	if req.IP == "127.0.0.1" || req.IP == "::1" {
+		return &Evaluation{Decision: "ALLOW", Reason: "loopback", Chain: []RuleMatch{}}, nil
+	}
+	ev := f.evaluate(addr, req.Port, req.Method, req.Path, true)
+	for _, id := range f.listed(addr) {
+		ev.Feeds = append(ev.Feeds, f.feedName(id))
+	}
+	_, banned := f.banned(addr)
+	switch {
+	case banned:
+		// The ban cache is checked before any rule
+		shadowChain(ev)
+		ev.Decision, ev.Reason, ev.Rule = "DENY", "banned", nil
+	case len(ev.Feeds) > 0 && (ev.Rule == nil || ev.Rule.Action != "ALLOW"):
+		shadowChain(ev)
+		ev.Decision, ev.Reason, ev.Rule = "DENY", "feed", nil
+	}
+	return ev, nil
+}
+
+// shadowChain marks every matched rule as shadowed, for requests decided before the
+// rules.
+func shadowChain(ev *Evaluation) {
+	for i := range ev.Chain {
+		ev.Chain[i].Applied, ev.Chain[i].Shadowed = false, true
+	}
+}
@@ -0,0 +1,336 @@
+package redeye
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"exile/server/models"
+)
+
+func TestParsePorts(t *testing.T) {
+	cases := []struct {
+		spec    string
+		want    []portRange
+		wantErr bool
+	}{
+		{spec: "", want: nil},
+		{spec: "*", want: nil},
+		{spec: "80", want: []portRange{{80, 80}}},
+		{spec: "8000-8100", want: []portRange{{8000, 8100}}},
+		{spec: "80, 443,7000-7010", want: []portRange{{80, 80}, {443, 443}, {7000, 7010}}},
+		{spec: "80,*", want: nil},
+		{spec: "http", wantErr: true},
+		{spec: "90-80", wantErr: true},
+		{spec: "0", wantErr: true},
+		{spec: "65536", wantErr: true},
+		{spec: "80-", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := parsePorts(c.spec)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", c.spec, err, c.wantErr)
+			continue
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%q: got %v, want %v", c.spec, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%q: got %v, want %v", c.spec, got, c.want)
+			}
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		spec       string
+		wantPrefix string
+		wantRegex  bool
+		wantErr    bool
+	}{
+		{spec: "", wantPrefix: ""},
+		{spec: "*", wantPrefix: ""},
+		{spec: "/api/game/*", wantPrefix: "/api/game/"},
+		{spec: "/api/game", wantPrefix: "/api/game"},
+		{spec: "^/api/v[0-9]+/admin", wantRegex: true},
+		{spec: "/login$", wantRegex: true},
+		{spec: "/api/(", wantErr: true},
+	}
+	for _, c := range cases {
+		prefix, re, err := parsePath(c.spec)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", c.spec, err, c.wantErr)
+			continue
+		}
+		if c.wantErr {
+			continue
+		}
+		if prefix != c.wantPrefix || (re != nil) != c.wantRegex {
+			t.Errorf("%q: got prefix %q regex %v", c.spec, prefix, re)
+		}
+	}
+}
+
+func TestCompileRuleRejectsInvalidFields(t *testing.T) {
+	cases := []struct {
+		name string
+		rule models.RedEyeRule
+	}{
+		{"bad protocol", models.RedEyeRule{Protocol: "SCTP"}},
+		{"bad port", models.RedEyeRule{Port: "eighty"}},
+		{"bad method", models.RedEyeRule{Method: "GET,,POST"}},
+		{"bad regex", models.RedEyeRule{PathPattern: "^/api/(["}},
+		{"bad cidr", models.RedEyeRule{CIDR: "10.0.0.0/33"}},
+		{"not an ip", models.RedEyeRule{CIDR: "example.com"}},
+	}
+	for _, c := range cases {
+		if c.rule.CIDR == "" {
+			c.rule.CIDR = "*"
+		}
+		if _, err := compileRule(&c.rule); err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+	}
+}
+
+func TestCompiledRuleMatches(t *testing.T) {
+	type request struct {
+		ip     string
+		port   int
+		method string
+		path   string
+	}
+	cases := []struct {
+		name  string
+		rule  models.RedEyeRule
+		req   request
+		match bool
+	}{
+		// CIDR only (host-wide)
+		{"any rule matches", models.RedEyeRule{CIDR: "*", Port: "*", Protocol: "ANY"}, request{"1.2.3.4", 8080, "GET", "/"}, true},
+		{"ip outside cidr", models.RedEyeRule{CIDR: "10.0.0.0/8"}, request{"1.2.3.4", 8080, "GET", "/"}, false},
+		{"ip inside cidr", models.RedEyeRule{CIDR: "10.0.0.0/8"}, request{"10.1.2.3", 8080, "GET", "/"}, true},
+
+		// Port
+		{"single port hit", models.RedEyeRule{CIDR: "*", Port: "8080"}, request{"1.2.3.4", 8080, "GET", "/"}, true},
+		{"single port miss", models.RedEyeRule{CIDR: "*", Port: "443"}, request{"1.2.3.4", 8080, "GET", "/"}, false},
+		{"port range hit", models.RedEyeRule{CIDR: "*", Port: "8000-8100"}, request{"1.2.3.4", 8100, "GET", "/"}, true},
+		{"port range miss", models.RedEyeRule{CIDR: "*", Port: "8000-8100"}, request{"1.2.3.4", 8101, "GET", "/"}, false},
+		{"port list hit", models.RedEyeRule{CIDR: "*", Port: "80,443"}, request{"1.2.3.4", 443, "GET", "/"}, true},
+
+		// Protocol
+		{"tcp matches http", models.RedEyeRule{CIDR: "*", Protocol: "tcp"}, request{"1.2.3.4", 80, "GET", "/"}, true},
+		{"udp never matches http", models.RedEyeRule{CIDR: "*", Protocol: "UDP"}, request{"1.2.3.4", 80, "GET", "/"}, false},
+		{"icmp never matches http", models.RedEyeRule{CIDR: "*", Protocol: "ICMP"}, request{"1.2.3.4", 80, "GET", "/"}, false},
+
+		// Method
+		{"method hit", models.RedEyeRule{CIDR: "*", Method: "POST"}, request{"1.2.3.4", 80, "POST", "/"}, true},
+		{"method miss", models.RedEyeRule{CIDR: "*", Method: "POST"}, request{"1.2.3.4", 80, "GET", "/"}, false},
+		{"method list is case-insensitive", models.RedEyeRule{CIDR: "*", Method: "get, put"}, request{"1.2.3.4", 80, "PUT", "/"}, true},
+
+		// Path
+		{"prefix hit", models.RedEyeRule{CIDR: "*", PathPattern: "/api/game/*"}, request{"1.2.3.4", 80, "GET", "/api/game/auth"}, true},
+		{"prefix miss", models.RedEyeRule{CIDR: "*", PathPattern: "/api/game/*"}, request{"1.2.3.4", 80, "GET", "/api/admin/players"}, false},
+		{"regex hit", models.RedEyeRule{CIDR: "*", PathPattern: "^/api/v[0-9]+/admin"}, request{"1.2.3.4", 80, "GET", "/api/v2/admin/x"}, true},
+		{"regex miss", models.RedEyeRule{CIDR: "*", PathPattern: "^/api/v[0-9]+/admin"}, request{"1.2.3.4", 80, "GET", "/api/vx/admin"}, false},
+
+		// Combinations
+		{"all fields hit", models.RedEyeRule{CIDR: "10.0.0.0/8", Port: "8080", Protocol: "TCP", Method: "POST", PathPattern: "/api/game/*"}, request{"10.0.0.1", 8080, "POST", "/api/game/reports"}, true},
+		{"all fields, wrong method", models.RedEyeRule{CIDR: "10.0.0.0/8", Port: "8080", Protocol: "TCP", Method: "POST", PathPattern: "/api/game/*"}, request{"10.0.0.1", 8080, "GET", "/api/game/reports"}, false},
+		{"all fields, wrong port", models.RedEyeRule{CIDR: "10.0.0.0/8", Port: "8080", Protocol: "TCP", Method: "POST", PathPattern: "/api/game/*"}, request{"10.0.0.1", 8443, "POST", "/api/game/reports"}, false},
+		{"all fields, wrong path", models.RedEyeRule{CIDR: "10.0.0.0/8", Port: "8080", Protocol: "TCP", Method: "POST", PathPattern: "/api/game/*"}, request{"10.0.0.1", 8080, "POST", "/api/admin"}, false},
+		{"all fields, wrong ip", models.RedEyeRule{CIDR: "10.0.0.0/8", Port: "8080", Protocol: "TCP", Method: "POST", PathPattern: "/api/game/*"}, request{"192.168.0.1", 8080, "POST", "/api/game/reports"}, false},
+	}
+	for _, c := range cases {
+		rule := c.rule
+		rule.Action = "DENY"
+		compiled, err := compileRule(&rule)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		f := newFirewall([]compiledRule{*compiled}, newIPTrie[string]())
+		ev := f.evaluate(netip.MustParseAddr(c.req.ip), c.req.port, c.req.method, c.req.path, false)
+		if got := ev.Rule != nil; got != c.match {
+			t.Errorf("%s: matches = %v, want %v", c.name, got, c.match)
+		}
+	}
+}
+
+func TestEvaluateRulesOrder(t *testing.T) {
+	rules := []models.RedEyeRule{
+		{ID: 1, CIDR: "*", Action: "DENY", Enabled: false},
+		{ID: 2, CIDR: "*", PathPattern: "/api/*", Action: "RATE_LIMIT", Priority: 10, Enabled: true},
+		{ID: 3, CIDR: "*", PathPattern: "/api/game/*", Action: "DENY", Priority: 5, Enabled: true},
+		{ID: 4, CIDR: "*", Protocol: "BOGUS", Action: "DENY", Enabled: true},
+		{ID: 5, CIDR: "*", PathPattern: "/api/game/*", Action: "ALLOW", Priority: 5, Enabled: true},
+		{ID: 6, CIDR: "*", PathPattern: "/api/*", Action: "LOG_ONLY", Enabled: true},
+	}
+	compiled, errs := compileRules(rules)
+	if len(compiled) != 4 || len(errs) != 1 {
+		t.Fatalf("expected 4 compiled rules and 1 error, got %d and %v", len(compiled), errs)
+	}
+	f := newFirewall(compiled, newIPTrie[string]())
+	var order []int
+	for _, c := range f.rules {
+		order = append(order, c.rule.ID)
+	}
+	if fmt.Sprint(order) != "[6 3 5 2]" {
+		t.Fatalf("rules should be ordered by priority then ID, got %v", order)
+	}
+
+	cases := []struct {
+		path     string
+		decision string
+		rule     int
+		chain    string // ID and state of each match: a = applied, s = shadowed, l = logged
+	}{
+		{"/api/game/auth", "DENY", 3, "6l 3a 5s 2s"},
+		{"/api/admin", "RATE_LIMIT", 2, "6l 2a"},
+		{"/index.html", "ALLOW", 0, ""},
+	}
+	for _, c := range cases {
+		ev := f.evaluate(netip.MustParseAddr("1.2.3.4"), 80, "GET", c.path, true)
+		if ev.Decision != c.decision {
+			t.Errorf("%s: decision %s, want %s", c.path, ev.Decision, c.decision)
+		}
+		if (c.rule == 0) != (ev.Rule == nil) || (ev.Rule != nil && ev.Rule.ID != c.rule) {
+			t.Errorf("%s: deciding rule %v, want %d", c.path, ev.Rule, c.rule)
+		}
+		if got := describeChain(ev.Chain); got != c.chain {
+			t.Errorf("%s: chain %q, want %q", c.path, got, c.chain)
+		}
+	}
+
+	// The middleware stops at the deciding rule
+	if ev := f.evaluate(netip.MustParseAddr("1.2.3.4"), 80, "GET", "/api/game/auth", false); describeChain(ev.Chain) != "6l 3a" {
+		t.Errorf("short evaluation should stop at the deciding rule, got %q", describeChain(ev.Chain))
+	}
+}
+
+func describeChain(chain []RuleMatch) string {
+	var parts []string
+	for _, m := range chain {
+		state := "l"
+		if m.Applied {
+			state = "a"
+		} else if m.Shadowed {
+			state = "s"
+		}
+		parts = append(parts, fmt.Sprintf("%d%s", m.Rule.ID, state))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestRuleOrder(t *testing.T) {
+	rule := func(id, priority, bits int) *compiledRule {
+		return &compiledRule{rule: &models.RedEyeRule{ID: id, Priority: priority}, bits: bits}
+	}
+	stored := rule(9, 5, 32)
+	if candidate := rule(0, 5, 32); !ruleOrder(stored, candidate) || ruleOrder(candidate, stored) {
+		t.Error("a candidate should run after the stored rules it ties with")
+	}
+	if !ruleOrder(rule(0, 1, 0), stored) {
+		t.Error("a lower priority should run first")
+	}
+	if !ruleOrder(rule(12, 5, 32), rule(3, 5, 24)) {
+		t.Error("a more specific CIDR should run first within a priority")
+	}
+}
+
+func TestEvaluateWithCandidate(t *testing.T) {
+	compiled, _ := compileRules([]models.RedEyeRule{
+		{ID: 1, CIDR: "10.0.0.0/8", Action: "ALLOW", Priority: 0, Enabled: true},
+		{ID: 2, CIDR: "*", PathPattern: "/api/game/*", Action: "LOG_ONLY", Priority: 10, Enabled: true},
+	})
+	bans, _ := buildBanTrie([]string{"6.6.6.6"})
+	old := activeFirewall.Swap(newFirewall(compiled, bans))
+	defer activeFirewall.Store(old)
+
+	cases := []struct {
+		name     string
+		req      SimulatedRequest
+		decision string
+		reason   string
+		chain    string
+	}{
+		{"log only", SimulatedRequest{IP: "1.2.3.4", Path: "/api/game/auth"}, "ALLOW", "default", "2l"},
+		{"allowed range", SimulatedRequest{IP: "10.0.0.1", Path: "/api/game/auth"}, "ALLOW", "rule", "1a 2s"},
+		{"banned", SimulatedRequest{IP: "6.6.6.6", Path: "/api/game/auth"}, "DENY", "banned", "2s"},
+		{"loopback", SimulatedRequest{IP: "127.0.0.1"}, "ALLOW", "loopback", ""},
+		{"new deny rule", SimulatedRequest{IP: "1.2.3.4", Method: "post", Path: "/api/game/reports",
+			Rule: &models.RedEyeRule{CIDR: "*", Method: "POST", Action: "DENY", Priority: 10}}, "DENY", "rule", "2l 0a"},
+		{"edited rule replaces stored one", SimulatedRequest{IP: "1.2.3.4", Path: "/api/game/auth",
+			Rule: &models.RedEyeRule{ID: 2, CIDR: "*", PathPattern: "/api/game/*", Action: "DENY", Priority: 10}}, "DENY", "rule", "2a"},
+	}
+	for _, c := range cases {
+		ev, err := Evaluate(c.req)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if ev.Decision != c.decision || ev.Reason != c.reason || describeChain(ev.Chain) != c.chain {
+			t.Errorf("%s: got %s/%s chain %q, want %s/%s chain %q", c.name, ev.Decision, ev.Reason, describeChain(ev.Chain), c.decision, c.reason, c.chain)
+		}
+	}
+
+	if _, err := Evaluate(SimulatedRequest{IP: "not-an-ip"}); err == nil {
+		t.Error("an invalid IP should be rejected")
+	}
+	if _, err := Evaluate(SimulatedRequest{IP: "1.2.3.4", Rule: &models.RedEyeRule{CIDR: "*", Action: "BLOCK"}}); err == nil {
+		t.Error("an invalid candidate rule should be rejected")
+	}
+}
+
+func TestGetPort(t *testing.T) {
+	withLocal := httptest.NewRequest("GET", "http://example.com/", nil)
+	withLocal = withLocal.WithContext(context.WithValue(withLocal.Context(), http.LocalAddrContextKey,
+		&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8443}))
+
+	withHost := httptest.NewRequest("GET", "http://example.com:9000/", nil)
+	plain := httptest.NewRequest("GET", "http://example.com/", nil)
+	secure := httptest.NewRequest("GET", "https://example.com/", nil)
+	secure.TLS = &tls.ConnectionState{}
+
+	cases := []struct {
+		name string
+		port int
+		got  int
+	}{
+		{"listener address", 8443, getPort(withLocal)},
+		{"host header", 9000, getPort(withHost)},
+		{"http default", 80, getPort(plain)},
+		{"https default", 443, getPort(secure)},
+	}
+	for _, c := range cases {
+		if c.got != c.port {
+			t.Errorf("%s: got %d, want %d", c.name, c.got, c.port)
+		}
+	}
+}
+
+func TestIsHostWide(t *testing.T) {
+	cases := []struct {
+		rule models.RedEyeRule
+		want bool
+	}{
+		{models.RedEyeRule{Port: "*", Protocol: "ANY"}, true},
+		{models.RedEyeRule{}, true},
+		{models.RedEyeRule{Port: "*", Protocol: "ANY", PathPattern: "/api/game/*"}, false},
+		{models.RedEyeRule{Port: "8080", Protocol: "ANY"}, false},
+		{models.RedEyeRule{Port: "*", Protocol: "TCP"}, false},
+		{models.RedEyeRule{Port: "*", Protocol: "ANY", Method: "POST"}, false},
+	}
+	for _, c := range cases {
+		if got := c.rule.IsHostWide(); got != c.want {
+			t.Errorf("%+v: IsHostWide = %v, want %v", c.rule, got, c.want)
+		}
+	}
+}
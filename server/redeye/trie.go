@@ -0,0 +1,155 @@
+package redeye
+
+import (
+	"fmt"
+	"math/bits"
+	"net/netip"
+	"strings"
+)
+
+// ipTrie is a path-compressed binary radix trie over IPv4 and IPv6 prefixes. A lookup
+// visits at most one node per distinct prefix length on the path to the address, no
+// matter how many prefixes are stored. It is built once and then only read, so it is
+// safe for concurrent lookups.
+type ipTrie[T any] struct {
+	v4, v6   *trieNode[T]
+	prefixes int
+}
+
+type trieNode[T any] struct {
+	prefix netip.Prefix // Masked; children extend it by at least one bit
+	child  [2]*trieNode[T]
+	values []T // Empty on nodes that only join two branches
+}
+
+func newIPTrie[T any]() *ipTrie[T] {
+	return &ipTrie[T]{}
+}
+
+// bitAt returns bit i of addr, counting from the most significant bit.
+func bitAt(addr netip.Addr, i int) int {
+	b := addr.AsSlice()
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
+
+// commonBits returns how many leading bits two prefixes of the same family share, up to
+// the shorter prefix length.
+func commonBits(a, b netip.Prefix) int {
+	limit := min(a.Bits(), b.Bits())
+	x, y := a.Addr().AsSlice(), b.Addr().AsSlice()
+	n := 0
+	for i := range x {
+		if d := x[i] ^ y[i]; d != 0 {
+			n += bits.LeadingZeros8(d)
+			break
+		}
+		n += 8
+	}
+	return min(n, limit)
+}
+
+func (t *ipTrie[T]) root(addr netip.Addr) **trieNode[T] {
+	if addr.Is4() {
+		return &t.v4
+	}
+	return &t.v6
+}
+
+// Insert adds a value under a prefix. IPv4-mapped IPv6 prefixes are stored as IPv4.
+func (t *ipTrie[T]) Insert(p netip.Prefix, v T) {
+	p = normalizePrefix(p)
+	t.prefixes++
+	n := t.root(p.Addr())
+	for {
+		cur := *n
+		if cur == nil {
+			*n = &trieNode[T]{prefix: p, values: []T{v}}
+			return
+		}
+		common := commonBits(cur.prefix, p)
+		switch {
+		case common == cur.prefix.Bits() && common == p.Bits():
+			cur.values = append(cur.values, v)
+			return
+		case common == cur.prefix.Bits():
+			// p is inside cur: descend
+			n = &cur.child[bitAt(p.Addr(), common)]
+		case common == p.Bits():
+			// p contains cur: p becomes cur's parent
+			node := &trieNode[T]{prefix: p, values: []T{v}}
+			node.child[bitAt(cur.prefix.Addr(), common)] = cur
+			*n = node
+			return
+		default:
+			// Diverging branches: join them under their common prefix
+			glue := &trieNode[T]{prefix: netip.PrefixFrom(p.Addr(), common).Masked()}
+			glue.child[bitAt(cur.prefix.Addr(), common)] = cur
+			glue.child[bitAt(p.Addr(), common)] = &trieNode[T]{prefix: p, values: []T{v}}
+			*n = glue
+			return
+		}
+	}
+}
+
+// Lookup calls fn for every stored prefix containing addr, from the least to the most
+// specific.
+func (t *ipTrie[T]) Lookup(addr netip.Addr, fn func(p netip.Prefix, values []T)) {
+	if !addr.IsValid() {
+		return
+	}
+	addr = addr.Unmap().WithZone("")
+	n := *t.root(addr)
+	for n != nil && n.prefix.Contains(addr) {
+		if len(n.values) > 0 {
+			fn(n.prefix, n.values)
+		}
+		if n.prefix.Bits() == addr.BitLen() {
+			return
+		}
+		n = n.child[bitAt(addr, n.prefix.Bits())]
+	}
+}
+
+// MostSpecific returns the longest stored prefix containing addr and its values.
+func (t *ipTrie[T]) MostSpecific(addr netip.Addr) (netip.Prefix, []T, bool) {
+	var best netip.Prefix
+	var values []T
+	t.Lookup(addr, func(p netip.Prefix, v []T) { best, values = p, v })
+	return best, values, values != nil
+}
+
+// Len returns the number of inserted prefixes.
+func (t *ipTrie[T]) Len() int {
+	return t.prefixes
+}
+
+// normalizePrefix masks p and turns IPv4-mapped IPv6 prefixes into IPv4 ones.
+func normalizePrefix(p netip.Prefix) netip.Prefix {
+	if a := p.Addr(); a.Is4In6() && p.Bits() >= 96 {
+		p = netip.PrefixFrom(a.Unmap(), p.Bits()-96)
+	}
+	return p.Masked()
+}
+
+// parsePrefixes parses a rule CIDR or ban entry: an IP, a CIDR, or "*" (also
+// "0.0.0.0/0" and "::/0"), which matches every address of both families.
+func parsePrefixes(s string) ([]netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "*", "0.0.0.0/0", "::/0":
+		return []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")}, nil
+	}
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", s)
+		}
+		addr = addr.Unmap().WithZone("")
+		return []netip.Prefix{netip.PrefixFrom(addr, addr.BitLen())}, nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP or CIDR %q", s)
+	}
+	return []netip.Prefix{normalizePrefix(p)}, nil
+}
@@ -0,0 +1,224 @@
+package redeye
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"exile/server/models"
+)
+
+func TestIPTrieLookup(t *testing.T) {
+	trie := newIPTrie[string]()
+	for _, p := range []string{"10.0.0.0/8", "10.1.0.0/16", "10.1.2.3/32", "192.168.0.0/24", "2001:db8::/32", "2001:db8:1::/48"} {
+		trie.Insert(netip.MustParsePrefix(p), p)
+	}
+	// Inserting a shorter prefix after its sub-prefixes exercises the parent case
+	trie.Insert(netip.MustParsePrefix("10.0.0.0/7"), "10.0.0.0/7")
+
+	cases := []struct {
+		addr string
+		want string // Matching prefixes, least to most specific
+	}{
+		{"10.1.2.3", "10.0.0.0/7 10.0.0.0/8 10.1.0.0/16 10.1.2.3/32"},
+		{"10.1.9.9", "10.0.0.0/7 10.0.0.0/8 10.1.0.0/16"},
+		{"11.0.0.1", "10.0.0.0/7"},
+		{"10.200.0.1", "10.0.0.0/7 10.0.0.0/8"},
+		{"192.168.0.77", "192.168.0.0/24"},
+		{"192.168.1.1", ""},
+		{"::ffff:10.1.2.3", "10.0.0.0/7 10.0.0.0/8 10.1.0.0/16 10.1.2.3/32"},
+		{"2001:db8:1::5", "2001:db8::/32 2001:db8:1::/48"},
+		{"2001:db8:2::5", "2001:db8::/32"},
+		{"2001:db9::1", ""},
+	}
+	for _, c := range cases {
+		var got []string
+		trie.Lookup(netip.MustParseAddr(c.addr), func(p netip.Prefix, values []string) {
+			got = append(got, values...)
+		})
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("%s: got %q, want %q", c.addr, strings.Join(got, " "), c.want)
+		}
+	}
+
+	if p, _, ok := trie.MostSpecific(netip.MustParseAddr("10.1.7.7")); !ok || p.String() != "10.1.0.0/16" {
+		t.Errorf("most specific prefix of 10.1.7.7: got %v", p)
+	}
+	if trie.Len() != 7 {
+		t.Errorf("expected 7 prefixes, got %d", trie.Len())
+	}
+}
+
+func TestParsePrefixes(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "*", want: "0.0.0.0/0 ::/0"},
+		{in: "0.0.0.0/0", want: "0.0.0.0/0 ::/0"},
+		{in: "1.2.3.4", want: "1.2.3.4/32"},
+		{in: "10.1.2.3/8", want: "10.0.0.0/8"},
+		{in: "::ffff:10.0.0.0/104", want: "10.0.0.0/8"},
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "10.0.0.0/33", wantErr: true},
+		{in: "banned.example", wantErr: true},
+	}
+	for _, c := range cases {
+		prefixes, err := parsePrefixes(c.in)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", c.in, err, c.wantErr)
+			continue
+		}
+		var got []string
+		for _, p := range prefixes {
+			got = append(got, p.String())
+		}
+		if strings.Join(got, " ") != c.want {
+			t.Errorf("%q: got %v, want %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRangedBans(t *testing.T) {
+	bans, errs := buildBanTrie([]string{"203.0.113.0/24", "198.51.100.7", "2001:db8::/32", "bogus"})
+	if len(errs) != 1 {
+		t.Fatalf("expected the bogus entry to be skipped, got %v", errs)
+	}
+	f := newFirewall(nil, bans)
+	cases := []struct {
+		addr string
+		ban  string
+	}{
+		{"203.0.113.250", "203.0.113.0/24"},
+		{"198.51.100.7", "198.51.100.7"},
+		{"198.51.100.8", ""},
+		{"2001:db8:ffff::1", "2001:db8::/32"},
+		{"2001:db9::1", ""},
+	}
+	for _, c := range cases {
+		ban, ok := f.banned(netip.MustParseAddr(c.addr))
+		if ban != c.ban || ok != (c.ban != "") {
+			t.Errorf("%s: got %q %v, want %q", c.addr, ban, ok, c.ban)
+		}
+	}
+}
+
+func TestMostSpecificRuleWins(t *testing.T) {
+	compiled, errs := compileRules([]models.RedEyeRule{
+		{ID: 1, CIDR: "10.0.0.0/8", Action: "DENY", Enabled: true},
+		{ID: 2, CIDR: "10.1.0.0/16", Action: "ALLOW", Enabled: true},
+		{ID: 3, CIDR: "10.1.2.3", Action: "RATE_LIMIT", Enabled: true},
+		{ID: 4, CIDR: "*", Action: "DENY", Priority: -1, PathPattern: "/admin", Enabled: true},
+	})
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	f := newFirewall(compiled, newIPTrie[string]())
+
+	cases := []struct {
+		addr string
+		path string
+		want int
+	}{
+		{"10.1.2.3", "/", 3},
+		{"10.1.2.4", "/", 2},
+		{"10.2.0.1", "/", 1},
+		{"10.1.2.3", "/admin", 4}, // Priority beats specificity
+		{"172.16.0.1", "/", 0},
+	}
+	for _, c := range cases {
+		ev := f.evaluate(netip.MustParseAddr(c.addr), 80, "GET", c.path, false)
+		switch {
+		case c.want == 0 && ev.Rule != nil:
+			t.Errorf("%s %s: expected no rule, got %d", c.addr, c.path, ev.Rule.ID)
+		case c.want != 0 && (ev.Rule == nil || ev.Rule.ID != c.want):
+			t.Errorf("%s %s: expected rule %d, got %v", c.addr, c.path, c.want, ev.Rule)
+		}
+	}
+}
+
+// -- Benchmarks --
+
+// linearIPMatch is the matching RedEye did before the trie: the CIDR is parsed for every
+// rule on every request.
+func linearIPMatch(ip, cidr string) bool {
+	if cidr == "*" || cidr == "0.0.0.0/0" || cidr == "::/0" {
+		return true
+	}
+	if !strings.Contains(cidr, "/") {
+		return ip == cidr
+	}
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	return ipnet.Contains(net.ParseIP(ip))
+}
+
+// benchmarkSet builds n auto-ban style /32 DENY rules, n/10 ranged rules and n bans,
+// the shape of a server with many auto-bans and an imported blocklist.
+func benchmarkSet(n int) ([]models.RedEyeRule, []string) {
+	rules := make([]models.RedEyeRule, 0, n+n/10)
+	bans := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		ip := fmt.Sprintf("100.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		rules = append(rules, models.RedEyeRule{ID: i + 1, CIDR: ip, Port: "*", Protocol: "ANY", Action: "DENY", Enabled: true})
+		bans = append(bans, fmt.Sprintf("101.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff))
+	}
+	for i := 0; i < n/10; i++ {
+		rules = append(rules, models.RedEyeRule{ID: n + i + 1, CIDR: fmt.Sprintf("%d.%d.0.0/16", 20+i/256, i%256), Action: "DENY", Enabled: true})
+	}
+	return rules, bans
+}
+
+// A client that matches nothing is the common case and the worst case for the linear
+// scan, which has to try every rule.
+const benchClient = "8.8.8.8"
+
+func benchmarkLinear(b *testing.B, n int) {
+	rules, banList := benchmarkSet(n)
+	bans := make(map[string]bool, len(banList))
+	for _, ip := range banList {
+		bans[ip] = true
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if bans[benchClient] {
+			b.Fatal("unexpected ban")
+		}
+		for j := range rules {
+			if rules[j].Enabled && linearIPMatch(benchClient, rules[j].CIDR) {
+				b.Fatal("unexpected match")
+			}
+		}
+	}
+}
+
+func benchmarkTrie(b *testing.B, n int) {
+	rules, banList := benchmarkSet(n)
+	compiled, _ := compileRules(rules)
+	bans, _ := buildBanTrie(banList)
+	f := newFirewall(compiled, bans)
+	addr := netip.MustParseAddr(benchClient)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, banned := f.banned(addr); banned {
+			b.Fatal("unexpected ban")
+		}
+		if ev := f.evaluate(addr, 80, "GET", "/api/game/servers", false); ev.Rule != nil {
+			b.Fatal("unexpected match")
+		}
+	}
+}
+
+func BenchmarkLinearScan100(b *testing.B)   { benchmarkLinear(b, 100) }
+func BenchmarkLinearScan1000(b *testing.B)  { benchmarkLinear(b, 1000) }
+func BenchmarkLinearScan10000(b *testing.B) { benchmarkLinear(b, 10000) }
+func BenchmarkTrie100(b *testing.B)         { benchmarkTrie(b, 100) }
+func BenchmarkTrie1000(b *testing.B)        { benchmarkTrie(b, 1000) }
+func BenchmarkTrie10000(b *testing.B)       { benchmarkTrie(b, 10000) }
@@ -0,0 +1,344 @@
+package releases
+
+import (
+	"net/http"
+	"strings"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/registry"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Channel Handlers --
+
+func ListChannelsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	channels, err := database.ListReleaseChannels(database.DBConn)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, channels)
+}
+
+func CreateChannelHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	var c models.ReleaseChannel
+	if err := utils.DecodeJSON(r, &c); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := validateChannel(&c); msg != "" {
+		utils.WriteError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	// Rollout state is driven through the rollout endpoints, not set directly
+	c.ID = 0
+	c.VersionID = 0
+	c.RolloutPercent = 0
+	c.Status = StatusIdle
+	c.StatusReason = ""
+
+	if _, err := database.SaveReleaseChannel(database.DBConn, &c); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, c)
+}
+
+func UpdateChannelHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req models.ReleaseChannel
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := validateChannel(&req); msg != "" {
+		utils.WriteError(w, r, http.StatusBadRequest, msg)
+		return
+	}
+
+	rolloutMu.Lock()
+	defer rolloutMu.Unlock()
+
+	c, err := database.GetReleaseChannel(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if c == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "channel not found")
+		return
+	}
+
+	// Only subscription and policy fields are editable here
+	c.Name = req.Name
+	c.Priority = req.Priority
+	c.Regions = req.Regions
+	c.Tags = req.Tags
+	c.PreviousVersionID = req.PreviousVersionID
+	c.WaveStep = req.WaveStep
+	c.WaveIntervalSeconds = req.WaveIntervalSeconds
+	c.AutoRollback = req.AutoRollback
+	c.MaxFailureRate = req.MaxFailureRate
+
+	if _, err := database.SaveReleaseChannel(database.DBConn, c); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, c)
+}
+
+func DeleteChannelHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := database.DeleteReleaseChannel(database.DBConn, id); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// -- Rollout Handlers --
+
+func StartRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req struct {
+		VersionID      int `json:"version_id"`
+		InitialPercent int `json:"initial_percent"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c, err := StartRollout(database.DBConn, id, req.VersionID, req.InitialPercent)
+	if err != nil {
+		writeRolloutError(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, c)
+}
+
+func SetRolloutPercentHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req struct {
+		Percent int `json:"percent"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c, err := SetRolloutPercent(database.DBConn, id, req.Percent)
+	if err != nil {
+		writeRolloutError(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, c)
+}
+
+func PauseRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	setPaused(w, r, true)
+}
+
+func ResumeRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	setPaused(w, r, false)
+}
+
+func setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c, err := SetPaused(database.DBConn, id, paused)
+	if err != nil {
+		writeRolloutError(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, c)
+}
+
+func RollbackHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c, err := Rollback(database.DBConn, id, "manual rollback")
+	if err != nil {
+		writeRolloutError(w, r, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, c)
+}
+
+// GetChannelHealthHandler reports the live health numbers the auto-rollback decision is based on.
+func GetChannelHealthHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	channels, err := database.ListReleaseChannels(database.DBConn)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for i := range channels {
+		if channels[i].ID == id {
+			utils.WriteJSON(w, http.StatusOK, EvaluateHealth(channels, &channels[i]))
+			return
+		}
+	}
+
+	utils.WriteError(w, r, http.StatusNotFound, "channel not found")
+}
+
+// ListNodeAssignmentsHandler shows which channel and version every node resolves to.
+func ListNodeAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	channels, err := database.ListReleaseChannels(database.DBConn)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	type assignment struct {
+		NodeID          int    `json:"node_id"`
+		NodeName        string `json:"node_name"`
+		Channel         string `json:"channel"`
+		Bucket          int    `json:"bucket"`
+		InRollout       bool   `json:"in_rollout"`
+		TargetVersionID int    `json:"target_version_id"` // 0 = global active version
+		RunningVersion  string `json:"running_version"`
+	}
+
+	out := []assignment{}
+	for _, n := range registry.GlobalRegistry.List() {
+		a := assignment{NodeID: n.ID, NodeName: n.Name, RunningVersion: n.GameVersion}
+		if c := SelectChannel(channels, &n); c != nil {
+			a.Channel = c.Name
+			a.Bucket = Bucket(c.ID, n.ID)
+			a.InRollout = InRollout(c, n.ID)
+			a.TargetVersionID = TargetVersionID(c, n.ID)
+		}
+		out = append(out, a)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, out)
+}
+
+func validateChannel(c *models.ReleaseChannel) string {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return "name is required"
+	}
+	if strings.TrimSpace(c.Regions) == "" && strings.TrimSpace(c.Tags) == "" {
+		return "at least one region or tag is required"
+	}
+	if c.WaveStep < 0 || c.WaveStep > 100 {
+		return "wave_step must be between 0 and 100"
+	}
+	if c.WaveIntervalSeconds < 0 {
+		return "wave_interval_seconds must not be negative"
+	}
+	if c.MaxFailureRate < 0 || c.MaxFailureRate > 1 {
+		return "max_failure_rate must be between 0 and 1"
+	}
+	return ""
+}
+
+func writeRolloutError(w http.ResponseWriter, r *http.Request, err error) {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		utils.WriteError(w, r, http.StatusNotFound, msg)
+	case strings.HasPrefix(msg, "channel "), strings.HasSuffix(msg, "failed package validation"):
+		utils.WriteError(w, r, http.StatusConflict, msg)
+	default:
+		utils.WriteError(w, r, http.StatusInternalServerError, msg)
+	}
+}
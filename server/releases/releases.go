@@ -0,0 +1,466 @@
+package releases
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/gamepkg"
+	"exile/server/models"
+	"exile/server/registry"
+	"exile/server/utils"
+	"exile/server/ws"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// =================================================================================
+// RELEASE CHANNELS: per-group versions with staged (wave) rollouts
+// =================================================================================
+
+const (
+	StatusIdle       = "idle"
+	StatusRollingOut = "rolling_out"
+	StatusPaused     = "paused"
+	StatusCompleted  = "completed"
+	StatusRolledBack = "rolled_back"
+
+	monitorInterval = 30 * time.Second
+)
+
+var (
+	// Serializes rollout state transitions (manual API calls vs. the monitor loop)
+	rolloutMu sync.Mutex
+
+	done    chan struct{}
+	wg      sync.WaitGroup
+	running bool
+)
+
+// RolloutHealth summarises how the nodes already moved onto a channel's new version are doing.
+type RolloutHealth struct {
+	UpgradedNodes    int     `json:"upgraded_nodes"`
+	UnhealthyNodes   int     `json:"unhealthy_nodes"`
+	Instances        int     `json:"instances"`
+	CrashedInstances int     `json:"crashed_instances"`
+	FailureRate      float64 `json:"failure_rate"`
+}
+
+// Bucket returns the stable 0-99 rollout bucket of a node within a channel.
+// Hashing with the channel ID keeps canary nodes from always being the same machines.
+func Bucket(channelID, nodeID int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprintf("%d:%d", channelID, nodeID)))
+	return int(h.Sum32() % 100)
+}
+
+// Matches reports whether a node subscribes to a channel by region or tag.
+func Matches(c *models.ReleaseChannel, n *models.Node) bool {
+	for _, region := range utils.ParseTags(c.Regions) {
+		if strings.EqualFold(region, n.Region) {
+			return true
+		}
+	}
+	nodeTags := utils.ParseTags(n.Tags)
+	for _, want := range utils.ParseTags(c.Tags) {
+		for _, have := range nodeTags {
+			if want == have {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SelectChannel picks the highest-priority channel the node subscribes to.
+// Channels are expected in priority order (as returned by ListReleaseChannels).
+func SelectChannel(channels []models.ReleaseChannel, n *models.Node) *models.ReleaseChannel {
+	for i := range channels {
+		if Matches(&channels[i], n) {
+			return &channels[i]
+		}
+	}
+	return nil
+}
+
+// InRollout reports whether the node has been moved onto the channel's new version.
+func InRollout(c *models.ReleaseChannel, nodeID int) bool {
+	if c.VersionID == 0 || c.Status == StatusRolledBack {
+		return false
+	}
+	return Bucket(c.ID, nodeID) < c.RolloutPercent
+}
+
+// TargetVersionID returns the version a node on this channel should run (0 = global active version).
+func TargetVersionID(c *models.ReleaseChannel, nodeID int) int {
+	if InRollout(c, nodeID) {
+		return c.VersionID
+	}
+	return c.PreviousVersionID
+}
+
+// ResolveNodeVersion returns the version a node should download, falling back to the
+// globally active version when the node is not subscribed to a channel.
+func ResolveNodeVersion(db *sqlx.DB, node *models.Node) (*models.GameServerVersion, *models.ReleaseChannel, error) {
+	var channel *models.ReleaseChannel
+	if node != nil {
+		channels, err := database.ListReleaseChannels(db)
+		if err != nil {
+			return nil, nil, err
+		}
+		channel = SelectChannel(channels, node)
+	}
+
+	if channel != nil {
+		if id := TargetVersionID(channel, node.ID); id != 0 {
+			v, err := database.GetServerVersionByID(db, id)
+			if err != nil {
+				return nil, channel, err
+			}
+			if v != nil {
+				return v, channel, nil
+			}
+		}
+	}
+
+	v, err := database.GetActiveServerVersion(db)
+	return v, channel, err
+}
+
+// channelNodes returns the registered nodes whose effective channel is c.
+func channelNodes(channels []models.ReleaseChannel, c *models.ReleaseChannel) []models.Node {
+	out := []models.Node{}
+	for _, n := range registry.GlobalRegistry.List() {
+		if sel := SelectChannel(channels, &n); sel != nil && sel.ID == c.ID {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// notifyNodes asks nodes to re-check their template; the download endpoint serves the channel version.
+func notifyNodes(nodes []models.Node) {
+	for _, n := range nodes {
+		if n.Status != "Online" {
+			continue
+		}
+		if err := ws.GlobalWSManager.SendCommand(n.ID, "update_template", nil); err != nil {
+			log.Printf("Releases: failed to notify node %d: %v", n.ID, err)
+		}
+	}
+}
+
+// StartRollout begins rolling versionID out on the channel, starting at initialPercent.
+func StartRollout(db *sqlx.DB, channelID, versionID, initialPercent int) (*models.ReleaseChannel, error) {
+	rolloutMu.Lock()
+	defer rolloutMu.Unlock()
+
+	c, err := database.GetReleaseChannel(db, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("channel not found")
+	}
+	v, err := database.GetServerVersionByID(db, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("version not found")
+	}
+	// Only inspected, valid packages reach the fleet, as with HandleSetActiveVersion
+	switch v.ValidationStatus {
+	case gamepkg.StatusValid:
+	case gamepkg.StatusUnchecked:
+		return nil, fmt.Errorf("version %d has not been validated; run POST /api/versions/%d/validate first", versionID, versionID)
+	default:
+		return nil, fmt.Errorf("version %d failed package validation", versionID)
+	}
+
+	// Whatever the channel fully runs today becomes the fallback for nodes not yet in a wave.
+	if c.VersionID != 0 && c.RolloutPercent >= 100 && c.Status != StatusRolledBack {
+		c.PreviousVersionID = c.VersionID
+	} else if c.PreviousVersionID == 0 {
+		if active, _ := database.GetActiveServerVersion(db); active != nil {
+			c.PreviousVersionID = active.ID
+		}
+	}
+
+	c.VersionID = versionID
+	c.RolloutPercent = clampPercent(initialPercent)
+	c.Status = StatusRollingOut
+	c.StatusReason = ""
+	c.LastWaveAt = time.Now().UTC()
+	if _, err := database.SaveReleaseChannel(db, c); err != nil {
+		return nil, err
+	}
+
+	channels, _ := database.ListReleaseChannels(db)
+	notifyNodes(channelNodes(channels, c))
+	log.Printf("Releases: channel %s rolling out version %d at %d%%", c.Name, versionID, c.RolloutPercent)
+	return c, nil
+}
+
+// SetRolloutPercent moves the rollout to an explicit percentage and pushes newly included nodes.
+func SetRolloutPercent(db *sqlx.DB, channelID, percent int) (*models.ReleaseChannel, error) {
+	rolloutMu.Lock()
+	defer rolloutMu.Unlock()
+
+	c, err := database.GetReleaseChannel(db, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("channel not found")
+	}
+	if c.VersionID == 0 {
+		return nil, fmt.Errorf("channel has no version to roll out")
+	}
+	if err := applyPercent(db, c, clampPercent(percent)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// applyPercent persists a new rollout percentage and notifies every node whose target changed.
+func applyPercent(db *sqlx.DB, c *models.ReleaseChannel, percent int) error {
+	channels, err := database.ListReleaseChannels(db)
+	if err != nil {
+		return err
+	}
+	nodes := channelNodes(channels, c)
+	before := make(map[int]bool, len(nodes))
+	for _, n := range nodes {
+		before[n.ID] = InRollout(c, n.ID)
+	}
+
+	c.RolloutPercent = percent
+	if c.Status != StatusRollingOut {
+		c.Status = StatusRollingOut
+		c.StatusReason = ""
+	}
+	c.LastWaveAt = time.Now().UTC()
+	if _, err := database.SaveReleaseChannel(db, c); err != nil {
+		return err
+	}
+
+	changed := []models.Node{}
+	for _, n := range nodes {
+		if InRollout(c, n.ID) != before[n.ID] {
+			changed = append(changed, n)
+		}
+	}
+	notifyNodes(changed)
+	return nil
+}
+
+// SetPaused pauses or resumes automatic waves without changing what nodes run.
+func SetPaused(db *sqlx.DB, channelID int, paused bool) (*models.ReleaseChannel, error) {
+	rolloutMu.Lock()
+	defer rolloutMu.Unlock()
+
+	c, err := database.GetReleaseChannel(db, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("channel not found")
+	}
+	if paused && c.Status == StatusRollingOut {
+		c.Status = StatusPaused
+	} else if !paused && c.Status == StatusPaused {
+		c.Status = StatusRollingOut
+		c.LastWaveAt = time.Now().UTC()
+	} else {
+		return nil, fmt.Errorf("channel is %s", c.Status)
+	}
+	if _, err := database.SaveReleaseChannel(db, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Rollback returns every node on the channel to its previous version.
+func Rollback(db *sqlx.DB, channelID int, reason string) (*models.ReleaseChannel, error) {
+	rolloutMu.Lock()
+	defer rolloutMu.Unlock()
+
+	c, err := database.GetReleaseChannel(db, channelID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, fmt.Errorf("channel not found")
+	}
+	if err := rollback(db, c, reason); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func rollback(db *sqlx.DB, c *models.ReleaseChannel, reason string) error {
+	channels, err := database.ListReleaseChannels(db)
+	if err != nil {
+		return err
+	}
+	upgraded := []models.Node{}
+	for _, n := range channelNodes(channels, c) {
+		if InRollout(c, n.ID) {
+			upgraded = append(upgraded, n)
+		}
+	}
+
+	c.RolloutPercent = 0
+	c.Status = StatusRolledBack
+	c.StatusReason = reason
+	if _, err := database.SaveReleaseChannel(db, c); err != nil {
+		return err
+	}
+
+	log.Printf("Releases: channel %s rolled back from version %d: %s", c.Name, c.VersionID, reason)
+	notifyNodes(upgraded)
+	return nil
+}
+
+// EvaluateHealth inspects the nodes already on the channel's new version. Offline nodes count as
+// failed health checks; instances reported in the "Error" state count as crashes.
+func EvaluateHealth(channels []models.ReleaseChannel, c *models.ReleaseChannel) RolloutHealth {
+	var h RolloutHealth
+	for _, n := range channelNodes(channels, c) {
+		if !InRollout(c, n.ID) {
+			continue
+		}
+		h.UpgradedNodes++
+		if n.Status != "Online" && n.Status != "Updating" {
+			h.UnhealthyNodes++
+			continue
+		}
+
+		instances, err := ws.GlobalWSManager.ListInstances(n.ID, 5*time.Second)
+		if err != nil {
+			h.UnhealthyNodes++
+			continue
+		}
+		for _, inst := range instances {
+			h.Instances++
+			if inst.Status == "Error" {
+				h.CrashedInstances++
+			}
+		}
+	}
+
+	if h.UpgradedNodes > 0 {
+		h.FailureRate = float64(h.UnhealthyNodes) / float64(h.UpgradedNodes)
+	}
+	if h.Instances > 0 {
+		if crashRate := float64(h.CrashedInstances) / float64(h.Instances); crashRate > h.FailureRate {
+			h.FailureRate = crashRate
+		}
+	}
+	return h
+}
+
+// StartReleaseMonitor launches the background loop that advances waves and triggers rollbacks.
+func StartReleaseMonitor(db *sqlx.DB) {
+	if db == nil || running {
+		return
+	}
+	done = make(chan struct{})
+	running = true
+	wg.Add(1)
+	go monitorLoop(db)
+}
+
+// StopReleaseMonitor stops the background loop.
+func StopReleaseMonitor() {
+	if !running {
+		return
+	}
+	close(done)
+	wg.Wait()
+	running = false
+}
+
+func monitorLoop(db *sqlx.DB) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			checkRollouts(db)
+		}
+	}
+}
+
+func checkRollouts(db *sqlx.DB) {
+	channels, err := database.ListReleaseChannels(db)
+	if err != nil {
+		log.Printf("Releases: failed to load channels: %v", err)
+		return
+	}
+
+	for i := range channels {
+		c := channels[i]
+		if c.Status != StatusRollingOut {
+			continue
+		}
+
+		health := EvaluateHealth(channels, &c)
+		rolloutMu.Lock()
+		// Re-read so a concurrent manual change wins over this stale snapshot
+		current, err := database.GetReleaseChannel(db, c.ID)
+		if err != nil || current == nil || current.Status != StatusRollingOut {
+			rolloutMu.Unlock()
+			continue
+		}
+
+		if current.AutoRollback && health.UpgradedNodes > 0 && health.FailureRate > current.MaxFailureRate {
+			reason := fmt.Sprintf("auto-rollback: failure rate %.0f%% exceeded %.0f%% (%d/%d nodes unhealthy, %d/%d instances crashed)",
+				health.FailureRate*100, current.MaxFailureRate*100, health.UnhealthyNodes, health.UpgradedNodes, health.CrashedInstances, health.Instances)
+			if err := rollback(db, current, reason); err != nil {
+				log.Printf("Releases: rollback of %s failed: %v", current.Name, err)
+			}
+			rolloutMu.Unlock()
+			continue
+		}
+
+		soak := time.Duration(current.WaveIntervalSeconds) * time.Second
+		if time.Since(current.LastWaveAt) >= soak {
+			if current.RolloutPercent >= 100 {
+				current.Status = StatusCompleted
+				current.StatusReason = ""
+				if _, err := database.SaveReleaseChannel(db, current); err != nil {
+					log.Printf("Releases: failed to complete %s: %v", current.Name, err)
+				}
+			} else if current.WaveStep > 0 {
+				next := clampPercent(current.RolloutPercent + current.WaveStep)
+				if err := applyPercent(db, current, next); err != nil {
+					log.Printf("Releases: failed to advance %s: %v", current.Name, err)
+				} else {
+					log.Printf("Releases: channel %s advanced to %d%%", current.Name, next)
+				}
+			}
+		}
+		rolloutMu.Unlock()
+	}
+}
+
+func clampPercent(p int) int {
+	if p < 0 {
+		return 0
+	}
+	if p > 100 {
+		return 100
+	}
+	return p
+}
@@ -0,0 +1,72 @@
+package releases
+
+import (
+	"testing"
+
+	"exile/server/models"
+)
+
+func TestBucketIsStableAndInRange(t *testing.T) {
+	for nodeID := 1; nodeID <= 500; nodeID++ {
+		b := Bucket(7, nodeID)
+		if b < 0 || b > 99 {
+			t.Fatalf("bucket %d out of range for node %d", b, nodeID)
+		}
+		if b != Bucket(7, nodeID) {
+			t.Fatalf("bucket not stable for node %d", nodeID)
+		}
+	}
+}
+
+func TestRolloutGrowsMonotonically(t *testing.T) {
+	c := &models.ReleaseChannel{ID: 3, VersionID: 10, PreviousVersionID: 9, Status: StatusRollingOut}
+	prev := map[int]bool{}
+	for _, pct := range []int{0, 10, 25, 50, 100} {
+		c.RolloutPercent = pct
+		count := 0
+		for nodeID := 1; nodeID <= 200; nodeID++ {
+			in := InRollout(c, nodeID)
+			if prev[nodeID] && !in {
+				t.Fatalf("node %d left the rollout when moving to %d%%", nodeID, pct)
+			}
+			prev[nodeID] = in
+			if in {
+				count++
+			}
+		}
+		if pct == 0 && count != 0 {
+			t.Fatalf("expected no nodes at 0%%, got %d", count)
+		}
+		if pct == 100 && count != 200 {
+			t.Fatalf("expected all nodes at 100%%, got %d", count)
+		}
+	}
+}
+
+func TestTargetVersionAfterRollback(t *testing.T) {
+	c := &models.ReleaseChannel{ID: 1, VersionID: 10, PreviousVersionID: 9, RolloutPercent: 100, Status: StatusRolledBack}
+	if got := TargetVersionID(c, 42); got != 9 {
+		t.Fatalf("expected previous version 9 after rollback, got %d", got)
+	}
+}
+
+func TestSelectChannelByPriority(t *testing.T) {
+	channels := []models.ReleaseChannel{
+		{ID: 2, Name: "canary", Priority: 10, Tags: "canary"},
+		{ID: 1, Name: "stable", Priority: 0, Regions: "eu-west,us-east"},
+	}
+
+	canaryNode := &models.Node{ID: 1, Region: "EU-West", Tags: `["canary","ranked"]`}
+	if c := SelectChannel(channels, canaryNode); c == nil || c.Name != "canary" {
+		t.Fatalf("expected canary channel, got %+v", c)
+	}
+
+	stableNode := &models.Node{ID: 2, Region: "us-east", Tags: "ranked"}
+	if c := SelectChannel(channels, stableNode); c == nil || c.Name != "stable" {
+		t.Fatalf("expected stable channel, got %+v", c)
+	}
+
+	if c := SelectChannel(channels, &models.Node{ID: 3, Region: "ap-south"}); c != nil {
+		t.Fatalf("expected no channel, got %s", c.Name)
+	}
+}
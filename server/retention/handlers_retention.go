@@ -0,0 +1,95 @@
+package retention
+
+import (
+	"net/http"
+
+	"exile/server/database"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Retention Handlers --
+
+// GetRetentionHandler returns the active policy and the latest run report.
+func GetRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"policy":      GetPolicy(),
+		"last_report": LastReport(),
+	})
+}
+
+func UpdateRetentionPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	var p Policy
+	if err := utils.DecodeJSON(r, &p); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	SetPolicy(p)
+	utils.WriteJSON(w, http.StatusOK, GetPolicy())
+}
+
+// RunRetentionHandler triggers a run now. Without a body it is a dry run, so the
+// dashboard can preview what the policy would remove.
+func RunRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	req := struct {
+		DryRun *bool `json:"dry_run"`
+	}{}
+	if r.ContentLength > 0 {
+		if err := utils.DecodeJSON(r, &req); err != nil {
+			utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	dryRun := req.DryRun == nil || *req.DryRun
+
+	rep, err := Run(database.DBConn, dryRun)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, rep)
+}
+
+// PinVersionHandler protects a version from retention (or releases it).
+func PinVersionHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req struct {
+		Pinned bool `json:"pinned"`
+	}
+	if err := utils.DecodeJSON(r, &req); err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	v, err := database.GetServerVersionByID(database.DBConn, id)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if v == nil {
+		utils.WriteError(w, r, http.StatusNotFound, "version not found")
+		return
+	}
+	if err := database.SetServerVersionPinned(database.DBConn, id, req.Pinned); err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	v.Pinned = req.Pinned
+	utils.WriteJSON(w, http.StatusOK, v)
+}
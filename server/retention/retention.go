@@ -0,0 +1,341 @@
+package retention
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/registry"
+	"exile/server/utils"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// =================================================================================
+// VERSION RETENTION: garbage collection of uploaded builds in ./files
+// =================================================================================
+
+const (
+	filesDir = "files"
+
+	// Legacy package served when no version is active; never touched
+	fallbackPackage = "game_server.zip"
+
+	// Files younger than this are never treated as orphans: an upload may have been
+	// moved into place but not yet recorded in the database.
+	orphanGracePeriod = time.Hour
+)
+
+// Policy controls which versions are kept.
+type Policy struct {
+	KeepLast        int  `json:"keep_last"`        // Newest N versions are always kept
+	MaxAgeDays      int  `json:"max_age_days"`     // Older unprotected versions are deleted (0 = anything beyond keep_last)
+	IntervalSeconds int  `json:"interval_seconds"` // How often the background job runs
+	DryRun          bool `json:"dry_run"`          // Report only, delete nothing
+}
+
+// Decision explains what happened (or would happen) to a single version or file.
+type Decision struct {
+	VersionID int    `json:"version_id,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Filename  string `json:"filename"`
+	Bytes     int64  `json:"bytes,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// Report is the outcome of one retention run.
+type Report struct {
+	DryRun       bool       `json:"dry_run"`
+	Policy       Policy     `json:"policy"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   time.Time  `json:"finished_at"`
+	Kept         []Decision `json:"kept"`
+	Deleted      []Decision `json:"deleted"`       // Versions removed (or to be removed) by policy
+	OrphanFiles  []Decision `json:"orphan_files"`  // Files in ./files with no version row
+	MissingFiles []Decision `json:"missing_files"` // Version rows whose package is gone
+	FreedBytes   int64      `json:"freed_bytes"`
+	Errors       []string   `json:"errors,omitempty"`
+}
+
+var (
+	policyMu sync.RWMutex
+	policy   = Policy{KeepLast: 5, MaxAgeDays: 30, IntervalSeconds: 6 * 3600, DryRun: true}
+
+	// Only one run at a time (background job vs. manual trigger)
+	runMu      sync.Mutex
+	lastReport *Report
+
+	done    chan struct{}
+	wg      sync.WaitGroup
+	running bool
+)
+
+func (p Policy) interval() time.Duration {
+	return time.Duration(p.IntervalSeconds) * time.Second
+}
+
+// LoadPolicyFromEnv reads VERSION_RETENTION_* settings. Deletion is opt-in: the job
+// only reports until VERSION_RETENTION_DRY_RUN=false.
+func LoadPolicyFromEnv() {
+	p := Policy{
+		KeepLast:   utils.GetEnvInt("VERSION_RETENTION_KEEP_LAST", 5),
+		MaxAgeDays: utils.GetEnvInt("VERSION_RETENTION_MAX_AGE_DAYS", 30),
+		DryRun:     !strings.EqualFold(utils.GetEnv("VERSION_RETENTION_DRY_RUN", "true"), "false"),
+	}
+	p.IntervalSeconds = int(utils.GetEnvDuration("VERSION_RETENTION_INTERVAL", 6*time.Hour).Seconds())
+	SetPolicy(p)
+}
+
+// GetPolicy returns the current policy.
+func GetPolicy() Policy {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return policy
+}
+
+// SetPolicy replaces the policy; out-of-range values are clamped.
+func SetPolicy(p Policy) {
+	if p.KeepLast < 1 {
+		p.KeepLast = 1
+	}
+	if p.MaxAgeDays < 0 {
+		p.MaxAgeDays = 0
+	}
+	if p.IntervalSeconds < 60 {
+		p.IntervalSeconds = 60
+	}
+	policyMu.Lock()
+	policy = p
+	policyMu.Unlock()
+}
+
+// LastReport returns the most recent run, or nil if none has happened yet.
+func LastReport() *Report {
+	runMu.Lock()
+	defer runMu.Unlock()
+	return lastReport
+}
+
+// protectedVersions returns why each version must be kept regardless of age:
+// active, manually pinned, referenced by a release channel, or installed on a node.
+func protectedVersions(versions []models.GameServerVersion, channels []models.ReleaseChannel, nodes []models.Node) map[int]string {
+	byChannel := map[int]string{}
+	for _, c := range channels {
+		if c.VersionID != 0 {
+			byChannel[c.VersionID] = fmt.Sprintf("target of channel %q", c.Name)
+		}
+		if c.PreviousVersionID != 0 {
+			byChannel[c.PreviousVersionID] = fmt.Sprintf("rollback target of channel %q", c.Name)
+		}
+	}
+	onNodes := map[string]int{}
+	for _, n := range nodes {
+		if n.GameVersion != "" {
+			onNodes[n.GameVersion] = n.ID
+		}
+	}
+
+	out := map[int]string{}
+	for _, v := range versions {
+		switch {
+		case v.IsActive:
+			out[v.ID] = "active version"
+		case v.Pinned:
+			out[v.ID] = "pinned"
+		case byChannel[v.ID] != "":
+			out[v.ID] = byChannel[v.ID]
+		case v.Version != "" && onNodes[v.Version] != 0:
+			out[v.ID] = fmt.Sprintf("installed on node %d", onNodes[v.Version])
+		}
+	}
+	return out
+}
+
+// Plan decides which versions the policy removes. Versions must be sorted newest first.
+func Plan(p Policy, versions []models.GameServerVersion, protected map[int]string, now time.Time) (kept, deleted []Decision) {
+	cutoff := now.AddDate(0, 0, -p.MaxAgeDays)
+	for i, v := range versions {
+		d := Decision{VersionID: v.ID, Version: v.Version, Filename: v.Filename, Bytes: v.SizeBytes}
+		switch {
+		case protected[v.ID] != "":
+			d.Reason = protected[v.ID]
+		case i < p.KeepLast:
+			d.Reason = fmt.Sprintf("within newest %d", p.KeepLast)
+		case p.MaxAgeDays > 0 && v.UploadedAt.After(cutoff):
+			d.Reason = fmt.Sprintf("younger than %d days", p.MaxAgeDays)
+		default:
+			if p.MaxAgeDays > 0 {
+				d.Reason = fmt.Sprintf("older than %d days and beyond newest %d", p.MaxAgeDays, p.KeepLast)
+			} else {
+				d.Reason = fmt.Sprintf("beyond newest %d", p.KeepLast)
+			}
+			deleted = append(deleted, d)
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept, deleted
+}
+
+// Run applies the policy and reconciles ./files against the version table.
+func Run(db *sqlx.DB, dryRun bool) (*Report, error) {
+	runMu.Lock()
+	defer runMu.Unlock()
+
+	p := GetPolicy()
+	rep := &Report{DryRun: dryRun, Policy: p, StartedAt: time.Now().UTC(),
+		Kept: []Decision{}, Deleted: []Decision{}, OrphanFiles: []Decision{}, MissingFiles: []Decision{}}
+
+	versions, err := database.ListServerVersions(db)
+	if err != nil {
+		return nil, fmt.Errorf("list versions: %w", err)
+	}
+	channels, err := database.ListReleaseChannels(db)
+	if err != nil {
+		return nil, fmt.Errorf("list release channels: %w", err)
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].UploadedAt.After(versions[j].UploadedAt) })
+	protected := protectedVersions(versions, channels, registry.GlobalRegistry.List())
+
+	// Rows whose package vanished can't be served; drop them unless something still points at them
+	present := make([]models.GameServerVersion, 0, len(versions))
+	known := map[string]bool{}
+	for _, v := range versions {
+		known[v.Filename] = true
+		if _, err := os.Stat(filepath.Join(filesDir, v.Filename)); err == nil {
+			present = append(present, v)
+			continue
+		}
+		d := Decision{VersionID: v.ID, Version: v.Version, Filename: v.Filename}
+		if reason := protected[v.ID]; reason != "" {
+			d.Reason = "package missing but kept: " + reason
+			rep.Errors = append(rep.Errors, fmt.Sprintf("version %d (%s) is %s but its package is missing", v.ID, v.Filename, reason))
+		} else {
+			d.Reason = "package missing, row removed"
+			if !dryRun {
+				if _, err := database.DeleteServerVersion(db, v.ID); err != nil {
+					rep.Errors = append(rep.Errors, fmt.Sprintf("delete row %d: %v", v.ID, err))
+					d.Reason = "package missing, row removal failed"
+				}
+			}
+		}
+		rep.MissingFiles = append(rep.MissingFiles, d)
+	}
+
+	kept, deleted := Plan(p, present, protected, rep.StartedAt)
+	rep.Kept = append(rep.Kept, kept...)
+	for _, d := range deleted {
+		if !dryRun {
+			if err := deleteVersion(db, d); err != nil {
+				rep.Errors = append(rep.Errors, err.Error())
+				continue
+			}
+		}
+		rep.Deleted = append(rep.Deleted, d)
+		rep.FreedBytes += d.Bytes
+	}
+
+	for _, d := range findOrphans(known, rep.StartedAt) {
+		if !dryRun {
+			if err := os.Remove(filepath.Join(filesDir, d.Filename)); err != nil {
+				rep.Errors = append(rep.Errors, fmt.Sprintf("remove orphan %s: %v", d.Filename, err))
+				continue
+			}
+		}
+		rep.OrphanFiles = append(rep.OrphanFiles, d)
+		rep.FreedBytes += d.Bytes
+	}
+
+	rep.FinishedAt = time.Now().UTC()
+	lastReport = rep
+	return rep, nil
+}
+
+func deleteVersion(db *sqlx.DB, d Decision) error {
+	if _, err := database.DeleteServerVersion(db, d.VersionID); err != nil {
+		return fmt.Errorf("delete version %d: %w", d.VersionID, err)
+	}
+	if err := os.Remove(filepath.Join(filesDir, d.Filename)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove %s: %w", d.Filename, err)
+	}
+	return nil
+}
+
+// findOrphans lists regular files in ./files that no version row refers to.
+// The upload staging area and the legacy fallback package are skipped.
+func findOrphans(known map[string]bool, now time.Time) []Decision {
+	entries, err := os.ReadDir(filesDir)
+	if err != nil {
+		return nil
+	}
+	out := []Decision{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || name == fallbackPackage || known[name] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || now.Sub(info.ModTime()) < orphanGracePeriod {
+			continue
+		}
+		out = append(out, Decision{Filename: name, Bytes: info.Size(), Reason: "no version row"})
+	}
+	return out
+}
+
+// StartRetentionJob launches the periodic cleanup using the current policy.
+func StartRetentionJob(db *sqlx.DB) {
+	if db == nil || running {
+		return
+	}
+	done = make(chan struct{})
+	running = true
+	wg.Add(1)
+	go retentionLoop(db)
+}
+
+// StopRetentionJob stops the background loop.
+func StopRetentionJob() {
+	if !running {
+		return
+	}
+	close(done)
+	wg.Wait()
+	running = false
+}
+
+func retentionLoop(db *sqlx.DB) {
+	defer wg.Done()
+
+	timer := time.NewTimer(GetPolicy().interval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-timer.C:
+			p := GetPolicy()
+			rep, err := Run(db, p.DryRun)
+			if err != nil {
+				log.Printf("Retention: run failed: %v", err)
+			} else {
+				verb := "deleted"
+				if rep.DryRun {
+					verb = "would delete"
+				}
+				log.Printf("Retention: %s %d versions, %d orphan files (%d bytes); %d rows with missing packages",
+					verb, len(rep.Deleted), len(rep.OrphanFiles), rep.FreedBytes, len(rep.MissingFiles))
+			}
+			// Policy changes made through the API take effect from the next tick
+			timer.Reset(GetPolicy().interval())
+		}
+	}
+}
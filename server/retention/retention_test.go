@@ -0,0 +1,48 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"exile/server/models"
+)
+
+func TestPlan(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) time.Time { return now.AddDate(0, 0, -d) }
+
+	// Newest first, as Run sorts them
+	versions := []models.GameServerVersion{
+		{ID: 6, UploadedAt: daysAgo(1)},
+		{ID: 5, UploadedAt: daysAgo(5)},
+		{ID: 4, UploadedAt: daysAgo(20)},
+		{ID: 3, UploadedAt: daysAgo(40), IsActive: true},
+		{ID: 2, UploadedAt: daysAgo(50), Version: "1.0.2"},
+		{ID: 1, UploadedAt: daysAgo(60)},
+	}
+	channels := []models.ReleaseChannel{{Name: "canary", VersionID: 6, PreviousVersionID: 1}}
+	nodes := []models.Node{{ID: 9, GameVersion: "1.0.2"}}
+	protected := protectedVersions(versions, channels, nodes)
+
+	for id, want := range map[int]string{3: "active version", 6: `target of channel "canary"`, 1: `rollback target of channel "canary"`, 2: "installed on node 9"} {
+		if protected[id] != want {
+			t.Errorf("version %d: protected reason %q, want %q", id, protected[id], want)
+		}
+	}
+
+	_, deleted := Plan(Policy{KeepLast: 2, MaxAgeDays: 10}, versions, protected, now)
+	if len(deleted) != 1 || deleted[0].VersionID != 4 {
+		t.Fatalf("expected only version 4 to be deleted, got %+v", deleted)
+	}
+
+	// Without an age limit everything unprotected beyond keep_last goes
+	delete(protected, 2)
+	_, deleted = Plan(Policy{KeepLast: 1}, versions, protected, now)
+	ids := []int{}
+	for _, d := range deleted {
+		ids = append(ids, d.VersionID)
+	}
+	if len(ids) != 3 || ids[0] != 5 || ids[1] != 4 || ids[2] != 2 {
+		t.Fatalf("expected versions 5, 4, 2 to be deleted, got %v", ids)
+	}
+}
@@ -0,0 +1,71 @@
+package sessions
+
+import (
+	"net/http"
+	"strconv"
+
+	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// -- Session Handlers --
+
+// GetPlayerSessionsHandler returns a player's join/leave history, newest first.
+func GetPlayerSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBConn == nil {
+		utils.WriteError(w, r, http.StatusServiceUnavailable, "database not connected")
+		return
+	}
+	id, err := utils.ParseID(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	list, err := database.ListPlayerSessions(database.DBConn, int64(id), limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to retrieve sessions")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// GetInstanceRosterHandler returns the players currently on an instance and its recent sessions.
+func GetInstanceRosterHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := utils.ParseID(vars["id"])
+	if err != nil {
+		utils.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	instanceID := vars["instance_id"]
+	if instanceID == "" {
+		utils.WriteError(w, r, http.StatusBadRequest, "missing instance_id")
+		return
+	}
+
+	if database.DBConn == nil {
+		utils.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"players": []models.PlayerSession{},
+			"recent":  []models.PlayerSession{},
+		})
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	players, err := database.ListOpenSessions(database.DBConn, id, instanceID)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to retrieve roster")
+		return
+	}
+	recent, err := database.ListInstanceSessions(database.DBConn, id, instanceID, limit)
+	if err != nil {
+		utils.WriteError(w, r, http.StatusInternalServerError, "failed to retrieve sessions")
+		return
+	}
+	utils.WriteJSON(w, http.StatusOK, map[string]interface{}{"players": players, "recent": recent})
+}
@@ -0,0 +1,192 @@
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"exile/server/bans"
+	"exile/server/database"
+	"exile/server/jointicket"
+	"exile/server/models"
+	"exile/server/ws"
+	"exile/server/ws_player"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// =================================================================================
+// PLAYER SESSIONS: who played where, reported by game servers through their node
+// =================================================================================
+
+const (
+	// Node WS messages
+	MsgPlayerEvent  = "PLAYER_EVENT"  // A game server reported a join or leave
+	MsgPlayerRoster = "PLAYER_ROSTER" // Full list of connected players, sent by a node after (re)connecting
+
+	EventJoin  = "join"
+	EventLeave = "leave"
+
+	// ReasonLost closes sessions a node no longer reports after reconnecting
+	ReasonLost = "lost"
+)
+
+// Event is a join or leave forwarded by a node.
+type Event struct {
+	InstanceID string `json:"instance_id"`
+	PlayerID   int64  `json:"player_id"`
+	Event      string `json:"event"`
+	Reason     string `json:"reason,omitempty"`
+	At         int64  `json:"at,omitempty"` // Unix seconds on the node; defaults to receipt time
+}
+
+// InitSessions starts recording sessions from node messages.
+func InitSessions(db *sqlx.DB) {
+	if db == nil {
+		return
+	}
+	ws.GlobalWSManager.RegisterHandler(MsgPlayerEvent, func(nodeID int, payload json.RawMessage) {
+		var ev Event
+		if err := json.Unmarshal(payload, &ev); err != nil {
+			log.Printf("Sessions: invalid player event from node %d: %v", nodeID, err)
+			return
+		}
+		if err := Record(db, nodeID, ev); err != nil {
+			log.Printf("Sessions: node %d: %v", nodeID, err)
+		}
+	})
+	ws.GlobalWSManager.RegisterHandler(MsgPlayerRoster, func(nodeID int, payload json.RawMessage) {
+		var req struct {
+			Instances map[string][]int64 `json:"instances"`
+		}
+		if err := json.Unmarshal(payload, &req); err != nil {
+			log.Printf("Sessions: invalid roster from node %d: %v", nodeID, err)
+			return
+		}
+		opened, closed, err := Reconcile(db, nodeID, req.Instances, time.Now())
+		if err != nil {
+			log.Printf("Sessions: reconcile node %d: %v", nodeID, err)
+		} else if opened+closed > 0 {
+			log.Printf("Sessions: node %d roster reconciled (%d opened, %d closed)", nodeID, opened, closed)
+		}
+	})
+}
+
+// Record stores a join or leave.
+func Record(db *sqlx.DB, nodeID int, ev Event) error {
+	if ev.PlayerID <= 0 || ev.InstanceID == "" {
+		return fmt.Errorf("player event needs player_id and instance_id")
+	}
+	at := time.Now()
+	if ev.At > 0 {
+		at = time.Unix(ev.At, 0)
+	}
+
+	switch ev.Event {
+	case EventJoin:
+		if _, err := database.StartPlayerSession(db, ev.PlayerID, nodeID, ev.InstanceID, at); err != nil {
+			return err
+		}
+		updateLastJoined(ev.PlayerID, ev.InstanceID)
+		setPresence(ev.PlayerID, nodeID, ev.InstanceID)
+		removeIfBanned(ev.PlayerID, nodeID, ev.InstanceID)
+	case EventLeave:
+		reason := ev.Reason
+		if reason == "" {
+			reason = "left"
+		}
+		if _, err := database.EndPlayerSession(db, ev.PlayerID, nodeID, ev.InstanceID, at, reason); err != nil {
+			return err
+		}
+		clearPresence(ev.PlayerID, ev.InstanceID)
+	default:
+		return fmt.Errorf("unknown player event %q", ev.Event)
+	}
+	return nil
+}
+
+// Reconcile makes the open sessions of a node match the roster it reports: sessions
+// for players no longer connected are closed as lost, and connected players without a
+// session get one (their join happened while the node was cut off from the master).
+func Reconcile(db *sqlx.DB, nodeID int, roster map[string][]int64, at time.Time) (opened, closed int, err error) {
+	open, err := database.ListOpenSessions(db, nodeID, "")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	present := map[string]map[int64]bool{}
+	for instanceID, players := range roster {
+		present[instanceID] = map[int64]bool{}
+		for _, id := range players {
+			present[instanceID][id] = true
+		}
+	}
+
+	seen := map[string]map[int64]bool{}
+	for _, s := range open {
+		if present[s.InstanceID][s.PlayerID] {
+			if seen[s.InstanceID] == nil {
+				seen[s.InstanceID] = map[int64]bool{}
+			}
+			seen[s.InstanceID][s.PlayerID] = true
+			setPresence(s.PlayerID, nodeID, s.InstanceID) // Restores presence after a master restart
+			continue
+		}
+		if err := database.EndSessionByID(db, s.ID, at, ReasonLost); err != nil {
+			return opened, closed, err
+		}
+		clearPresence(s.PlayerID, s.InstanceID)
+		closed++
+	}
+
+	for instanceID, players := range present {
+		for id := range players {
+			if seen[instanceID][id] {
+				continue
+			}
+			if _, err := database.StartPlayerSession(db, id, nodeID, instanceID, at); err != nil {
+				return opened, closed, err
+			}
+			setPresence(id, nodeID, instanceID)
+			opened++
+		}
+	}
+	return opened, closed, nil
+}
+
+// updateLastJoined keeps Player.LastJoinedServer pointing at the latest instance.
+func updateLastJoined(playerID int64, instanceID string) {
+	p, err := database.GetPlayerByID(database.DBConn, playerID)
+	if err != nil || p == nil || p.LastJoinedServer == instanceID {
+		return
+	}
+	p.LastJoinedServer = instanceID
+	if err := database.UpdatePlayer(database.DBConn, p); err != nil {
+		log.Printf("Sessions: failed to update last joined server for player %d: %v", playerID, err)
+	}
+}
+
+// removeIfBanned has the nodes drop a banned player who got onto a server anyway, e.g.
+// through a game server that does not check join tickets.
+func removeIfBanned(playerID int64, nodeID int, instanceID string) {
+	ban, err := bans.Active(playerID, models.BanScopeGame)
+	if err != nil || ban == nil {
+		return
+	}
+	log.Printf("Sessions: banned player %d joined %s on node %d, removing them", playerID, instanceID, nodeID)
+	jointicket.Revoke(playerID)
+}
+
+// setPresence and clearPresence show friends which server a player is on.
+func setPresence(playerID int64, nodeID int, instanceID string) {
+	if ws_player.GlobalPlayerWS != nil {
+		ws_player.GlobalPlayerWS.SetPlayerMatch(playerID, nodeID, instanceID)
+	}
+}
+
+func clearPresence(playerID int64, instanceID string) {
+	if ws_player.GlobalPlayerWS != nil {
+		ws_player.GlobalPlayerWS.ClearPlayerMatch(playerID, instanceID)
+	}
+}
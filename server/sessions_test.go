@@ -0,0 +1,70 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"exile/server/database"
+	"exile/server/sessions"
+)
+
+func TestPlayerSessions(t *testing.T) {
+	if database.DBConn == nil {
+		t.Skip("database not initialized")
+	}
+	db := database.DBConn
+	_, _ = db.Exec("DELETE FROM player_sessions")
+
+	at := time.Now().Add(-time.Hour).Unix()
+	record := func(ev sessions.Event) {
+		t.Helper()
+		if err := sessions.Record(db, 1, ev); err != nil {
+			t.Fatalf("record %+v: %v", ev, err)
+		}
+	}
+
+	record(sessions.Event{InstanceID: "a", PlayerID: 10, Event: sessions.EventJoin, At: at})
+	record(sessions.Event{InstanceID: "a", PlayerID: 11, Event: sessions.EventJoin, At: at})
+	// Joining another instance closes the open session on the first one
+	record(sessions.Event{InstanceID: "b", PlayerID: 10, Event: sessions.EventJoin, At: at + 60})
+	record(sessions.Event{InstanceID: "b", PlayerID: 10, Event: sessions.EventLeave, Reason: "quit", At: at + 120})
+
+	history, err := database.ListPlayerSessions(db, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 sessions for player 10, got %d", len(history))
+	}
+	if history[0].InstanceID != "b" || history[0].EndReason != "quit" || history[0].DurationSeconds != 60 || history[0].Active {
+		t.Errorf("unexpected latest session %+v", history[0])
+	}
+	if history[1].InstanceID != "a" || history[1].EndReason != "moved" {
+		t.Errorf("unexpected first session %+v", history[1])
+	}
+
+	if err := sessions.Record(db, 1, sessions.Event{InstanceID: "a", PlayerID: 10, Event: "teleport"}); err == nil {
+		t.Error("expected error for unknown event")
+	}
+
+	// After a reconnect the node reports 12 on "a"; 11 is gone
+	opened, closed, err := sessions.Reconcile(db, 1, map[string][]int64{"a": {12}}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != 1 || closed != 1 {
+		t.Fatalf("expected 1 opened and 1 closed, got %d/%d", opened, closed)
+	}
+
+	roster, err := database.ListOpenSessions(db, 1, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roster) != 1 || roster[0].PlayerID != 12 {
+		t.Fatalf("expected only player 12 on the roster, got %+v", roster)
+	}
+	lost, _ := database.ListPlayerSessions(db, 11, 1)
+	if len(lost) != 1 || lost[0].EndReason != sessions.ReasonLost {
+		t.Fatalf("expected player 11's session to be lost, got %+v", lost)
+	}
+}
@@ -0,0 +1,113 @@
+package main_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"exile/server/database"
+	"exile/server/handlers"
+
+	"github.com/gorilla/mux"
+)
+
+func sha(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestChunkedUploadFlow(t *testing.T) {
+	if database.DBConn == nil {
+		t.Skip("database not available")
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	handlers.InitializeUploadManager()
+
+	payload := bytes.Repeat([]byte("exile"), 300000) // 1.5MB -> two 1MB chunks
+	chunk := 1 << 20
+
+	initBody, _ := json.Marshal(map[string]interface{}{
+		"filename":   "server.zip",
+		"size":       len(payload),
+		"sha256":     sha(payload),
+		"chunk_size": chunk,
+		"version":    "1.2.3",
+	})
+	w := httptest.NewRecorder()
+	handlers.InitChunkedUploadHandler(w, httptest.NewRequest("POST", "/api/upload/init", bytes.NewReader(initBody)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("init: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var session struct {
+		UploadID    string `json:"upload_id"`
+		TotalChunks int    `json:"total_chunks"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&session)
+	if session.TotalChunks != 2 {
+		t.Fatalf("expected 2 chunks, got %d", session.TotalChunks)
+	}
+
+	putPart := func(index int, data []byte, sum string) int {
+		req := httptest.NewRequest("PUT", "/", bytes.NewReader(data))
+		req.Header.Set("X-Chunk-SHA256", sum)
+		req = mux.SetURLVars(req, map[string]string{"upload_id": session.UploadID, "index": strconv.Itoa(index)})
+		rec := httptest.NewRecorder()
+		handlers.UploadChunkHandler(rec, req)
+		return rec.Code
+	}
+	complete := func() *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("POST", "/", nil), map[string]string{"upload_id": session.UploadID})
+		rec := httptest.NewRecorder()
+		handlers.CompleteChunkedUploadHandler(rec, req)
+		return rec
+	}
+
+	second := payload[chunk:]
+	if code := putPart(1, second, sha([]byte("wrong"))); code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad chunk checksum: expected 422, got %d", code)
+	}
+	if code := putPart(1, second, sha(second)); code != http.StatusOK {
+		t.Fatalf("chunk 1: expected 200, got %d", code)
+	}
+	if rec := complete(); rec.Code != http.StatusConflict {
+		t.Fatalf("incomplete upload: expected 409, got %d", rec.Code)
+	}
+	if code := putPart(0, payload[:chunk], sha(payload[:chunk])); code != http.StatusOK {
+		t.Fatalf("chunk 0: expected 200, got %d", code)
+	}
+
+	rec := complete()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("complete: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var result struct {
+		Filename string `json:"filename"`
+		Version  struct {
+			ValidationStatus string `json:"validation_status"`
+			IsActive         bool   `json:"is_active"`
+		} `json:"version"`
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&result)
+	// The payload is not a zip: it is kept for inspection but must never go live
+	if result.Version.ValidationStatus != "invalid" || result.Version.IsActive {
+		t.Fatalf("expected an invalid, inactive version, got %+v", result.Version)
+	}
+	stored, err := os.ReadFile(filepath.Join("files", result.Filename))
+	if err != nil || !bytes.Equal(stored, payload) {
+		t.Fatalf("stored file does not match upload (err=%v)", err)
+	}
+	if _, err := os.Stat(filepath.Join("files", ".uploads", session.UploadID)); !os.IsNotExist(err) {
+		t.Fatalf("staging directory was not cleaned up")
+	}
+}
@@ -163,16 +163,29 @@ func ValidateIP(ip string) error {
 	return nil
 }
 
-func BlockIPSystem(ip string) error {
-	if runtime.GOOS != "linux" {
-		return fmt.Errorf("ufw is only supported on Linux")
+// ufwSource canonicalizes an IP or CIDR range for use as a ufw "from" address.
+func ufwSource(ip string) (string, error) {
+	if strings.Contains(ip, "/") {
+		_, ipnet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return "", fmt.Errorf("invalid CIDR")
+		}
+		return ipnet.String(), nil
 	}
 	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
-		return fmt.Errorf("invalid IP")
+		return "", fmt.Errorf("invalid IP")
 	}
-	ipStr := parsedIP.String()
-	if err := ValidateIP(ipStr); err != nil {
+	return parsedIP.String(), nil
+}
+
+// BlockIPSystem adds a ufw deny rule for an IP or CIDR range.
+func BlockIPSystem(ip string) error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("ufw is only supported on Linux")
+	}
+	ipStr, err := ufwSource(ip)
+	if err != nil {
 		return err
 	}
 	cmd := exec.Command("ufw", "deny", "from", ipStr, "to", "any")
@@ -189,15 +202,15 @@ func BlockIPSystem(ip string) error {
 	return nil
 }
 
+// UnblockIPSystem removes the ufw deny rule added by BlockIPSystem.
 func UnblockIPSystem(ip string) error {
 	if runtime.GOOS != "linux" {
 		return fmt.Errorf("ufw is only supported on Linux")
 	}
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
-		return fmt.Errorf("invalid IP")
+	ipStr, err := ufwSource(ip)
+	if err != nil {
+		return err
 	}
-	ipStr := parsedIP.String()
 	cmd := exec.Command("ufw", "delete", "deny", "from", ipStr, "to", "any")
 	if output, err := cmd.CombinedOutput(); err != nil {
 		if strings.Contains(string(output), "root") || strings.Contains(string(output), "permission") {
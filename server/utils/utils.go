@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"exile/server/models"
@@ -102,3 +103,28 @@ func GetEnvDuration(key string, fallback time.Duration) time.Duration {
 	}
 	return fallback
 }
+
+// ParseTags splits a node/key tag string into trimmed, lower-cased tags.
+// Both CSV ("eu,ranked") and JSON array (`["eu","ranked"]`) forms are accepted.
+func ParseTags(raw string) []string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	var parts []string
+	if strings.HasPrefix(raw, "[") {
+		if err := json.Unmarshal([]byte(raw), &parts); err != nil {
+			parts = strings.Split(strings.Trim(raw, "[]"), ",")
+		}
+	} else {
+		parts = strings.Split(raw, ",")
+	}
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.ToLower(strings.Trim(strings.TrimSpace(p), `"`))
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
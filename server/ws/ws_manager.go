@@ -103,6 +103,31 @@ type WSManager struct {
 	Unregister      chan *NodeConnection
 	pendingRequests map[string]chan WSResponse
 	pendingMu       sync.Mutex
+
+	hooksMu       sync.RWMutex
+	registerHooks []func(c *NodeConnection)
+	handlers      map[string]NodeMessageHandler // Unsolicited node messages handled by other subsystems
+}
+
+// NodeMessageHandler processes an unsolicited message type sent by a node.
+type NodeMessageHandler func(nodeID int, payload json.RawMessage)
+
+// RegisterHandler routes node messages of msgType to h.
+func (manager *WSManager) RegisterHandler(msgType string, h NodeMessageHandler) {
+	manager.hooksMu.Lock()
+	defer manager.hooksMu.Unlock()
+	if manager.handlers == nil {
+		manager.handlers = make(map[string]NodeMessageHandler)
+	}
+	manager.handlers[msgType] = h
+}
+
+// OnRegister registers a callback run after a node has registered over the WebSocket,
+// e.g. to push it configuration it needs before serving players.
+func (manager *WSManager) OnRegister(fn func(c *NodeConnection)) {
+	manager.hooksMu.Lock()
+	defer manager.hooksMu.Unlock()
+	manager.registerHooks = append(manager.registerHooks, fn)
 }
 
 // IsClientConnected checks if a node with the given ID is currently connected via WebSocket.
@@ -236,6 +261,48 @@ func (manager *WSManager) SendCommandSync(nodeID int, msgType string, payload in
 	}
 }
 
+// ListInstances asks a node for its game instances and decodes the reply.
+func (manager *WSManager) ListInstances(nodeID int, timeout time.Duration) ([]models.GameInstance, error) {
+	resp, err := manager.SendCommandSync(nodeID, "list_instances", nil, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != "success" {
+		if resp.Error != "" {
+			return nil, fmt.Errorf("%s", resp.Error)
+		}
+		return nil, fmt.Errorf("no response from node")
+	}
+
+	var data struct {
+		Instances []models.GameInstance `json:"instances"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse node response: %w", err)
+	}
+	return data.Instances, nil
+}
+
+// SpawnInstance asks a node to create a new game instance and returns it.
+func (manager *WSManager) SpawnInstance(nodeID int, timeout time.Duration) (*models.GameInstance, error) {
+	resp, err := manager.SendCommandSync(nodeID, "spawn", nil, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != "success" {
+		if resp.Error != "" {
+			return nil, fmt.Errorf("%s", resp.Error)
+		}
+		return nil, fmt.Errorf("no response from node")
+	}
+
+	var inst models.GameInstance
+	if err := json.Unmarshal(resp.Data, &inst); err != nil {
+		return nil, fmt.Errorf("failed to parse node response: %w", err)
+	}
+	return &inst, nil
+}
+
 // SendCommand sends a command to a specific Node asynchronously.
 func (manager *WSManager) SendCommand(nodeID int, msgType string, payload interface{}) error {
 	// Check status first
@@ -272,6 +339,49 @@ func (manager *WSManager) SendCommand(nodeID int, msgType string, payload interf
 	return nil
 }
 
+// SendToAll sends a command to every connected node without waiting; nodes whose
+// buffer is full are skipped. Returns how many nodes it was queued for.
+func (manager *WSManager) SendToAll(msgType string, payload interface{}) int {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0
+	}
+	bytes, err := json.Marshal(WSMessage{Type: msgType, Payload: data})
+	if err != nil {
+		return 0
+	}
+
+	manager.Mu.RLock()
+	defer manager.Mu.RUnlock()
+	sent := 0
+	for _, conn := range manager.Connections {
+		select {
+		case conn.WriteChan <- bytes:
+			sent++
+		default:
+		}
+	}
+	return sent
+}
+
+// Send queues a command on this connection without waiting for a reply.
+func (c *NodeConnection) Send(msgType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.Marshal(WSMessage{Type: msgType, Payload: data})
+	if err != nil {
+		return err
+	}
+	select {
+	case c.WriteChan <- bytes:
+		return nil
+	default:
+		return fmt.Errorf("write channel full")
+	}
+}
+
 // HandleWS handles WebSocket requests from Nodes.
 func (manager *WSManager) HandleWS(w http.ResponseWriter, r *http.Request) {
 	// 1. Authenticate (Already checked by UnifiedAuthMiddleware if configured correctly)
@@ -439,6 +549,13 @@ func (c *NodeConnection) handleMessage(msg WSMessage) {
 				default:
 				}
 			}
+
+			c.Manager.hooksMu.RLock()
+			hooks := c.Manager.registerHooks
+			c.Manager.hooksMu.RUnlock()
+			for _, fn := range hooks {
+				fn(c)
+			}
 		} else {
 			log.Printf("❌ Invalid REGISTER payload: %v", err)
 		}
@@ -474,5 +591,12 @@ func (c *NodeConnection) handleMessage(msg WSMessage) {
 		}
 	case "LOGS":
 		// Handle streaming logs?
+	default:
+		c.Manager.hooksMu.RLock()
+		h, ok := c.Manager.handlers[msg.Type]
+		c.Manager.hooksMu.RUnlock()
+		if ok && c.ID != 0 {
+			h(c.ID, msg.Payload)
+		}
 	}
 }
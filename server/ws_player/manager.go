@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"exile/server/database"
+	"exile/server/models"
+	"exile/server/utils"
 
 	"github.com/gorilla/websocket"
 )
@@ -27,15 +29,36 @@ type PlayerConnection struct {
 	WriteChan chan []byte
 }
 
+// MessageHandler processes a client message type registered by another subsystem.
+type MessageHandler func(c *PlayerConnection, payload json.RawMessage)
+
 // PlayerWSManager manages all player websocket connections.
 type PlayerWSManager struct {
 	mu          sync.RWMutex
 	Connections map[int64]*PlayerConnection // PlayerID -> Connection
 	SessionKeys map[string]int64            // SessionKey -> PlayerID (temporary auth)
+
+	hooksMu         sync.RWMutex
+	handlers        map[string]MessageHandler // Extra message types (server browser, matchmaking, ...)
+	connectHooks    []func(playerID int64)
+	disconnectHooks []func(playerID int64)
+	partyHooks      []func(PartyEvent)
+
+	presenceMu sync.Mutex
+	presence   map[int64]*presenceEntry // Players online or in a match
+
+	partyMu      sync.Mutex
+	parties      map[string]*party
+	playerParty  map[int64]*party
+	partyInvites map[int64]map[int64]partyInvite // Invitee -> inviter -> invite
 }
 
 var GlobalPlayerWS *PlayerWSManager
 
+// OfflineGrace delays the offline notification to friends so a quick reconnect
+// (network switch, client restart) does not show up as offline and back online.
+var OfflineGrace = 10 * time.Second
+
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true // Allow all origins for game clients
@@ -46,6 +69,43 @@ func InitPlayerWS() {
 	GlobalPlayerWS = &PlayerWSManager{
 		Connections: make(map[int64]*PlayerConnection),
 		SessionKeys: make(map[string]int64),
+		handlers:    make(map[string]MessageHandler),
+		presence:    make(map[int64]*presenceEntry),
+
+		parties:      make(map[string]*party),
+		playerParty:  make(map[int64]*party),
+		partyInvites: make(map[int64]map[int64]partyInvite),
+	}
+	OfflineGrace = utils.GetEnvDuration("PRESENCE_OFFLINE_GRACE", OfflineGrace)
+	MaxPartySize = utils.GetEnvInt("PARTY_MAX_SIZE", MaxPartySize)
+	PartyInviteTTL = utils.GetEnvDuration("PARTY_INVITE_TTL", PartyInviteTTL)
+	PartyAwayTimeout = utils.GetEnvDuration("PARTY_AWAY_TIMEOUT", PartyAwayTimeout)
+}
+
+// RegisterHandler routes client messages of msgType to h. Built-in types cannot be overridden.
+func (pm *PlayerWSManager) RegisterHandler(msgType string, h MessageHandler) {
+	pm.hooksMu.Lock()
+	defer pm.hooksMu.Unlock()
+	pm.handlers[msgType] = h
+}
+
+// OnConnect registers a callback run after a player's connection is established.
+func (pm *PlayerWSManager) OnConnect(fn func(playerID int64)) {
+	pm.hooksMu.Lock()
+	defer pm.hooksMu.Unlock()
+	pm.connectHooks = append(pm.connectHooks, fn)
+}
+
+// OnDisconnect registers a callback run when a player's (current) connection goes away.
+func (pm *PlayerWSManager) OnDisconnect(fn func(playerID int64)) {
+	pm.hooksMu.Lock()
+	defer pm.hooksMu.Unlock()
+	pm.disconnectHooks = append(pm.disconnectHooks, fn)
+}
+
+func (pm *PlayerWSManager) runHooks(hooks []func(playerID int64), playerID int64) {
+	for _, fn := range hooks {
+		fn(playerID)
 	}
 }
 
@@ -81,6 +141,17 @@ func (pm *PlayerWSManager) HandleWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Account-wide bans also apply to keys handed out before the ban
+	if database.DBConn != nil {
+		if ban, err := database.GetActiveBan(database.DBConn, playerID, models.BanScopeAll); err != nil {
+			http.Error(w, "failed to check ban status", http.StatusInternalServerError)
+			return
+		} else if ban != nil {
+			http.Error(w, "player is banned", http.StatusForbidden)
+			return
+		}
+	}
+
 	// 2. Upgrade Connection
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -110,17 +181,38 @@ func (pm *PlayerWSManager) HandleWS(w http.ResponseWriter, r *http.Request) {
 	// Start pumps
 	go client.writePump()
 	go client.readPump(pm)
+
+	pm.presenceConnected(playerID)
+	pm.partyConnected(playerID)
+
+	pm.hooksMu.RLock()
+	hooks := pm.connectHooks
+	pm.hooksMu.RUnlock()
+	pm.runHooks(hooks, playerID)
 }
 
 func (c *PlayerConnection) readPump(pm *PlayerWSManager) {
 	defer func() {
 		pm.mu.Lock()
+		wasCurrent := false
 		if current, ok := pm.Connections[c.PlayerID]; ok && current == c {
 			delete(pm.Connections, c.PlayerID)
+			wasCurrent = true
 		}
 		pm.mu.Unlock()
 		_ = c.Conn.Close()
 		log.Printf("PlayerWS: Player %d disconnected", c.PlayerID)
+
+		// A replaced connection is not a disconnect: the player is still online on the new one
+		if wasCurrent {
+			pm.presenceDisconnected(c.PlayerID)
+			pm.partyDisconnected(c.PlayerID)
+
+			pm.hooksMu.RLock()
+			hooks := pm.disconnectHooks
+			pm.hooksMu.RUnlock()
+			pm.runHooks(hooks, c.PlayerID)
+		}
 	}()
 
 	for {
@@ -176,16 +268,34 @@ func (pm *PlayerWSManager) handleMessage(c *PlayerConnection, msg WSMessage) {
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 			return
 		}
-		_ = database.RemoveFriendship(database.DBConn, c.PlayerID, payload.FriendID)
+		if err := database.RemoveFriendship(database.DBConn, c.PlayerID, payload.FriendID); err == nil {
+			pm.FriendsChanged(c.PlayerID, payload.FriendID)
+		}
+
+	case MsgPresenceSet:
+		pm.handlePresenceSet(c, msg.Payload)
+
+	case MsgPartyInvite, MsgPartyAccept, MsgPartyDecline, MsgPartyLeave, MsgPartyKick, MsgPartyPromote:
+		pm.handlePartyMessage(c, msg)
+
+	case MsgBlockAdd, MsgBlockRemove, MsgBlockList, MsgPrivacyGet, MsgPrivacySet:
+		pm.handlePrivacyMessage(c, msg)
 
 	default:
+		pm.hooksMu.RLock()
+		h, ok := pm.handlers[msg.Type]
+		pm.hooksMu.RUnlock()
+		if ok {
+			h(c, msg.Payload)
+			return
+		}
 		log.Printf("PlayerWS: Unknown message type %s from %d", msg.Type, c.PlayerID)
 	}
 }
 
 func (pm *PlayerWSManager) handleFriendRequest(c *PlayerConnection, receiverID int64) {
 	if err := database.SendFriendRequest(database.DBConn, c.PlayerID, receiverID); err != nil {
-		pm.sendError(c.PlayerID, fmt.Sprintf("Friend request failed: %v", err))
+		pm.SendError(c.PlayerID, fmt.Sprintf("Friend request failed: %v", err))
 		return
 	}
 
@@ -202,7 +312,7 @@ func (pm *PlayerWSManager) handleFriendRequest(c *PlayerConnection, receiverID i
 
 func (pm *PlayerWSManager) handleFriendAccept(c *PlayerConnection, senderID int64) {
 	if err := database.AcceptFriendRequest(database.DBConn, senderID, c.PlayerID); err != nil {
-		pm.sendError(c.PlayerID, fmt.Sprintf("Failed to accept friend: %v", err))
+		pm.SendError(c.PlayerID, fmt.Sprintf("Failed to accept friend: %v", err))
 		return
 	}
 
@@ -225,9 +335,12 @@ func (pm *PlayerWSManager) handleFriendAccept(c *PlayerConnection, senderID int6
 			"friend_name": me.Name,
 		}),
 	})
+
+	pm.FriendsChanged(c.PlayerID, senderID)
 }
 
-func (pm *PlayerWSManager) sendError(playerID int64, message string) {
+// SendError sends an ERROR message to a player.
+func (pm *PlayerWSManager) SendError(playerID int64, message string) {
 	pm.SendMessage(playerID, WSMessage{
 		Type: "ERROR",
 		Payload: mustMarshal(map[string]string{
@@ -236,6 +349,11 @@ func (pm *PlayerWSManager) sendError(playerID int64, message string) {
 	})
 }
 
+// NewMessage builds an envelope for SendMessage.
+func NewMessage(msgType string, payload interface{}) WSMessage {
+	return WSMessage{Type: msgType, Payload: mustMarshal(payload)}
+}
+
 func mustMarshal(v interface{}) json.RawMessage {
 	data, _ := json.Marshal(v)
 	return data
@@ -289,6 +407,34 @@ func (pm *PlayerWSManager) SendMessage(playerID int64, payload interface{}) {
 	}
 }
 
+// Kick sends a player a last message and closes their connection after grace, giving
+// the message time to go out.
+func (pm *PlayerWSManager) Kick(playerID int64, msg WSMessage, grace time.Duration) {
+	pm.mu.RLock()
+	client, ok := pm.Connections[playerID]
+	pm.mu.RUnlock()
+	if !ok {
+		return
+	}
+	pm.SendMessage(playerID, msg)
+	time.AfterFunc(grace, func() {
+		if client.Conn != nil {
+			_ = client.Conn.Close()
+		}
+	})
+}
+
+// OnlinePlayers returns the IDs of all connected players.
+func (pm *PlayerWSManager) OnlinePlayers() []int64 {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	ids := make([]int64, 0, len(pm.Connections))
+	for id := range pm.Connections {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
 // IsPlayerOnline checks if a player is currently connected
 func (pm *PlayerWSManager) IsPlayerOnline(playerID int64) bool {
 	pm.mu.RLock()
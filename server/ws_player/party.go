@@ -0,0 +1,508 @@
+package ws_player
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"exile/server/database"
+	"exile/server/utils"
+)
+
+// -- Parties --
+//
+// Parties are small groups that queue and join servers together. They live in memory:
+// a party only matters while its members are online, and members who stay disconnected
+// longer than PartyAwayTimeout are removed (a removed leader hands over to the next member).
+
+const (
+	// Client messages
+	MsgPartyInvite  = "PARTY_INVITE"
+	MsgPartyAccept  = "PARTY_ACCEPT"
+	MsgPartyDecline = "PARTY_DECLINE"
+	MsgPartyLeave   = "PARTY_LEAVE"
+	MsgPartyKick    = "PARTY_KICK"
+	MsgPartyPromote = "PARTY_PROMOTE"
+
+	// Server messages
+	MsgPartyInvited   = "PARTY_INVITED"
+	MsgPartyDeclined  = "PARTY_INVITE_DECLINED"
+	MsgPartyUpdate    = "PARTY_UPDATE"
+	MsgPartyLeft      = "PARTY_LEFT"
+	MsgPartyDisbanded = "PARTY_DISBANDED"
+
+	// PartyEvent types
+	PartyCreated   = "created"
+	PartyJoined    = "joined"
+	PartyLeft      = "left"
+	PartyPromoted  = "promoted"
+	PartyDisbanded = "disbanded"
+)
+
+var (
+	// MaxPartySize caps the number of members, leader included.
+	MaxPartySize = 4
+	// PartyInviteTTL is how long an invite can be accepted.
+	PartyInviteTTL = time.Minute
+	// PartyAwayTimeout is how long a disconnected member keeps their place.
+	PartyAwayTimeout = 2 * time.Minute
+)
+
+// Party is the state members see.
+type Party struct {
+	ID        string        `json:"id"`
+	LeaderID  int64         `json:"leader_id"`
+	Members   []PartyMember `json:"members"`
+	MaxSize   int           `json:"max_size"`
+	CreatedAt int64         `json:"created_at"`
+}
+
+// PartyMember is one member of a party.
+type PartyMember struct {
+	PlayerID int64  `json:"player_id"`
+	Name     string `json:"name,omitempty"`
+	Online   bool   `json:"online"`
+	JoinedAt int64  `json:"joined_at"`
+}
+
+// MemberIDs returns the member IDs, leader first.
+func (p Party) MemberIDs() []int64 {
+	ids := []int64{p.LeaderID}
+	for _, m := range p.Members {
+		if m.PlayerID != p.LeaderID {
+			ids = append(ids, m.PlayerID)
+		}
+	}
+	return ids
+}
+
+// PartyEvent describes a membership change, for subsystems that follow parties (chat,
+// matchmaking). PlayerID is the member who joined, left or became leader.
+type PartyEvent struct {
+	Type     string
+	Party    Party
+	PlayerID int64
+	Reason   string // For PartyLeft and PartyDisbanded: left, kicked, timeout
+}
+
+type party struct {
+	id        string
+	leaderID  int64
+	members   []int64 // In join order
+	names     map[int64]string
+	joinedAt  map[int64]int64
+	away      map[int64]*time.Timer
+	createdAt int64
+}
+
+func (p *party) has(playerID int64) bool {
+	for _, id := range p.members {
+		if id == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+type partyInvite struct {
+	partyID   string // Empty if the inviter was not in a party yet
+	expiresAt time.Time
+}
+
+// partyOutbox collects what to send while partyMu is held; flush it after unlocking.
+type partyOutbox struct {
+	msgs   []partyOut
+	events []PartyEvent
+}
+
+type partyOut struct {
+	to  int64
+	msg WSMessage
+}
+
+func (o *partyOutbox) send(to int64, msgType string, payload interface{}) {
+	o.msgs = append(o.msgs, partyOut{to: to, msg: NewMessage(msgType, payload)})
+}
+
+func (pm *PlayerWSManager) flushParty(o *partyOutbox) {
+	for _, m := range o.msgs {
+		pm.SendMessage(m.to, m.msg)
+	}
+	if len(o.events) == 0 {
+		return
+	}
+	pm.hooksMu.RLock()
+	hooks := pm.partyHooks
+	pm.hooksMu.RUnlock()
+	for _, ev := range o.events {
+		for _, fn := range hooks {
+			fn(ev)
+		}
+	}
+}
+
+// OnPartyChange registers a callback run after every party membership change.
+func (pm *PlayerWSManager) OnPartyChange(fn func(PartyEvent)) {
+	pm.hooksMu.Lock()
+	defer pm.hooksMu.Unlock()
+	pm.partyHooks = append(pm.partyHooks, fn)
+}
+
+// snapshot builds the public view of a party. Caller holds partyMu.
+func (pm *PlayerWSManager) snapshot(p *party) Party {
+	out := Party{ID: p.id, LeaderID: p.leaderID, MaxSize: MaxPartySize, CreatedAt: p.createdAt}
+	for _, id := range p.members {
+		_, away := p.away[id]
+		out.Members = append(out.Members, PartyMember{
+			PlayerID: id,
+			Name:     p.names[id],
+			Online:   !away && pm.IsPlayerOnline(id),
+			JoinedAt: p.joinedAt[id],
+		})
+	}
+	return out
+}
+
+// broadcastParty queues a PARTY_UPDATE for every member. Caller holds partyMu.
+func (pm *PlayerWSManager) broadcastParty(p *party, o *partyOutbox) Party {
+	snap := pm.snapshot(p)
+	for _, id := range p.members {
+		o.send(id, MsgPartyUpdate, snap)
+	}
+	return snap
+}
+
+// PartyOf returns the party a player is in.
+func (pm *PlayerWSManager) PartyOf(playerID int64) (Party, bool) {
+	pm.partyMu.Lock()
+	defer pm.partyMu.Unlock()
+	p, ok := pm.playerParty[playerID]
+	if !ok {
+		return Party{}, false
+	}
+	return pm.snapshot(p), true
+}
+
+// PartySize returns the number of players that join together with playerID (1 when solo).
+func (pm *PlayerWSManager) PartySize(playerID int64) int {
+	pm.partyMu.Lock()
+	defer pm.partyMu.Unlock()
+	if p, ok := pm.playerParty[playerID]; ok {
+		return len(p.members)
+	}
+	return 1
+}
+
+// PartyQueueMembers returns who queues together with playerID: the whole party when they
+// lead one, nil when solo. Other members cannot queue on their own.
+func (pm *PlayerWSManager) PartyQueueMembers(playerID int64) ([]int64, error) {
+	pm.partyMu.Lock()
+	defer pm.partyMu.Unlock()
+	p, ok := pm.playerParty[playerID]
+	if !ok {
+		return nil, nil
+	}
+	if p.leaderID != playerID {
+		return nil, fmt.Errorf("only the party leader can queue")
+	}
+	return append([]int64(nil), p.members...), nil
+}
+
+func (pm *PlayerWSManager) playerName(playerID int64) string {
+	if database.DBConn == nil {
+		return ""
+	}
+	p, err := database.GetPlayerByID(database.DBConn, playerID)
+	if err != nil || p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+// InviteToParty invites a friend into the inviter's party (created when they accept).
+func (pm *PlayerWSManager) InviteToParty(inviterID, inviteeID int64) error {
+	if inviterID == inviteeID {
+		return fmt.Errorf("cannot invite yourself")
+	}
+	if !pm.IsPlayerOnline(inviteeID) {
+		return fmt.Errorf("player is not online")
+	}
+	if database.DBConn != nil {
+		if ok, err := database.AreFriends(database.DBConn, inviterID, inviteeID); err != nil || !ok {
+			return fmt.Errorf("you can only invite friends")
+		}
+	}
+
+	pm.partyMu.Lock()
+	inv := partyInvite{expiresAt: time.Now().Add(PartyInviteTTL)}
+	if p, ok := pm.playerParty[inviterID]; ok {
+		if p.leaderID != inviterID {
+			pm.partyMu.Unlock()
+			return fmt.Errorf("only the party leader can invite")
+		}
+		if p.has(inviteeID) {
+			pm.partyMu.Unlock()
+			return fmt.Errorf("player is already in your party")
+		}
+		if len(p.members) >= MaxPartySize {
+			pm.partyMu.Unlock()
+			return fmt.Errorf("party is full (%d players)", MaxPartySize)
+		}
+		inv.partyID = p.id
+	}
+	if pm.partyInvites[inviteeID] == nil {
+		pm.partyInvites[inviteeID] = make(map[int64]partyInvite)
+	}
+	pm.partyInvites[inviteeID][inviterID] = inv
+	pm.partyMu.Unlock()
+
+	pm.SendMessage(inviteeID, NewMessage(MsgPartyInvited, map[string]interface{}{
+		"from":       inviterID,
+		"from_name":  pm.playerName(inviterID),
+		"party_id":   inv.partyID,
+		"expires_at": inv.expiresAt.Unix(),
+	}))
+	return nil
+}
+
+// AcceptPartyInvite joins the inviter's party, leaving any current party first.
+func (pm *PlayerWSManager) AcceptPartyInvite(playerID, inviterID int64) error {
+	playerName, inviterName := pm.playerName(playerID), pm.playerName(inviterID)
+
+	var o partyOutbox
+	defer pm.flushParty(&o)
+	pm.partyMu.Lock()
+	defer pm.partyMu.Unlock()
+
+	inv, ok := pm.partyInvites[playerID][inviterID]
+	delete(pm.partyInvites[playerID], inviterID)
+	if !ok || time.Now().After(inv.expiresAt) {
+		return fmt.Errorf("invite expired")
+	}
+
+	p, inParty := pm.playerParty[inviterID]
+	switch {
+	case inParty && (p.leaderID != inviterID || (inv.partyID != "" && inv.partyID != p.id)):
+		return fmt.Errorf("invite is no longer valid")
+	case !inParty && inv.partyID != "":
+		return fmt.Errorf("party no longer exists")
+	case inParty && len(p.members) >= MaxPartySize:
+		return fmt.Errorf("party is full")
+	case inParty && p.has(playerID):
+		return nil
+	}
+
+	if _, ok := pm.playerParty[playerID]; ok {
+		pm.removeFromPartyLocked(playerID, "left", &o)
+	}
+
+	now := time.Now().Unix()
+	if !inParty {
+		p = &party{
+			id:        utils.GenerateRandomString(12),
+			leaderID:  inviterID,
+			members:   []int64{inviterID},
+			names:     map[int64]string{inviterID: inviterName},
+			joinedAt:  map[int64]int64{inviterID: now},
+			away:      make(map[int64]*time.Timer),
+			createdAt: now,
+		}
+		pm.parties[p.id] = p
+		pm.playerParty[inviterID] = p
+		o.events = append(o.events, PartyEvent{Type: PartyCreated, Party: pm.snapshot(p), PlayerID: inviterID})
+	}
+	p.members = append(p.members, playerID)
+	p.names[playerID] = playerName
+	p.joinedAt[playerID] = now
+	pm.playerParty[playerID] = p
+
+	snap := pm.broadcastParty(p, &o)
+	o.events = append(o.events, PartyEvent{Type: PartyJoined, Party: snap, PlayerID: playerID})
+	return nil
+}
+
+// DeclinePartyInvite drops an invite and tells the inviter.
+func (pm *PlayerWSManager) DeclinePartyInvite(playerID, inviterID int64) {
+	pm.partyMu.Lock()
+	_, ok := pm.partyInvites[playerID][inviterID]
+	delete(pm.partyInvites[playerID], inviterID)
+	pm.partyMu.Unlock()
+	if ok {
+		pm.SendMessage(inviterID, NewMessage(MsgPartyDeclined, map[string]int64{"player_id": playerID}))
+	}
+}
+
+// LeaveParty removes a player from their party.
+func (pm *PlayerWSManager) LeaveParty(playerID int64, reason string) {
+	var o partyOutbox
+	pm.partyMu.Lock()
+	pm.removeFromPartyLocked(playerID, reason, &o)
+	pm.partyMu.Unlock()
+	pm.flushParty(&o)
+}
+
+// KickFromParty lets the leader remove a member.
+func (pm *PlayerWSManager) KickFromParty(leaderID, targetID int64) error {
+	var o partyOutbox
+	defer pm.flushParty(&o)
+	pm.partyMu.Lock()
+	defer pm.partyMu.Unlock()
+
+	p, ok := pm.playerParty[leaderID]
+	if !ok || p.leaderID != leaderID {
+		return fmt.Errorf("only the party leader can kick")
+	}
+	if targetID == leaderID || !p.has(targetID) {
+		return fmt.Errorf("player is not a member of your party")
+	}
+	pm.removeFromPartyLocked(targetID, "kicked", &o)
+	return nil
+}
+
+// PromotePartyLeader hands leadership to another member.
+func (pm *PlayerWSManager) PromotePartyLeader(leaderID, targetID int64) error {
+	var o partyOutbox
+	defer pm.flushParty(&o)
+	pm.partyMu.Lock()
+	defer pm.partyMu.Unlock()
+
+	p, ok := pm.playerParty[leaderID]
+	if !ok || p.leaderID != leaderID {
+		return fmt.Errorf("only the party leader can promote")
+	}
+	if targetID == leaderID || !p.has(targetID) {
+		return fmt.Errorf("player is not a member of your party")
+	}
+	p.leaderID = targetID
+	snap := pm.broadcastParty(p, &o)
+	o.events = append(o.events, PartyEvent{Type: PartyPromoted, Party: snap, PlayerID: targetID})
+	return nil
+}
+
+// removeFromPartyLocked removes a member, handing over leadership or disbanding the party
+// when fewer than two members remain. Caller holds partyMu.
+func (pm *PlayerWSManager) removeFromPartyLocked(playerID int64, reason string, o *partyOutbox) {
+	p, ok := pm.playerParty[playerID]
+	if !ok {
+		return
+	}
+	if t, ok := p.away[playerID]; ok {
+		t.Stop()
+		delete(p.away, playerID)
+	}
+	for i, id := range p.members {
+		if id == playerID {
+			p.members = append(p.members[:i], p.members[i+1:]...)
+			break
+		}
+	}
+	delete(pm.playerParty, playerID)
+	o.send(playerID, MsgPartyLeft, map[string]interface{}{"party_id": p.id, "reason": reason})
+
+	if len(p.members) < 2 {
+		pm.disbandLocked(p, reason, o)
+		return
+	}
+
+	o.events = append(o.events, PartyEvent{Type: PartyLeft, Party: pm.snapshot(p), PlayerID: playerID, Reason: reason})
+	if p.leaderID == playerID {
+		// Prefer a member who is connected right now
+		p.leaderID = p.members[0]
+		for _, id := range p.members {
+			if _, away := p.away[id]; !away && pm.IsPlayerOnline(id) {
+				p.leaderID = id
+				break
+			}
+		}
+		o.events = append(o.events, PartyEvent{Type: PartyPromoted, Party: pm.snapshot(p), PlayerID: p.leaderID})
+	}
+	pm.broadcastParty(p, o)
+}
+
+func (pm *PlayerWSManager) disbandLocked(p *party, reason string, o *partyOutbox) {
+	snap := pm.snapshot(p)
+	for _, id := range p.members {
+		if t, ok := p.away[id]; ok {
+			t.Stop()
+		}
+		delete(pm.playerParty, id)
+		o.send(id, MsgPartyDisbanded, map[string]interface{}{"party_id": p.id, "reason": reason})
+	}
+	delete(pm.parties, p.id)
+	o.events = append(o.events, PartyEvent{Type: PartyDisbanded, Party: snap, Reason: reason})
+}
+
+// partyDisconnected starts the away timer of a member.
+func (pm *PlayerWSManager) partyDisconnected(playerID int64) {
+	var o partyOutbox
+	pm.partyMu.Lock()
+	delete(pm.partyInvites, playerID)
+	if p, ok := pm.playerParty[playerID]; ok {
+		if _, away := p.away[playerID]; !away {
+			p.away[playerID] = time.AfterFunc(PartyAwayTimeout, func() {
+				var o partyOutbox
+				pm.partyMu.Lock()
+				if current, ok := pm.playerParty[playerID]; ok && current == p {
+					if _, still := p.away[playerID]; still {
+						delete(p.away, playerID)
+						log.Printf("PlayerWS: Player %d removed from party %s after being away", playerID, p.id)
+						pm.removeFromPartyLocked(playerID, "timeout", &o)
+					}
+				}
+				pm.partyMu.Unlock()
+				pm.flushParty(&o)
+			})
+		}
+		pm.broadcastParty(p, &o)
+	}
+	pm.partyMu.Unlock()
+	pm.flushParty(&o)
+}
+
+// partyConnected cancels the away timer of a member and resends them their party.
+func (pm *PlayerWSManager) partyConnected(playerID int64) {
+	var o partyOutbox
+	pm.partyMu.Lock()
+	if p, ok := pm.playerParty[playerID]; ok {
+		if t, away := p.away[playerID]; away {
+			t.Stop()
+			delete(p.away, playerID)
+		}
+		pm.broadcastParty(p, &o)
+	}
+	pm.partyMu.Unlock()
+	pm.flushParty(&o)
+}
+
+func (pm *PlayerWSManager) handlePartyMessage(c *PlayerConnection, msg WSMessage) {
+	var payload struct {
+		PlayerID int64 `json:"player_id"`
+		From     int64 `json:"from"`
+	}
+	if len(msg.Payload) > 0 {
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			pm.SendError(c.PlayerID, "invalid party payload")
+			return
+		}
+	}
+
+	var err error
+	switch msg.Type {
+	case MsgPartyInvite:
+		err = pm.InviteToParty(c.PlayerID, payload.PlayerID)
+	case MsgPartyAccept:
+		err = pm.AcceptPartyInvite(c.PlayerID, payload.From)
+	case MsgPartyDecline:
+		pm.DeclinePartyInvite(c.PlayerID, payload.From)
+	case MsgPartyLeave:
+		pm.LeaveParty(c.PlayerID, "left")
+	case MsgPartyKick:
+		err = pm.KickFromParty(c.PlayerID, payload.PlayerID)
+	case MsgPartyPromote:
+		err = pm.PromotePartyLeader(c.PlayerID, payload.PlayerID)
+	}
+	if err != nil {
+		pm.SendError(c.PlayerID, fmt.Sprintf("Party: %v", err))
+	}
+}
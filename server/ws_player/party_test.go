@@ -0,0 +1,152 @@
+package ws_player
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// drain returns the message types queued for a connection.
+func drain(c *PlayerConnection) []string {
+	var types []string
+	for {
+		select {
+		case raw := <-c.WriteChan:
+			var msg WSMessage
+			_ = json.Unmarshal(raw, &msg)
+			types = append(types, msg.Type)
+		default:
+			return types
+		}
+	}
+}
+
+func has(types []string, want string) bool {
+	for _, t := range types {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPartyLifecycle(t *testing.T) {
+	InitPlayerWS()
+	pm := GlobalPlayerWS
+	MaxPartySize = 3
+
+	var events []PartyEvent
+	pm.OnPartyChange(func(ev PartyEvent) { events = append(events, ev) })
+
+	conns := map[int64]*PlayerConnection{}
+	for id := int64(1); id <= 4; id++ {
+		conns[id] = fakeConnect(pm, id)
+	}
+
+	if err := pm.InviteToParty(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !has(drain(conns[2]), MsgPartyInvited) {
+		t.Fatal("expected invite notification")
+	}
+	if err := pm.AcceptPartyInvite(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	p, ok := pm.PartyOf(2)
+	if !ok || p.LeaderID != 1 || len(p.Members) != 2 {
+		t.Fatalf("unexpected party %+v", p)
+	}
+	if len(events) != 2 || events[0].Type != PartyCreated || events[1].Type != PartyJoined || events[1].PlayerID != 2 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if err := pm.AcceptPartyInvite(3, 1); err == nil {
+		t.Fatal("accepting without an invite should fail")
+	}
+
+	// Only the leader queues, for the whole party
+	if members, err := pm.PartyQueueMembers(1); err != nil || len(members) != 2 {
+		t.Fatalf("expected leader to queue the party, got %v %v", members, err)
+	}
+	if _, err := pm.PartyQueueMembers(2); err == nil {
+		t.Fatal("members should not queue on their own")
+	}
+	if err := pm.InviteToParty(2, 3); err == nil {
+		t.Fatal("only the leader invites")
+	}
+
+	_ = pm.InviteToParty(1, 3)
+	_ = pm.InviteToParty(1, 4)
+	if err := pm.AcceptPartyInvite(3, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.AcceptPartyInvite(4, 1); err == nil {
+		t.Fatal("expected party full")
+	}
+	if pm.PartySize(1) != 3 {
+		t.Fatalf("expected 3 members, got %d", pm.PartySize(1))
+	}
+
+	if err := pm.PromotePartyLeader(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.KickFromParty(1, 2); err == nil {
+		t.Fatal("former leader should not be able to kick")
+	}
+	drain(conns[2])
+	if err := pm.KickFromParty(3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !has(drain(conns[2]), MsgPartyLeft) {
+		t.Fatal("kicked member should be told")
+	}
+
+	// Two members left; one leaving disbands the party
+	drain(conns[1])
+	pm.LeaveParty(3, "left")
+	if !has(drain(conns[1]), MsgPartyDisbanded) {
+		t.Fatal("remaining member should be told the party was disbanded")
+	}
+	if _, ok := pm.PartyOf(1); ok {
+		t.Fatal("party should be gone")
+	}
+	if last := events[len(events)-1]; last.Type != PartyDisbanded {
+		t.Fatalf("expected disbanded event, got %+v", last)
+	}
+}
+
+func TestPartyLeaderAway(t *testing.T) {
+	InitPlayerWS()
+	pm := GlobalPlayerWS
+	MaxPartySize = 4
+	PartyAwayTimeout = 30 * time.Millisecond
+
+	for id := int64(1); id <= 3; id++ {
+		fakeConnect(pm, id)
+	}
+	_ = pm.InviteToParty(1, 2)
+	_ = pm.AcceptPartyInvite(2, 1)
+	_ = pm.InviteToParty(1, 3)
+	_ = pm.AcceptPartyInvite(3, 1)
+
+	// A short disconnect keeps the place
+	pm.partyDisconnected(1)
+	pm.partyConnected(1)
+	time.Sleep(3 * PartyAwayTimeout)
+	if p, _ := pm.PartyOf(2); p.LeaderID != 1 || len(p.Members) != 3 {
+		t.Fatalf("leader should still lead after reconnecting, got %+v", p)
+	}
+
+	pm.mu.Lock()
+	delete(pm.Connections, 1)
+	pm.mu.Unlock()
+	pm.partyDisconnected(1)
+	time.Sleep(3 * PartyAwayTimeout)
+
+	p, ok := pm.PartyOf(2)
+	if !ok || p.LeaderID != 2 || len(p.Members) != 2 {
+		t.Fatalf("expected leadership to pass to 2 after the leader timed out, got %+v", p)
+	}
+	if _, ok := pm.PartyOf(1); ok {
+		t.Fatal("timed out leader should have been removed")
+	}
+}
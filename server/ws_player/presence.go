@@ -0,0 +1,360 @@
+package ws_player
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"exile/server/database"
+)
+
+// -- Presence --
+//
+// Presence lives in memory. A player's friend list is loaded once when they come online
+// (or first change state) and reused for every broadcast until they go offline, so
+// connects, disconnects and status changes do not hit the database.
+
+const (
+	PresenceOffline = "offline"
+	PresenceOnline  = "online"
+	PresenceInMenu  = "in_menu"
+	PresenceInMatch = "in_match"
+
+	// Client messages
+	MsgPresenceSet = "PRESENCE_SET"
+	// Server messages
+	MsgPresenceSnapshot = "PRESENCE_SNAPSHOT"
+	MsgPresenceUpdate   = "PRESENCE_UPDATE"
+
+	maxCustomStatusLen = 64
+)
+
+// Presence is what friends see of a player.
+type Presence struct {
+	PlayerID   int64  `json:"player_id"`
+	Name       string `json:"name,omitempty"`
+	State      string `json:"state"`
+	Status     string `json:"status,omitempty"` // Custom status text
+	NodeID     int    `json:"node_id,omitempty"`
+	InstanceID string `json:"instance_id,omitempty"`
+	Since      int64  `json:"since"` // Unix time the state last changed
+}
+
+type presenceEntry struct {
+	connected  bool
+	activity   string // online or in_menu, set by the client
+	status     string
+	nodeID     int
+	instanceID string // Set from game server session reports
+
+	friends       map[int64]string // Friend ID -> name
+	friendsLoaded bool
+	hidden        bool // Privacy setting: friends see the player as offline
+
+	published    Presence
+	offlineTimer *time.Timer
+}
+
+func (e *presenceEntry) state() string {
+	switch {
+	case e.instanceID != "":
+		return PresenceInMatch
+	case e.connected:
+		if e.activity != "" {
+			return e.activity
+		}
+		return PresenceOnline
+	}
+	return PresenceOffline
+}
+
+func (e *presenceEntry) presence(playerID int64) Presence {
+	p := Presence{PlayerID: playerID, State: e.state(), Since: e.published.Since}
+	if e.hidden {
+		p.State = PresenceOffline
+	}
+	if p.State != PresenceOffline {
+		p.Status = e.status
+		p.NodeID = e.nodeID
+		p.InstanceID = e.instanceID
+	}
+	return p
+}
+
+// presenceFor returns the entry of playerID, creating it. Caller holds presenceMu.
+func (pm *PlayerWSManager) presenceFor(playerID int64) *presenceEntry {
+	e, ok := pm.presence[playerID]
+	if !ok {
+		e = &presenceEntry{published: Presence{PlayerID: playerID, State: PresenceOffline}}
+		pm.presence[playerID] = e
+	}
+	return e
+}
+
+// loadFriends fills the friend cache of an entry. Called without presenceMu held.
+func (pm *PlayerWSManager) loadFriends(playerID int64) map[int64]string {
+	friends := make(map[int64]string)
+	if database.DBConn == nil {
+		return friends
+	}
+	list, err := database.GetFriends(database.DBConn, playerID)
+	if err != nil {
+		log.Printf("PlayerWS: Failed to load friends of %d for presence: %v", playerID, err)
+		return friends
+	}
+	for _, f := range list {
+		friends[f.ID] = f.Name
+	}
+	return friends
+}
+
+// ensureFriends loads the friend cache (and presence privacy setting) of playerID if it
+// is not loaded yet.
+func (pm *PlayerWSManager) ensureFriends(playerID int64) {
+	pm.presenceMu.Lock()
+	e := pm.presenceFor(playerID)
+	loaded := e.friendsLoaded
+	pm.presenceMu.Unlock()
+	if loaded {
+		return
+	}
+
+	friends := pm.loadFriends(playerID)
+	hidden := pm.loadHidden(playerID)
+
+	pm.presenceMu.Lock()
+	if e, ok := pm.presence[playerID]; ok && !e.friendsLoaded {
+		e.friends = friends
+		e.friendsLoaded = true
+		e.hidden = hidden
+	}
+	pm.presenceMu.Unlock()
+}
+
+// publish broadcasts the entry's presence to online friends if it changed. Caller holds
+// presenceMu; the returned func sends the messages and must be called after unlocking.
+func (pm *PlayerWSManager) publish(playerID int64, e *presenceEntry) func() {
+	next := e.presence(playerID)
+	prev := e.published
+	next.Since = prev.Since
+	if next.State != prev.State || next.InstanceID != prev.InstanceID {
+		next.Since = time.Now().Unix()
+	}
+	if e.state() == PresenceOffline && e.offlineTimer == nil {
+		delete(pm.presence, playerID) // Checked before the no-change return: a hidden player is published as offline all along
+	}
+	if next == prev {
+		return func() {}
+	}
+	e.published = next
+
+	friends := make([]int64, 0, len(e.friends))
+	for id := range e.friends {
+		friends = append(friends, id)
+	}
+
+	msg := NewMessage(MsgPresenceUpdate, next)
+	return func() {
+		for _, id := range friends {
+			pm.SendMessage(id, msg)
+		}
+	}
+}
+
+// presenceConnected marks a player online and sends them the presence of their friends.
+func (pm *PlayerWSManager) presenceConnected(playerID int64) {
+	pm.ensureFriends(playerID)
+
+	pm.presenceMu.Lock()
+	e := pm.presenceFor(playerID)
+	if e.offlineTimer != nil {
+		// Reconnected within the grace period: friends never saw the player leave
+		e.offlineTimer.Stop()
+		e.offlineTimer = nil
+	}
+	e.connected = true
+	send := pm.publish(playerID, e)
+
+	snapshot := make([]Presence, 0, len(e.friends))
+	for id, name := range e.friends {
+		p := Presence{PlayerID: id, State: PresenceOffline}
+		if fe, ok := pm.presence[id]; ok {
+			p = fe.published
+		}
+		p.Name = name
+		snapshot = append(snapshot, p)
+	}
+	pm.presenceMu.Unlock()
+
+	send()
+	pm.SendMessage(playerID, NewMessage(MsgPresenceSnapshot, map[string]interface{}{"friends": snapshot}))
+}
+
+// presenceDisconnected marks a player offline after OfflineGrace, unless they reconnect
+// or are still in a match.
+func (pm *PlayerWSManager) presenceDisconnected(playerID int64) {
+	pm.presenceMu.Lock()
+	defer pm.presenceMu.Unlock()
+
+	e, ok := pm.presence[playerID]
+	if !ok {
+		return
+	}
+	e.connected = false
+	e.activity = ""
+	if e.state() != PresenceOffline || e.offlineTimer != nil {
+		return
+	}
+	e.offlineTimer = time.AfterFunc(OfflineGrace, func() {
+		pm.presenceMu.Lock()
+		if current, ok := pm.presence[playerID]; !ok || current != e || e.offlineTimer == nil {
+			pm.presenceMu.Unlock()
+			return
+		}
+		e.offlineTimer = nil
+		send := pm.publish(playerID, e)
+		pm.presenceMu.Unlock()
+		send()
+	})
+}
+
+// SetPlayerMatch records that a player joined an instance (reported by the game server).
+func (pm *PlayerWSManager) SetPlayerMatch(playerID int64, nodeID int, instanceID string) {
+	pm.ensureFriends(playerID)
+
+	pm.presenceMu.Lock()
+	e := pm.presenceFor(playerID)
+	e.nodeID = nodeID
+	e.instanceID = instanceID
+	if e.offlineTimer != nil {
+		e.offlineTimer.Stop()
+		e.offlineTimer = nil
+	}
+	send := pm.publish(playerID, e)
+	pm.presenceMu.Unlock()
+	send()
+}
+
+// ClearPlayerMatch records that a player left an instance. Leaves for an instance other
+// than the current one (e.g. a late leave after moving servers) are ignored.
+func (pm *PlayerWSManager) ClearPlayerMatch(playerID int64, instanceID string) {
+	pm.presenceMu.Lock()
+	e, ok := pm.presence[playerID]
+	if !ok || e.instanceID != instanceID {
+		pm.presenceMu.Unlock()
+		return
+	}
+	e.nodeID = 0
+	e.instanceID = ""
+	send := pm.publish(playerID, e)
+	pm.presenceMu.Unlock()
+	send()
+}
+
+// FriendsChanged reloads the cached friend lists of two players after they became or
+// stopped being friends, and tells each about the other if they are now friends.
+func (pm *PlayerWSManager) FriendsChanged(a, b int64) {
+	pm.reloadFriends(a)
+	pm.reloadFriends(b)
+
+	pm.presenceMu.Lock()
+	var msgs []struct {
+		to int64
+		p  Presence
+	}
+	for _, pair := range [][2]int64{{a, b}, {b, a}} {
+		to, from := pair[0], pair[1]
+		te, ok := pm.presence[to]
+		if !ok {
+			continue
+		}
+		name, friends := te.friends[from]
+		if !friends {
+			continue
+		}
+		p := Presence{PlayerID: from, State: PresenceOffline}
+		if fe, ok := pm.presence[from]; ok {
+			p = fe.published
+		}
+		p.Name = name
+		msgs = append(msgs, struct {
+			to int64
+			p  Presence
+		}{to, p})
+	}
+	pm.presenceMu.Unlock()
+
+	for _, m := range msgs {
+		pm.SendMessage(m.to, NewMessage(MsgPresenceUpdate, m.p))
+	}
+}
+
+func (pm *PlayerWSManager) reloadFriends(playerID int64) {
+	pm.presenceMu.Lock()
+	_, ok := pm.presence[playerID]
+	pm.presenceMu.Unlock()
+	if !ok {
+		return // Loaded on demand when the player comes online
+	}
+
+	friends := pm.loadFriends(playerID)
+
+	pm.presenceMu.Lock()
+	if e, ok := pm.presence[playerID]; ok {
+		e.friends = friends
+		e.friendsLoaded = true
+	}
+	pm.presenceMu.Unlock()
+}
+
+// GetPresence returns the current presence of a player.
+func (pm *PlayerWSManager) GetPresence(playerID int64) Presence {
+	pm.presenceMu.Lock()
+	defer pm.presenceMu.Unlock()
+	if e, ok := pm.presence[playerID]; ok {
+		return e.published
+	}
+	return Presence{PlayerID: playerID, State: PresenceOffline}
+}
+
+// handlePresenceSet lets a client switch between online and in_menu and set a custom status.
+func (pm *PlayerWSManager) handlePresenceSet(c *PlayerConnection, raw json.RawMessage) {
+	var payload struct {
+		State  string  `json:"state"`
+		Status *string `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		pm.SendError(c.PlayerID, "invalid presence payload")
+		return
+	}
+	if payload.State != "" && payload.State != PresenceOnline && payload.State != PresenceInMenu {
+		pm.SendError(c.PlayerID, "state must be online or in_menu")
+		return
+	}
+	var status string
+	if payload.Status != nil {
+		status = strings.TrimSpace(*payload.Status)
+		if utf8.RuneCountInString(status) > maxCustomStatusLen {
+			pm.SendError(c.PlayerID, "status is too long")
+			return
+		}
+	}
+
+	pm.presenceMu.Lock()
+	e, ok := pm.presence[c.PlayerID]
+	if !ok || !e.connected {
+		pm.presenceMu.Unlock()
+		return
+	}
+	if payload.State != "" {
+		e.activity = payload.State
+	}
+	if payload.Status != nil {
+		e.status = status
+	}
+	send := pm.publish(c.PlayerID, e)
+	pm.presenceMu.Unlock()
+	send()
+}
@@ -0,0 +1,188 @@
+package ws_player
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// fakeConnect registers a connection without a socket so tests can read what it is sent.
+func fakeConnect(pm *PlayerWSManager, playerID int64) *PlayerConnection {
+	c := &PlayerConnection{PlayerID: playerID, WriteChan: make(chan []byte, 16)}
+	pm.mu.Lock()
+	pm.Connections[playerID] = c
+	pm.mu.Unlock()
+	return c
+}
+
+func withFriends(pm *PlayerWSManager, playerID int64, friends map[int64]string) {
+	pm.presenceMu.Lock()
+	e := pm.presenceFor(playerID)
+	e.friends = friends
+	e.friendsLoaded = true
+	pm.presenceMu.Unlock()
+}
+
+func nextMessage(t *testing.T, c *PlayerConnection) (string, Presence) {
+	t.Helper()
+	select {
+	case raw := <-c.WriteChan:
+		var msg WSMessage
+		_ = json.Unmarshal(raw, &msg)
+		var p Presence
+		_ = json.Unmarshal(msg.Payload, &p)
+		return msg.Type, p
+	case <-time.After(time.Second):
+		t.Fatal("no message")
+		return "", Presence{}
+	}
+}
+
+func expectNone(t *testing.T, c *PlayerConnection) {
+	t.Helper()
+	select {
+	case raw := <-c.WriteChan:
+		t.Fatalf("unexpected message %s", raw)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestPresenceBroadcast(t *testing.T) {
+	InitPlayerWS()
+	pm := GlobalPlayerWS
+	OfflineGrace = 20 * time.Millisecond
+
+	withFriends(pm, 1, map[int64]string{2: "bob"})
+	withFriends(pm, 2, map[int64]string{1: "alice"})
+	bob := fakeConnect(pm, 2)
+	pm.presenceConnected(2)
+	if typ, _ := nextMessage(t, bob); typ != MsgPresenceSnapshot {
+		t.Fatalf("expected snapshot on connect, got %s", typ)
+	}
+
+	alice := fakeConnect(pm, 1)
+	pm.presenceConnected(1)
+	if typ, p := nextMessage(t, bob); typ != MsgPresenceUpdate || p.PlayerID != 1 || p.State != PresenceOnline {
+		t.Fatalf("expected alice online, got %s %+v", typ, p)
+	}
+	raw := <-alice.WriteChan
+	var snap struct {
+		Payload struct {
+			Friends []Presence `json:"friends"`
+		} `json:"payload"`
+	}
+	_ = json.Unmarshal(raw, &snap)
+	if len(snap.Payload.Friends) != 1 || snap.Payload.Friends[0].State != PresenceOnline || snap.Payload.Friends[0].Name != "bob" {
+		t.Fatalf("unexpected snapshot %s", raw)
+	}
+
+	status := "lfg"
+	payload, _ := json.Marshal(map[string]interface{}{"state": PresenceInMenu, "status": status})
+	pm.handlePresenceSet(alice, payload)
+	if _, p := nextMessage(t, bob); p.State != PresenceInMenu || p.Status != "lfg" {
+		t.Fatalf("expected in_menu with status, got %+v", p)
+	}
+	pm.handlePresenceSet(alice, payload)
+	expectNone(t, bob) // Unchanged
+
+	pm.SetPlayerMatch(1, 3, "eu-7777")
+	if _, p := nextMessage(t, bob); p.State != PresenceInMatch || p.InstanceID != "eu-7777" {
+		t.Fatalf("expected in_match, got %+v", p)
+	}
+
+	// Closing the client while in a match keeps the player in the match
+	pm.presenceDisconnected(1)
+	expectNone(t, bob)
+
+	pm.ClearPlayerMatch(1, "other")
+	expectNone(t, bob) // Late leave from a previous server
+	pm.ClearPlayerMatch(1, "eu-7777")
+	if _, p := nextMessage(t, bob); p.State != PresenceOffline || p.Status != "" {
+		t.Fatalf("expected offline, got %+v", p)
+	}
+}
+
+func TestPresenceOfflineGrace(t *testing.T) {
+	InitPlayerWS()
+	pm := GlobalPlayerWS
+	OfflineGrace = 50 * time.Millisecond
+
+	withFriends(pm, 1, map[int64]string{2: "bob"})
+	bob := fakeConnect(pm, 2)
+	pm.presenceConnected(1)
+	if _, p := nextMessage(t, bob); p.State != PresenceOnline {
+		t.Fatalf("expected online, got %+v", p)
+	}
+
+	// A quick reconnect is invisible to friends
+	pm.presenceDisconnected(1)
+	pm.presenceConnected(1)
+	expectNone(t, bob)
+	time.Sleep(2 * OfflineGrace)
+	expectNone(t, bob)
+
+	pm.presenceDisconnected(1)
+	if _, p := nextMessage(t, bob); p.State != PresenceOffline {
+		t.Fatalf("expected offline after grace, got %+v", p)
+	}
+	if got := pm.GetPresence(1); got.State != PresenceOffline {
+		t.Fatalf("expected offline presence, got %+v", got)
+	}
+}
+
+func TestPresenceHidden(t *testing.T) {
+	InitPlayerWS()
+	pm := GlobalPlayerWS
+	OfflineGrace = 20 * time.Millisecond
+
+	withFriends(pm, 1, map[int64]string{2: "bob"})
+	withFriends(pm, 2, map[int64]string{1: "alice"})
+	pm.presenceMu.Lock()
+	pm.presence[1].hidden = true
+	pm.presenceMu.Unlock()
+
+	bob := fakeConnect(pm, 2)
+	pm.presenceConnected(2)
+	nextMessage(t, bob)
+
+	fakeConnect(pm, 1)
+	pm.presenceConnected(1)
+	pm.SetPlayerMatch(1, 3, "eu-7777")
+	expectNone(t, bob) // Appearing offline: nothing to announce
+
+	if p := pm.GetPresence(1); p.State != PresenceOffline || p.InstanceID != "" {
+		t.Fatalf("hidden player should look offline, got %+v", p)
+	}
+
+	// Turning the setting off shows the real state right away
+	pm.presenceMu.Lock()
+	e := pm.presence[1]
+	e.hidden = false
+	send := pm.publish(1, e)
+	pm.presenceMu.Unlock()
+	send()
+	if _, p := nextMessage(t, bob); p.State != PresenceInMatch {
+		t.Fatalf("expected in_match once visible, got %+v", p)
+	}
+
+	pm.presenceMu.Lock()
+	e.hidden = true
+	send = pm.publish(1, e)
+	pm.presenceMu.Unlock()
+	send()
+	if _, p := nextMessage(t, bob); p.State != PresenceOffline {
+		t.Fatalf("expected offline once hidden, got %+v", p)
+	}
+
+	// A hidden player going offline for real is still cleaned up
+	pm.ClearPlayerMatch(1, "eu-7777")
+	pm.presenceDisconnected(1)
+	time.Sleep(3 * OfflineGrace)
+	pm.presenceMu.Lock()
+	_, ok := pm.presence[1]
+	pm.presenceMu.Unlock()
+	if ok {
+		t.Fatal("presence entry should be removed after the grace period")
+	}
+	expectNone(t, bob)
+}
@@ -0,0 +1,169 @@
+package ws_player
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"exile/server/database"
+	"exile/server/models"
+)
+
+// -- Block List & Privacy --
+//
+// Block lists and privacy settings are stored in the database. Friend requests and
+// direct messages are checked there when they are sent; the presence setting is cached
+// in the presence entry with the friend list.
+
+const (
+	// Client messages
+	MsgBlockAdd    = "BLOCK_ADD"
+	MsgBlockRemove = "BLOCK_REMOVE"
+	MsgBlockList   = "BLOCK_LIST" // Also the reply to all three block messages
+	MsgPrivacyGet  = "PRIVACY_GET"
+	MsgPrivacySet  = "PRIVACY_SET"
+	// Server messages
+	MsgPrivacySettings = "PRIVACY_SETTINGS"
+)
+
+// PrivacyUpdate changes some privacy settings; nil fields are left as they are.
+type PrivacyUpdate struct {
+	FriendRequests *string `json:"friend_requests"`
+	Presence       *string `json:"presence"`
+	Messages       *string `json:"messages"`
+}
+
+// BlockPlayer adds blockedID to blockerID's block list. It ends their friendship and
+// drops pending friend requests and party invites between them.
+func (pm *PlayerWSManager) BlockPlayer(blockerID, blockedID int64) error {
+	if database.DBConn == nil {
+		return fmt.Errorf("database not connected")
+	}
+	if err := database.BlockPlayer(database.DBConn, blockerID, blockedID); err != nil {
+		return err
+	}
+	pm.FriendsChanged(blockerID, blockedID)
+
+	pm.partyMu.Lock()
+	delete(pm.partyInvites[blockerID], blockedID)
+	delete(pm.partyInvites[blockedID], blockerID)
+	pm.partyMu.Unlock()
+	return nil
+}
+
+// UnblockPlayer removes blockedID from blockerID's block list.
+func (pm *PlayerWSManager) UnblockPlayer(blockerID, blockedID int64) error {
+	if database.DBConn == nil {
+		return fmt.Errorf("database not connected")
+	}
+	return database.UnblockPlayer(database.DBConn, blockerID, blockedID)
+}
+
+// UpdatePrivacy applies a privacy update and returns the resulting settings. Friends
+// see the player go offline (or come back) right away when the presence setting changes.
+func (pm *PlayerWSManager) UpdatePrivacy(playerID int64, u PrivacyUpdate) (*models.PrivacySettings, error) {
+	if database.DBConn == nil {
+		return nil, fmt.Errorf("database not connected")
+	}
+	s, err := database.GetPrivacySettings(database.DBConn, playerID)
+	if err != nil {
+		return nil, err
+	}
+	if u.FriendRequests != nil {
+		s.FriendRequests = *u.FriendRequests
+	}
+	if u.Presence != nil {
+		s.Presence = *u.Presence
+	}
+	if u.Messages != nil {
+		s.Messages = *u.Messages
+	}
+	if err := database.SetPrivacySettings(database.DBConn, s); err != nil {
+		return nil, err
+	}
+
+	pm.presenceMu.Lock()
+	send := func() {}
+	if e, ok := pm.presence[playerID]; ok {
+		e.hidden = s.Presence == models.PrivacyNobody
+		send = pm.publish(playerID, e)
+	}
+	pm.presenceMu.Unlock()
+	send()
+	return s, nil
+}
+
+// loadHidden reports whether a player chose to appear offline. Called without presenceMu held.
+func (pm *PlayerWSManager) loadHidden(playerID int64) bool {
+	if database.DBConn == nil {
+		return false
+	}
+	s, err := database.GetPrivacySettings(database.DBConn, playerID)
+	if err != nil {
+		log.Printf("PlayerWS: Failed to load privacy settings of %d: %v", playerID, err)
+		return false
+	}
+	return s.Presence == models.PrivacyNobody
+}
+
+func (pm *PlayerWSManager) sendBlockList(playerID int64) {
+	blocked, err := database.GetBlockedPlayers(database.DBConn, playerID)
+	if err != nil {
+		pm.SendError(playerID, "failed to load block list")
+		return
+	}
+	pm.SendMessage(playerID, NewMessage(MsgBlockList, map[string]interface{}{"blocked": blocked}))
+}
+
+func (pm *PlayerWSManager) handlePrivacyMessage(c *PlayerConnection, msg WSMessage) {
+	if database.DBConn == nil {
+		pm.SendError(c.PlayerID, "database not connected")
+		return
+	}
+
+	switch msg.Type {
+	case MsgBlockAdd, MsgBlockRemove:
+		var payload struct {
+			PlayerID int64 `json:"player_id"`
+		}
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.PlayerID <= 0 {
+			pm.SendError(c.PlayerID, "player_id is required")
+			return
+		}
+		var err error
+		if msg.Type == MsgBlockAdd {
+			err = pm.BlockPlayer(c.PlayerID, payload.PlayerID)
+		} else {
+			err = pm.UnblockPlayer(c.PlayerID, payload.PlayerID)
+		}
+		if err != nil {
+			pm.SendError(c.PlayerID, fmt.Sprintf("Block failed: %v", err))
+			return
+		}
+		pm.sendBlockList(c.PlayerID)
+
+	case MsgBlockList:
+		pm.sendBlockList(c.PlayerID)
+
+	case MsgPrivacyGet:
+		s, err := database.GetPrivacySettings(database.DBConn, c.PlayerID)
+		if err != nil {
+			pm.SendError(c.PlayerID, "failed to load privacy settings")
+			return
+		}
+		pm.SendMessage(c.PlayerID, NewMessage(MsgPrivacySettings, s))
+
+	case MsgPrivacySet:
+		var u PrivacyUpdate
+		if err := json.Unmarshal(msg.Payload, &u); err != nil {
+			pm.SendError(c.PlayerID, "invalid privacy payload")
+			return
+		}
+		s, err := pm.UpdatePrivacy(c.PlayerID, u)
+		if err != nil {
+			pm.SendError(c.PlayerID, fmt.Sprintf("Privacy: %v", err))
+			return
+		}
+		pm.SendMessage(c.PlayerID, NewMessage(MsgPrivacySettings, s))
+	}
+}
@@ -97,7 +97,9 @@ export interface RedEyeRule {
 	port: string;
 	path_pattern: string;
 	protocol: string;
-	action: 'ALLOW' | 'DENY' | 'RATE_LIMIT';
+	method: string;
+	action: 'ALLOW' | 'DENY' | 'RATE_LIMIT' | 'LOG_ONLY';
+	priority: number;
 	rate_limit: number;
 	burst: number;
 	enabled: boolean;